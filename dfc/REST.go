@@ -16,25 +16,43 @@ type ActionMsg struct {
 
 // ActionMsg.Action enum
 const (
-	ActShutdown  = "shutdown"
-	ActSyncSmap  = "syncsmap"  // synchronize cluster map aka Smap across all targets
-	ActRebalance = "rebalance" // rebalance local caches upon target(s) joining and/or leaving the cluster
-	ActLRU       = "lru"
-	ActSyncLB    = "synclb"
-	ActCreateLB  = "createlb"
-	ActDestroyLB = "destroylb"
-	ActSetConfig = "setconfig"
-	ActRename    = "rename"
-	ActEvict     = "evict"
-	ActDelete    = "delete"
-	ActPrefetch  = "prefetch"
+	ActShutdown    = "shutdown"
+	ActSyncSmap    = "syncsmap"  // synchronize cluster map aka Smap across all targets
+	ActRebalance   = "rebalance" // rebalance local caches upon target(s) joining and/or leaving the cluster
+	ActLRU         = "lru"
+	ActSyncLB      = "synclb"
+	ActCreateLB    = "createlb"
+	ActDestroyLB   = "destroylb"
+	ActSetConfig   = "setconfig"
+	ActRename      = "rename"
+	ActEvict       = "evict"
+	ActDelete      = "delete"
+	ActPrefetch    = "prefetch"
+	ActSetCloud    = "setcloud"    // assign cloud provider to a Cloud bucket
+	ActSetCopies   = "setcopies"   // set the number of object replicas for a bucket
+	ActSetEC       = "setec"       // set erasure coding data and parity slices for a local bucket (ECMsg)
+	ActSetProps    = "setprops"    // set checksum, versioning, LRU, and ack policies for a bucket (ActionMsg.Value = BucketProps)
+	ActECRepair    = "ecrepair"    // restore erasure coded slices (target only)
+	ActRebAbort    = "rebabort"    // abort the rebalance in progress
+	ActRebThrottle = "rebthrottle" // limit the rebalance bandwidth per target, MB/s (ActionMsg.Value; 0 - unlimited)
+	ActXactAbort   = "xactabort"   // abort the xaction by its ID (ActionMsg.Value) on all targets or the one named (ActionMsg.Name)
+	ActPin         = "pin"         // make the list or range of objects non-evictable (ActionMsg.Name: optional expiration, e.g. "24h")
+	ActUnpin       = "unpin"
+	ActCopy        = "copy" // copy the list or range of objects to another bucket (ActionMsg.Name)
+	ActMove        = "move" // ditto, and then delete the source objects
+	// multipart upload: initiate, complete, and abort (upload part is a PUT with ParamUploadID and ParamPartNum)
+	ActMPInit     = "mpinit"
+	ActMPComplete = "mpcomplete"
+	ActMPAbort    = "mpabort"
 )
 
 // Cloud Provider enum
 const (
-	amazoncloud = "aws"
-	googlecloud = "gcp"
-	dfclocal    = "dfc"
+	amazoncloud   = "aws"
+	googlecloud   = "gcp"
+	posixcloud    = "posix"    // local or mounted directory tree that acts as the Cloud
+	s3compatcloud = "s3compat" // S3-compatible endpoint (e.g. on-prem object storage)
+	dfclocal      = "dfc"
 )
 
 // Header Key enum
@@ -42,25 +60,110 @@ const (
 	HeaderServer          = "Server"                // Server: from Cloud Provider enum
 	HeaderDfcChecksumType = "HeaderDfcChecksumType" // Checksum Type (xxhash, md5, none)
 	HeaderDfcChecksumVal  = "HeaderDfcChecksumVal"  // Checksum Value
+	HeaderRange           = "Range"                 // Range: bytes=<first>-[<last>][, ...] (RFC 7233)
+	HeaderIfRange         = "If-Range"              // If-Range: ETag or Last-Modified
+	HeaderETag            = "ETag"                  // ETag: quoted object checksum
+	HeaderDfcObjVersion   = "HeaderDfcObjVersion"   // Object version (Cloud objects and versioned local buckets)
+	HeaderDfcECMeta       = "HeaderDfcECMeta"       // Erasure coded slice metadata (JSON)
+	HeaderDfcPinned       = "HeaderDfcPinned"       // Pin expiration, Unix nanoseconds (0 - never expires)
+	HeaderDfcMetaPrefix   = "HeaderDfcMeta-"        // User-defined object metadata: HeaderDfcMeta-<key>: <value>
+	HeaderDfcBucketProps  = "HeaderDfcBucketProps"  // Bucket's effective properties (JSON BucketProps)
+	HeaderTraceParent     = "traceparent"           // W3C trace context: 00-<trace ID>-<parent span ID>-<flags>
+	HeaderDfcRequestID    = "HeaderDfcRequestID"    // Request ID: the trace ID of the request (tracing enabled)
+	HeaderAuthorization   = "Authorization"         // Authorization: Bearer <token> (authentication enabled)
 )
 
 // URL Query Parameter enum
 const (
-	ParamLocal  = "local"      //local=bool - true if bucket is expected to be local, false otherwise.
-	ParamToID   = "to_id"      // to_id=string - ID to copy to
-	ParamFromID = "from_id"    // from_id=string - ID to copy from
-	ParamCached = "cachedonly" //cachedonly=bool - true if target should return cached objects info instead of reqesting object list from cloud
+	ParamLocal       = "local"       //local=bool - true if bucket is expected to be local, false otherwise.
+	ParamToID        = "to_id"       // to_id=string - ID to copy to
+	ParamFromID      = "from_id"     // from_id=string - ID to copy from
+	ParamCached      = "cachedonly"  //cachedonly=bool - true if target should return cached objects info instead of reqesting object list from cloud
+	ParamRangeOnly   = "rangeonly"   // rangeonly=bool - cold GET with a single Range fetches (and returns) only the range without caching the object
+	ParamUploadID    = "uploadid"    // uploadid=string - multipart upload ID
+	ParamPartNum     = "partnum"     // partnum=int - multipart upload part number, starting from 1
+	ParamReplica     = "replica"     // replica=bool - target to target: access the local replica only (no cold GET, no Cloud DELETE)
+	ParamECSlice     = "ecslice"     // ecslice=bool - target to target: access the object's erasure coded slice
+	ParamWhat        = "what"        // what=string - same as GetMsg.GetWhat, e.g. GET /v1/cluster?what=rebalance
+	ParamJobID       = "jobid"       // jobid=string - proxy to target: list/range job ID
+	ParamCopy        = "copy"        // copy=bool - target to target: the object is a copy from another bucket
+	ParamVersion     = "version"     // version=string - local bucket object version ID (GET and DELETE)
+	ParamTraceParent = "traceparent" // traceparent=string - proxy to target: trace context of the redirected request
+	ParamRedirect    = "redirect"    // redirect=<expiration>.<signature> - proxy to target: the redirected request is authorized
 )
 
-// TODO: sort and some props are TBD
+// MPUploadMsg is returned by the multipart upload initiation ({"action": "mpinit"});
+// the upload ID is then passed with each part (ParamUploadID), and with mpcomplete and mpabort (ActionMsg.Name)
+type MPUploadMsg struct {
+	UploadID string `json:"uploadid"`
+}
+
+// TokenMsg requests a token from the proxy's built-in auth service: POST /v1/tokens
+type TokenMsg struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+}
+
+// TokenInfo is returned by the built-in auth service; the token is then passed
+// with each request: "Authorization: Bearer <token>"
+type TokenInfo struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires"` // Unix seconds
+}
+
+// AuthToken contains the claims of a signed token (JWT, HS256): the user and the user's permissions.
+// Tokens are issued by the built-in auth service or by any other issuer that has the cluster's secret
+type AuthToken struct {
+	User    string            `json:"sub"`
+	Expires int64             `json:"exp"`               // Unix seconds
+	Admin   bool              `json:"admin,omitempty"`   // cluster admin: all buckets and cluster actions
+	Buckets map[string]string `json:"buckets,omitempty"` // bucket ("*" - any bucket) => AuthRead | AuthWrite | AuthAdmin
+	Cluster bool              `json:"cluster,omitempty"` // intra-cluster: issued by the cluster's daemons to themselves
+}
+
+// AuthToken.Buckets permissions, each implies the preceding ones
+const (
+	AuthRead  = "read"  // GET and HEAD objects, list the bucket
+	AuthWrite = "write" // PUT, DELETE, rename, and list/range operations (the destination of copy and move included)
+	AuthAdmin = "admin" // create and destroy (local bucket), set bucket properties, replication, erasure coding, and provider
+)
+
+// JobMsg is returned by the list/range operations (prefetch, delete, evict, pin, unpin);
+// the job ID is then used to query the job's status and to cancel it: GET and DELETE /v1/jobs/<jobid>
+type JobMsg struct {
+	JobID string `json:"jobid"`
+}
+
+// BucketProps is the value of the {"action": "setprops"} message: the bucket's checksum, versioning,
+// LRU, and ack policies that override the global config; empty strings revert to the global config.
+// The same applies to local and Cloud buckets, except Versioning (local buckets only)
+type BucketProps struct {
+	Checksum        string `json:"checksum,omitempty"`          // ChecksumXXHash | ChecksumNone
+	ValidateColdGet string `json:"validate_cold_get,omitempty"` // "true" | "false"
+	ValidateWarmGet string `json:"validate_warm_get,omitempty"` // ditto
+	LRUEnabled      string `json:"lru_enabled,omitempty"`       // ditto
+	DontEvictTime   string `json:"dont_evict_time,omitempty"`   // e.g. "2h"
+	AckPut          string `json:"ack_put,omitempty"`           // AckWhenInMem | AckWhenOnDisk
+	Versioning      string `json:"versioning,omitempty"`        // "true" | "false": keep older versions of local bucket objects
+}
+
+// ECMsg is the value of the {"action": "setec"} message: local bucket objects get erasure coded
+// into Data + Parity slices stored on as many targets; zeros disable erasure coding
+type ECMsg struct {
+	Data   int `json:"data"`
+	Parity int `json:"parity"`
+}
+
+// TODO: some props are TBD
 // GetMsg represents properties and options for get requests
 type GetMsg struct {
 	GetWhat       string `json:"what"`        // "config" | "stats" ...
-	GetSort       string `json:"sort"`        // "ascending, atime" | "descending, name"
+	GetSort       string `json:"sort"`        // "ascending, atime" | "descending, name" | "size"
 	GetProps      string `json:"props"`       // e.g. "checksum, size" | "atime, size" | "ctime, iscached" | "bucket, size"
 	GetTimeFormat string `json:"time_format"` // "RFC822" default - see the enum below
 	GetPrefix     string `json:"prefix"`      // object name filter: return only objects which name starts with prefix
-	GetPageMarker string `json:"pagemarker"`  // AWS/GCP: marker
+	GetPageMarker string `json:"pagemarker"`  // return objects that follow the marker (BucketList.PageMarker of the previous page)
+	GetPageSize   int    `json:"pagesize"`    // max number of objects per page (0: all for local buckets, provider's default for Cloud)
 }
 
 // RangeListMsgBase contains fields common to Range and List operations
@@ -91,16 +194,23 @@ type RangeMsg struct {
 
 // GetMsg.GetWhat enum
 const (
-	GetWhatFile   = "file" // { "what": "file" } is implied by default and can be omitted
-	GetWhatConfig = "config"
-	GetWhatSmap   = "smap"
-	GetWhatStats  = "stats"
+	GetWhatFile      = "file" // { "what": "file" } is implied by default and can be omitted
+	GetWhatConfig    = "config"
+	GetWhatSmap      = "smap"
+	GetWhatStats     = "stats"
+	GetWhatMeta      = "meta"      // versioned cluster metadata: Smap and lbmap
+	GetWhatMetasync  = "metasync"  // per-daemon acknowledged Smap and lbmap versions (cluster only)
+	GetWhatRebalance = "rebalance" // rebalance progress
+	GetWhatXactions  = "xactions"  // running and recently finished xactions
 )
 
 // GetMsg.GetSort enum
 const (
-	GetSortAsc = "ascending"
-	GetSortDes = "descending"
+	GetSortAsc   = "ascending"
+	GetSortDes   = "descending"
+	GetSortName  = "name"
+	GetSortSize  = "size"
+	GetSortAtime = "atime"
 )
 
 // GetMsg.GetTimeFormat enum
@@ -123,6 +233,10 @@ const (
 	GetPropsIsCached = "iscached"
 	GetPropsBucket   = "bucket"
 	GetPropsVersion  = "version"
+	GetPropsPinned   = "pinned"
+	GetPropsUserMeta = "usermeta"
+	// local buckets with versioning: all versions and delete markers, each with its version ID
+	GetPropsAllVersions = "allversions"
 )
 
 //===================
@@ -142,6 +256,12 @@ type BucketEntry struct {
 	Bucket   string `json:"bucket"`   // parent bucket name
 	Version  string `json:"version"`  // version/generation ID. In GCP it is int64, in AWS it is a string
 	IsCached bool   `json:"iscached"` // if the file is cached on one of targets
+	Pinned   bool   `json:"pinned"`   // if the file is pinned (non-evictable)
+	// user-defined metadata (GetPropsUserMeta)
+	UserMeta map[string]string `json:"usermeta,omitempty"`
+	// GetPropsAllVersions only
+	IsLatest     bool `json:"islatest,omitempty"`     // the current version (or the delete marker that replaced it)
+	DeleteMarker bool `json:"deletemarker,omitempty"` // deleted as of this version
 }
 
 // BucketList represents the response to a ListBucket call
@@ -163,4 +283,8 @@ const (
 	Rsynclb    = ActSyncLB
 	Rpush      = "push"
 	Rkeepalive = "keepalive"
+	Rproxy     = "proxy"
+	Rjobs      = "jobs"
+	Rmetrics   = "metrics" // GET /metrics (unversioned): Prometheus text format
+	Rtokens    = "tokens"  // POST /v1/tokens: the built-in auth service (TokenMsg => TokenInfo)
 )
@@ -0,0 +1,369 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//======
+//
+// authentication and access control: every request carries a signed token
+// ("Authorization: Bearer <JWT>", HS256 with the cluster's secret) that names the user
+// and the user's permissions - cluster admin and/or per-bucket read, write, or admin.
+// Tokens are issued by the proxy's built-in auth service (POST /v1/tokens) or by any
+// other issuer that has the secret.
+// The proxy signs the redirects (method, path, query, and expiration) - targets only serve
+// the data path to redirected requests and to the cluster's own daemons, the latter
+// authenticated with short-lived tokens that the daemons issue to themselves
+//
+//======
+
+const (
+	authMinSecret   = 16             // min secret length
+	authTokenTTL    = 24 * time.Hour // default lifetime of the built-in service's tokens
+	authClusterTTL  = time.Hour      // intra-cluster tokens, renewed at half-life
+	authRedirectTTL = time.Minute    // signed redirects
+	authScheme      = "Bearer "
+)
+
+// permission levels: each implies the preceding ones
+var authlevels = map[string]int{AuthRead: 1, AuthWrite: 2, AuthAdmin: 3}
+
+var jwtheader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+type authn struct {
+	secret []byte
+	sub    string // intra-cluster token's subject: <role>:<daemon ID>
+	mu     sync.Mutex
+	token  string // cached intra-cluster token
+	renew  time.Time
+}
+
+// authtransport adds the intra-cluster token to the requests that do not carry one
+type authtransport struct {
+	base http.RoundTripper
+	auth *authn
+}
+
+//
+// tokens
+//
+
+// SignToken returns the token signed with the given secret (JWT, HS256)
+func SignToken(secret string, token *AuthToken) (string, error) {
+	claims, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	signed := jwtheader + "." + base64.RawURLEncoding.EncodeToString(claims)
+	return signed + "." + hmacsign([]byte(secret), signed), nil
+}
+
+func hmacsign(secret []byte, s string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(s))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func newauthn(sub string) *authn {
+	return &authn{secret: []byte(ctx.config.Auth.Secret), sub: sub}
+}
+
+func (a *authn) parsetoken(s string) (token *AuthToken, errstr string) {
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return nil, "malformed token"
+	}
+	hdrbytes, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, "malformed token header"
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+	}
+	if err = json.Unmarshal(hdrbytes, &hdr); err != nil || hdr.Alg != "HS256" {
+		return nil, fmt.Sprintf("unsupported token algorithm %q", hdr.Alg)
+	}
+	if !hmac.Equal([]byte(hmacsign(a.secret, parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return nil, "invalid token signature"
+	}
+	claims, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, "malformed token claims"
+	}
+	token = &AuthToken{}
+	if err = json.Unmarshal(claims, token); err != nil {
+		return nil, fmt.Sprintf("malformed token claims, err: %v", err)
+	}
+	if time.Now().Unix() >= token.Expires {
+		return nil, fmt.Sprintf("token of user %q expired", token.User)
+	}
+	return
+}
+
+// clustertoken returns the daemon's own token - the intra-cluster requests' credentials
+func (a *authn) clustertoken() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	now := time.Now()
+	if a.token == "" || now.After(a.renew) {
+		token := &AuthToken{User: a.sub, Expires: now.Add(authClusterTTL).Unix(), Cluster: true}
+		signed, err := SignToken(string(a.secret), token)
+		assert(err == nil, err)
+		a.token, a.renew = signed, now.Add(authClusterTTL/2)
+	}
+	return a.token
+}
+
+func (t *authtransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Header.Get(HeaderAuthorization) != "" {
+		return t.base.RoundTrip(r)
+	}
+	// a RoundTripper must not modify the request
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.Header = make(http.Header, len(r.Header)+1)
+	for k, v := range r.Header {
+		r2.Header[k] = v
+	}
+	r2.Header.Set(HeaderAuthorization, authScheme+t.auth.clustertoken())
+	return t.base.RoundTrip(r2)
+}
+
+// authpermits returns true if the token grants (at least) the permission on the bucket
+func authpermits(token *AuthToken, bucket, perm string) bool {
+	if token.Admin || token.Cluster {
+		return true
+	}
+	granted := authlevels[token.Buckets[bucket]]
+	if any := authlevels[token.Buckets["*"]]; any > granted {
+		granted = any
+	}
+	return granted >= authlevels[perm]
+}
+
+//
+// redirects
+//
+
+// signredirect returns the ParamRedirect value:
+// <expiration>.<signature of method, path, canonical query, and expiration>
+func (a *authn) signredirect(method, path string, query url.Values) string {
+	exp := strconv.FormatInt(time.Now().Add(authRedirectTTL).Unix(), 10)
+	return exp + "." + hmacsign(a.secret, method+"\n"+path+"\n"+redirectquery(query)+"\n"+exp)
+}
+
+func (a *authn) checkredirect(r *http.Request) bool {
+	query := r.URL.Query()
+	sig := query.Get(ParamRedirect)
+	i := strings.IndexByte(sig, '.')
+	if i < 0 {
+		return false
+	}
+	exp, err := strconv.ParseInt(sig[:i], 10, 64)
+	if err != nil || time.Now().Unix() > exp {
+		return false
+	}
+	expected := hmacsign(a.secret, r.Method+"\n"+r.URL.Path+"\n"+redirectquery(query)+"\n"+sig[:i])
+	return hmac.Equal([]byte(expected), []byte(sig[i+1:]))
+}
+
+// redirectquery returns the query sorted by key, without the signature itself
+func redirectquery(query url.Values) string {
+	signed := make(url.Values, len(query))
+	for k, v := range query {
+		if k != ParamRedirect {
+			signed[k] = v
+		}
+	}
+	return signed.Encode()
+}
+
+//
+// http
+//
+
+// authhandler validates the request's token, if any, and then lets the daemon authorize the request
+func (h *httprunner) authhandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var token *AuthToken
+		if s := r.Header.Get(HeaderAuthorization); s != "" {
+			var errstr string
+			if !strings.HasPrefix(s, authScheme) {
+				errstr = "unsupported authorization scheme"
+			} else {
+				token, errstr = h.auth.parsetoken(s[len(authScheme):])
+			}
+			if errstr != "" {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				h.invalmsghdlr(w, r, errstr, http.StatusUnauthorized)
+				return
+			}
+		}
+		if status, errstr := h.authorize(r, token); errstr != "" {
+			if status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+			}
+			h.invalmsghdlr(w, r, errstr, status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// peekaction returns the request's ActionMsg, and leaves the body in place for the handler
+func peekaction(r *http.Request) (msg ActionMsg) {
+	if r.Body == nil {
+		return
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(b))
+	if err == nil {
+		_ = json.Unmarshal(b, &msg) // the handler reports invalid messages
+	}
+	return
+}
+
+// control plane (daemon, cluster, jobs, metrics): GET requires a valid token, the rest - cluster admin
+func authcontrol(r *http.Request, token *AuthToken) (status int, errstr string) {
+	if token == nil {
+		return http.StatusUnauthorized, "missing token"
+	}
+	if r.Method != http.MethodGet && !token.Admin && !token.Cluster {
+		return http.StatusForbidden, fmt.Sprintf("user %q is not a cluster admin", token.User)
+	}
+	return
+}
+
+func (p *proxyrunner) authorize(r *http.Request, token *AuthToken) (status int, errstr string) {
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if len(apitems) == 2 && apitems[0] == Rversion && apitems[1] == Rtokens {
+		return // the auth service itself
+	}
+	if p.auth.checkredirect(r) {
+		return // e.g., standby => primary
+	}
+	if len(apitems) < 3 || apitems[0] != Rversion || apitems[1] != Rfiles {
+		return authcontrol(r, token)
+	}
+	if token == nil {
+		return http.StatusUnauthorized, "missing token"
+	}
+	bucket, perm := apitems[2], AuthWrite
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		perm = AuthRead
+	case http.MethodDelete:
+		if len(apitems) == 3 && peekaction(r).Action == ActDestroyLB {
+			perm = AuthAdmin
+		}
+	case http.MethodPost:
+		msg := peekaction(r)
+		switch msg.Action {
+		case ActCreateLB, ActSetCloud, ActSetCopies, ActSetEC, ActSetProps:
+			perm = AuthAdmin
+		case ActSyncLB:
+			if !token.Admin && !token.Cluster {
+				return http.StatusForbidden, fmt.Sprintf("user %q is not a cluster admin", token.User)
+			}
+		case ActCopy, ActMove:
+			if !authpermits(token, msg.Name, AuthWrite) {
+				return http.StatusForbidden,
+					fmt.Sprintf("user %q does not have %s permission on bucket %s", token.User, AuthWrite, msg.Name)
+			}
+		}
+	}
+	if !authpermits(token, bucket, perm) {
+		return http.StatusForbidden, fmt.Sprintf("user %q does not have %s permission on bucket %s", token.User, perm, bucket)
+	}
+	return
+}
+
+// targets serve the data path only to the redirected requests and to the cluster's daemons;
+// push (HTTP/2) is a target-only API that requires read permission
+func (t *targetrunner) authorize(r *http.Request, token *AuthToken) (status int, errstr string) {
+	if token != nil && token.Cluster {
+		return
+	}
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if len(apitems) < 2 || apitems[0] != Rversion || (apitems[1] != Rfiles && apitems[1] != Rpush) {
+		return authcontrol(r, token)
+	}
+	if t.auth.checkredirect(r) {
+		return
+	}
+	if apitems[1] == Rpush && len(apitems) > 2 {
+		if token == nil {
+			return http.StatusUnauthorized, "missing token"
+		}
+		if !authpermits(token, apitems[2], AuthRead) {
+			return http.StatusForbidden, fmt.Sprintf("user %q does not have %s permission on bucket %s", token.User, AuthRead, apitems[2])
+		}
+		return
+	}
+	return http.StatusForbidden, "direct access not permitted: the request must be redirected by a proxy"
+}
+
+// POST /v1/tokens: the built-in auth service
+func (p *proxyrunner) tokenhdlr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		invalhdlr(w, r)
+		return
+	}
+	var msg TokenMsg
+	if p.readJSON(w, r, &msg) != nil {
+		return
+	}
+	user, ok := ctx.config.Auth.Users[msg.User]
+	sum := sha256.Sum256([]byte(msg.Password))
+	if !ok || subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(strings.ToLower(user.Password))) != 1 {
+		p.invalmsghdlr(w, r, "invalid user or password", http.StatusUnauthorized)
+		return
+	}
+	token := &AuthToken{
+		User:    msg.User,
+		Expires: time.Now().Add(ctx.config.Auth.TokenTTL).Unix(),
+		Admin:   user.Admin,
+		Buckets: user.Buckets,
+	}
+	signed, err := SignToken(ctx.config.Auth.Secret, token)
+	assert(err == nil, err)
+	jsbytes, err := json.Marshal(&TokenInfo{Token: signed, Expires: token.Expires})
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "tokenhdlr")
+}
+
+// redactedconfig returns the configuration without the secrets - to be returned to the clients
+func redactedconfig() *dfconfig {
+	config := ctx.config
+	if config.Auth.Secret == "" && len(config.Auth.Users) == 0 {
+		return &config
+	}
+	config.Auth.Secret = ""
+	config.Auth.Users = make(map[string]*authuser, len(ctx.config.Auth.Users))
+	for name, user := range ctx.config.Auth.Users {
+		redacted := *user
+		redacted.Password = ""
+		config.Auth.Users[name] = &redacted
+	}
+	return &config
+}
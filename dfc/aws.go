@@ -8,6 +8,7 @@ package dfc
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -27,6 +28,7 @@ const (
 	awsPutDfcHashVal  = "x-amz-meta-dfc-hash-val"
 	awsGetDfcHashType = "X-Amz-Meta-Dfc-Hash-Type"
 	awsGetDfcHashVal  = "X-Amz-Meta-Dfc-Hash-Val"
+	awsUserMetaPrefix = "x-amz-meta-" // user-defined metadata (see usermeta.go)
 	awsMultipartDelim = "-"
 )
 
@@ -36,7 +38,8 @@ const (
 //
 //======
 type awsimpl struct {
-	t *targetrunner
+	t        *targetrunner
+	provider string // amazoncloud or s3compatcloud
 }
 
 //======
@@ -44,11 +47,17 @@ type awsimpl struct {
 // session FIXME: optimize
 //
 //======
-func createsession() *session.Session {
+func (awsimpl *awsimpl) createsession() *session.Session {
 	// TODO: avoid creating sessions for each request
-	return session.Must(session.NewSessionWithOptions(session.Options{
-		SharedConfigState: session.SharedConfigEnable}))
-
+	opts := session.Options{SharedConfigState: session.SharedConfigEnable}
+	if awsimpl.provider == s3compatcloud {
+		opts.Config.Endpoint = aws.String(ctx.config.S3.Endpoint)
+		opts.Config.S3ForcePathStyle = aws.Bool(ctx.config.S3.PathStyle)
+		if ctx.config.S3.Region != "" {
+			opts.Config.Region = aws.String(ctx.config.S3.Region)
+		}
+	}
+	return session.Must(session.NewSessionWithOptions(opts))
 }
 
 func awsErrorToHTTP(awsError error) int {
@@ -66,7 +75,7 @@ func awsErrorToHTTP(awsError error) int {
 //======
 func (awsimpl *awsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
 	glog.Infof("aws: listbucket %s", bucket)
-	sess := createsession()
+	sess := awsimpl.createsession()
 	svc := s3.New(sess)
 
 	params := &s3.ListObjectsInput{Bucket: aws.String(bucket)}
@@ -76,6 +85,9 @@ func (awsimpl *awsimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	if msg.GetPageMarker != "" {
 		params.Marker = &msg.GetPageMarker
 	}
+	if msg.GetPageSize > 0 {
+		params.MaxKeys = aws.Int64(int64(msg.GetPageSize))
+	}
 
 	resp, err := svc.ListObjects(params)
 	if err != nil {
@@ -156,7 +168,7 @@ func (awsimpl *awsimpl) headbucket(bucket string) (bucketprops map[string]string
 	glog.Infof("aws: headbucket %s", bucket)
 	bucketprops = make(map[string]string)
 
-	sess := createsession()
+	sess := awsimpl.createsession()
 	svc := s3.New(sess)
 	input := &s3.HeadBucketInput{Bucket: aws.String(bucket)}
 
@@ -166,7 +178,7 @@ func (awsimpl *awsimpl) headbucket(bucket string) (bucketprops map[string]string
 		errstr = fmt.Sprintf("aws: The bucket %s either does not exist or is not accessible, err: %v", bucket, err)
 		return
 	}
-	bucketprops[HeaderServer] = amazoncloud
+	bucketprops[HeaderServer] = awsimpl.provider
 	return
 }
 
@@ -174,7 +186,7 @@ func (awsimpl *awsimpl) headobject(bucket string, objname string) (objmeta map[s
 	glog.Infof("aws: headobject %s/%s", bucket, objname)
 	objmeta = make(map[string]string)
 
-	sess := createsession()
+	sess := awsimpl.createsession()
 	svc := s3.New(sess)
 	input := &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(objname)}
 
@@ -184,16 +196,19 @@ func (awsimpl *awsimpl) headobject(bucket string, objname string) (objmeta map[s
 		errstr = fmt.Sprintf("aws: Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
 		return
 	}
-	objmeta[HeaderServer] = amazoncloud
+	objmeta[HeaderServer] = awsimpl.provider
 	if headOutput.VersionId != nil {
 		objmeta["version"] = *headOutput.VersionId
 	}
+	for k, v := range awsusermeta(headOutput.Metadata) {
+		objmeta[HeaderDfcMetaPrefix+k] = v
+	}
 	return
 }
 
 func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
 	var v cksumvalue
-	sess := createsession()
+	sess := awsimpl.createsession()
 	svc := s3.New(sess)
 	obj, err := svc.GetObject(&s3.GetObjectInput{
 		Bucket: aws.String(bucket),
@@ -211,6 +226,7 @@ func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps,
 			v = newcksumvalue(*htype, *hval)
 		}
 	}
+	usermeta := awsusermeta(obj.Metadata)
 	md5, _ := strconv.Unquote(*obj.ETag)
 	// FIXME: multipart
 	if strings.Contains(md5, awsMultipartDelim) {
@@ -219,8 +235,8 @@ func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps,
 		}
 		md5 = ""
 	}
-	props = &objectProps{}
-	if _, props.nhobj, props.size, errstr = awsimpl.t.receive(fqn, false, objname, md5, v, obj.Body); errstr != "" {
+	props = &objectProps{usermeta: usermeta}
+	if _, props.nhobj, props.size, errstr = awsimpl.t.receive(fqn, false, bucket, objname, md5, v, obj.Body); errstr != "" {
 		return
 	}
 	if obj.VersionId != nil {
@@ -232,7 +248,55 @@ func (awsimpl *awsimpl) getobj(fqn, bucket, objname string) (props *objectProps,
 	return
 }
 
-func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue) (errstr string, errcode int) {
+// awsusermeta returns the user-defined part of the S3 object's metadata
+func awsusermeta(md map[string]*string) (usermeta map[string]string) {
+	for k, v := range md {
+		key := strings.TrimPrefix(strings.ToLower(k), awsUserMetaPrefix)
+		if v == nil || awsUserMetaPrefix+key == awsPutDfcHashType || awsUserMetaPrefix+key == awsPutDfcHashVal {
+			continue
+		}
+		if usermeta == nil {
+			usermeta = make(map[string]string, len(md))
+		}
+		usermeta[key] = *v
+	}
+	return
+}
+
+func (awsimpl *awsimpl) getobjrange(bucket, objname string, offset, length int64) (rc io.ReadCloser, objsize int64, errstr string, errcode int) {
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length >= 0 {
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+	sess := awsimpl.createsession()
+	svc := s3.New(sess)
+	obj, err := svc.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(objname),
+		Range:  aws.String(rng),
+	})
+	if err != nil {
+		errcode = awsErrorToHTTP(err)
+		errstr = fmt.Sprintf("aws: Failed to GET %s/%s (%s), err: %v", bucket, objname, rng, err)
+		return
+	}
+	// Content-Range: bytes <first>-<last>/<size>
+	if obj.ContentRange != nil {
+		if idx := strings.LastIndex(*obj.ContentRange, "/"); idx >= 0 {
+			objsize, _ = strconv.ParseInt((*obj.ContentRange)[idx+1:], 10, 64)
+		}
+	}
+	if objsize == 0 && obj.ContentLength != nil {
+		objsize = offset + *obj.ContentLength
+	}
+	rc = obj.Body
+	if glog.V(3) {
+		glog.Infof("aws: GET %s/%s (%s)", bucket, objname, rng)
+	}
+	return
+}
+
+func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue, usermeta map[string]string) (errstr string, errcode int) {
 	var (
 		err          error
 		htype, hval  string
@@ -245,7 +309,13 @@ func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksu
 		md[awsPutDfcHashType] = aws.String(htype)
 		md[awsPutDfcHashVal] = aws.String(hval)
 	}
-	sess := createsession()
+	for k, v := range usermeta {
+		if md == nil {
+			md = make(map[string]*string, len(usermeta))
+		}
+		md[awsUserMetaPrefix+k] = aws.String(v)
+	}
+	sess := awsimpl.createsession()
 	uploader := s3manager.NewUploader(sess)
 	uploadoutput, err = uploader.Upload(&s3manager.UploadInput{
 		Bucket:   aws.String(bucket),
@@ -271,7 +341,7 @@ func (awsimpl *awsimpl) putobj(file *os.File, bucket, objname string, ohash cksu
 }
 
 func (awsimpl *awsimpl) deleteobj(bucket, objname string) (errstr string, errcode int) {
-	sess := createsession()
+	sess := awsimpl.createsession()
 	svc := s3.New(sess)
 	_, err := svc.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(objname)})
 	if err != nil {
@@ -0,0 +1,246 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// s3emulator is a minimal path-style S3 endpoint: one bucket, no versioning history
+type s3emulator struct {
+	sync.Mutex
+	bucket  string
+	objects map[string]*s3object
+	version int
+}
+
+type s3object struct {
+	data    []byte
+	version string
+	header  http.Header
+}
+
+type s3listbucket struct {
+	XMLName     xml.Name `xml:"ListBucketResult"`
+	Name        string
+	IsTruncated bool
+	Contents    []s3listentry
+}
+
+type s3listentry struct {
+	Key  string
+	Size int64
+	ETag string
+}
+
+func (s3e *s3emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s3e.Lock()
+	defer s3e.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, objname := path, ""
+	if idx := strings.Index(path, "/"); idx >= 0 {
+		bucket, objname = path[:idx], path[idx+1:]
+	}
+	if bucket != s3e.bucket {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if objname == "" {
+		switch r.Method {
+		case http.MethodHead:
+		case http.MethodGet:
+			s3e.list(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	obj, ok := s3e.objects[objname]
+	switch r.Method {
+	case http.MethodPut:
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s3e.version++
+		obj = &s3object{data: data, version: strconv.Itoa(s3e.version), header: make(http.Header)}
+		for k, v := range r.Header {
+			if strings.HasPrefix(strings.ToLower(k), awsUserMetaPrefix) {
+				obj.header[k] = v
+			}
+		}
+		s3e.objects[objname] = obj
+		w.Header().Set("x-amz-version-id", obj.version)
+	case http.MethodHead:
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for k, v := range obj.header {
+			w.Header()[k] = v
+		}
+		w.Header().Set("x-amz-version-id", obj.version)
+		w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	case http.MethodDelete:
+		delete(s3e.objects, objname)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (s3e *s3emulator) list(w http.ResponseWriter, r *http.Request) {
+	var (
+		query      = r.URL.Query()
+		prefix     = query.Get("prefix")
+		marker     = query.Get("marker")
+		maxkeys    = 1000
+		names      []string
+		listresult = s3listbucket{Name: s3e.bucket}
+	)
+	if n, err := strconv.Atoi(query.Get("max-keys")); err == nil {
+		maxkeys = n
+	}
+	for objname := range s3e.objects {
+		if strings.HasPrefix(objname, prefix) && objname > marker {
+			names = append(names, objname)
+		}
+	}
+	sort.Strings(names)
+	if len(names) > maxkeys {
+		names = names[:maxkeys]
+		listresult.IsTruncated = true
+	}
+	for _, objname := range names {
+		entry := s3listentry{Key: objname, Size: int64(len(s3e.objects[objname].data)), ETag: `"etag"`}
+		listresult.Contents = append(listresult.Contents, entry)
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(listresult)
+}
+
+func news3test(t *testing.T) (*awsimpl, *s3emulator, func()) {
+	s3e := &s3emulator{bucket: "bucket", objects: make(map[string]*s3object)}
+	server := httptest.NewServer(s3e)
+	saved := ctx.config.S3
+	env := map[string]string{
+		"AWS_ACCESS_KEY_ID":           "testkey",
+		"AWS_SECRET_ACCESS_KEY":       "testsecret",
+		"AWS_CONFIG_FILE":             os.DevNull,
+		"AWS_SHARED_CREDENTIALS_FILE": os.DevNull,
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	ctx.config.S3.Endpoint = server.URL
+	ctx.config.S3.PathStyle = true
+	ctx.config.S3.Region = "us-east-1"
+	return &awsimpl{provider: s3compatcloud}, s3e, func() {
+		server.Close()
+		ctx.config.S3 = saved
+	}
+}
+
+func TestS3compatObjects(t *testing.T) {
+	awsimpl, s3e, cleanup := news3test(t)
+	defer cleanup()
+
+	if _, errstr, _ := awsimpl.headbucket("bucket"); errstr != "" {
+		t.Fatal(errstr)
+	}
+	if _, _, errcode := awsimpl.headbucket("nobucket"); errcode != http.StatusNotFound {
+		t.Errorf("head missing bucket: status %d, expecting %d", errcode, http.StatusNotFound)
+	}
+
+	src, err := ioutil.TempFile("", "s3src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(src.Name())
+	if _, err = src.WriteString("0123456789"); err == nil {
+		_, err = src.Seek(0, 0)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if errstr, _ := awsimpl.putobj(src, "bucket", "dir/obj", nil, map[string]string{"color": "blue"}); errstr != "" {
+		t.Fatal(errstr)
+	}
+	if obj := s3e.objects["dir/obj"]; obj == nil || string(obj.data) != "0123456789" {
+		t.Fatalf("put: stored %+v", obj)
+	}
+
+	objmeta, errstr, _ := awsimpl.headobject("bucket", "dir/obj")
+	if errstr != "" {
+		t.Fatal(errstr)
+	}
+	if objmeta[HeaderServer] != s3compatcloud || objmeta["version"] != "1" || objmeta[HeaderDfcMetaPrefix+"color"] != "blue" {
+		t.Errorf("head: %v", objmeta)
+	}
+	if _, _, errcode := awsimpl.headobject("bucket", "nobject"); errcode != http.StatusNotFound {
+		t.Errorf("head missing object: status %d, expecting %d", errcode, http.StatusNotFound)
+	}
+
+	if errstr, _ := awsimpl.deleteobj("bucket", "dir/obj"); errstr != "" {
+		t.Fatal(errstr)
+	}
+	if _, ok := s3e.objects["dir/obj"]; ok {
+		t.Errorf("delete: dir/obj still exists")
+	}
+}
+
+func TestS3compatListPages(t *testing.T) {
+	awsimpl, s3e, cleanup := news3test(t)
+	defer cleanup()
+	expected := []string{"a-b", "a.txt", "a/b.txt", "a/b/c", "b"}
+	for _, objname := range expected {
+		s3e.objects[objname] = &s3object{data: []byte(objname)}
+	}
+
+	var (
+		names []string
+		msg   = &GetMsg{GetPageSize: 2, GetProps: GetPropsSize}
+	)
+	for pages := 0; ; pages++ {
+		if pages > len(expected) {
+			t.Fatal("too many pages")
+		}
+		jsbytes, errstr, _ := awsimpl.listbucket("bucket", msg)
+		if errstr != "" {
+			t.Fatal(errstr)
+		}
+		var page BucketList
+		if err := json.Unmarshal(jsbytes, &page); err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range page.Entries {
+			if entry.Size != int64(len(entry.Name)) {
+				t.Errorf("%s: size %d", entry.Name, entry.Size)
+			}
+			names = append(names, entry.Name)
+		}
+		if page.PageMarker == "" {
+			break
+		}
+		msg.GetPageMarker = page.PageMarker
+	}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("listed %v, expecting %v", names, expected)
+	}
+	if _, _, errcode := awsimpl.listbucket("nobucket", &GetMsg{}); errcode != http.StatusNotFound {
+		t.Errorf("list missing bucket: status %d, expecting %d", errcode, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,135 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+//======
+//
+// bucket listing pages and sorting: local and cached listings are paginated by object name -
+// each target returns, in ascending name order, up to GetMsg.GetPageSize names that follow
+// the GetMsg.GetPageMarker, and the proxy merges the target pages into one (mergepages).
+// The GetMsg.GetSort ordering then applies to the merged page as a whole
+//
+//======
+
+type sortspec struct {
+	by   string // GetSortName | GetSortSize | GetSortAtime
+	desc bool
+}
+
+// parsesort parses GetMsg.GetSort, e.g. "ascending, atime" or "descending, name";
+// either part can be omitted and defaults to ascending and name, respectively
+func parsesort(s string) (spec sortspec, errstr string) {
+	spec.by = GetSortName
+	for _, tok := range strings.Split(s, ",") {
+		switch tok = strings.TrimSpace(tok); tok {
+		case "", GetSortAsc:
+		case GetSortDes:
+			spec.desc = true
+		case GetSortName, GetSortSize, GetSortAtime:
+			spec.by = tok
+		default:
+			errstr = fmt.Sprintf("Invalid sort %q: expecting [%s|%s][, %s|%s|%s]",
+				s, GetSortAsc, GetSortDes, GetSortName, GetSortSize, GetSortAtime)
+			return
+		}
+	}
+	return
+}
+
+// sortentries orders the entries as per the spec; ties are broken by name
+func sortentries(entries []*BucketEntry, spec sortspec, timeformat string) {
+	if timeformat == "" {
+		timeformat = RFC822
+	}
+	less := func(i, j int) bool { return entries[i].Name < entries[j].Name }
+	switch spec.by {
+	case GetSortSize:
+		less = func(i, j int) bool {
+			if entries[i].Size != entries[j].Size {
+				return entries[i].Size < entries[j].Size
+			}
+			return entries[i].Name < entries[j].Name
+		}
+	case GetSortAtime:
+		atimes := make(map[*BucketEntry]time.Time, len(entries))
+		for _, entry := range entries {
+			// not cached Cloud objects have no atime and go first
+			atimes[entry], _ = time.Parse(timeformat, entry.Atime)
+		}
+		less = func(i, j int) bool {
+			ti, tj := atimes[entries[i]], atimes[entries[j]]
+			if !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+			return entries[i].Name < entries[j].Name
+		}
+	}
+	if spec.desc {
+		asc := less
+		less = func(i, j int) bool { return asc(j, i) }
+	}
+	sort.SliceStable(entries, less)
+}
+
+// pagebymarker sorts the entries by name, drops those that do not follow the marker,
+// and ends the page at pagesize distinct names (0 - no limit); the page marker of a
+// truncated page is its last name. Entries with the same name (object versions) stay
+// in their original order and are never split between pages
+func pagebymarker(entries []*BucketEntry, marker string, pagesize int) *BucketList {
+	page := &BucketList{Entries: make([]*BucketEntry, 0, len(entries))}
+	for _, entry := range entries {
+		if marker == "" || entry.Name > marker {
+			page.Entries = append(page.Entries, entry)
+		}
+	}
+	sort.SliceStable(page.Entries, func(i, j int) bool { return page.Entries[i].Name < page.Entries[j].Name })
+	page.Entries, page.PageMarker = cutpage(page.Entries, "", pagesize)
+	return page
+}
+
+// mergepages merges the name-ordered target pages that follow the same marker. A target
+// that has truncated its page may have more objects beyond the page's last name, so the
+// merged page ends at the smallest such name, and at pagesize distinct names
+func mergepages(pages []*BucketList, pagesize int) *BucketList {
+	var (
+		merged = &BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
+		bound  string
+	)
+	for _, page := range pages {
+		merged.Entries = append(merged.Entries, page.Entries...)
+		if page.PageMarker != "" && (bound == "" || page.PageMarker < bound) {
+			bound = page.PageMarker
+		}
+	}
+	sort.SliceStable(merged.Entries, func(i, j int) bool { return merged.Entries[i].Name < merged.Entries[j].Name })
+	merged.Entries, merged.PageMarker = cutpage(merged.Entries, bound, pagesize)
+	return merged
+}
+
+// cutpage ends the name-ordered entries at the bound (if any) and at pagesize distinct
+// names, and returns the marker of the next page (empty if nothing follows)
+func cutpage(entries []*BucketEntry, bound string, pagesize int) ([]*BucketEntry, string) {
+	names := 0
+	for i, entry := range entries {
+		if bound != "" && entry.Name > bound {
+			return entries[:i], bound
+		}
+		if i > 0 && entry.Name == entries[i-1].Name {
+			continue
+		}
+		if names++; pagesize > 0 && names > pagesize {
+			return entries[:i], entries[i-1].Name
+		}
+	}
+	return entries, bound
+}
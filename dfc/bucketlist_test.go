@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newentries makes the entries out of "name" or "name:version"
+func newentries(names ...string) []*BucketEntry {
+	entries := make([]*BucketEntry, 0, len(names))
+	for _, name := range names {
+		entry := &BucketEntry{Name: name}
+		if idx := strings.Index(name, ":"); idx > 0 {
+			entry.Name, entry.Version = name[:idx], name[idx+1:]
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func entrynames(entries []*BucketEntry) []string {
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name
+		if entry.Version != "" {
+			name += ":" + entry.Version
+		}
+		names = append(names, name)
+	}
+	return names
+}
+
+func TestPageByMarker(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		marker   string
+		pagesize int
+		expected []string
+		next     string
+	}{
+		{"all", []string{"d", "a", "c", "b"}, "", 0, []string{"a", "b", "c", "d"}, ""},
+		{"empty-marker", []string{"d", "a", "c", "b"}, "", 2, []string{"a", "b"}, "b"},
+		{"after-marker", []string{"d", "a", "e", "c", "b"}, "b", 2, []string{"c", "d"}, "d"},
+		{"last-page", []string{"d", "a", "c", "b"}, "b", 2, []string{"c", "d"}, ""},
+		{"past-the-end", []string{"a", "b"}, "b", 2, []string{}, ""},
+		// versions of the same object keep their order, and count as one name
+		{"versions", []string{"c", "b:2", "a", "b:1", "b:3"}, "", 2, []string{"a", "b:2", "b:1", "b:3"}, "b"},
+		{"versions-next", []string{"c", "b:2", "a", "b:1", "b:3"}, "b", 2, []string{"c"}, ""},
+		{"no-limit-versions", []string{"b:2", "a", "b:1"}, "", 0, []string{"a", "b:2", "b:1"}, ""},
+	}
+	for _, test := range tests {
+		page := pagebymarker(newentries(test.entries...), test.marker, test.pagesize)
+		if names := entrynames(page.Entries); !reflect.DeepEqual(names, test.expected) || page.PageMarker != test.next {
+			t.Errorf("%s: page %v, marker %q, expecting %v, %q", test.name, names, page.PageMarker, test.expected, test.next)
+		}
+	}
+}
+
+func TestMergePages(t *testing.T) {
+	type targetpage struct {
+		entries []string
+		marker  string // non-empty if truncated
+	}
+	tests := []struct {
+		name     string
+		pages    []targetpage
+		pagesize int
+		expected []string
+		next     string
+	}{
+		{"untruncated", []targetpage{{[]string{"a", "c"}, ""}, {[]string{"b", "d"}, ""}}, 0,
+			[]string{"a", "b", "c", "d"}, ""},
+		{"empty", []targetpage{{nil, ""}, {nil, ""}}, 2, []string{}, ""},
+		// a truncated target page may have more objects beyond its last name
+		{"truncated-bound", []targetpage{{[]string{"a", "c", "e"}, "e"}, {[]string{"b", "d", "f", "g"}, ""}}, 10,
+			[]string{"a", "b", "c", "d", "e"}, "e"},
+		{"smallest-bound", []targetpage{{[]string{"a", "d"}, "d"}, {[]string{"b", "c"}, "c"}, {[]string{"e"}, ""}}, 10,
+			[]string{"a", "b", "c"}, "c"},
+		{"truncated-no-limit", []targetpage{{[]string{"a", "c"}, "c"}, {[]string{"b", "d"}, ""}}, 0,
+			[]string{"a", "b", "c"}, "c"},
+		{"pagesize", []targetpage{{[]string{"a", "c"}, ""}, {[]string{"b", "d"}, ""}}, 3,
+			[]string{"a", "b", "c"}, "c"},
+		{"pagesize-before-bound", []targetpage{{[]string{"a", "c", "e"}, "e"}, {[]string{"b", "d"}, ""}}, 2,
+			[]string{"a", "b"}, "b"},
+		// the same name on the different targets is never split
+		{"same-name", []targetpage{{[]string{"a", "b:1"}, ""}, {[]string{"b:2", "c"}, ""}}, 2,
+			[]string{"a", "b:1", "b:2"}, "b"},
+		{"same-name-bound", []targetpage{{[]string{"a", "b:1"}, "b"}, {[]string{"b:2", "c"}, ""}}, 10,
+			[]string{"a", "b:1", "b:2"}, "b"},
+	}
+	for _, test := range tests {
+		pages := make([]*BucketList, 0, len(test.pages))
+		for _, p := range test.pages {
+			pages = append(pages, &BucketList{Entries: newentries(p.entries...), PageMarker: p.marker})
+		}
+		merged := mergepages(pages, test.pagesize)
+		if names := entrynames(merged.Entries); !reflect.DeepEqual(names, test.expected) || merged.PageMarker != test.next {
+			t.Errorf("%s: page %v, marker %q, expecting %v, %q", test.name, names, merged.PageMarker, test.expected, test.next)
+		}
+	}
+}
+
+func TestCutPage(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		bound    string
+		pagesize int
+		expected []string
+		next     string
+	}{
+		{"no-limit", []string{"a", "b", "c"}, "", 0, []string{"a", "b", "c"}, ""},
+		{"exact", []string{"a", "b", "c"}, "", 3, []string{"a", "b", "c"}, ""},
+		{"pagesize", []string{"a", "b", "c"}, "", 2, []string{"a", "b"}, "b"},
+		{"bound", []string{"a", "b", "c"}, "b", 0, []string{"a", "b"}, "b"},
+		{"bound-past-the-end", []string{"a", "b"}, "c", 0, []string{"a", "b"}, "c"},
+		{"same-name", []string{"a:1", "a:2", "b"}, "", 1, []string{"a:1", "a:2"}, "a"},
+		{"same-name-last", []string{"a", "b:1", "b:2"}, "", 2, []string{"a", "b:1", "b:2"}, ""},
+		{"empty", nil, "", 2, []string{}, ""},
+	}
+	for _, test := range tests {
+		entries, next := cutpage(newentries(test.entries...), test.bound, test.pagesize)
+		if names := entrynames(entries); !reflect.DeepEqual(names, test.expected) || next != test.next {
+			t.Errorf("%s: page %v, marker %q, expecting %v, %q", test.name, names, next, test.expected, test.next)
+		}
+	}
+}
@@ -6,6 +6,8 @@
 package dfc
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -44,6 +46,7 @@ type dfconfig struct {
 	CloudBuckets     string            `json:"cloud_buckets"`
 	LocalBuckets     string            `json:"local_buckets"`
 	LBConf           string            `json:"lb_conf"`
+	SmapConf         string            `json:"smap_conf"`
 	StatsTimeStr     string            `json:"stats_time"`
 	StatsTime        time.Duration     `json:"-"` // omitempty
 	HTTP             httpconfig        `json:"http"`
@@ -53,26 +56,82 @@ type dfconfig struct {
 	Listen           listenconfig      `json:"listen"`
 	Proxy            proxyconfig       `json:"proxy"`
 	S3               s3config          `json:"s3"`
+	Posix            posixconfig       `json:"posix"`
 	LRUConfig        lruconfig         `json:"lru_config"`
 	CksumConfig      cksumconfig       `json:"cksum_config"`
 	VersionConfig    versionconfig     `json:"version_config"`
 	FSpaths          map[string]string `json:"fspaths"`
 	TestFSP          testfspathconf    `json:"test_fspaths"`
 	AckPolicy        ackpolicy         `json:"ack_policy"`
+	Multipart        mpconfig          `json:"multipart"`
+	Trace            traceconfig       `json:"trace"`
+	Auth             authconfig        `json:"auth"`
+	TLS              tlsconfig         `json:"tls"`
 }
 
 type s3config struct {
 	Maxconcurrdownld uint32 `json:"maxconcurrdownld"` // Concurent Download for a session.
 	Maxconcurrupld   uint32 `json:"maxconcurrupld"`   // Concurrent Upload for a session.
 	Maxpartsize      uint64 `json:"maxpartsize"`      // Maximum part size for Upload and Download used for buffering.
+	Endpoint         string `json:"endpoint"`         // S3-compatible endpoint URL (s3compat only)
+	Region           string `json:"region"`           // ditto, optional
+	PathStyle        bool   `json:"path_style"`       // ditto, use path-style addressing: endpoint/bucket/object
+}
+
+type posixconfig struct {
+	Root string `json:"root"` // directory tree that contains posix "cloud" buckets, one bucket per subdirectory
 }
 
 type lruconfig struct {
-	LowWM            uint32        `json:"lowwm"`           // capacity usage low watermark
-	HighWM           uint32        `json:"highwm"`          // capacity usage high watermark
-	DontEvictTimeStr string        `json:"dont_evict_time"` // eviction is not permitted during [atime, atime + dont]
-	LRUEnabled       bool          `json:"lru_enabled"`     // LRU will only run when LRUEnabled is true
-	DontEvictTime    time.Duration `json:"-"`               // omitempty
+	LowWM            uint32                  `json:"lowwm"`           // capacity usage low watermark
+	HighWM           uint32                  `json:"highwm"`          // capacity usage high watermark
+	DontEvictTimeStr string                  `json:"dont_evict_time"` // eviction is not permitted during [atime, atime + dont]
+	LRUEnabled       bool                    `json:"lru_enabled"`     // LRU will only run when LRUEnabled is true
+	DontEvictTime    time.Duration           `json:"-"`               // omitempty
+	Policy           string                  `json:"policy"`          // eviction policy: lru (default), lfu, gds, or arc - see evict.go
+	BucketPolicy     map[string]string       `json:"bucket_policy"`   // per-bucket eviction policy, overrides the above
+	BucketQuota      map[string]*bucketquota `json:"bucket_quota"`    // per-bucket quotas, reserves, and eviction priorities - see quota.go
+}
+
+// bucketquota: cluster-wide capacity constraints of a given bucket
+type bucketquota struct {
+	QuotaMB    int64 `json:"quota_mb"`    // max size, 0 - unlimited
+	ReservedMB int64 `json:"reserved_mb"` // min size that is never evicted to satisfy the watermarks
+	Priority   int   `json:"priority"`    // eviction priority class: lower classes get evicted first, 0 - default
+}
+
+type mpconfig struct {
+	AbandonTimeStr string        `json:"abandon_time"` // multipart uploads with no activity during this time get removed
+	AbandonTime    time.Duration `json:"-"`            // omitempty
+}
+
+type traceconfig struct {
+	Enabled   bool    `json:"enabled"`   // trace requests and export the spans
+	Collector string  `json:"collector"` // OTLP/HTTP traces endpoint, e.g. http://localhost:4318/v1/traces
+	File      string  `json:"file"`      // append the spans to this file (OTLP JSON, one export request per line)
+	Sample    float64 `json:"sample"`    // fraction of the new traces to export; requests that come with a trace context follow its sampled flag
+}
+
+type authconfig struct {
+	Enabled     bool                 `json:"enabled"`   // require signed tokens (see auth.go)
+	Secret      string               `json:"secret"`    // HMAC-SHA256 key shared by the cluster and the token issuer(s)
+	TokenTTLStr string               `json:"token_ttl"` // lifetime of the tokens issued by the built-in auth service
+	TokenTTL    time.Duration        `json:"-"`         // omitempty
+	Users       map[string]*authuser `json:"users"`     // users of the built-in auth service (POST /v1/tokens)
+}
+
+type authuser struct {
+	Password string            `json:"password"`          // hex-encoded SHA-256 of the password
+	Admin    bool              `json:"admin"`             // cluster admin
+	Buckets  map[string]string `json:"buckets,omitempty"` // bucket ("*" - any bucket) => read | write | admin
+}
+
+type tlsconfig struct {
+	Enabled     bool   `json:"enabled"`     // serve HTTPS, and use HTTPS within the cluster
+	Certificate string `json:"certificate"` // PEM-encoded certificate of the daemon: server and intra-cluster client
+	Key         string `json:"key"`         // the certificate's private key (PEM)
+	CA          string `json:"ca"`          // PEM-encoded cluster CA that verifies the daemons' certificates; empty - system roots
+	Mutual      bool   `json:"mutual"`      // mTLS within the cluster: the daemons present their certificates to each other
 }
 
 type testfspathconf struct {
@@ -89,6 +148,7 @@ type listenconfig struct {
 type proxyconfig struct {
 	URL      string `json:"url"`      // used to register caching servers
 	Passthru bool   `json:"passthru"` // false: get then redirect, true (default): redirect right away
+	Standby  bool   `json:"standby"`  // true: start as a standby proxy and join the primary at the URL above
 }
 
 type cksumconfig struct {
@@ -166,6 +226,47 @@ func getConfig(fpath string) {
 	}
 }
 
+// validateprovider checks that the cloud provider is supported and, unless config is nil, configured
+func validateprovider(provider string, config *dfconfig) error {
+	switch provider {
+	case amazoncloud, googlecloud:
+	case posixcloud:
+		if config != nil && config.Posix.Root == "" {
+			return fmt.Errorf("Invalid posix config: root directory is not specified")
+		}
+	case s3compatcloud:
+		if config != nil && config.S3.Endpoint == "" {
+			return fmt.Errorf("Invalid s3compat config: endpoint is not specified")
+		}
+	default:
+		return fmt.Errorf("Invalid cloud provider: %s - expecting %s, %s, %s or %s",
+			provider, amazoncloud, googlecloud, posixcloud, s3compatcloud)
+	}
+	return nil
+}
+
+// validatebprops validates the bucket's properties that override the config (ActSetProps)
+func validatebprops(bprops *BucketProps) error {
+	if bprops.Checksum != "" && bprops.Checksum != ChecksumXXHash && bprops.Checksum != ChecksumNone {
+		return fmt.Errorf("Invalid checksum: %s - expecting %s or %s", bprops.Checksum, ChecksumXXHash, ChecksumNone)
+	}
+	for name, v := range map[string]string{"validate_cold_get": bprops.ValidateColdGet,
+		"validate_warm_get": bprops.ValidateWarmGet, "lru_enabled": bprops.LRUEnabled, "versioning": bprops.Versioning} {
+		if v != "" && v != "true" && v != "false" {
+			return fmt.Errorf("Invalid %s: %q - expecting true or false", name, v)
+		}
+	}
+	if bprops.DontEvictTime != "" {
+		if _, err := time.ParseDuration(bprops.DontEvictTime); err != nil {
+			return fmt.Errorf("Bad dont-evict-time format %s, err: %v", bprops.DontEvictTime, err)
+		}
+	}
+	if bprops.AckPut != "" && bprops.AckPut != AckWhenInMem && bprops.AckPut != AckWhenOnDisk {
+		return fmt.Errorf("Invalid ack policy: %s - expecting %s or %s", bprops.AckPut, AckWhenInMem, AckWhenOnDisk)
+	}
+	return nil
+}
+
 func validateconf() (err error) {
 	// durations
 	if ctx.config.StatsTime, err = time.ParseDuration(ctx.config.StatsTimeStr); err != nil {
@@ -183,6 +284,28 @@ func validateconf() (err error) {
 	if ctx.config.LRUConfig.DontEvictTime, err = time.ParseDuration(ctx.config.LRUConfig.DontEvictTimeStr); err != nil {
 		return fmt.Errorf("Bad dont-evict-time format %s, err: %v", ctx.config.LRUConfig.DontEvictTimeStr, err)
 	}
+	if ctx.config.Multipart.AbandonTimeStr == "" {
+		ctx.config.Multipart.AbandonTime = mpAbandonTime
+	} else if ctx.config.Multipart.AbandonTime, err = time.ParseDuration(ctx.config.Multipart.AbandonTimeStr); err != nil {
+		return fmt.Errorf("Bad multipart abandon-time format %s, err: %v", ctx.config.Multipart.AbandonTimeStr, err)
+	}
+	if ctx.config.SmapConf == "" {
+		ctx.config.SmapConf = smapconfname
+	}
+	if ctx.config.LRUConfig.Policy == "" {
+		ctx.config.LRUConfig.Policy = EvictLRU
+	}
+	if err = validatepolicy(ctx.config.LRUConfig.Policy); err != nil {
+		return err
+	}
+	for bucket, policy := range ctx.config.LRUConfig.BucketPolicy {
+		if err = validatepolicy(policy); err != nil {
+			return fmt.Errorf("Bucket %s: %v", bucket, err)
+		}
+	}
+	if err = validatequotas(ctx.config.LRUConfig.BucketQuota); err != nil {
+		return err
+	}
 	hwm, lwm := ctx.config.LRUConfig.HighWM, ctx.config.LRUConfig.LowWM
 	if hwm <= 0 || lwm <= 0 || hwm < lwm || lwm > 100 || hwm > 100 {
 		return fmt.Errorf("Invalid LRU configuration %+v", ctx.config.LRUConfig)
@@ -196,8 +319,64 @@ func validateconf() (err error) {
 			}
 		}
 	}
+	if err = validateprovider(ctx.config.CloudProvider, &ctx.config); err != nil {
+		return err
+	}
 	if ctx.config.CksumConfig.Checksum != ChecksumXXHash && ctx.config.CksumConfig.Checksum != ChecksumNone {
 		return fmt.Errorf("Invalid checksum: %s - expecting %s or %s", ctx.config.CksumConfig.Checksum, ChecksumXXHash, ChecksumNone)
 	}
+	if trace := ctx.config.Trace; trace.Enabled {
+		if trace.Collector == "" && trace.File == "" {
+			return fmt.Errorf("Invalid trace configuration %+v: neither collector nor file specified", trace)
+		}
+		if trace.Sample < 0 || trace.Sample > 1 {
+			return fmt.Errorf("Invalid trace sample %v: expecting a fraction between 0 and 1", trace.Sample)
+		}
+	}
+	if ctx.config.Auth.Enabled {
+		if err = validateauth(&ctx.config.Auth); err != nil {
+			return err
+		}
+	}
+	if tls := ctx.config.TLS; tls.Enabled {
+		if tls.Certificate == "" || tls.Key == "" {
+			return fmt.Errorf("Invalid TLS configuration %+v: expecting certificate and key", tls)
+		}
+		if tls.Mutual && tls.CA == "" {
+			return fmt.Errorf("Invalid TLS configuration %+v: mutual TLS requires the cluster CA", tls)
+		}
+		if ctx.config.H2c {
+			return fmt.Errorf("Invalid configuration: h2c and TLS are mutually exclusive (HTTP/2 is negotiated over TLS)")
+		}
+		if !strings.HasPrefix(ctx.config.Proxy.URL, "https://") {
+			return fmt.Errorf("Invalid proxy URL %s: expecting https:// with TLS enabled", ctx.config.Proxy.URL)
+		}
+	}
+	return nil
+}
+
+func validateauth(auth *authconfig) (err error) {
+	if len(auth.Secret) < authMinSecret {
+		return fmt.Errorf("Invalid auth secret: expecting at least %d characters", authMinSecret)
+	}
+	if auth.TokenTTLStr == "" {
+		auth.TokenTTL = authTokenTTL
+	} else if auth.TokenTTL, err = time.ParseDuration(auth.TokenTTLStr); err != nil {
+		return fmt.Errorf("Bad auth token-ttl format %s, err: %v", auth.TokenTTLStr, err)
+	}
+	for name, user := range auth.Users {
+		if user == nil {
+			return fmt.Errorf("Invalid auth user %q: no password", name)
+		}
+		if b, err := hex.DecodeString(user.Password); err != nil || len(b) != sha256.Size {
+			return fmt.Errorf("Invalid auth user %q: expecting hex-encoded SHA-256 of the password", name)
+		}
+		for bucket, perm := range user.Buckets {
+			if _, ok := authlevels[perm]; !ok {
+				return fmt.Errorf("Invalid auth user %q: bucket %s permission %q - expecting %s, %s, or %s",
+					name, bucket, perm, AuthRead, AuthWrite, AuthAdmin)
+			}
+		}
+	}
 	return nil
 }
@@ -0,0 +1,233 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// bucket to bucket copy and move: {"action": "copy" | "move", "name": destination-bucket,
+// "value": list or range} is a list/range job (see jobs.go) that copies the selected objects
+// local => local, Cloud => local, or local => Cloud (upload). Each target copies the source
+// objects it stores (as per HRW) directly to the destination's HRW targets via sendfile;
+// Cloud objects that are not cached get cold-GET first. Move deletes the source objects
+// once copied
+//
+//======
+
+type xactCopy struct {
+	xactBase
+	targetrunner *targetrunner
+}
+
+//===================
+//
+// proxy
+//
+//===================
+func (p *proxyrunner) copybucket(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	tobucket := msg.Name
+	if tobucket == "" || tobucket == bucket {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid destination bucket %q to %s %s", tobucket, msg.Action, bucket))
+		return
+	}
+	p.lbmap.lock()
+	fromlocal, tolocal := p.islocalBucket(bucket), p.islocalBucket(tobucket)
+	p.lbmap.unlock()
+	if !fromlocal && !tolocal {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Cannot %s %s => %s: Cloud to Cloud is not supported", msg.Action, bucket, tobucket))
+		return
+	}
+	p.actionlistrange(w, r, msg)
+}
+
+//===================
+//
+// target
+//
+//===================
+func (t *targetrunner) copyfiles(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	var (
+		move     = msg.Action == ActMove
+		tobucket = msg.Name
+	)
+	if tobucket == "" {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Missing destination bucket to %s", msg.Action))
+		return
+	}
+	jsmap, ok := msg.Value.(map[string]interface{})
+	if !ok {
+		t.invalmsghdlr(w, r, "Could not parse List/Range Message: ActionMsg.Value was not map[string]interface{}")
+		return
+	}
+	if _, ok := jsmap["objnames"]; ok {
+		// Copy with List
+		if copyMsg, err := parseListMsg(jsmap); err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse CopyMsg: %v", err))
+		} else {
+			t.listOperation(w, r, msg.Action, copyMsg,
+				func(objs []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error {
+					return t.doListCopy(move, tobucket, objs, bucket, deadline, done, job)
+				})
+		}
+	} else {
+		// Copy with Range
+		if copyMsg, err := parseRangeMsg(jsmap); err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse CopyMsg: %v", err))
+		} else {
+			t.rangeOperation(w, r, msg.Action, copyMsg,
+				func(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}, job *lrjob) error {
+					var (
+						objs []string
+						err  error
+					)
+					if t.islocalBucket(bucket) {
+						objs, err = t.getLocalListFromRange(bucket, prefix, regex, min, max)
+					} else {
+						objs, err = t.getListFromRange(bucket, prefix, regex, min, max)
+					}
+					if err != nil {
+						job.finish(err)
+						if done != nil {
+							done <- struct{}{}
+						}
+						return err
+					}
+					return t.doListCopy(move, tobucket, objs, bucket, deadline, done, job)
+				})
+		}
+	}
+}
+
+func (t *targetrunner) doListCopy(move bool, tobucket string, objs []string, bucket string, deadline time.Duration,
+	done chan struct{}, job *lrjob) (err error) {
+	xcopy := t.xactinp.newCopy(t, move)
+	job.settotal(len(objs))
+	defer func() {
+		job.finish(err)
+		if done != nil {
+			var v struct{}
+			done <- v
+		}
+		xcopy.etime = time.Now()
+		t.xactinp.del(xcopy.id)
+	}()
+
+	var absdeadline time.Time
+	if deadline != 0 {
+		absdeadline = time.Now().Add(deadline)
+	}
+	var ncopied, nfailed int
+	for _, objname := range objs {
+		select {
+		case <-xcopy.abrt:
+			return nil
+		default:
+		}
+		if job.iscancelled() {
+			return nil
+		}
+		if !absdeadline.IsZero() && time.Now().After(absdeadline) {
+			break
+		}
+		size, errstr := t.copyfile(bucket, objname, tobucket)
+		if errstr == "" && move {
+			if errdel := t.fildelete(nil, bucket, objname, false); errdel != nil {
+				errstr = fmt.Sprintf("Copied %s/%s => %s but failed to delete the source, err: %v", bucket, objname, tobucket, errdel)
+			}
+		}
+		if errstr != "" {
+			glog.Errorf("%s: %s", xcopy.tostring(), errstr)
+			job.done(objname, errors.New(errstr))
+			nfailed++
+			continue
+		}
+		job.done(objname, nil)
+		ncopied++
+		xcopy.progress(1, size)
+	}
+	glog.Infof("%s: %s => %s, %d objects done, %d failed", xcopy.tostring(), bucket, tobucket, ncopied, nfailed)
+	if nfailed > 0 {
+		return fmt.Errorf("%s: failed to process %d out of %d objects", xcopy.tostring(), nfailed, len(objs))
+	}
+	return nil
+}
+
+// copyfile copies a single object to the destination bucket's HRW target and returns its size
+func (t *targetrunner) copyfile(bucket, objname, tobucket string) (size int64, errstr string) {
+	if !t.islocalBucket(bucket) {
+		if _, errstr = t.prefetchMissing(objname, bucket); errstr != "" {
+			return
+		}
+	}
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, false)
+
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		errstr = fmt.Sprintf("Copy: failed to fstat %s (bucket %s, object %s), err: %v", fqn, bucket, objname, err)
+		return
+	}
+	size = finfo.Size()
+	si, errstr := hrwTarget(tobucket+"/"+objname, t.smap)
+	if errstr != "" {
+		return
+	}
+	if si.DaemonID != t.si.DaemonID {
+		errstr = t.sendfile(nil, http.MethodPut, bucket, objname, si, size, tobucket, "")
+		return
+	}
+	// the destination is this target
+	file, err := os.Open(fqn)
+	if err != nil {
+		errstr = fmt.Sprintf("Failed to open %s, err: %v", fqn, err)
+		return
+	}
+	defer file.Close()
+	tofqn := t.fqn(tobucket, objname)
+	putfqn := fmt.Sprintf("%s.%d", tofqn, time.Now().UnixNano())
+	var nhobj cksumvalue
+	if _, nhobj, _, errstr = t.receive(putfqn, false, tobucket, objname, "", nil, file); errstr != "" {
+		return
+	}
+	if errstr = setusermeta(putfqn, getusermeta(fqn)); errstr != "" {
+		os.Remove(putfqn)
+		return
+	}
+	errstr, _ = t.putCommit(nil, tobucket, objname, putfqn, tofqn, nhobj, false)
+	return
+}
+
+func (q *xactInProgress) newCopy(t *targetrunner, move bool) *xactCopy {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	kind := ActCopy
+	if move {
+		kind = ActMove
+	}
+	id := q.uniqueid()
+	xcopy := &xactCopy{xactBase: *newxactBase(id, kind), targetrunner: t}
+	q.add(xcopy)
+	return xcopy
+}
+
+func (xact *xactCopy) tostring() string {
+	start := xact.stime.Sub(xact.targetrunner.starttime)
+	if !xact.finished() {
+		return fmt.Sprintf("xaction %s:%d started %v", xact.kind, xact.id, start)
+	}
+	fin := time.Since(xact.targetrunner.starttime)
+	return fmt.Sprintf("xaction %s:%d started %v finished %v", xact.kind, xact.id, start, fin)
+}
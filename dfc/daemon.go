@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -25,6 +26,8 @@ const (
 	xstorstats    = "storstats"
 	xproxykalive  = "proxykalive"
 	xtargetkalive = "targetkalive"
+	xmetasyncer   = "metasyncer"
+	xtracer       = "tracer"
 )
 
 //======
@@ -45,6 +48,7 @@ type cliVars struct {
 type Smap struct {
 	sync.Mutex
 	Smap        map[string]*daemonInfo `json:"smap"`
+	Pmap        map[string]*daemonInfo `json:"pmap"` // proxies: the primary (ProxySI) and standbys
 	ProxySI     *daemonInfo            `json:"proxy_si"`
 	Version     int64                  `json:"version"`
 	syncversion int64
@@ -66,14 +70,32 @@ type daemonInfo struct {
 	DirectURL  string `json:"direct_url"`
 }
 
-// local (cache-only) bucket names and their TBD props
+// local (cache-only) bucket names,
+// Cloud bucket names that override the default (configured) cloud provider,
+// and per-bucket (local and Cloud) properties
 type lbmap struct {
 	sync.Mutex
-	LBmap       map[string]string `json:"l_bmap"`
-	Version     int64             `json:"version"`
+	LBmap       map[string]string       `json:"l_bmap"`
+	CBmap       map[string]string       `json:"c_bmap"` // cloud bucket => cloud provider
+	Props       map[string]*bucketProps `json:"props"`  // bucket => properties
+	Version     int64                   `json:"version"`
 	syncversion int64
 }
 
+// versioned cluster metadata (GetWhatMeta)
+type clustermeta struct {
+	Smap  *Smap  `json:"smap"`
+	LBmap *lbmap `json:"lbmap"`
+}
+
+// bucket properties
+type bucketProps struct {
+	Copies       int `json:"copies"`        // number of object replicas, stored on the top-N HRW targets
+	DataSlices   int `json:"data_slices"`   // erasure coding (local buckets): number of data slices
+	ParitySlices int `json:"parity_slices"` // erasure coding (local buckets): number of parity slices
+	BucketProps      // checksum, versioning, LRU, and ack policies - see REST.go
+}
+
 // runner if
 type runner interface {
 	run() error
@@ -126,6 +148,19 @@ func (m *Smap) del(sid string) {
 	m.Version++
 }
 
+func (m *Smap) addproxy(si *daemonInfo) {
+	if m.Pmap == nil {
+		m.Pmap = make(map[string]*daemonInfo, 4)
+	}
+	m.Pmap[si.DaemonID] = si
+	m.Version++
+}
+
+func (m *Smap) delproxy(sid string) {
+	delete(m.Pmap, sid)
+	m.Version++
+}
+
 func (m *Smap) version() int64 {
 	return m.Version
 }
@@ -164,6 +199,10 @@ func (m *Smap) unlock() {
 // lbmap wrapper - NOTE - caller must take the lock
 //
 //====================
+func newlbmap() *lbmap {
+	return &lbmap{LBmap: make(map[string]string), CBmap: make(map[string]string), Props: make(map[string]*bucketProps)}
+}
+
 func (m *lbmap) add(b string) bool {
 	_, ok := m.LBmap[b]
 	if ok {
@@ -180,10 +219,210 @@ func (m *lbmap) del(b string) bool {
 		return false
 	}
 	delete(m.LBmap, b)
+	delete(m.Props, b)
 	m.Version++
 	return true
 }
 
+// setprovider (re)assigns cloud provider to a given Cloud bucket;
+// empty provider reverts the bucket to the default dfconfig.CloudProvider
+func (m *lbmap) setprovider(b, provider string) bool {
+	if provider == ctx.config.CloudProvider {
+		provider = ""
+	}
+	if m.CBmap[b] == provider {
+		return false
+	}
+	if provider == "" {
+		delete(m.CBmap, b)
+	} else {
+		m.CBmap[b] = provider
+	}
+	m.Version++
+	return true
+}
+
+func (m *lbmap) provider(b string) string {
+	if provider, ok := m.CBmap[b]; ok {
+		return provider
+	}
+	return ctx.config.CloudProvider
+}
+
+// mprops returns the bucket's properties for modification; see also propsdone
+func (m *lbmap) mprops(b string) *bucketProps {
+	if m.Props == nil {
+		m.Props = make(map[string]*bucketProps)
+	}
+	props, ok := m.Props[b]
+	if !ok {
+		props = &bucketProps{}
+		m.Props[b] = props
+	}
+	return props
+}
+
+// propsdone removes the bucket's properties if all of them are defaults
+func (m *lbmap) propsdone(b string) {
+	if props, ok := m.Props[b]; ok && *props == (bucketProps{}) {
+		delete(m.Props, b)
+	}
+	m.Version++
+}
+
+// setcopies sets the number of replicas for a given (local or Cloud) bucket;
+// one copy (the default) means no replication
+func (m *lbmap) setcopies(b string, copies int) bool {
+	if m.copies(b) == copies {
+		return false
+	}
+	if copies <= 1 {
+		copies = 0
+	}
+	m.mprops(b).Copies = copies
+	m.propsdone(b)
+	return true
+}
+
+func (m *lbmap) copies(b string) int {
+	if props, ok := m.Props[b]; ok && props.Copies > 1 {
+		return props.Copies
+	}
+	return 1
+}
+
+// replicated returns true if any of the buckets has more than one copy
+func (m *lbmap) replicated() bool {
+	for b := range m.Props {
+		if m.copies(b) > 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// setec sets the number of erasure coding data and parity slices for a given local bucket;
+// zeros disable erasure coding
+func (m *lbmap) setec(b string, data, parity int) bool {
+	if d, p := m.ecslices(b); d == data && p == parity {
+		return false
+	}
+	props := m.mprops(b)
+	props.DataSlices, props.ParitySlices = data, parity
+	m.propsdone(b)
+	return true
+}
+
+func (m *lbmap) ecslices(b string) (data, parity int) {
+	if props, ok := m.Props[b]; ok {
+		data, parity = props.DataSlices, props.ParitySlices
+	}
+	return
+}
+
+// erasurecoded returns true if any of the buckets is erasure coded
+func (m *lbmap) erasurecoded() bool {
+	for _, props := range m.Props {
+		if props.DataSlices > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// setprops sets the bucket's checksum, versioning, LRU, and ack policies (validated by the caller)
+func (m *lbmap) setprops(b string, bprops BucketProps) bool {
+	if props, ok := m.Props[b]; ok && props.BucketProps == bprops {
+		return false
+	} else if !ok && bprops == (BucketProps{}) {
+		return false
+	}
+	m.mprops(b).BucketProps = bprops
+	m.propsdone(b)
+	return true
+}
+
+func (m *lbmap) bprops(b string) (bprops BucketProps) {
+	if props, ok := m.Props[b]; ok {
+		bprops = props.BucketProps
+	}
+	return
+}
+
+// cksumconf, versionconf, lruconf, and ackput return the bucket's effective configuration:
+// the global config overridden by the bucket's properties, if any
+func (m *lbmap) cksumconf(b string) *cksumconfig {
+	bprops, conf := m.bprops(b), ctx.config.CksumConfig
+	if bprops.Checksum != "" {
+		conf.Checksum = bprops.Checksum
+	}
+	if bprops.ValidateColdGet != "" {
+		conf.ValidateColdGet = bprops.ValidateColdGet == "true"
+	}
+	return &conf
+}
+
+func (m *lbmap) versionconf(b string) *versionconfig {
+	bprops, conf := m.bprops(b), ctx.config.VersionConfig
+	if bprops.ValidateWarmGet != "" {
+		conf.ValidateWarmGet = bprops.ValidateWarmGet == "true"
+	}
+	return &conf
+}
+
+func (m *lbmap) lruconf(b string) (enabled bool, dontevict time.Duration) {
+	bprops := m.bprops(b)
+	enabled, dontevict = ctx.config.LRUConfig.LRUEnabled, ctx.config.LRUConfig.DontEvictTime
+	if bprops.LRUEnabled != "" {
+		enabled = bprops.LRUEnabled == "true"
+	}
+	if bprops.DontEvictTime != "" {
+		if d, err := time.ParseDuration(bprops.DontEvictTime); err == nil {
+			dontevict = d
+		}
+	}
+	return
+}
+
+func (m *lbmap) versioning(b string) bool {
+	return m.bprops(b).Versioning == "true"
+}
+
+// lruenabled returns true if LRU is enabled globally or for any bucket
+func (m *lbmap) lruenabled() bool {
+	if ctx.config.LRUConfig.LRUEnabled {
+		return true
+	}
+	for _, props := range m.Props {
+		if props.LRUEnabled == "true" {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *lbmap) ackput(b string) string {
+	if bprops := m.bprops(b); bprops.AckPut != "" {
+		return bprops.AckPut
+	}
+	return ctx.config.AckPolicy.Put
+}
+
+// effprops returns all of the above, e.g. for HEAD(bucket)
+func (m *lbmap) effprops(b string) BucketProps {
+	cksumcfg, versioncfg := m.cksumconf(b), m.versionconf(b)
+	enabled, dontevict := m.lruconf(b)
+	return BucketProps{
+		Checksum:        cksumcfg.Checksum,
+		ValidateColdGet: strconv.FormatBool(cksumcfg.ValidateColdGet),
+		ValidateWarmGet: strconv.FormatBool(versioncfg.ValidateWarmGet),
+		LRUEnabled:      strconv.FormatBool(enabled),
+		DontEvictTime:   dontevict.String(),
+		AckPut:          m.ackput(b),
+		Versioning:      strconv.FormatBool(m.versioning(b)),
+	}
+}
+
 func (m *lbmap) version() int64 {
 	return m.Version
 }
@@ -284,22 +523,26 @@ func dfcinit() {
 	}
 	assert(clivars.role == xproxy || clivars.role == xtarget, "Invalid flag: role="+clivars.role)
 	if clivars.role == xproxy {
-		if clivars.ntargets <= 0 {
+		if clivars.ntargets <= 0 && !ctx.config.Proxy.Standby {
 			glog.Fatalf("Unspecified or invalid number (%d) of storage targets (a hint for the http proxy)",
 				clivars.ntargets)
 		}
 		confdir := filepath.Dir(clivars.conffile)
-		ctx.smap = &Smap{Smap: make(map[string]*daemonInfo, 8)}
+		ctx.smap = &Smap{Smap: make(map[string]*daemonInfo, 8), Pmap: make(map[string]*daemonInfo, 4)}
 		p := &proxyrunner{confdir: confdir}
 		ctx.rg.add(p, xproxy)
-		ctx.rg.add(&proxystatsrunner{}, xproxystats)
+		ctx.rg.add(&proxystatsrunner{Latency: newlatencies(proxyLatencyOps)}, xproxystats)
 		ctx.rg.add(newproxykalive(p), xproxykalive)
+		ctx.rg.add(newmetasyncer(p), xmetasyncer)
 	} else {
 		t := &targetrunner{}
 		ctx.rg.add(t, xtarget)
-		ctx.rg.add(&storstatsrunner{}, xstorstats)
+		ctx.rg.add(&storstatsrunner{Latency: newlatencies(targetLatencyOps)}, xstorstats)
 		ctx.rg.add(newtargetkalive(t), xtargetkalive)
 	}
+	if ctx.config.Trace.Enabled {
+		ctx.rg.add(newtracerunner(), xtracer)
+	}
 	ctx.rg.add(&sigrunner{}, xsignal)
 }
 
@@ -355,6 +598,13 @@ func getproxykalive() *proxykalive {
 	return rr
 }
 
+func getmetasyncer() *metasyncer {
+	r := ctx.rg.runmap[xmetasyncer]
+	rr, ok := r.(*metasyncer)
+	assert(ok)
+	return rr
+}
+
 func gettarget() *targetrunner {
 	r := ctx.rg.runmap[xtarget]
 	rr, ok := r.(*targetrunner)
@@ -381,9 +631,12 @@ func getstorstats() *targetCoreStats {
 	return &rr.Core
 }
 
-func getcloudif() cloudif {
-	r := ctx.rg.runmap[xtarget]
-	rr, ok := r.(*targetrunner)
+func gettracerunner() *tracerunner {
+	r, ok := ctx.rg.runmap[xtracer]
+	if !ok {
+		return nil // tracing disabled
+	}
+	rr, ok := r.(*tracerunner)
 	assert(ok)
-	return rr.cloudif
+	return rr
 }
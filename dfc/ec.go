@@ -0,0 +1,663 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/klauspost/reedsolomon"
+)
+
+// Erasure coding (local buckets only):
+// - once PUT, the object stays in its entirety at its HRW target (the "primary") and, in addition,
+//   gets split into (data + parity) Reed-Solomon slices stored on the top (data + parity) HRW targets,
+//   one slice per target - see hrwTargets;
+// - a slice is stored under mpath/ec/bucket/objname, with its metadata in the xattrECMeta;
+// - GET of an object that is missing at its primary restores the object from any data-count
+//   slices found in the cluster (degraded read);
+// - when targets join or leave the cluster, the ecrepair xaction restores missing objects and slices.
+const (
+	ecDir       = "ec"
+	ecTmpSuffix = ".ectmp"
+	xattrECMeta = "user.obj.ecmeta"
+)
+
+type ecmeta struct {
+	Size   int64  `json:"size"`   // object size
+	Data   int    `json:"data"`   // number of data slices
+	Parity int    `json:"parity"` // number of parity slices
+	Idx    int    `json:"idx"`    // this slice: [0, data) - data, [data, data+parity) - parity
+	Cksum  string `json:"cksum"`  // object checksum (xxhash), if available
+}
+
+// slices of the same object (modulo the index)
+func (meta *ecmeta) matches(other *ecmeta) bool {
+	return meta.Size == other.Size && meta.Data == other.Data && meta.Parity == other.Parity &&
+		meta.Cksum == other.Cksum
+}
+
+func ecslicefqn(bucket, objname string) string {
+	return filepath.Join(hrwMpath(bucket+"/"+objname), ecDir, bucket, objname)
+}
+
+func ectmpfqn(bucket, objname string, idx int) string {
+	return fmt.Sprintf("%s.%d.%d%s", ecslicefqn(bucket, objname), idx, time.Now().UnixNano(), ecTmpSuffix)
+}
+
+func ecsliceurl(si *daemonInfo, bucket, objname string) string {
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	return url + fmt.Sprintf("?%s=true", ParamECSlice)
+}
+
+func ecreadmeta(fqn string) (meta *ecmeta, errstr string) {
+	jsbytes, errstr := Getxattr(fqn, xattrECMeta)
+	if errstr != "" {
+		return
+	}
+	if jsbytes == nil {
+		errstr = fmt.Sprintf("%s is not an erasure coded slice", fqn)
+		return
+	}
+	meta = &ecmeta{}
+	if err := json.Unmarshal(jsbytes, meta); err != nil {
+		meta, errstr = nil, fmt.Sprintf("Failed to unmarshal %s metadata, err: %v", fqn, err)
+	}
+	return
+}
+
+func (t *targetrunner) ecenabled(bucket string) bool {
+	data, _ := t.lbmap.ecslices(bucket)
+	return data > 0 && t.islocalBucket(bucket)
+}
+
+//==============================================================
+//
+// encode and distribute the slices
+//
+//==============================================================
+// ecupdate erasure codes the (locally stored) object anew;
+// failures are not fatal - ecrepair will restore the slices
+func (t *targetrunner) ecupdate(bucket, objname string) {
+	if errstr := t.ecencode(bucket, objname); errstr != "" {
+		glog.Errorln(errstr)
+	}
+}
+
+func (t *targetrunner) ecencode(bucket, objname string) (errstr string) {
+	data, parity := t.lbmap.ecslices(bucket)
+	sis, errstr := hrwTargets(bucket+"/"+objname, t.smap, data+parity)
+	if errstr != "" {
+		return
+	}
+	if len(sis) < data+parity {
+		return fmt.Sprintf("Cannot erasure code %s/%s: %d targets for %d data and %d parity slices",
+			bucket, objname, len(sis), data, parity)
+	}
+	fqn := t.fqn(bucket, objname)
+	file, err := os.Open(fqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to open %s, err: %v", fqn, err)
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		return fmt.Sprintf("Failed to fstat %s, err: %v", fqn, err)
+	}
+	if finfo.Size() == 0 {
+		return // nothing to encode
+	}
+	meta := &ecmeta{Size: finfo.Size(), Data: data, Parity: parity}
+	if hash, errs := Getxattr(fqn, xattrXXHashVal); errs == "" && hash != nil {
+		meta.Cksum = string(hash)
+	}
+	enc, err := reedsolomon.NewStream(data, parity)
+	if err != nil {
+		return fmt.Sprintf("Failed to create %d/%d encoder, err: %v", data, parity, err)
+	}
+	// slices => local temp files first
+	tmpfqns := make([]string, data+parity)
+	files := make([]*os.File, data+parity)
+	writers := make([]io.Writer, data+parity)
+	defer func() {
+		for i, f := range files {
+			if f != nil {
+				f.Close()
+				os.Remove(tmpfqns[i])
+			}
+		}
+	}()
+	for i := range files {
+		tmpfqns[i] = ectmpfqn(bucket, objname, i)
+		if files[i], err = CreateFile(tmpfqns[i]); err != nil {
+			return fmt.Sprintf("Failed to create %s, err: %v", tmpfqns[i], err)
+		}
+		writers[i] = files[i]
+	}
+	if err = enc.Split(file, writers[:data], meta.Size); err != nil {
+		return fmt.Sprintf("Failed to split %s/%s into %d slices, err: %v", bucket, objname, data, err)
+	}
+	readers := make([]io.Reader, data)
+	for i := 0; i < data; i++ {
+		if _, err = files[i].Seek(0, io.SeekStart); err != nil {
+			return fmt.Sprintf("Unexpected fseek failure %s, err: %v", tmpfqns[i], err)
+		}
+		readers[i] = files[i]
+	}
+	if err = enc.Encode(readers, writers[data:]); err != nil {
+		return fmt.Sprintf("Failed to encode %s/%s, err: %v", bucket, objname, err)
+	}
+	// distribute
+	for i, si := range sis {
+		meta.Idx = i
+		if _, err = files[i].Seek(0, io.SeekStart); err != nil {
+			return fmt.Sprintf("Unexpected fseek failure %s, err: %v", tmpfqns[i], err)
+		}
+		if si.DaemonID == t.si.DaemonID {
+			errstr = t.ecstoreslice(tmpfqns[i], bucket, objname, meta)
+		} else {
+			errstr = t.ecsendslice(si, files[i], bucket, objname, meta)
+		}
+		if errstr != "" {
+			return
+		}
+	}
+	if glog.V(3) {
+		glog.Infof("Erasure coded %s/%s: %d data and %d parity slices", bucket, objname, data, parity)
+	}
+	return
+}
+
+func (t *targetrunner) ecstoreslice(tmpfqn, bucket, objname string, meta *ecmeta) (errstr string) {
+	jsbytes, err := json.Marshal(meta)
+	assert(err == nil, err)
+	if errstr = Setxattr(tmpfqn, xattrECMeta, jsbytes); errstr != "" {
+		return
+	}
+	fqn := ecslicefqn(bucket, objname)
+	if err = os.Rename(tmpfqn, fqn); err != nil {
+		errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", tmpfqn, fqn, err)
+	}
+	return
+}
+
+func (t *targetrunner) ecsendslice(si *daemonInfo, reader io.Reader, bucket, objname string, meta *ecmeta) string {
+	jsbytes, err := json.Marshal(meta)
+	assert(err == nil, err)
+	url := ecsliceurl(si, bucket, objname)
+	request, err := http.NewRequest(http.MethodPut, url, reader)
+	if err != nil {
+		return fmt.Sprintf("Unexpected failure to create PUT request %s, err: %v", url, err)
+	}
+	request.Header.Set(HeaderDfcECMeta, string(jsbytes))
+	response, err := t.httpclient.Do(request)
+	if err != nil {
+		return fmt.Sprintf("Failed to send slice %d of %s/%s to %s, err: %v", meta.Idx, bucket, objname, si.DaemonID, err)
+	}
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Sprintf("Failed to send slice %d of %s/%s to %s: %s", meta.Idx, bucket, objname, si.DaemonID, response.Status)
+	}
+	return ""
+}
+
+// ecdelslices removes the object's slices from their holders (see ecencode)
+func (t *targetrunner) ecdelslices(bucket, objname string) {
+	data, parity := t.lbmap.ecslices(bucket)
+	if data == 0 {
+		return
+	}
+	sis, errstr := hrwTargets(bucket+"/"+objname, t.smap, data+parity)
+	if errstr != "" {
+		glog.Errorf("Failed to delete slices of %s/%s: %s", bucket, objname, errstr)
+		return
+	}
+	for _, si := range sis {
+		t.ecremoveslice(si, bucket, objname)
+	}
+}
+
+// ecdelstale removes the object's slices from all targets except the current holders -
+// the slices that were left behind when the cluster map or the bucket's EC config changed
+func (t *targetrunner) ecdelstale(bucket, objname string, holders []*daemonInfo) {
+outer:
+	for id, si := range t.smap.Smap {
+		for _, e := range holders {
+			if e.DaemonID == id {
+				continue outer
+			}
+		}
+		t.ecremoveslice(si, bucket, objname)
+	}
+}
+
+func (t *targetrunner) ecremoveslice(si *daemonInfo, bucket, objname string) {
+	if si.DaemonID == t.si.DaemonID {
+		fqn := ecslicefqn(bucket, objname)
+		if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+			glog.Errorf("Failed to delete slice %s, err: %v", fqn, err)
+		}
+		return
+	}
+	if _, err, errstr, _ := t.call(si, ecsliceurl(si, bucket, objname), http.MethodDelete, nil); err != nil {
+		glog.Errorf("Failed to delete slice of %s/%s at %s: %s", bucket, objname, si.DaemonID, errstr)
+	}
+}
+
+//==============================================================
+//
+// restore the object from its slices (degraded read);
+// the caller must hold the exclusive lock on the object's name
+//
+//==============================================================
+func (t *targetrunner) ecrestore(bucket, objname, fqn string) (errstr string) {
+	var (
+		meta    *ecmeta
+		slices  = make(map[int]*os.File)
+		tmpfqns []string
+	)
+	defer func() {
+		for _, f := range slices {
+			f.Close()
+		}
+		for _, tmpfqn := range tmpfqns {
+			os.Remove(tmpfqn)
+		}
+	}()
+	// the slices are most likely to be found at the top of the HRW list but may, in fact, be anywhere
+	sis, errstr := hrwTargets(bucket+"/"+objname, t.smap, t.smap.count())
+	if errstr != "" {
+		return
+	}
+	for _, si := range sis {
+		if meta != nil && len(slices) >= meta.Data {
+			break
+		}
+		var (
+			smeta *ecmeta
+			file  *os.File
+			err   error
+		)
+		if si.DaemonID == t.si.DaemonID {
+			sfqn := ecslicefqn(bucket, objname)
+			if smeta, errstr = ecreadmeta(sfqn); errstr != "" {
+				continue
+			}
+			if file, err = os.Open(sfqn); err != nil {
+				continue
+			}
+		} else {
+			var tmpfqn string
+			if smeta, file, tmpfqn = t.ecfetchslice(si, bucket, objname); file == nil {
+				continue
+			}
+			tmpfqns = append(tmpfqns, tmpfqn)
+		}
+		if meta == nil {
+			meta = smeta
+		}
+		if _, ok := slices[smeta.Idx]; ok || !meta.matches(smeta) {
+			file.Close()
+			continue
+		}
+		slices[smeta.Idx] = file
+	}
+	if meta == nil || len(slices) < meta.Data {
+		return fmt.Sprintf("Cannot restore %s/%s: not enough slices (found %d)", bucket, objname, len(slices))
+	}
+	enc, err := reedsolomon.NewStream(meta.Data, meta.Parity)
+	if err != nil {
+		return fmt.Sprintf("Failed to create %d/%d decoder, err: %v", meta.Data, meta.Parity, err)
+	}
+	// reconstruct the missing data slices (if any)
+	var (
+		valid  = make([]io.Reader, meta.Data+meta.Parity)
+		fill   = make([]io.Writer, meta.Data+meta.Parity)
+		filled = make(map[int]*os.File)
+	)
+	for idx, file := range slices {
+		valid[idx] = file
+	}
+	for i := 0; i < meta.Data; i++ {
+		if valid[i] != nil {
+			continue
+		}
+		tmpfqn := ectmpfqn(bucket, objname, i)
+		file, err := CreateFile(tmpfqn)
+		if err != nil {
+			return fmt.Sprintf("Failed to create %s, err: %v", tmpfqn, err)
+		}
+		tmpfqns = append(tmpfqns, tmpfqn)
+		filled[i], fill[i] = file, file
+	}
+	if len(filled) > 0 {
+		err = enc.Reconstruct(valid, fill)
+		for i, file := range filled {
+			slices[i] = file // to close
+		}
+		if err != nil {
+			return fmt.Sprintf("Failed to reconstruct %s/%s, err: %v", bucket, objname, err)
+		}
+	}
+	// join the data slices
+	readers := make([]io.Reader, meta.Data)
+	for i := 0; i < meta.Data; i++ {
+		if _, err = slices[i].Seek(0, io.SeekStart); err != nil {
+			return fmt.Sprintf("Unexpected fseek failure (slice %d of %s/%s), err: %v", i, bucket, objname, err)
+		}
+		readers[i] = slices[i]
+	}
+	var (
+		ohobj  = newcksumvalue(ChecksumXXHash, meta.Cksum)
+		getfqn = fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
+		pr, pw = io.Pipe()
+	)
+	go func() {
+		pw.CloseWithError(enc.Join(pw, readers, meta.Size))
+	}()
+	_, nhobj, _, errstr := t.receive(getfqn, false, bucket, objname, "", ohobj, pr)
+	pr.Close()
+	if errstr == "" {
+		errstr = finalizeobj(getfqn, nhobj)
+	}
+	if errstr == "" {
+		if err = os.Rename(getfqn, fqn); err != nil {
+			errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", getfqn, fqn, err)
+		}
+	}
+	if errstr != "" {
+		os.Remove(getfqn)
+		return
+	}
+	glog.Infof("Restored %s/%s from %d slices (%d reconstructed)", bucket, objname, meta.Data, len(filled))
+	t.statsif.add("numecrestored", 1)
+	return
+}
+
+func (t *targetrunner) ecfetchslice(si *daemonInfo, bucket, objname string) (meta *ecmeta, file *os.File, tmpfqn string) {
+	response, err := t.httpclient.Get(ecsliceurl(si, bucket, objname))
+	if err != nil {
+		glog.Errorf("Failed to get slice of %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		return
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return
+	}
+	meta = &ecmeta{}
+	if err = json.Unmarshal([]byte(response.Header.Get(HeaderDfcECMeta)), meta); err != nil {
+		glog.Errorf("Invalid slice metadata of %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		return
+	}
+	tmpfqn = ectmpfqn(bucket, objname, meta.Idx)
+	if file, err = CreateFile(tmpfqn); err != nil {
+		glog.Errorf("Failed to create %s, err: %v", tmpfqn, err)
+		return
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	_, err = io.CopyBuffer(file, response.Body, buf)
+	slab.free(buf)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		glog.Errorf("Failed to receive slice of %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		file.Close()
+		os.Remove(tmpfqn)
+		file = nil
+	}
+	return
+}
+
+//==============================================================
+//
+// repair
+//
+//==============================================================
+
+// ecrepairobj (executed by the object's primary) restores the object if missing,
+// and then re-encodes it if any of its slices is missing or stale
+func (t *targetrunner) ecrepairobj(bucket, objname string) (errstr string) {
+	if !t.ecenabled(bucket) {
+		return
+	}
+	data, parity := t.lbmap.ecslices(bucket)
+	sis, errstr := hrwTargets(bucket+"/"+objname, t.smap, data+parity)
+	if errstr != "" {
+		return
+	}
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, true)
+
+	finfo, err := os.Stat(fqn)
+	if err != nil && os.IsNotExist(err) {
+		if errstr = t.ecrestore(bucket, objname, fqn); errstr != "" {
+			return
+		}
+		finfo, err = os.Stat(fqn)
+	}
+	if err != nil {
+		return fmt.Sprintf("Failed to fstat %s, err: %v", fqn, err)
+	}
+	expected := &ecmeta{Size: finfo.Size(), Data: data, Parity: parity}
+	if hash, errs := Getxattr(fqn, xattrXXHashVal); errs == "" && hash != nil {
+		expected.Cksum = string(hash)
+	}
+	for i, si := range sis {
+		var meta *ecmeta
+		if si.DaemonID == t.si.DaemonID {
+			meta, _ = ecreadmeta(ecslicefqn(bucket, objname))
+		} else {
+			meta = t.echeadslice(si, bucket, objname)
+		}
+		if meta != nil && meta.Idx == i && meta.matches(expected) {
+			continue
+		}
+		glog.Infof("Repairing %s/%s: slice %d at %s is missing or stale", bucket, objname, i, si.DaemonID)
+		if errstr = t.ecencode(bucket, objname); errstr == "" {
+			t.ecdelstale(bucket, objname, sis)
+		}
+		return
+	}
+	return
+}
+
+func (t *targetrunner) echeadslice(si *daemonInfo, bucket, objname string) (meta *ecmeta) {
+	response, err := t.httpclient.Head(ecsliceurl(si, bucket, objname))
+	if err != nil {
+		return
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return
+	}
+	meta = &ecmeta{}
+	if err = json.Unmarshal([]byte(response.Header.Get(HeaderDfcECMeta)), meta); err != nil {
+		meta = nil
+	}
+	return
+}
+
+// ecrepairreq asks the object's primary to repair it
+func (t *targetrunner) ecrepairreq(si *daemonInfo, bucket, objname string) (errstr string) {
+	jsbytes, err := json.Marshal(&ActionMsg{Action: ActECRepair})
+	assert(err == nil, err)
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	_, err, errstr, _ = t.call(si, url, http.MethodPost, jsbytes)
+	return
+}
+
+// runECRepair traverses local slices and local objects of the erasure coded buckets:
+// the primaries repair their objects, the rest of the slice holders request the repair
+func (t *targetrunner) runECRepair() {
+	xrep := t.xactinp.renewECRepair(t.smap.Version, t)
+	if xrep == nil {
+		return
+	}
+	glog.Infoln(xrep.tostring())
+	visited := make(map[string]bool)
+	for mpath := range ctx.mountpaths {
+		if aborted := t.oneECRepair(filepath.Join(mpath, ecDir), true, visited, xrep); aborted {
+			break
+		}
+		if aborted := t.oneECRepair(filepath.Join(mpath, ctx.config.LocalBuckets), false, visited, xrep); aborted {
+			break
+		}
+	}
+	xrep.etime = time.Now()
+	glog.Infoln(xrep.tostring())
+	t.xactinp.del(xrep.id)
+}
+
+func (t *targetrunner) oneECRepair(dir string, slices bool, visited map[string]bool, xrep *xactECRepair) bool {
+	walkfn := func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if osfi.IsDir() || strings.HasSuffix(fqn, ecTmpSuffix) {
+			return nil
+		}
+		select {
+		case <-xrep.abrt:
+			return fmt.Errorf("%s aborted, exiting", xrep.tostring())
+		default:
+		}
+		rel, err := filepath.Rel(dir, fqn)
+		if err != nil {
+			return err
+		}
+		items := strings.SplitN(filepath.ToSlash(rel), "/", 2)
+		if len(items) < 2 {
+			return nil
+		}
+		bucket, objname := items[0], items[1]
+		if visited[bucket+"/"+objname] {
+			return nil
+		}
+		visited[bucket+"/"+objname] = true
+		xrep.progress(1, osfi.Size())
+		if !t.ecenabled(bucket) {
+			if slices {
+				glog.Infof("Removing slice %s: %s is not erasure coded", fqn, bucket)
+				os.Remove(fqn)
+			}
+			return nil
+		}
+		si, errstr := hrwTarget(bucket+"/"+objname, t.smap)
+		if errstr != "" {
+			return fmt.Errorf(errstr)
+		}
+		if si.DaemonID == t.si.DaemonID {
+			errstr = t.ecrepairobj(bucket, objname)
+		} else if slices {
+			errstr = t.ecrepairreq(si, bucket, objname)
+		}
+		if errstr != "" {
+			glog.Errorf("Failed to repair %s/%s: %s", bucket, objname, errstr)
+		}
+		return nil
+	}
+	if err := filepath.Walk(dir, walkfn); err != nil {
+		glog.Errorf("Failed to traverse %q, err: %v", dir, err)
+		return true
+	}
+	return false
+}
+
+// ecrename removes the old name's slices and has the new name's primary encode the object
+func (t *targetrunner) ecrename(bucket, objname, newobjname string, primary *daemonInfo) {
+	t.ecdelslices(bucket, objname)
+	var errstr string
+	if primary.DaemonID == t.si.DaemonID {
+		errstr = t.ecrepairobj(bucket, newobjname)
+	} else {
+		errstr = t.ecrepairreq(primary, bucket, newobjname)
+	}
+	if errstr != "" {
+		glog.Errorf("Failed to erasure code %s/%s: %s", bucket, newobjname, errstr)
+	}
+}
+
+//==============================================================
+//
+// target-to-target handlers (ParamECSlice=true)
+//
+//==============================================================
+func (t *targetrunner) ecputslice(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	meta := &ecmeta{}
+	if err := json.Unmarshal([]byte(r.Header.Get(HeaderDfcECMeta)), meta); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Invalid slice metadata of %s/%s, err: %v", bucket, objname, err))
+		return
+	}
+	tmpfqn := ectmpfqn(bucket, objname, meta.Idx)
+	if _, _, _, errstr := t.receive(tmpfqn, false, bucket, objname, "", nil, r.Body); errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	if errstr := t.ecstoreslice(tmpfqn, bucket, objname, meta); errstr != "" {
+		os.Remove(tmpfqn)
+		t.invalmsghdlr(w, r, errstr)
+	}
+}
+
+func (t *targetrunner) ecgetslice(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn := ecslicefqn(bucket, objname)
+	meta, errstr := ecreadmeta(fqn)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
+	jsbytes, err := json.Marshal(meta)
+	assert(err == nil, err)
+	w.Header().Set(HeaderDfcECMeta, string(jsbytes))
+	if r.Method == http.MethodHead {
+		return
+	}
+	file, err := os.Open(fqn)
+	if err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to open %s, err: %v", fqn, err), http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	if _, err = io.CopyBuffer(w, file, buf); err != nil {
+		glog.Errorf("Failed to send slice %s, err: %v", fqn, err)
+	}
+}
+
+func (t *targetrunner) ecdelslice(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn := ecslicefqn(bucket, objname)
+	if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to delete slice %s, err: %v", fqn, err))
+	}
+}
+
+func (t *targetrunner) ecrepair(w http.ResponseWriter, r *http.Request) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Rfiles); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	if errstr := t.ecrepairobj(bucket, objname); errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+	}
+}
@@ -0,0 +1,233 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// highly available proxy: one of the cluster's proxies is the primary (Smap.ProxySI),
+// the rest are standbys (all of them are listed in Smap.Pmap):
+// - standbys join the primary at the configured proxy URL and then receive Smap and lbmap
+//   updates along with the targets;
+// - the primary keeps alive targets and standbys, while standbys keep alive the primary (see kalive);
+// - when the primary fails, standbys elect the new one: the first live proxy in the HRW order
+//   of proxy IDs (see hrwProxies) - given the same Smap, every standby makes the same choice;
+// - the new primary broadcasts the new Smap, targets then re-point to it;
+// - standbys redirect client requests to the primary
+//
+//======
+
+func (p *proxyrunner) isprimary() bool {
+	primary := ctx.smap.ProxySI
+	return primary != nil && primary.DaemonID == p.si.DaemonID
+}
+
+// primaryurl returns the primary's URL as per the current Smap or, if not known yet, the configured one
+func (p *proxyrunner) primaryurl() string {
+	if primary := ctx.smap.ProxySI; primary != nil {
+		return primary.DirectURL
+	}
+	return ctx.config.Proxy.URL
+}
+
+// standby registration (timeout == 0) or keepalive with the primary
+func (p *proxyrunner) register(timeout time.Duration) (status int, err error) {
+	jsbytes, err := json.Marshal(p.si)
+	if err != nil {
+		return 0, fmt.Errorf("Unexpected failure to json-marshal %+v, err: %v", p.si, err)
+	}
+	url := p.primaryurl() + "/" + Rversion + "/" + Rcluster + "/" + Rproxy
+	if timeout > 0 {
+		url += "/" + Rkeepalive
+		_, err, _, status = p.call(ctx.smap.ProxySI, url, http.MethodPost, jsbytes, timeout)
+	} else {
+		_, err, _, status = p.call(ctx.smap.ProxySI, url, http.MethodPost, jsbytes)
+	}
+	return
+}
+
+func (p *proxyrunner) unregister() (status int, err error) {
+	url := p.primaryurl() + "/" + Rversion + "/" + Rcluster + "/" + Rdaemon + "/" + p.si.DaemonID
+	_, err, _, status = p.call(ctx.smap.ProxySI, url, http.MethodDelete, nil)
+	return
+}
+
+func (p *proxyrunner) join() error {
+	status, err := p.register(0)
+	if err != nil && (IsErrConnectionRefused(err) || status == http.StatusRequestTimeout) {
+		glog.Errorf("Standby proxy %s: retrying registration...", p.si.DaemonID)
+		time.Sleep(time.Second * 3)
+		_, err = p.register(0)
+	}
+	if err != nil {
+		glog.Errorf("Standby proxy %s failed to join the primary at %s, err: %v", p.si.DaemonID, p.primaryurl(), err)
+		return err
+	}
+	glog.Infof("Standby proxy %s joined the primary at %s", p.si.DaemonID, p.primaryurl())
+	return nil
+}
+
+// redirectprimary redirects client requests to the primary (returns false if this proxy is the primary)
+func (p *proxyrunner) redirectprimary(w http.ResponseWriter, r *http.Request) bool {
+	if p.isprimary() {
+		return false
+	}
+	primary := ctx.smap.ProxySI
+	if primary == nil {
+		p.invalmsghdlr(w, r, "Standby proxy has not joined the cluster yet", http.StatusServiceUnavailable)
+		return true
+	}
+	redirecturl := primary.DirectURL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		redirecturl += "?" + r.URL.RawQuery
+	}
+	if glog.V(3) {
+		glog.Infof("Redirecting %q to the primary %s (%s)", r.URL.Path, primary.DaemonID, r.Method)
+	}
+	p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+	return true
+}
+
+//==============================
+//
+// primary
+//
+//==============================
+
+// register|keepalive standby proxy
+func (p *proxyrunner) registerproxy(w http.ResponseWriter, r *http.Request, keepalive bool) {
+	var nsi daemonInfo
+	if p.readJSON(w, r, &nsi) != nil {
+		return
+	}
+	if net.ParseIP(nsi.NodeIPAddr) == nil {
+		s := fmt.Sprintf("register proxy %s: invalid IP address %v", nsi.DaemonID, nsi.NodeIPAddr)
+		p.invalmsghdlr(w, r, s)
+		return
+	}
+	p.statsif.add("numpost", 1)
+	ctx.smap.lock()
+	osi := ctx.smap.Pmap[nsi.DaemonID]
+	if keepalive && osi != nil && osi.DirectURL == nsi.DirectURL {
+		ctx.smap.unlock()
+		p.kalive.timestamp(nsi.DaemonID)
+		return
+	}
+	if keepalive {
+		glog.Warningf("register/keepalive proxy %s: adding back to the cluster map", nsi.DaemonID)
+	} else {
+		glog.Infof("register standby proxy %s", nsi.DaemonID)
+	}
+	ctx.smap.addproxy(&nsi)
+	p.savesmapconf()
+	ctx.smap.unlock()
+	go p.synchronizeMaps(0, "")
+}
+
+//==============================
+//
+// standby
+//
+//==============================
+
+// elect is executed by a standby upon the primary's failure
+func (p *proxyrunner) elect(failed *daemonInfo) {
+	jsbytes, err := json.Marshal(&GetMsg{GetWhat: GetWhatSmap})
+	assert(err == nil, err)
+	for _, si := range hrwProxies(ctx.smap, failed.DaemonID) {
+		if si.DaemonID == p.si.DaemonID {
+			p.becomeprimary(failed)
+			return
+		}
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		if _, err, _, _ := p.call(si, url, http.MethodGet, jsbytes, kalivetimeout); err == nil {
+			glog.Infof("Proxy %s: %s is the new primary", p.si.DaemonID, si.DaemonID)
+			return
+		}
+	}
+	glog.Errorf("Proxy %s: failed to elect the new primary", p.si.DaemonID)
+}
+
+func (p *proxyrunner) becomeprimary(failed *daemonInfo) {
+	ctx.smap.lock()
+	if ctx.smap.ProxySI == nil || ctx.smap.ProxySI.DaemonID != failed.DaemonID {
+		ctx.smap.unlock()
+		return // has changed in the meantime
+	}
+	ctx.smap.delproxy(failed.DaemonID)
+	ctx.smap.ProxySI = p.si
+	version := ctx.smap.Version
+	p.savesmapconf()
+	ctx.smap.unlock()
+	glog.Infof("Proxy %s: taking over as the primary (failed primary %s, Smap v%d)", p.si.DaemonID, failed.DaemonID, version)
+	go p.synchronizeMaps(0, "")
+}
+
+// PUT '{Smap}' /v1/daemon/(syncsmap|rebalance)
+func (p *proxyrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request) {
+	newsmap := &Smap{}
+	if p.readJSON(w, r, newsmap) != nil {
+		return
+	}
+	defer p.metaack(w, r)
+	ctx.smap.lock()
+	curversion := ctx.smap.Version
+	if newsmap.Version <= curversion {
+		ctx.smap.unlock()
+		if newsmap.Version < curversion {
+			glog.Errorf("Warning: attempt to downgrade Smap verion %d to %d", curversion, newsmap.Version)
+		}
+		return
+	}
+	wasprimary := p.isprimary()
+	ctx.smap.Smap, ctx.smap.Pmap, ctx.smap.ProxySI = newsmap.Smap, newsmap.Pmap, newsmap.ProxySI
+	ctx.smap.Version, ctx.smap.syncversion = newsmap.Version, newsmap.Version
+	p.savesmapconf()
+	ctx.smap.unlock()
+	primary := "<none>"
+	if newsmap.ProxySI != nil {
+		primary = newsmap.ProxySI.DaemonID
+	}
+	glog.Infof("Proxy %s: new Smap version %d (old %d), primary %s", p.si.DaemonID, newsmap.Version, curversion, primary)
+	if wasprimary && !p.isprimary() {
+		glog.Warningf("Proxy %s is no longer the primary", p.si.DaemonID)
+	}
+}
+
+// PUT '{lbmap}' /v1/daemon/localbuckets
+func (p *proxyrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request) {
+	newlbmap := newlbmap()
+	if p.readJSON(w, r, newlbmap) != nil {
+		return
+	}
+	defer p.metaack(w, r)
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	if newlbmap.Version <= p.lbmap.Version {
+		return
+	}
+	p.lbmap.LBmap, p.lbmap.CBmap, p.lbmap.Props = newlbmap.LBmap, newlbmap.CBmap, newlbmap.Props
+	p.lbmap.Version, p.lbmap.syncversion = newlbmap.Version, newlbmap.Version
+	if p.lbmap.CBmap == nil {
+		p.lbmap.CBmap = make(map[string]string)
+	}
+	if p.lbmap.Props == nil {
+		p.lbmap.Props = make(map[string]*bucketProps)
+	}
+	lbpathname := p.confdir + "/" + ctx.config.LBConf
+	if err := localSave(lbpathname, p.lbmap); err != nil {
+		glog.Errorf("Failed to store localbucket config %s, err: %v", lbpathname, err)
+	}
+}
@@ -0,0 +1,258 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// eviction policies: the LRU xaction (see lru.go) orders the eviction candidates
+// by the score assigned by the policy of the respective bucket, lowest score first;
+// the policy is configured globally (lru_config.policy) and, optionally, per bucket
+// (lru_config.bucket_policy); each warm or cold GET increments the object's
+// access counter: in memory first (see accesscnts), and then in its xattrs
+// (xattrAccessCnt) when the LRU walk gets to the object; the increments
+// not yet flushed do not survive the restart
+//
+//======
+
+// lru_config.policy enum
+const (
+	EvictLRU = "lru" // least recently used (default)
+	EvictLFU = "lfu" // least frequently used, ties broken by the last use
+	EvictGDS = "gds" // GreedyDual-Size: recency plus the per-byte access credit, small and popular objects stay longer
+	EvictARC = "arc" // adaptive replacement: objects accessed once vs. many times, self-tuning
+)
+
+const (
+	xattrAccessCnt = "user.obj.dfcaccess"
+
+	gdscredit    = time.Hour      // GDS: the recency credit of a single access to a 1MB object
+	gdsmaxcredit = time.Hour * 24 // ditto, max total credit
+	arcstep      = time.Minute * 15
+	arcmaxshift  = time.Hour * 24
+	arcmaxghosts = 64 * 1024
+
+	accessmaxpending = 64 * 1024 // flush all the access counters when that many objects have them pending
+)
+
+type evictpolicy interface {
+	score(fi *fileinfo) float64 // the lowest score gets evicted first
+	evicted(fi *fileinfo)       // notifies of the eviction
+	missed(fqn string)          // notifies of the cold GET
+}
+
+var evictpolicies = map[string]evictpolicy{
+	EvictLRU: &lrupolicy{},
+	EvictLFU: &lfupolicy{},
+	EvictGDS: &gdspolicy{},
+	EvictARC: &arcpolicy{shift: arcmaxshift / 2, ghosts: make(map[string]bool, 64)},
+}
+
+func validatepolicy(policy string) error {
+	if _, ok := evictpolicies[policy]; !ok {
+		return fmt.Errorf("Invalid eviction policy %q - expecting %s, %s, %s, or %s", policy, EvictLRU, EvictLFU, EvictGDS, EvictARC)
+	}
+	return nil
+}
+
+// evictpolicyname returns the name of the policy configured for a given bucket
+func evictpolicyname(bucket string) string {
+	if policy, ok := ctx.config.LRUConfig.BucketPolicy[bucket]; ok {
+		return policy
+	}
+	return ctx.config.LRUConfig.Policy
+}
+
+func evictpolicyfor(bucket string) evictpolicy {
+	if policy, ok := evictpolicies[evictpolicyname(bucket)]; ok {
+		return policy
+	}
+	return evictpolicies[EvictLRU]
+}
+
+//
+// access counters
+//
+type accesscnts struct {
+	sync.Mutex
+	pending map[string]int64 // increments not yet flushed to xattrs, by fqn
+}
+
+var accesses = &accesscnts{pending: make(map[string]int64, 1024)}
+
+func (a *accesscnts) inc(fqn string) {
+	a.Lock()
+	a.pending[fqn]++
+	full := len(a.pending) >= accessmaxpending
+	var pending map[string]int64
+	if full {
+		pending, a.pending = a.pending, make(map[string]int64, 1024)
+	}
+	a.Unlock()
+	if full {
+		go flushaccesscnts(pending)
+	}
+}
+
+// flush writes the pending increments of a given object to its xattrs and returns
+// the resulting counter, or -1 if there was nothing to flush
+func (a *accesscnts) flush(fqn string) int64 {
+	a.Lock()
+	n, ok := a.pending[fqn]
+	delete(a.pending, fqn)
+	a.Unlock()
+	if !ok {
+		return -1
+	}
+	return addaccesscnt(fqn, n)
+}
+
+func flushaccesscnts(pending map[string]int64) {
+	for fqn, n := range pending {
+		if _, err := os.Stat(fqn); err != nil {
+			continue // removed or evicted since
+		}
+		addaccesscnt(fqn, n)
+	}
+}
+
+func addaccesscnt(fqn string, n int64) int64 {
+	cnt := getaccesscnt(fqn) + n
+	if errstr := Setxattr(fqn, xattrAccessCnt, []byte(strconv.FormatInt(cnt, 10))); errstr != "" {
+		glog.Errorln(errstr)
+	}
+	return cnt
+}
+
+func getaccesscnt(fqn string) int64 {
+	b, errstr := Getxattr(fqn, xattrAccessCnt)
+	if errstr != "" || len(b) == 0 {
+		return 0
+	}
+	cnt, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return cnt
+}
+
+// the access counter of an eviction candidate, read once (and before the eviction)
+func (fi *fileinfo) accesscnt() int64 {
+	if fi.naccess < 0 {
+		fi.naccess = getaccesscnt(fi.fqn)
+	}
+	return fi.naccess
+}
+
+// accessed is called upon GET, under the object's (exclusive) name lock
+func (t *targetrunner) accessed(bucket, fqn string, coldget bool) {
+	if coldget {
+		evictpolicyfor(bucket).missed(fqn)
+	}
+	accesses.inc(fqn)
+}
+
+//
+// policies
+//
+func usetimescore(fi *fileinfo) float64 {
+	return float64(fi.usetime.UnixNano()) / float64(time.Second)
+}
+
+type nopnotify struct{}
+
+func (n *nopnotify) evicted(fi *fileinfo) {}
+func (n *nopnotify) missed(fqn string)    {}
+
+type lrupolicy struct {
+	nopnotify
+}
+
+func (p *lrupolicy) score(fi *fileinfo) float64 { return usetimescore(fi) }
+
+type lfupolicy struct {
+	nopnotify
+}
+
+func (p *lfupolicy) score(fi *fileinfo) float64 { return float64(fi.accesscnt()) }
+
+// GreedyDual-Size with the inflation value (aka clock) advancing with time:
+// H = usetime + accesses * cost/size, where the cost is a fixed recency credit per MB
+type gdspolicy struct {
+	nopnotify
+}
+
+func (p *gdspolicy) score(fi *fileinfo) float64 {
+	size := fi.size
+	if size < 4*1024 {
+		size = 4 * 1024
+	}
+	credit := float64(fi.accesscnt()) * gdscredit.Seconds() * float64(1024*1024) / float64(size)
+	if credit > gdsmaxcredit.Seconds() {
+		credit = gdsmaxcredit.Seconds()
+	}
+	return usetimescore(fi) + credit
+}
+
+// ARC-style: objects accessed at most once (the recency list) are aged by the shift
+// relative to those accessed many times (the frequency list); the shift adapts
+// based on the cold GETs of the recently evicted objects (the ghost lists)
+type arcpolicy struct {
+	sync.Mutex
+	shift  time.Duration
+	ghosts map[string]bool // evicted: true - from the frequency list, false - recency
+}
+
+func (p *arcpolicy) score(fi *fileinfo) float64 {
+	score := usetimescore(fi)
+	if fi.accesscnt() > 1 {
+		return score
+	}
+	p.Lock()
+	shift := p.shift
+	p.Unlock()
+	return score - shift.Seconds()
+}
+
+func (p *arcpolicy) evicted(fi *fileinfo) {
+	frequent := fi.accesscnt() > 1
+	p.Lock()
+	if len(p.ghosts) >= arcmaxghosts {
+		p.ghosts = make(map[string]bool, 64)
+	}
+	p.ghosts[fi.fqn] = frequent
+	p.Unlock()
+}
+
+func (p *arcpolicy) missed(fqn string) {
+	p.Lock()
+	defer p.Unlock()
+	frequent, ok := p.ghosts[fqn]
+	if !ok {
+		return
+	}
+	delete(p.ghosts, fqn)
+	if frequent {
+		p.shift += arcstep // favor the frequency list
+		if p.shift > arcmaxshift {
+			p.shift = arcmaxshift
+		}
+	} else {
+		p.shift -= arcstep // favor the recency list
+		if p.shift < 0 {
+			p.shift = 0
+		}
+	}
+}
@@ -0,0 +1,186 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"container/heap"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type evictcandidate struct {
+	name    string
+	age     time.Duration // since the last use
+	size    int64
+	naccess int64
+}
+
+func newarcpolicy() *arcpolicy {
+	return &arcpolicy{shift: arcmaxshift / 2, ghosts: make(map[string]bool, 64)}
+}
+
+// evictorder returns the names in the order the LRU xaction evicts them (see doLRU)
+func evictorder(policy evictpolicy, now time.Time, candidates []evictcandidate) []string {
+	g := newevictgroup(policy, 0, 1<<40)
+	for _, c := range candidates {
+		fi := &fileinfo{fqn: c.name, usetime: now.Add(-c.age), size: c.size, naccess: c.naccess}
+		fi.score = policy.score(fi)
+		g.push(fi)
+	}
+	names := make([]string, 0, len(candidates))
+	for g.h.Len() > 0 {
+		names = append(names, heap.Pop(g.h).(*fileinfo).fqn)
+	}
+	return names
+}
+
+func TestEvictOrder(t *testing.T) {
+	const mb = 1024 * 1024
+	tests := []struct {
+		name       string
+		policy     evictpolicy
+		candidates []evictcandidate
+		expected   []string
+	}{
+		{"lru", &lrupolicy{}, []evictcandidate{
+			{"recent", time.Minute, mb, 100},
+			{"oldest", 3 * time.Hour, mb, 100},
+			{"old", 2 * time.Hour, mb, 0},
+		}, []string{"oldest", "old", "recent"}},
+
+		// the fewest accesses first, ties broken by the last use
+		{"lfu", &lfupolicy{}, []evictcandidate{
+			{"popular", 10 * time.Hour, mb, 50},
+			{"once", time.Minute, mb, 1},
+			{"once-old", time.Hour, mb, 1},
+			{"never", 0, mb, 0},
+			{"few", 5 * time.Hour, mb, 3},
+		}, []string{"never", "once-old", "once", "few", "popular"}},
+
+		// recency plus the credit per access per MB
+		{"gds", &gdspolicy{}, []evictcandidate{
+			{"large-popular", 3 * time.Hour, 100 * mb, 10},               // -3h + 6m
+			{"mb-once", 2 * time.Hour, mb, 1},                            // -2h + 1h
+			{"mb-never", 30 * time.Minute, mb, 0},                        // -30m
+			{"small-once", 10 * time.Hour, 64 * 1024, 1},                 // -10h + 16h
+			{"tiny-popular", 48 * time.Hour, 1024, 1000},                 // -48h + 24h (max credit)
+			{"large-recent", 0, 1024 * mb, 1},                            // -0 + 3.5s
+			{"large-old-popular", 26 * time.Hour, 1024 * mb, 100 * 1024}, // -26h + 24h (max credit)
+		}, []string{"tiny-popular", "large-popular", "large-old-popular", "mb-once", "mb-never", "large-recent", "small-once"}},
+
+		// the objects accessed at most once age by the shift (12h)
+		{"arc", newarcpolicy(), []evictcandidate{
+			{"frequent-old", 10 * time.Hour, mb, 5},
+			{"once-recent", time.Hour, mb, 1},
+			{"never-old", 14 * time.Hour, mb, 0},
+			{"frequent-older", 20 * time.Hour, mb, 2},
+			{"once-new", 0, mb, 1},
+		}, []string{"never-old", "frequent-older", "once-recent", "once-new", "frequent-old"}},
+	}
+	now := time.Now()
+	for _, test := range tests {
+		if names := evictorder(test.policy, now, test.candidates); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: evicted %v, expecting %v", test.name, names, test.expected)
+		}
+	}
+}
+
+func TestEvictARCAdapts(t *testing.T) {
+	var (
+		now        = time.Now()
+		candidates = []evictcandidate{
+			{"frequent", 8 * time.Hour, 1024, 2},
+			{"once", time.Hour, 1024, 1},
+		}
+	)
+	tests := []struct {
+		name     string
+		evicted  evictcandidate
+		misses   int
+		shift    time.Duration
+		expected []string
+	}{
+		// cold GETs of the evicted frequently used objects favor the frequency list...
+		{"frequent-missed", candidates[0], 100, arcmaxshift, []string{"once", "frequent"}},
+		// ...and of the ones used once - the recency list
+		{"once-missed", candidates[1], 100, 0, []string{"frequent", "once"}},
+		{"not-evicted", evictcandidate{name: "other"}, 1, arcmaxshift / 2, []string{"once", "frequent"}},
+	}
+	for _, test := range tests {
+		policy := newarcpolicy()
+		for i := 0; i < test.misses; i++ {
+			fi := &fileinfo{fqn: test.evicted.name, naccess: test.evicted.naccess}
+			policy.evicted(fi)
+			policy.missed(candidates[0].name)
+			policy.missed(candidates[1].name)
+		}
+		if policy.shift != test.shift {
+			t.Errorf("%s: shift %v, expecting %v", test.name, policy.shift, test.shift)
+		}
+		if names := evictorder(policy, now, candidates); !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%s: evicted %v, expecting %v", test.name, names, test.expected)
+		}
+	}
+}
+
+func TestEvictPolicyName(t *testing.T) {
+	saved := ctx.config.LRUConfig
+	defer func() { ctx.config.LRUConfig = saved }()
+	ctx.config.LRUConfig.Policy = EvictLFU
+	ctx.config.LRUConfig.BucketPolicy = map[string]string{"gdsbucket": EvictGDS, "badbucket": "mru"}
+
+	tests := []struct {
+		bucket   string
+		expected evictpolicy
+	}{
+		{"anybucket", evictpolicies[EvictLFU]},
+		{"gdsbucket", evictpolicies[EvictGDS]},
+		{"badbucket", evictpolicies[EvictLRU]},
+	}
+	for _, test := range tests {
+		if policy := evictpolicyfor(test.bucket); policy != test.expected {
+			t.Errorf("%s: policy %T, expecting %T", test.bucket, policy, test.expected)
+		}
+	}
+	if err := validatepolicy("mru"); err == nil {
+		t.Errorf("policy mru: expecting error")
+	}
+}
+
+func TestAccessCnts(t *testing.T) {
+	file, err := ioutil.TempFile("", "access")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fqn := file.Name()
+	file.Close()
+	defer os.Remove(fqn)
+	if errstr := Setxattr(fqn, xattrAccessCnt, []byte("5")); errstr != "" {
+		t.Skipf("xattrs are not supported: %s", errstr)
+	}
+
+	a := &accesscnts{pending: make(map[string]int64)}
+	if cnt := a.flush(fqn); cnt != -1 {
+		t.Errorf("nothing pending: %d, expecting -1", cnt)
+	}
+	for i := 0; i < 3; i++ {
+		a.inc(fqn)
+	}
+	if cnt := getaccesscnt(fqn); cnt != 5 {
+		t.Errorf("before the flush: %d, expecting 5", cnt)
+	}
+	if cnt := a.flush(fqn); cnt != 8 {
+		t.Errorf("flushed: %d, expecting 8", cnt)
+	}
+	if cnt := getaccesscnt(fqn); cnt != 8 {
+		t.Errorf("after the flush: %d, expecting 8", cnt)
+	}
+	if cnt := a.flush(fqn); cnt != -1 {
+		t.Errorf("flushed twice: %d, expecting -1", cnt)
+	}
+}
@@ -86,9 +86,13 @@ func (gcpimpl *gcpimpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte,
 	if msg.GetPageMarker != "" {
 		pageToken = msg.GetPageMarker
 	}
+	pagesize := gcpPageSize
+	if msg.GetPageSize > 0 {
+		pagesize = msg.GetPageSize
+	}
 
 	it := client.Bucket(bucket).Objects(gctx, query)
-	pager := iterator.NewPager(it, gcpPageSize, pageToken)
+	pager := iterator.NewPager(it, pagesize, pageToken)
 	objs := make([]*storage.ObjectAttrs, 0)
 	nextPageToken, err := pager.NextPage(&objs)
 	if err != nil {
@@ -173,6 +177,11 @@ func (gcpimpl *gcpimpl) headobject(bucket string, objname string) (objmeta map[s
 	}
 	objmeta[HeaderServer] = googlecloud
 	objmeta["version"] = fmt.Sprintf("%d", attrs.Generation)
+	for k, v := range attrs.Metadata {
+		if k != gcpDfcHashType && k != gcpDfcHashVal {
+			objmeta[HeaderDfcMetaPrefix+k] = v
+		}
+	}
 	return
 }
 
@@ -190,6 +199,16 @@ func (gcpimpl *gcpimpl) getobj(fqn string, bucket string, objname string) (props
 		return
 	}
 	v = newcksumvalue(attrs.Metadata[gcpDfcHashType], attrs.Metadata[gcpDfcHashVal])
+	var usermeta map[string]string
+	for k, v := range attrs.Metadata {
+		if k == gcpDfcHashType || k == gcpDfcHashVal {
+			continue
+		}
+		if usermeta == nil {
+			usermeta = make(map[string]string, len(attrs.Metadata))
+		}
+		usermeta[k] = v
+	}
 	md5 := hex.EncodeToString(attrs.MD5)
 	rc, err := o.NewReader(gctx)
 	if err != nil {
@@ -198,8 +217,8 @@ func (gcpimpl *gcpimpl) getobj(fqn string, bucket string, objname string) (props
 	}
 	defer rc.Close()
 	// hashtype and hash could be empty for legacy objects.
-	props = &objectProps{}
-	if _, props.nhobj, props.size, errstr = gcpimpl.t.receive(fqn, false, objname, md5, v, rc); errstr != "" {
+	props = &objectProps{usermeta: usermeta}
+	if _, props.nhobj, props.size, errstr = gcpimpl.t.receive(fqn, false, bucket, objname, md5, v, rc); errstr != "" {
 		return
 	}
 	props.version = fmt.Sprintf("%d", attrs.Generation)
@@ -209,7 +228,25 @@ func (gcpimpl *gcpimpl) getobj(fqn string, bucket string, objname string) (props
 	return
 }
 
-func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue) (errstr string, errcode int) {
+func (gcpimpl *gcpimpl) getobjrange(bucket, objname string, offset, length int64) (rc io.ReadCloser, objsize int64, errstr string, errcode int) {
+	client, gctx, errstr := createclient()
+	if errstr != "" {
+		return
+	}
+	reader, err := client.Bucket(bucket).Object(objname).NewRangeReader(gctx, offset, length)
+	if err != nil {
+		errcode = gcpErrorToHTTP(err)
+		errstr = fmt.Sprintf("gcp: Failed to GET %s/%s (offset %d, length %d), err: %v", bucket, objname, offset, length, err)
+		return
+	}
+	rc, objsize = reader, reader.Size()
+	if glog.V(3) {
+		glog.Infof("gcp: GET %s/%s (offset %d, length %d)", bucket, objname, offset, length)
+	}
+	return
+}
+
+func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue, usermeta map[string]string) (errstr string, errcode int) {
 	var (
 		htype, hval string
 		md          map[string]string
@@ -224,6 +261,12 @@ func (gcpimpl *gcpimpl) putobj(file *os.File, bucket, objname string, ohash cksu
 		md[gcpDfcHashType] = htype
 		md[gcpDfcHashVal] = hval
 	}
+	for k, v := range usermeta {
+		if md == nil {
+			md = make(map[string]string, len(usermeta))
+		}
+		md[k] = v
+	}
 	wc := client.Bucket(bucket).Object(objname).NewWriter(gctx)
 	wc.Metadata = md
 	slab := selectslab(0)
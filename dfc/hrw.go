@@ -6,6 +6,8 @@
 package dfc
 
 import (
+	"sort"
+
 	"github.com/OneOfOne/xxhash"
 )
 
@@ -32,6 +34,52 @@ func hrwTarget(name string, smap *Smap) (si *daemonInfo, errstr string) {
 	return
 }
 
+// hrwTargets returns up to n targets in the descending order of their HRW weights;
+// the first one is the hrwTarget, the rest are the replica holders
+func hrwTargets(name string, smap *Smap, n int) (sis []*daemonInfo, errstr string) {
+	if smap.count() == 0 {
+		errstr = "DFC cluster map is empty: no targets"
+		return
+	}
+	sis = hrwSorted(smap.Smap, name, "", n)
+	return
+}
+
+// hrwProxies returns the proxies, except the given one, in the descending order of the HRW weights
+// of their IDs - the order in which they are elected primary
+func hrwProxies(smap *Smap, except string) (sis []*daemonInfo) {
+	return hrwSorted(smap.Pmap, "", except, len(smap.Pmap))
+}
+
+// hrwSorted returns up to n daemons, except the given one, in the descending order of their
+// HRW weights: xxhash of "<daemon ID>:<name>" or, if the name is empty, of the daemon ID
+func hrwSorted(daemons map[string]*daemonInfo, name, except string, n int) (sis []*daemonInfo) {
+	type weighted struct {
+		si *daemonInfo
+		cs uint64
+	}
+	all := make([]weighted, 0, len(daemons))
+	for id, sinfo := range daemons {
+		if id == except {
+			continue
+		}
+		key := id
+		if name != "" {
+			key = id + ":" + name
+		}
+		all = append(all, weighted{si: sinfo, cs: xxhash.ChecksumString64S(key, mLCG32)})
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].cs > all[j].cs })
+	if n > len(all) {
+		n = len(all)
+	}
+	sis = make([]*daemonInfo, n)
+	for i := 0; i < n; i++ {
+		sis[i] = all[i].si
+	}
+	return
+}
+
 func hrwMpath(name string) (mpath string) {
 	var max uint64
 	for path := range ctx.mountpaths {
@@ -0,0 +1,55 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHrwTargets(t *testing.T) {
+	smap := &Smap{Smap: make(map[string]*daemonInfo), Pmap: make(map[string]*daemonInfo)}
+	for i := 0; i < 8; i++ {
+		id := fmt.Sprintf("target%d", i)
+		smap.Smap[id] = &daemonInfo{DaemonID: id}
+		id = fmt.Sprintf("proxy%d", i)
+		smap.Pmap[id] = &daemonInfo{DaemonID: id}
+	}
+	for i := 0; i < 100; i++ {
+		name := fmt.Sprintf("bucket/obj%d", i)
+		si, errstr := hrwTarget(name, smap)
+		if errstr != "" {
+			t.Fatal(errstr)
+		}
+		sis, errstr := hrwTargets(name, smap, 3)
+		if errstr != "" {
+			t.Fatal(errstr)
+		}
+		if len(sis) != 3 || sis[0] != si {
+			t.Fatalf("%s: HRW target %s, HRW list %v", name, si.DaemonID, sis)
+		}
+		all, _ := hrwTargets(name, smap, 100)
+		if len(all) != len(smap.Smap) {
+			t.Fatalf("%s: %d targets, expecting %d", name, len(all), len(smap.Smap))
+		}
+		for j := range sis {
+			if all[j] != sis[j] {
+				t.Fatalf("%s: HRW lists differ at %d: %s vs %s", name, j, all[j].DaemonID, sis[j].DaemonID)
+			}
+		}
+	}
+
+	proxies := hrwProxies(smap, "")
+	except := proxies[0].DaemonID
+	rest := hrwProxies(smap, except)
+	if len(rest) != len(proxies)-1 {
+		t.Fatalf("%d proxies except %s, expecting %d", len(rest), except, len(proxies)-1)
+	}
+	for i := range rest {
+		if rest[i] != proxies[i+1] {
+			t.Errorf("proxy %d: %s, expecting %s", i, rest[i].DaemonID, proxies[i+1].DaemonID)
+		}
+	}
+}
@@ -8,6 +8,7 @@ package dfc
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"html"
@@ -15,6 +16,7 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"runtime/debug"
 	"strconv"
@@ -34,9 +36,10 @@ const (
 )
 
 type objectProps struct {
-	version string
-	size    int64
-	nhobj   cksumvalue
+	version  string
+	size     int64
+	nhobj    cksumvalue
+	usermeta map[string]string
 }
 
 //===========
@@ -49,7 +52,9 @@ type cloudif interface {
 	headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int)
 	headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int)
 	getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int)
-	putobj(file *os.File, bucket, objname string, ohobj cksumvalue) (errstr string, errcode int)
+	// getobjrange reads length bytes at offset (length < 0: till the end); objsize is the size of the entire object
+	getobjrange(bucket, objname string, offset, length int64) (rc io.ReadCloser, objsize int64, errstr string, errcode int)
+	putobj(file *os.File, bucket, objname string, ohobj cksumvalue, usermeta map[string]string) (errstr string, errcode int)
 	deleteobj(bucket, objname string) (errstr string, errcode int)
 }
 
@@ -94,6 +99,11 @@ type httprunner struct {
 	httpclient            *http.Client // http client for intra-cluster comm
 	httpclientLongTimeout *http.Client // http client for long-wait intra-cluster comm
 	statsif               statsif
+	latency               latencies    // see latency.go
+	tracer                *tracerunner // nil: tracing disabled, see trace.go
+	auth                  *authn       // nil: authentication disabled, see auth.go
+	tlsconf               *tls.Config  // server; nil: plain HTTP, see tls.go
+	authorize             func(r *http.Request, token *AuthToken) (status int, errstr string)
 	kalive                kaliveif
 }
 
@@ -110,13 +120,24 @@ func (h *httprunner) init(s statsif) {
 	if errstr != "" {
 		glog.Fatalf("FATAL: %s", errstr)
 	}
+	var (
+		proto     = "http://"
+		tlsclient *tls.Config
+		err       error
+	)
+	if ctx.config.TLS.Enabled {
+		if h.tlsconf, tlsclient, err = newtlsconfig(&ctx.config.TLS); err != nil {
+			glog.Fatalf("FATAL: %v", err)
+		}
+		proto = "https://"
+	}
 	// http client
 	h.httpclient = &http.Client{
-		Transport: &http.Transport{MaxIdleConnsPerHost: maxidleconns},
+		Transport: &http.Transport{MaxIdleConnsPerHost: maxidleconns, TLSClientConfig: tlsclient},
 		Timeout:   ctx.config.HTTP.Timeout,
 	}
 	h.httpclientLongTimeout = &http.Client{
-		Transport: &http.Transport{MaxIdleConnsPerHost: maxidleconns},
+		Transport: &http.Transport{MaxIdleConnsPerHost: maxidleconns, TLSClientConfig: tlsclient},
 		Timeout:   ctx.config.HTTP.LongTimeout,
 	}
 	// init daemonInfo here
@@ -133,7 +154,16 @@ func (h *httprunner) init(s statsif) {
 		h.si.DaemonID = strconv.Itoa(int(cs&0xffff)) + ":" + ctx.config.Listen.Port
 	}
 
-	h.si.DirectURL = "http://" + h.si.NodeIPAddr + ":" + h.si.DaemonPort
+	h.si.DirectURL = proto + h.si.NodeIPAddr + ":" + h.si.DaemonPort
+
+	if h.tracer = gettracerunner(); h.tracer != nil {
+		h.tracer.setresource(h.si, clivars.role)
+	}
+	if ctx.config.Auth.Enabled {
+		h.auth = newauthn(clivars.role + ":" + h.si.DaemonID)
+		h.httpclient.Transport = &authtransport{base: h.httpclient.Transport, auth: h.auth}
+		h.httpclientLongTimeout.Transport = &authtransport{base: h.httpclientLongTimeout.Transport, auth: h.auth}
+	}
 }
 
 func (h *httprunner) run() error {
@@ -141,13 +171,28 @@ func (h *httprunner) run() error {
 	// os.Stderr would be used, as per golang.org/pkg/net/http/#Server
 	h.glogger = log.New(&glogwriter{}, "net/http err: ", 0)
 	var handler http.Handler = h.mux
+	if h.auth != nil {
+		handler = h.authhandler(handler)
+	}
+	if h.tlsconf != nil && ctx.config.TLS.Mutual {
+		handler = h.peerhandler(handler)
+	}
+	if h.tracer != nil {
+		handler = h.tracehandler(handler)
+	}
 	if ctx.config.H2c {
 		handler = h2c.Server{Handler: handler}
 	}
 
 	portstring := ":" + ctx.config.Listen.Port
-	h.h = &http.Server{Addr: portstring, Handler: handler, ErrorLog: h.glogger}
-	if err := h.h.ListenAndServe(); err != nil {
+	h.h = &http.Server{Addr: portstring, Handler: handler, ErrorLog: h.glogger, TLSConfig: h.tlsconf}
+	var err error
+	if h.tlsconf != nil {
+		err = h.h.ListenAndServeTLS("", "") // the certificate is in the TLSConfig
+	} else {
+		err = h.h.ListenAndServe()
+	}
+	if err != nil {
 		if err != http.ErrServerClosed {
 			glog.Errorf("Terminated %s with err: %v", h.name, err)
 			return err
@@ -175,6 +220,13 @@ func (h *httprunner) stop(err error) {
 // intra-cluster IPC, control plane; calls (via http) another target or a proxy
 // optionally, sends a json-encoded body to the callee
 func (h *httprunner) call(si *daemonInfo, url, method string, injson []byte,
+	timeout ...time.Duration) (outjson []byte, err error, errstr string, status int) {
+	return h.calltraced(nil, si, url, method, injson, timeout...)
+}
+
+// calltraced is call() on behalf of the given span (see trace.go); without
+// the span, the destination does not trace the request
+func (h *httprunner) calltraced(parent *span, si *daemonInfo, url, method string, injson []byte,
 	timeout ...time.Duration) (outjson []byte, err error, errstr string, status int) {
 	var (
 		request  *http.Request
@@ -201,6 +253,22 @@ func (h *httprunner) call(si *daemonInfo, url, method string, injson []byte,
 		errstr = fmt.Sprintf("Unexpected failure to create http request %s %s, err: %v", method, url, err)
 		return
 	}
+	if parent != nil {
+		sp := parent.child(method+" "+tracepath(request.URL.Path), spanClient)
+		sp.setstr("url.full", url)
+		sp.setstr("dfc.to_id", sid)
+		sp.inject(request.Header)
+		defer func() {
+			if response != nil {
+				sp.setint("http.response.status_code", int64(response.StatusCode))
+			}
+			sp.end(errstr)
+		}()
+	} else if h.tracer != nil {
+		c := h.tracer.newtracectx()
+		c.sampled = false
+		request.Header.Set(HeaderTraceParent, c.traceparent())
+	}
 
 	// Explicitly specifying a 0 timeout means the client wants no timeout.
 	if len(timeout) > 0 && timeout[0] != 0 {
@@ -317,6 +385,16 @@ func (h *httprunner) readJSON(w http.ResponseWriter, r *http.Request, out interf
 	return nil
 }
 
+// readGetMsg reads the GetMsg from the request body or, if provided, takes
+// the "what" from the URL query (e.g. GET /v1/cluster?what=rebalance)
+func (h *httprunner) readGetMsg(w http.ResponseWriter, r *http.Request, msg *GetMsg) error {
+	if what := r.URL.Query().Get(ParamWhat); what != "" {
+		msg.GetWhat = what
+		return nil
+	}
+	return h.readJSON(w, r, msg)
+}
+
 // NOTE: must be the last error-generating-and-handling call in the http handler
 //       writes http body and header
 //       calls invalmsghdlr() on err
@@ -388,6 +466,16 @@ func (h *httprunner) setconfig(name, value string) (errstr string) {
 		} else {
 			ctx.config.LRUConfig.LRUEnabled = v
 		}
+	case "lru_policy":
+		if err := validatepolicy(value); err != nil {
+			errstr = err.Error()
+		} else {
+			ctx.config.LRUConfig.Policy = value
+		}
+	case "bucket_quota":
+		if err := setquota(value); err != nil {
+			errstr = err.Error()
+		}
 	case "validate_cold_get":
 		if v, err := strconv.ParseBool(value); err != nil {
 			errstr = fmt.Sprintf("Failed to parse validate_cold_get, err: %v", err)
@@ -419,6 +507,31 @@ func (h *httprunner) setconfig(name, value string) (errstr string) {
 	return
 }
 
+// intra-cluster query parameters: never passed on from a client's request
+var peerparams = []string{ParamFromID, ParamToID, ParamReplica, ParamECSlice, ParamCopy, ParamRedirect}
+
+// redirect redirects the request with its trace context, if traced (see trace.go), so that
+// the redirected request becomes a child of the one that is being handled; and with
+// the signature that authorizes the redirected request, if authentication is enabled (see auth.go).
+// The intra-cluster parameters are dropped so that the signature never covers them.
+func (h *httprunner) redirect(w http.ResponseWriter, r *http.Request, redirecturl string, code int) {
+	if u, err := url.Parse(redirecturl); err == nil {
+		query := u.Query()
+		for _, param := range peerparams {
+			query.Del(param)
+		}
+		if sp := spanof(r); sp != nil {
+			query.Set(ParamTraceParent, sp.traceparent())
+		}
+		if h.auth != nil {
+			query.Set(ParamRedirect, h.auth.signredirect(r.Method, u.Path, query))
+		}
+		u.RawQuery = query.Encode()
+		redirecturl = u.String()
+	}
+	http.Redirect(w, r, redirecturl, code)
+}
+
 //=================
 //
 // http err + spec message + code + stats
@@ -431,7 +544,11 @@ func (h *httprunner) invalmsghdlr(w http.ResponseWriter, r *http.Request, specif
 	}
 	s := http.StatusText(status) + ": " + specific
 	s += ": " + r.Method + " " + r.URL.Path + " from " + r.RemoteAddr
-	glog.Errorln(s)
+	if reqid := spanof(r).reqid(); reqid != "" {
+		glog.Errorln(s + " (request ID " + reqid + ")")
+	} else {
+		glog.Errorln(s)
+	}
 	glog.Flush()
 	http.Error(w, s, status)
 	h.statsif.add("numerr", 1)
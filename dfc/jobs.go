@@ -0,0 +1,299 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// list/range jobs: the proxy assigns each list/range operation (prefetch, delete, evict,
+// pin, unpin) a cluster-wide job ID and passes it to the targets (ParamJobID); each target
+// then tracks the job's progress and failures, and cancels it upon request:
+// GET and DELETE /v1/jobs/<jobid>
+//
+//======
+
+const (
+	maxJobs        = 64  // finished jobs kept per target
+	maxJobFailures = 100 // per-object failures kept per job
+)
+
+// JobStats is the per-target state of a list/range job
+type JobStats struct {
+	ID        string            `json:"id"`
+	Action    string            `json:"action"`
+	Bucket    string            `json:"bucket"`
+	StartTime time.Time         `json:"start_time"`
+	EndTime   time.Time         `json:"end_time"`
+	Total     int64             `json:"total"` // objects to process, -1 until known (range)
+	Done      int64             `json:"done"`
+	Failed    int64             `json:"failed"`
+	Failures  map[string]string `json:"failures,omitempty"` // object name => error (truncated)
+	Error     string            `json:"error,omitempty"`    // failed as a whole
+	Status    string            `json:"status"`             // JobStats.Status enum
+}
+
+// JobInfo is the proxy-aggregated GET /v1/jobs/<jobid>
+type JobInfo struct {
+	ID     string               `json:"id"`
+	Status string               `json:"status"` // running if any target is running, etc.
+	Total  int64                `json:"total"`
+	Done   int64                `json:"done"`
+	Failed int64                `json:"failed"`
+	Target map[string]*JobStats `json:"target"`
+}
+
+// JobStats.Status enum
+const (
+	JobRunning   = "running"
+	JobFinished  = "finished"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+type lrjob struct {
+	sync.Mutex
+	stats     JobStats
+	cancelled int32
+}
+
+type lrjobs struct {
+	sync.Mutex
+	jobs  map[string]*lrjob
+	order []string // oldest first
+}
+
+func newlrjobs() *lrjobs {
+	return &lrjobs{jobs: make(map[string]*lrjob, maxJobs)}
+}
+
+// add registers a new job; the jobs without IDs (direct target requests) are not tracked
+func (q *lrjobs) add(id, action, bucket string) *lrjob {
+	if id == "" {
+		return nil
+	}
+	job := &lrjob{stats: JobStats{ID: id, Action: action, Bucket: bucket, StartTime: time.Now(), Total: -1, Status: JobRunning}}
+	q.Lock()
+	defer q.Unlock()
+	if _, ok := q.jobs[id]; !ok {
+		q.order = append(q.order, id)
+	}
+	q.jobs[id] = job
+	// forget the oldest finished
+	for i := 0; len(q.jobs) > maxJobs && i < len(q.order); {
+		old := q.jobs[q.order[i]]
+		if old.running() {
+			i++
+			continue
+		}
+		delete(q.jobs, q.order[i])
+		q.order = append(q.order[:i], q.order[i+1:]...)
+	}
+	return job
+}
+
+func (q *lrjobs) get(id string) *lrjob {
+	q.Lock()
+	defer q.Unlock()
+	return q.jobs[id]
+}
+
+//
+// lrjob methods are nil-safe: untracked jobs are nil
+//
+func (job *lrjob) settotal(n int) {
+	if job == nil {
+		return
+	}
+	job.Lock()
+	job.stats.Total = int64(n)
+	job.Unlock()
+}
+
+// done records the result of processing a single object
+func (job *lrjob) done(objname string, err error) {
+	if job == nil {
+		return
+	}
+	job.Lock()
+	defer job.Unlock()
+	job.stats.Done++
+	if err == nil {
+		return
+	}
+	job.stats.Failed++
+	if job.stats.Failures == nil {
+		job.stats.Failures = make(map[string]string, 4)
+	}
+	if len(job.stats.Failures) < maxJobFailures {
+		job.stats.Failures[objname] = err.Error()
+	}
+}
+
+// finish completes the job, with an error if the job failed as a whole;
+// can be called more than once, e.g. to report an error after the fact
+func (job *lrjob) finish(err error) {
+	if job == nil {
+		return
+	}
+	job.Lock()
+	defer job.Unlock()
+	if err != nil && job.stats.Error == "" {
+		job.stats.Error = err.Error()
+	}
+	if job.stats.EndTime.IsZero() {
+		job.stats.EndTime = time.Now()
+	}
+	switch {
+	case atomic.LoadInt32(&job.cancelled) != 0:
+		job.stats.Status = JobCancelled
+	case job.stats.Error != "":
+		job.stats.Status = JobFailed
+	default:
+		job.stats.Status = JobFinished
+	}
+}
+
+func (job *lrjob) cancel() {
+	atomic.StoreInt32(&job.cancelled, 1)
+}
+
+func (job *lrjob) iscancelled() bool {
+	return job != nil && atomic.LoadInt32(&job.cancelled) != 0
+}
+
+func (job *lrjob) running() bool {
+	job.Lock()
+	defer job.Unlock()
+	return job.stats.EndTime.IsZero()
+}
+
+func (job *lrjob) getstats() *JobStats {
+	job.Lock()
+	defer job.Unlock()
+	stats := job.stats
+	if len(job.stats.Failures) > 0 {
+		stats.Failures = make(map[string]string, len(job.stats.Failures))
+		for objname, errstr := range job.stats.Failures {
+			stats.Failures[objname] = errstr
+		}
+	}
+	return &stats
+}
+
+//===================
+//
+// target: GET and DELETE /v1/jobs/<jobid>
+//
+//===================
+func (t *targetrunner) jobhdlr(w http.ResponseWriter, r *http.Request) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 1, Rversion, Rjobs); apitems == nil {
+		return
+	}
+	job := t.lrjobs.get(apitems[0])
+	if job == nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Job %s does not exist", apitems[0]), http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		if job.running() {
+			job.cancel()
+			glog.Infof("Cancelling job %s", apitems[0])
+		}
+	default:
+		invalhdlr(w, r)
+		return
+	}
+	jsbytes, err := json.Marshal(job.getstats())
+	assert(err == nil, err)
+	t.writeJSON(w, r, jsbytes, "jobhdlr")
+}
+
+//===================
+//
+// proxy: GET and DELETE /v1/jobs/<jobid>, aggregated
+//
+//===================
+func (p *proxyrunner) jobhdlr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodDelete {
+		invalhdlr(w, r)
+		return
+	}
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 1, Rversion, Rjobs); apitems == nil {
+		return
+	}
+	jobid := apitems[0]
+	targets := p.targets()
+	out := &JobInfo{ID: jobid, Target: make(map[string]*JobStats, len(targets))}
+	for sid, si := range targets {
+		url := si.DirectURL + "/" + Rversion + "/" + Rjobs + "/" + jobid
+		outjson, err, errstr, status := p.call(si, url, r.Method, nil)
+		if err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Failed to %s job %s on %s, err: %s", r.Method, jobid, sid, errstr))
+			p.kalive.onerr(err, status)
+			return
+		}
+		stats := &JobStats{}
+		if err = json.Unmarshal(outjson, stats); err != nil || stats.ID != jobid {
+			continue // the target does not know the job (e.g., has joined since)
+		}
+		out.Target[sid] = stats
+		if stats.Total > 0 {
+			out.Total += stats.Total
+		}
+		out.Done += stats.Done
+		out.Failed += stats.Failed
+	}
+	if len(out.Target) == 0 {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Job %s does not exist", jobid), http.StatusNotFound)
+		return
+	}
+	out.Status = jobstatus(out.Target)
+	jsbytes, err := json.Marshal(out)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "jobhdlr")
+}
+
+// the job is running while any target is running it, and failed if any target failed
+func jobstatus(targets map[string]*JobStats) string {
+	var failed, cancelled bool
+	for _, stats := range targets {
+		switch stats.Status {
+		case JobRunning:
+			return JobRunning
+		case JobFailed:
+			failed = true
+		case JobCancelled:
+			cancelled = true
+		}
+	}
+	if cancelled {
+		return JobCancelled
+	}
+	if failed {
+		return JobFailed
+	}
+	return JobFinished
+}
+
+// newjobid returns a cluster-wide unique list/range job ID
+func (p *proxyrunner) newjobid() string {
+	return strconv.FormatInt(time.Now().UnixNano(), 36)
+}
@@ -135,6 +135,9 @@ func (r *proxykalive) keepalive(err error) (stopped bool) {
 		return
 	}
 	defer atomic.CompareAndSwapInt64(&r.atomic, aval, 0)
+	if !r.p.isprimary() {
+		return r.keepaliveprimary(err)
+	}
 	if err != nil {
 		glog.Infof("keepalive-alltargets: got err %v, checking now...", err)
 	}
@@ -170,11 +173,70 @@ func (r *proxykalive) keepalive(err error) (stopped bool) {
 		}
 		ctx.smap.lock()
 		ctx.smap.del(sid)
+		r.p.savesmapconf()
 		ctx.smap.unlock()
 	}
+	return r.keepalivestandbys()
+}
+
+// keepalivestandbys (primary) removes failed standby proxies from the cluster map
+func (r *proxykalive) keepalivestandbys() (stopped bool) {
+	jsbytes, err := json.Marshal(&GetMsg{GetWhat: GetWhatSmap})
+	assert(err == nil, err)
+	for sid, si := range ctx.smap.Pmap {
+		if sid == r.p.si.DaemonID || r.skipCheck(sid) {
+			continue
+		}
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		_, err, _, _ := r.p.call(si, url, http.MethodGet, jsbytes, kalivetimeout)
+		if err == nil {
+			continue
+		}
+		glog.Infof("Warning: standby proxy %s fails keepalive, err: %v", sid, err)
+		responded, stopped := r.poll(si, url, jsbytes)
+		if stopped {
+			return true
+		}
+		if responded {
+			continue
+		}
+		glog.Errorf("Standby proxy %s fails keepalive, err: %v - removing from the cluster map", sid, err)
+		ctx.smap.lock()
+		ctx.smap.delproxy(sid)
+		r.p.savesmapconf()
+		ctx.smap.unlock()
+		go r.p.synchronizeMaps(0, "")
+	}
 	return false
 }
 
+// keepaliveprimary (standby) checks the primary and, if the latter fails, elects the new one
+func (r *proxykalive) keepaliveprimary(err error) (stopped bool) {
+	primary := ctx.smap.ProxySI
+	if primary == nil || r.skipCheck(primary.DaemonID) {
+		return
+	}
+	status, err := r.p.register(kalivetimeout)
+	if err == nil {
+		return
+	}
+	if status > 0 {
+		glog.Infof("Warning: primary proxy %s fails keepalive with status %d, err: %v", primary.DaemonID, status, err)
+	} else {
+		glog.Infof("Warning: primary proxy %s fails keepalive, err: %v", primary.DaemonID, err)
+	}
+	jsbytes, err := json.Marshal(&GetMsg{GetWhat: GetWhatSmap})
+	assert(err == nil, err)
+	url := primary.DirectURL + "/" + Rversion + "/" + Rdaemon
+	responded, stopped := r.poll(primary, url, jsbytes)
+	if stopped || responded {
+		return
+	}
+	glog.Errorf("Primary proxy %s fails keepalive - electing the new primary", primary.DaemonID)
+	r.p.elect(primary)
+	return
+}
+
 func (r *proxykalive) poll(si *daemonInfo, url string, jsbytes []byte) (responded, stopped bool) {
 	var (
 		maxedout = 0
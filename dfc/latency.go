@@ -0,0 +1,176 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+//======
+//
+// per-operation latency histograms: log-scale buckets, four per power of two microseconds
+// (hence, percentiles are accurate to within 19%), updated atomically and with no locking.
+// GET {"what": "stats"} reports each histogram's p50/p90/p99/max along with its buckets,
+// so that the proxy can merge the targets' histograms into the cluster-wide ones
+//
+//======
+
+const (
+	latencyBuckets    = 128 // up to 2^(128/4) microseconds, the last bucket is unbounded
+	latencySubBuckets = 4   // per power of two
+)
+
+// latency operations
+const (
+	latGet      = "get"      // GET of a cached object (warm GET)
+	latColdGet  = "coldget"  // GET that loads the object from the Cloud
+	latPut      = "put"      // PUT, including the write-through to the Cloud
+	latDelete   = "delete"   // DELETE, including the Cloud
+	latList     = "list"     // list bucket
+	latSend     = "send"     // target-to-target object transfer: sending side
+	latRecv     = "recv"     // ditto: receiving side
+	latCloudGet = "cloudget" // Cloud getobj
+	latCloudPut = "cloudput" // Cloud putobj
+)
+
+var (
+	targetLatencyOps = []string{latGet, latColdGet, latPut, latDelete, latList, latSend, latRecv, latCloudGet, latCloudPut}
+	proxyLatencyOps  = []string{latList}
+)
+
+// LatencyStats is the JSON representation of a latency histogram
+type LatencyStats struct {
+	Count   int64         `json:"count"`
+	P50     time.Duration `json:"p50"` // nanoseconds
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+	Buckets []int64       `json:"buckets"` // counts, trailing zeros omitted
+}
+
+type latencyhist struct {
+	counts [latencyBuckets]int64
+	max    int64 // nanoseconds
+}
+
+// operation => histogram; the map itself is never modified once created
+type latencies map[string]*latencyhist
+
+func newlatencies(ops []string) latencies {
+	l := make(latencies, len(ops))
+	for _, op := range ops {
+		l[op] = &latencyhist{}
+	}
+	return l
+}
+
+func (l latencies) add(op string, d time.Duration) {
+	h, ok := l[op]
+	assert(ok, "Invalid latency operation "+op)
+	h.add(d)
+}
+
+// merge adds up the histograms (see httpclugetstats)
+func (l latencies) merge(other latencies) {
+	for op, h := range other {
+		if _, ok := l[op]; !ok {
+			l[op] = &latencyhist{}
+		}
+		l[op].merge(h)
+	}
+}
+
+//
+// latencyhist
+//
+
+// bucket i counts the latencies in (2^((i-1)/4), 2^(i/4)] microseconds
+func latencybucket(d time.Duration) int {
+	us := float64(d) / float64(time.Microsecond)
+	if us <= 1 {
+		return 0
+	}
+	i := int(math.Ceil(math.Log2(us) * latencySubBuckets))
+	if i >= latencyBuckets {
+		i = latencyBuckets - 1
+	}
+	return i
+}
+
+func latencybound(i int) time.Duration {
+	return time.Duration(math.Pow(2, float64(i)/latencySubBuckets) * float64(time.Microsecond))
+}
+
+func (h *latencyhist) add(d time.Duration) {
+	atomic.AddInt64(&h.counts[latencybucket(d)], 1)
+	for {
+		max := atomic.LoadInt64(&h.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&h.max, max, int64(d)) {
+			break
+		}
+	}
+}
+
+func (h *latencyhist) merge(other *latencyhist) {
+	for i := range other.counts {
+		h.counts[i] += other.counts[i]
+	}
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+// latencypercentile returns the upper bound of the bucket that contains the p-th percentile
+func latencypercentile(counts []int64, count int64, max time.Duration, p float64) time.Duration {
+	if count == 0 {
+		return 0
+	}
+	rank, seen := int64(math.Ceil(float64(count)*p/100)), int64(0)
+	for i, n := range counts {
+		if seen += n; seen >= rank {
+			if bound := latencybound(i); bound < max {
+				return bound
+			}
+			break
+		}
+	}
+	return max
+}
+
+func (h *latencyhist) MarshalJSON() ([]byte, error) {
+	stats := &LatencyStats{Max: time.Duration(atomic.LoadInt64(&h.max))}
+	counts := make([]int64, 0, latencyBuckets)
+	for i := range h.counts {
+		n := atomic.LoadInt64(&h.counts[i])
+		counts = append(counts, n)
+		stats.Count += n
+	}
+	for len(counts) > 0 && counts[len(counts)-1] == 0 {
+		counts = counts[:len(counts)-1]
+	}
+	stats.Buckets = counts
+	stats.P50 = latencypercentile(counts, stats.Count, stats.Max, 50)
+	stats.P90 = latencypercentile(counts, stats.Count, stats.Max, 90)
+	stats.P99 = latencypercentile(counts, stats.Count, stats.Max, 99)
+	return json.Marshal(stats)
+}
+
+func (h *latencyhist) UnmarshalJSON(b []byte) error {
+	stats := &LatencyStats{}
+	if err := json.Unmarshal(b, stats); err != nil {
+		return err
+	}
+	*h = latencyhist{max: int64(stats.Max)}
+	for i, n := range stats.Buckets {
+		if i < latencyBuckets {
+			h.counts[i] = n
+		}
+	}
+	return nil
+}
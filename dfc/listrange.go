@@ -7,6 +7,7 @@ package dfc
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"regexp"
@@ -29,6 +30,7 @@ type filesWithDeadline struct {
 	bucket   string
 	deadline time.Time
 	done     chan struct{}
+	job      *lrjob
 }
 
 type xactPrefetch struct {
@@ -51,7 +53,7 @@ func (t *targetrunner) getListFromRange(bucket, prefix, regex string, min, max i
 	msg := &GetMsg{GetPrefix: prefix}
 	fullbucketlist := &BucketList{Entries: make([]*BucketEntry, 0)}
 	for i := 0; i < maxPrefetchPages; i++ {
-		jsbytes, errstr, errcode := getcloudif().listbucket(bucket, msg)
+		jsbytes, errstr, errcode := t.getcloudif(bucket).listbucket(bucket, msg)
 		if errstr != "" {
 			return nil, fmt.Errorf("Error listing cloud bucket %s: %d(%s)", bucket, errcode, errstr)
 		}
@@ -107,10 +109,12 @@ func acceptRegexRange(name, prefix string, regex *regexp.Regexp, min, max int64)
 	return false
 }
 
-type listf func(objects []string, bucket string, deadline time.Duration, done chan struct{}) error
-type rangef func(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}) error
+type listf func(objects []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error
+type rangef func(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}, job *lrjob) error
 
-func (t *targetrunner) listOperation(w http.ResponseWriter, r *http.Request, listMsg ListMsg, operation listf) {
+// listOperation and rangeOperation register the job (ParamJobID) if the request comes from the proxy;
+// the operation then reports per-object progress and finishes the job
+func (t *targetrunner) listOperation(w http.ResponseWriter, r *http.Request, action string, listMsg ListMsg, operation listf) {
 	apitems := t.restAPIItems(r.URL.Path, 5)
 	if apitems = t.checkRestAPI(w, r, apitems, 1, Rversion, Rfiles); apitems == nil {
 		return
@@ -127,7 +131,11 @@ func (t *targetrunner) listOperation(w http.ResponseWriter, r *http.Request, lis
 			objs = append(objs, obj)
 		}
 	}
-	if len(objs) != 0 {
+	job := t.lrjobs.add(r.URL.Query().Get(ParamJobID), action, bucket)
+	job.settotal(len(objs))
+	if len(objs) == 0 {
+		job.finish(nil)
+	} else {
 		var done chan struct{}
 		if listMsg.Wait {
 			done = make(chan struct{}, 1)
@@ -135,20 +143,21 @@ func (t *targetrunner) listOperation(w http.ResponseWriter, r *http.Request, lis
 
 		// Asynchronously perform operation
 		go func() {
-			if err := operation(objs, bucket, listMsg.Deadline, done); err != nil {
+			if err := operation(objs, bucket, listMsg.Deadline, done, job); err != nil {
 				glog.Errorf("Error performing list operation: %v", err)
 				t.statsif.add("numerr", 1)
+				job.finish(err)
+				wakeup(done)
 			}
 		}()
 
 		if listMsg.Wait {
 			<-done
-			close(done)
 		}
 	}
 }
 
-func (t *targetrunner) rangeOperation(w http.ResponseWriter, r *http.Request, rangeMsg RangeMsg, operation rangef) {
+func (t *targetrunner) rangeOperation(w http.ResponseWriter, r *http.Request, action string, rangeMsg RangeMsg, operation rangef) {
 	var (
 		err error
 	)
@@ -161,7 +170,9 @@ func (t *targetrunner) rangeOperation(w http.ResponseWriter, r *http.Request, ra
 	if err != nil {
 		s := fmt.Sprintf("Error parsing range string (%s): %v", rangeMsg.Range, err)
 		t.invalmsghdlr(w, r, s)
+		return
 	}
+	job := t.lrjobs.add(r.URL.Query().Get(ParamJobID), action, bucket)
 
 	var done chan struct{}
 	if rangeMsg.Wait {
@@ -171,15 +182,28 @@ func (t *targetrunner) rangeOperation(w http.ResponseWriter, r *http.Request, ra
 	// Asynchronously perform operation
 	go func() {
 		if err := operation(bucket, rangeMsg.Prefix, rangeMsg.Regex,
-			min, max, rangeMsg.Deadline, done); err != nil {
+			min, max, rangeMsg.Deadline, done, job); err != nil {
 			glog.Errorf("Error performing range operation: %v", err)
 			t.statsif.add("numerr", 1)
+			job.finish(err)
+			wakeup(done)
 		}
 	}()
 
 	if rangeMsg.Wait {
 		<-done
-		close(done)
+	}
+}
+
+// wakeup unblocks the waiting request if the operation fails before doing so itself
+// (done is buffered and never closed)
+func wakeup(done chan struct{}) {
+	if done == nil {
+		return
+	}
+	select {
+	case done <- struct{}{}:
+	default:
 	}
 }
 
@@ -190,33 +214,38 @@ func (t *targetrunner) rangeOperation(w http.ResponseWriter, r *http.Request, ra
 //=============
 
 func (t *targetrunner) deleteList(w http.ResponseWriter, r *http.Request, deleteMsg ListMsg) {
-	t.listOperation(w, r, deleteMsg, t.doListDelete)
+	t.listOperation(w, r, ActDelete, deleteMsg, t.doListDelete)
 }
 
 func (t *targetrunner) evictList(w http.ResponseWriter, r *http.Request, evictMsg ListMsg) {
-	t.listOperation(w, r, evictMsg, t.doListEvict)
+	t.listOperation(w, r, ActEvict, evictMsg, t.doListEvict)
 }
 
 func (t *targetrunner) deleteRange(w http.ResponseWriter, r *http.Request, deleteRangeMsg RangeMsg) {
-	t.rangeOperation(w, r, deleteRangeMsg, t.doRangeDelete)
+	t.rangeOperation(w, r, ActDelete, deleteRangeMsg, t.doRangeDelete)
 }
 
 func (t *targetrunner) evictRange(w http.ResponseWriter, r *http.Request, evictMsg RangeMsg) {
-	t.rangeOperation(w, r, evictMsg, t.doRangeEvict)
+	t.rangeOperation(w, r, ActEvict, evictMsg, t.doRangeEvict)
 }
 
-func (t *targetrunner) doListEvictDelete(evict bool, objs []string, bucket string, deadline time.Duration, done chan struct{}) error {
+// doListEvictDelete keeps going when an object fails, and fails at the end
+func (t *targetrunner) doListEvictDelete(evict bool, objs []string, bucket string, deadline time.Duration,
+	done chan struct{}, job *lrjob) (err error) {
 	var xdel *xactDeleteEvict
 	if evict {
-		xdel = t.xactinp.newEvict()
+		xdel = t.xactinp.newEvict(t)
 	} else {
-		xdel = t.xactinp.newDelete()
+		xdel = t.xactinp.newDelete(t)
 	}
+	job.settotal(len(objs))
 	defer func() {
+		job.finish(err)
 		if done != nil {
 			var v struct{}
 			done <- v
 		}
+		xdel.etime = time.Now()
 		t.xactinp.del(xdel.id)
 	}()
 
@@ -226,66 +255,76 @@ func (t *targetrunner) doListEvictDelete(evict bool, objs []string, bucket strin
 		absdeadline = time.Now().Add(deadline)
 	}
 
+	var nfailed int
 	for _, objname := range objs {
 		select {
 		case <-xdel.abrt:
 			return nil
 		default:
 		}
+		if job.iscancelled() {
+			return nil
+		}
 		if !absdeadline.IsZero() && time.Now().After(absdeadline) {
 			continue
 		}
-		err := t.fildelete(bucket, objname, evict)
-		if err != nil {
-			return err
+		errdel := t.fildelete(nil, bucket, objname, evict)
+		job.done(objname, errdel)
+		if errdel != nil {
+			glog.Errorf("%s: %v", xdel.tostring(), errdel)
+			nfailed++
+			continue
 		}
+		xdel.progress(1, 0)
+	}
+	if nfailed > 0 {
+		return fmt.Errorf("%s: failed to process %d out of %d objects", xdel.tostring(), nfailed, len(objs))
 	}
-
 	return nil
 }
 
 func (t *targetrunner) doRangeEvictDelete(evict bool, bucket, prefix, regex string, min, max int64,
-	deadline time.Duration, done chan struct{}) error {
+	deadline time.Duration, done chan struct{}, job *lrjob) error {
 
 	objs, err := t.getListFromRange(bucket, prefix, regex, min, max)
 	if err != nil {
 		return err
 	}
 
-	return t.doListEvictDelete(evict, objs, bucket, deadline, done)
+	return t.doListEvictDelete(evict, objs, bucket, deadline, done, job)
 }
 
-func (t *targetrunner) doListDelete(objs []string, bucket string, deadline time.Duration, done chan struct{}) error {
-	return t.doListEvictDelete(false /* evict */, objs, bucket, deadline, done)
+func (t *targetrunner) doListDelete(objs []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error {
+	return t.doListEvictDelete(false /* evict */, objs, bucket, deadline, done, job)
 }
 
-func (t *targetrunner) doListEvict(objs []string, bucket string, deadline time.Duration, done chan struct{}) error {
-	return t.doListEvictDelete(true /* evict */, objs, bucket, deadline, done)
+func (t *targetrunner) doListEvict(objs []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error {
+	return t.doListEvictDelete(true /* evict */, objs, bucket, deadline, done, job)
 }
 
 func (t *targetrunner) doRangeDelete(bucket, prefix, regex string, min, max int64,
-	deadline time.Duration, done chan struct{}) error {
-	return t.doRangeEvictDelete(false /* evict */, bucket, prefix, regex, min, max, deadline, done)
+	deadline time.Duration, done chan struct{}, job *lrjob) error {
+	return t.doRangeEvictDelete(false /* evict */, bucket, prefix, regex, min, max, deadline, done, job)
 }
 func (t *targetrunner) doRangeEvict(bucket, prefix, regex string, min, max int64,
-	deadline time.Duration, done chan struct{}) error {
-	return t.doRangeEvictDelete(true /* evict */, bucket, prefix, regex, min, max, deadline, done)
+	deadline time.Duration, done chan struct{}, job *lrjob) error {
+	return t.doRangeEvictDelete(true /* evict */, bucket, prefix, regex, min, max, deadline, done, job)
 }
 
-func (q *xactInProgress) newDelete() *xactDeleteEvict {
+func (q *xactInProgress) newDelete(t *targetrunner) *xactDeleteEvict {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	id := q.uniqueid()
-	xpre := &xactDeleteEvict{xactBase: *newxactBase(id, ActDelete)}
+	xpre := &xactDeleteEvict{xactBase: *newxactBase(id, ActDelete), targetrunner: t}
 	q.add(xpre)
 	return xpre
 }
 
-func (q *xactInProgress) newEvict() *xactDeleteEvict {
+func (q *xactInProgress) newEvict(t *targetrunner) *xactDeleteEvict {
 	q.lock.Lock()
 	defer q.lock.Unlock()
 	id := q.uniqueid()
-	xpre := &xactDeleteEvict{xactBase: *newxactBase(id, ActEvict)}
+	xpre := &xactDeleteEvict{xactBase: *newxactBase(id, ActEvict), targetrunner: t}
 	q.add(xpre)
 	return xpre
 }
@@ -306,11 +345,11 @@ func (xact *xactDeleteEvict) tostring() string {
 //=========
 
 func (t *targetrunner) prefetchList(w http.ResponseWriter, r *http.Request, prefetchMsg ListMsg) {
-	t.listOperation(w, r, prefetchMsg, t.addPrefetchList)
+	t.listOperation(w, r, ActPrefetch, prefetchMsg, t.addPrefetchList)
 }
 
 func (t *targetrunner) prefetchRange(w http.ResponseWriter, r *http.Request, prefetchRangeMsg RangeMsg) {
-	t.rangeOperation(w, r, prefetchRangeMsg, t.addPrefetchRange)
+	t.rangeOperation(w, r, ActPrefetch, prefetchRangeMsg, t.addPrefetchRange)
 }
 
 func (t *targetrunner) doPrefetch() {
@@ -321,13 +360,36 @@ func (t *targetrunner) doPrefetch() {
 loop:
 	for {
 		select {
+		case <-xpre.abrt:
+			break loop
 		case fwd := <-t.prefetchQueue:
 			if !fwd.deadline.IsZero() && time.Now().After(fwd.deadline) {
+				fwd.job.finish(fmt.Errorf("%s: deadline exceeded", xpre.tostring()))
 				continue
 			}
 			bucket := fwd.bucket
+			var nfailed int
 			for _, objname := range fwd.objnames {
-				t.prefetchMissing(objname, bucket)
+				if xpre.finished() || fwd.job.iscancelled() {
+					break
+				}
+				size, errstr := t.prefetchMissing(objname, bucket)
+				if errstr != "" {
+					fwd.job.done(objname, errors.New(errstr))
+					nfailed++
+					continue
+				}
+				fwd.job.done(objname, nil)
+				if size > 0 {
+					xpre.progress(1, size)
+				}
+			}
+			if xpre.finished() {
+				fwd.job.finish(fmt.Errorf("%s: aborted", xpre.tostring()))
+			} else if nfailed > 0 {
+				fwd.job.finish(fmt.Errorf("%s: failed to prefetch %d out of %d objects", xpre.tostring(), nfailed, len(fwd.objnames)))
+			} else {
+				fwd.job.finish(nil)
 			}
 
 			// Signal completion of prefetch
@@ -341,17 +403,21 @@ loop:
 
 		}
 	}
+	if !xpre.finished() {
+		xpre.etime = time.Now()
+	}
 	t.xactinp.del(xpre.id)
 }
 
-func (t *targetrunner) prefetchMissing(objname, bucket string) {
+// prefetchMissing returns the size of the prefetched object, zero if not prefetched
+func (t *targetrunner) prefetchMissing(objname, bucket string) (size int64, errstr string) {
 	var (
-		errstr, version   string
+		version           string
 		errcode           int
 		vchanged, coldget bool
 		props             *objectProps
 	)
-	versioncfg := &ctx.config.VersionConfig
+	versioncfg := t.lbmap.versionconf(bucket)
 	fqn := t.fqn(bucket, objname)
 	uname := bucket + objname
 	//
@@ -364,7 +430,7 @@ func (t *targetrunner) prefetchMissing(objname, bucket string) {
 		return
 	}
 	if !coldget && versioncfg.ValidateWarmGet && version != "" {
-		if vchanged, errstr, _ = t.checkCloudVersion(bucket, objname, version); errstr != "" {
+		if vchanged, errstr, _ = t.checkCloudVersion(nil, bucket, objname, version); errstr != "" {
 			return
 		}
 		coldget = vchanged
@@ -383,7 +449,7 @@ func (t *targetrunner) prefetchMissing(objname, bucket string) {
 		return
 	}
 	if !coldget && versioncfg.ValidateWarmGet && version != "" {
-		if vchanged, errstr, _ = t.checkCloudVersion(bucket, objname, version); errstr != "" {
+		if vchanged, errstr, _ = t.checkCloudVersion(nil, bucket, objname, version); errstr != "" {
 			return
 		}
 		coldget = vchanged
@@ -394,24 +460,30 @@ func (t *targetrunner) prefetchMissing(objname, bucket string) {
 	//
 	// step 3: prefetch (FIXME: revisit potential use of timeout for prefetch deadline)
 	//
-	if props, errstr, errcode = getcloudif().getobj(fqn, bucket, objname); errstr != "" {
+	cloudstarted := time.Now()
+	if props, errstr, errcode = t.getcloudif(bucket).getobj(fqn, bucket, objname); errstr != "" {
 		glog.Errorf("Failed to prefetch %s/%s, err: %s, code %d", bucket, objname, errstr, errcode)
 		t.statsif.add("numerr", 1)
 		return
 	}
+	t.latency.add(latCloudGet, time.Since(cloudstarted))
 	glog.Infof("PREFETCH %s/%s => %s", bucket, objname, fqn)
 	t.statsif.add("numprefetch", 1)
 	t.statsif.add("bytesprefetched", props.size)
 	if props.version != "" {
 		Setxattr(fqn, xattrObjVersion, []byte(props.version))
 	}
+	if errs := setusermeta(fqn, props.usermeta); errs != "" {
+		glog.Errorln(errs)
+	}
 	if vchanged {
 		t.statsif.add("bytesvchanged", props.size)
 		t.statsif.add("numvchanged", 1)
 	}
+	return props.size, ""
 }
 
-func (t *targetrunner) addPrefetchList(objs []string, bucket string, deadline time.Duration, done chan struct{}) error {
+func (t *targetrunner) addPrefetchList(objs []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error {
 	if t.islocalBucket(bucket) {
 		return fmt.Errorf("Cannot prefetch from a local bucket: %s", bucket)
 	}
@@ -420,11 +492,12 @@ func (t *targetrunner) addPrefetchList(objs []string, bucket string, deadline ti
 		// 0 is no deadline - if deadline == 0, the absolute deadline is 0 time.
 		absdeadline = time.Now().Add(deadline)
 	}
-	t.prefetchQueue <- filesWithDeadline{objnames: objs, bucket: bucket, deadline: absdeadline, done: done}
+	job.settotal(len(objs))
+	t.prefetchQueue <- filesWithDeadline{objnames: objs, bucket: bucket, deadline: absdeadline, done: done, job: job}
 	return nil
 }
 
-func (t *targetrunner) addPrefetchRange(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}) error {
+func (t *targetrunner) addPrefetchRange(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}, job *lrjob) error {
 	if t.islocalBucket(bucket) {
 		return fmt.Errorf("Cannot prefetch from a local bucket: %s", bucket)
 	}
@@ -434,7 +507,7 @@ func (t *targetrunner) addPrefetchRange(bucket, prefix, regex string, min, max i
 		return err
 	}
 
-	return t.addPrefetchList(objs, bucket, deadline, done)
+	return t.addPrefetchList(objs, bucket, deadline, done, job)
 }
 
 func (q *xactInProgress) renewPrefetch(t *targetrunner) *xactPrefetch {
@@ -11,6 +11,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -21,20 +22,36 @@ import (
 // types
 type fileinfo struct {
 	fqn     string
+	bucket  string
 	usetime time.Time
 	size    int64
+	naccess int64   // access counter, -1 if not read yet (see accesscnt)
+	score   float64 // as per the eviction policy
+	evicted bool
 	index   int
 }
 
 type maxheap []*fileinfo
 
+// eviction candidates of the buckets that share the same eviction policy and priority class,
+// or of a single bucket that is over quota
+type evictgroup struct {
+	policy   evictpolicy
+	priority int
+	h        *maxheap
+	totsize  int64 // max to evict from this group
+	cursize  int64
+	maxscore float64
+}
+
 type lructx struct {
-	cursize int64
-	totsize int64
-	newest  time.Time
-	xlru    *xactLRU
-	h       *maxheap
-	t       *targetrunner
+	totsize   int64
+	groups    map[string]*evictgroup // by priority class and policy name
+	quotas    map[string]*evictgroup // by bucket (over quota)
+	excess    map[string]int64       // bytes over quota, by bucket
+	evictable map[string]int64       // bytes above the reserved size, by bucket (with reserves only)
+	xlru      *xactLRU
+	t         *targetrunner
 }
 
 // FIXME: mountpath.enabled is never used
@@ -47,14 +64,20 @@ func (t *targetrunner) runLRU() {
 	fschkwg := &sync.WaitGroup{}
 
 	glog.Infof("LRU: %s started: dont-evict-time %v", xlru.tostring(), ctx.config.LRUConfig.DontEvictTime)
+	var excess, evictable map[string]map[string]int64
+	if len(ctx.config.LRUConfig.BucketQuota) > 0 {
+		excess, evictable = t.lruquotas(t.bucketsizes())
+	}
 	for mpath := range ctx.mountpaths {
 		fschkwg.Add(1)
-		go t.oneLRU(mpath+"/"+ctx.config.LocalBuckets, fschkwg, xlru)
+		bucketdir := mpath + "/" + ctx.config.LocalBuckets
+		go t.oneLRU(bucketdir, fschkwg, xlru, excess[bucketdir], evictable[bucketdir])
 	}
 	fschkwg.Wait()
 	for mpath := range ctx.mountpaths {
 		fschkwg.Add(1)
-		go t.oneLRU(mpath+"/"+ctx.config.CloudBuckets, fschkwg, xlru)
+		bucketdir := mpath + "/" + ctx.config.CloudBuckets
+		go t.oneLRU(bucketdir, fschkwg, xlru, excess[bucketdir], evictable[bucketdir])
 	}
 	fschkwg.Wait()
 
@@ -74,19 +97,20 @@ func (t *targetrunner) runLRU() {
 }
 
 // TODO: local-buckets-first LRU policy
-func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *xactLRU) {
+func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *xactLRU, excess, evictable map[string]int64) {
 	defer fschkwg.Done()
-	h := &maxheap{}
-	heap.Init(h)
-
 	toevict, err := getToEvict(bucketdir, ctx.config.LRUConfig.HighWM, ctx.config.LRUConfig.LowWM)
 	if err != nil {
 		return
 	}
 	glog.Infof("LRU %s: to evict %.2f MB", bucketdir, float64(toevict)/1000/1000)
+	if toevict == 0 && len(excess) == 0 {
+		return
+	}
 
 	// init LRU context
-	lctx := &lructx{totsize: toevict, xlru: xlru, h: h, t: t}
+	lctx := &lructx{totsize: toevict, groups: make(map[string]*evictgroup, 4), quotas: make(map[string]*evictgroup, len(excess)),
+		excess: excess, evictable: evictable, xlru: xlru, t: t}
 
 	if err = filepath.Walk(bucketdir, lctx.lruwalkfn); err != nil {
 		s := err.Error()
@@ -105,7 +129,7 @@ func (t *targetrunner) oneLRU(bucketdir string, fschkwg *sync.WaitGroup, xlru *x
 // the walking callback is execited by the LRU xaction
 // (notice the receiver)
 func (lctx *lructx) lruwalkfn(fqn string, osfi os.FileInfo, err error) error {
-	xlru, h := lctx.xlru, lctx.h
+	xlru := lctx.xlru
 	if err != nil {
 		glog.Errorf("walkfunc callback invoked with err: %v", err)
 		return err
@@ -139,55 +163,151 @@ func (lctx *lructx) lruwalkfn(fqn string, osfi os.FileInfo, err error) error {
 	if mtime.After(atime) {
 		usetime = mtime
 	}
+	bucket, _, _ := lctx.t.fqn2bckobj(fqn)
+	enabled, dontevict := lctx.t.lbmap.lruconf(bucket)
+	if !enabled {
+		return nil
+	}
 	now := time.Now()
-	dontevictime := now.Add(-ctx.config.LRUConfig.DontEvictTime)
+	dontevictime := now.Add(-dontevict)
 	if usetime.After(dontevictime) {
 		if glog.V(3) {
 			glog.Infof("DEBUG: not evicting %s (usetime %v, dontevictime %v)", fqn, usetime, dontevictime)
 		}
 		return nil
 	}
-	// partial optimization:
-	// 	do nothing if the heap's cursize >= totsize &&
-	// 	the file is more recent then the the heap's newest
-	// full optimization (tbd) entails compacting the heap when its cursize >> totsize
-	if lctx.cursize >= lctx.totsize && usetime.After(lctx.newest) {
+	if ispinned(fqn) {
 		if glog.V(3) {
-			glog.Infof("DEBUG: use-time-after (usetime=%v, newest=%v) %s", usetime, lctx.newest, fqn)
+			glog.Infof("DEBUG: not evicting pinned %s", fqn)
 		}
 		return nil
 	}
-	// push and update the context
 	fi := &fileinfo{
 		fqn:     fqn,
+		bucket:  bucket,
 		usetime: usetime,
 		size:    stat.Size,
+		naccess: accesses.flush(fqn),
+	}
+	_, _, priority := lctx.t.bucketlimits(bucket)
+	policyname := evictpolicyname(bucket)
+	key := fmt.Sprintf("%d/%s", priority, policyname)
+	g, ok := lctx.groups[key]
+	if !ok {
+		g = newevictgroup(evictpolicyfor(bucket), priority, lctx.totsize)
+		lctx.groups[key] = g
 	}
-	heap.Push(h, fi)
-	lctx.cursize += fi.size
-	if usetime.After(lctx.newest) {
-		lctx.newest = usetime
+	fi.score = g.policy.score(fi)
+	g.push(fi)
+	// over quota: the same file is also a candidate for the quota-driven eviction
+	if excess := lctx.excess[bucket]; excess > 0 {
+		q, ok := lctx.quotas[bucket]
+		if !ok {
+			q = newevictgroup(g.policy, priority, excess)
+			lctx.quotas[bucket] = q
+		}
+		q.push(fi)
 	}
 	return nil
 }
 
+func newevictgroup(policy evictpolicy, priority int, totsize int64) *evictgroup {
+	h := &maxheap{}
+	heap.Init(h)
+	return &evictgroup{policy: policy, priority: priority, h: h, totsize: totsize}
+}
+
+func (g *evictgroup) push(fi *fileinfo) {
+	// partial optimization:
+	// 	do nothing if the heap's cursize >= totsize &&
+	// 	the file scores higher than the heap's max
+	// full optimization (tbd) entails compacting the heap when its cursize >> totsize
+	if g.cursize >= g.totsize && fi.score > g.maxscore {
+		if glog.V(3) {
+			glog.Infof("DEBUG: score-above-max (score=%.3f, max=%.3f) %s", fi.score, g.maxscore, fi.fqn)
+		}
+		return
+	}
+	heap.Push(g.h, fi)
+	g.cursize += fi.size
+	if fi.score > g.maxscore {
+		g.maxscore = fi.score
+	}
+}
+
+// doLRU first evicts the buckets that are over quota, each down to its quota, and then
+// evicts in the policy order, from the lowest priority class up, until the watermark is reached;
+// when the buckets of the same priority class have different policies, each group of buckets
+// (that share the policy) contributes in proportion to its eviction candidates
 func (t *targetrunner) doLRU(toevict int64, bucketdir string, lctx *lructx) error {
-	h := lctx.h
 	var (
 		fevicted, bevicted int64
 	)
-	for h.Len() > 0 && toevict > 10 {
-		fi := heap.Pop(h).(*fileinfo)
-		if err := t.lrufilRemove("lru", fi.fqn); err != nil {
-			glog.Errorf("Failed to evict %q, err: %v", fi.fqn, err)
-			continue
+	evict := func(g *evictgroup, share int64, quota bool) (evicted int64) {
+		for g.h.Len() > 0 && share > 10 {
+			fi := heap.Pop(g.h).(*fileinfo)
+			if fi.evicted {
+				continue
+			}
+			if left, ok := lctx.evictable[fi.bucket]; ok && !quota && left < fi.size {
+				continue // reserved
+			}
+			if err := t.lrufilRemove("lru", fi.fqn); err != nil {
+				glog.Errorf("Failed to evict %q, err: %v", fi.fqn, err)
+				continue
+			}
+			fi.evicted = true
+			lctx.xlru.progress(1, fi.size)
+			g.policy.evicted(fi)
+			if _, ok := lctx.evictable[fi.bucket]; ok {
+				lctx.evictable[fi.bucket] -= fi.size
+			}
+			if lctx.excess != nil {
+				t.evictedbucket(fi.bucket, fi.size)
+			}
+			if glog.V(3) {
+				glog.Infof("LRU %s: removed %q", bucketdir, fi.fqn)
+			}
+			share -= fi.size
+			evicted += fi.size
+			fevicted++
 		}
-		if glog.V(3) {
-			glog.Infof("LRU %s: removed %q", bucketdir, fi.fqn)
+		return
+	}
+	// 1. quotas
+	for bucket, q := range lctx.quotas {
+		glog.Infof("LRU %s: bucket %s is over quota, to evict %.2f MB", bucketdir, bucket, float64(q.totsize)/1000/1000)
+		bevicted += evict(q, q.totsize, true)
+	}
+	toevict -= bevicted
+	// 2. watermarks, by priority class
+	classes := make(map[int][]*evictgroup, 2)
+	priorities := make([]int, 0, 2)
+	for _, g := range lctx.groups {
+		if _, ok := classes[g.priority]; !ok {
+			priorities = append(priorities, g.priority)
 		}
-		toevict -= fi.size
-		bevicted += fi.size
-		fevicted++
+		classes[g.priority] = append(classes[g.priority], g)
+	}
+	sort.Ints(priorities)
+	for _, priority := range priorities {
+		if toevict <= 10 {
+			break
+		}
+		groups := classes[priority]
+		var candidates, evicted int64
+		for _, g := range groups {
+			candidates += g.cursize
+		}
+		for _, g := range groups {
+			share := toevict
+			if len(groups) > 1 {
+				share = int64(float64(toevict) * float64(g.cursize) / float64(candidates))
+			}
+			evicted += evict(g, share, false)
+		}
+		toevict -= evicted
+		bevicted += evicted
 	}
 	if ctx.rg != nil { // FIXME: for *_test only
 		stats := getstorstats()
@@ -227,7 +347,10 @@ func (t *targetrunner) lrufilRemove(prefix, fqn string) error {
 func (mh maxheap) Len() int { return len(mh) }
 
 func (mh maxheap) Less(i, j int) bool {
-	return mh[i].usetime.Before(mh[j].usetime)
+	if mh[i].score == mh[j].score {
+		return mh[i].usetime.Before(mh[j].usetime)
+	}
+	return mh[i].score < mh[j].score
 }
 
 func (mh maxheap) Swap(i, j int) {
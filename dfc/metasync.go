@@ -0,0 +1,311 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// metasync: the primary proxy distributes versioned cluster metadata (Smap and lbmap)
+// to all targets and standby proxies in two phases:
+// - phase 1: the metadata is sent to all the daemons in parallel; each daemon acknowledges
+//   by replying with the metadata versions it has after the update (see metaack);
+// - phase 2: the daemons that failed to acknowledge are retried, first right away and then
+//   periodically by the metasyncer runner, until they do or get removed from the cluster map.
+// Per-daemon acknowledged versions, and the daemons that lag behind, are reported via
+// GET /v1/cluster {"what": "metasync"}
+//
+//======
+const (
+	metasyncretries = 2               // immediate retries (phase 2)
+	metasyncretryiv = time.Second     // between immediate retries
+	metasyncpollivl = time.Second * 5 // periodic retries (phase 2, continued)
+)
+
+// metadata versions acknowledged by a target or a standby proxy
+type metaversions struct {
+	Smap  int64 `json:"smap"`
+	LBmap int64 `json:"lbmap"`
+}
+
+// MetasyncStatus is the metasync state of a given target or standby proxy
+type MetasyncStatus struct {
+	Smap      int64     `json:"smap"`  // acknowledged Smap version
+	LBmap     int64     `json:"lbmap"` // acknowledged lbmap version
+	Acked     time.Time `json:"acked"` // last acknowledgment
+	Failures  int       `json:"failures"`
+	Error     string    `json:"error,omitempty"`
+	Lagging   bool      `json:"lagging"`
+	pending   bool      // failed to acknowledge the last broadcast
+	rebalance bool      // ditto, and the latter was Rebalance
+}
+
+// MetasyncInfo is returned by GET /v1/cluster {"what": "metasync"}
+type MetasyncInfo struct {
+	Smap        int64                      `json:"smap"`         // the primary's current Smap version
+	LBmap       int64                      `json:"lbmap"`        // ditto, lbmap
+	SyncedSmap  int64                      `json:"synced_smap"`  // the last broadcast Smap version
+	SyncedLBmap int64                      `json:"synced_lbmap"` // ditto, lbmap
+	Daemons     map[string]*MetasyncStatus `json:"daemons"`      // by daemon ID
+	Lagging     []string                   `json:"lagging"`      // IDs of the daemons that lag behind the last broadcast
+}
+
+type metasyncer struct {
+	namedrunner
+	sync.Mutex
+	p       *proxyrunner
+	status  map[string]*MetasyncStatus
+	synced  metaversions
+	smapjs  []byte                 // last broadcast Smap
+	smapsis map[string]*daemonInfo // and its recipients
+	lbmapjs []byte                 // last broadcast lbmap
+	chstop  chan struct{}
+}
+
+func newmetasyncer(p *proxyrunner) *metasyncer {
+	return &metasyncer{p: p, status: make(map[string]*MetasyncStatus, 16)}
+}
+
+func (y *metasyncer) run() error {
+	glog.Infof("Starting %s", y.name)
+	y.chstop = make(chan struct{}, 4)
+	ticker := time.NewTicker(metasyncpollivl)
+	for {
+		select {
+		case <-ticker.C:
+			if y.p.isprimary() {
+				y.retry()
+			}
+		case <-y.chstop:
+			ticker.Stop()
+			return nil
+		}
+	}
+}
+
+func (y *metasyncer) stop(err error) {
+	glog.Infof("Stopping %s, err: %v", y.name, err)
+	var v struct{}
+	y.chstop <- v
+	close(y.chstop)
+}
+
+// syncSmap broadcasts the cluster map (phase 1) and retries the daemons that fail to acknowledge
+// (phase 2); action is either Rsyncsmap or Rebalance, standby proxies always get the former
+func (y *metasyncer) syncSmap(action string) {
+	ctx.smap.lock()
+	jsbytes, err := json.Marshal(ctx.smap)
+	version := ctx.smap.Version
+	daemons := y.daemonsLocked() // NOTE: exactly those that are in the Smap being sent
+	ctx.smap.unlock()
+	assert(err == nil, err)
+	y.Lock()
+	y.smapjs, y.smapsis, y.synced.Smap = jsbytes, daemons, version
+	y.Unlock()
+	y.broadcast(daemons, action, jsbytes)
+}
+
+// syncLBmap: same as above, for the local buckets metadata
+func (y *metasyncer) syncLBmap() {
+	y.p.lbmap.lock()
+	jsbytes, err := json.Marshal(y.p.lbmap)
+	version := y.p.lbmap.Version
+	y.p.lbmap.unlock()
+	assert(err == nil, err)
+	y.Lock()
+	y.lbmapjs, y.synced.LBmap = jsbytes, version
+	y.Unlock()
+	y.broadcast(y.daemons(), Rsynclb, jsbytes)
+}
+
+func (y *metasyncer) broadcast(daemons map[string]*daemonInfo, path string, jsbytes []byte) {
+	wg := &sync.WaitGroup{}
+	for _, si := range daemons {
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			y.send(si, path, jsbytes)
+			wg.Done()
+		}(si)
+	}
+	wg.Wait()
+}
+
+// send delivers the metadata to a given daemon and records the acknowledgment
+func (y *metasyncer) send(si *daemonInfo, path string, jsbytes []byte) (acked bool) {
+	if path == Rebalance && y.isproxy(si.DaemonID) {
+		path = Rsyncsmap
+	}
+	var (
+		url    = si.DirectURL + "/" + Rversion + "/" + Rdaemon + "/" + path
+		ack    metaversions
+		errstr string
+		status int
+	)
+	for i := 0; i <= metasyncretries; i++ {
+		if i > 0 {
+			time.Sleep(metasyncretryiv)
+		}
+		var (
+			outjson []byte
+			err     error
+		)
+		outjson, err, errstr, status = y.p.call(si, url, http.MethodPut, jsbytes)
+		if err == nil {
+			// anything other than the versions, e.g. an error message, is not an acknowledgment
+			if err = json.Unmarshal(outjson, &ack); err == nil {
+				acked = true
+				break
+			}
+			errstr = "no acknowledgment: " + string(outjson)
+		}
+		if i == 0 {
+			y.p.kalive.onerr(err, status)
+		}
+	}
+	y.Lock()
+	st, ok := y.status[si.DaemonID]
+	if !ok {
+		st = &MetasyncStatus{}
+		y.status[si.DaemonID] = st
+	}
+	if acked {
+		st.Smap, st.LBmap, st.Acked = ack.Smap, ack.LBmap, time.Now()
+		st.Failures, st.Error, st.pending, st.rebalance = 0, "", false, false
+	} else {
+		st.Failures++
+		st.Error, st.pending = errstr, true
+		if path == Rebalance {
+			st.rebalance = true
+		}
+	}
+	y.Unlock()
+	if !acked {
+		glog.Errorf("metasync: %s failed to acknowledge %s (attempts: %d): %s", si.DaemonID, path, metasyncretries+1, errstr)
+	}
+	return
+}
+
+// retry (phase 2, continued) resends the last broadcast metadata to the daemons that have failed
+// to acknowledge it: lbmap first, then Smap (with Rebalance, if missed)
+func (y *metasyncer) retry() {
+	daemons := y.daemons()
+	y.Lock()
+	for sid := range y.status {
+		if _, ok := daemons[sid]; !ok {
+			delete(y.status, sid) // no longer in the cluster map
+		}
+	}
+	type resend struct {
+		si                     *daemonInfo
+		lbmap, smap, rebalance bool
+	}
+	todo := make([]resend, 0, 4)
+	for sid, st := range y.status {
+		if st.pending {
+			// NOTE: the Smap goes only to those that are in it
+			_, insmap := y.smapsis[sid]
+			todo = append(todo, resend{daemons[sid], st.LBmap < y.synced.LBmap, insmap, st.rebalance})
+		}
+	}
+	smapjs, lbmapjs := y.smapjs, y.lbmapjs
+	y.Unlock()
+	for _, rs := range todo {
+		if rs.lbmap && lbmapjs != nil && !y.send(rs.si, Rsynclb, lbmapjs) {
+			continue
+		}
+		if !rs.smap || smapjs == nil {
+			continue
+		}
+		path := Rsyncsmap
+		if rs.rebalance {
+			path = Rebalance
+		}
+		if y.send(rs.si, path, smapjs) {
+			glog.Infof("metasync: %s is now in sync", rs.si.DaemonID)
+		}
+	}
+}
+
+// targets and standby proxies, as per the current cluster map
+func (y *metasyncer) daemons() map[string]*daemonInfo {
+	ctx.smap.lock()
+	defer ctx.smap.unlock()
+	return y.daemonsLocked()
+}
+
+// ditto, requires the caller to lock ctx.smap
+func (y *metasyncer) daemonsLocked() map[string]*daemonInfo {
+	daemons := make(map[string]*daemonInfo, len(ctx.smap.Smap)+len(ctx.smap.Pmap))
+	for sid, si := range ctx.smap.Smap {
+		daemons[sid] = si
+	}
+	for sid, si := range ctx.smap.Pmap {
+		if sid != y.p.si.DaemonID {
+			daemons[sid] = si
+		}
+	}
+	return daemons
+}
+
+func (y *metasyncer) isproxy(sid string) bool {
+	ctx.smap.lock()
+	_, ok := ctx.smap.Pmap[sid]
+	ctx.smap.unlock()
+	return ok
+}
+
+func (y *metasyncer) info() *MetasyncInfo {
+	daemons := y.daemons()
+	info := &MetasyncInfo{
+		Smap:    ctx.smap.versionLocked(),
+		LBmap:   y.p.lbmap.versionLocked(),
+		Daemons: make(map[string]*MetasyncStatus, len(daemons)),
+		Lagging: make([]string, 0, 4),
+	}
+	y.Lock()
+	info.SyncedSmap, info.SyncedLBmap = y.synced.Smap, y.synced.LBmap
+	for sid := range daemons {
+		st := &MetasyncStatus{}
+		if ost, ok := y.status[sid]; ok {
+			*st = *ost
+		}
+		st.Lagging = st.Smap < y.synced.Smap || st.LBmap < y.synced.LBmap
+		if st.Lagging {
+			info.Lagging = append(info.Lagging, sid)
+		}
+		info.Daemons[sid] = st
+	}
+	y.Unlock()
+	sort.Strings(info.Lagging)
+	return info
+}
+
+//==============================
+//
+// receiving side: acknowledgments
+//
+//==============================
+func (h *httprunner) writemetaack(w http.ResponseWriter, r *http.Request, smapversion, lbversion int64) {
+	jsbytes, err := json.Marshal(&metaversions{Smap: smapversion, LBmap: lbversion})
+	assert(err == nil, err)
+	h.writeJSON(w, r, jsbytes, "metaack")
+}
+
+func (t *targetrunner) metaack(w http.ResponseWriter, r *http.Request) {
+	t.writemetaack(w, r, t.smap.Version, t.lbmap.Version)
+}
+
+func (p *proxyrunner) metaack(w http.ResponseWriter, r *http.Request) {
+	p.writemetaack(w, r, ctx.smap.versionLocked(), p.lbmap.versionLocked())
+}
@@ -0,0 +1,207 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+//======
+//
+// GET /metrics: the daemon's core counters, mountpath capacities, bucket usage, xactions,
+// and cluster metadata versions in the Prometheus text exposition format; every sample is
+// labeled with the daemon's ID and role (proxy | target)
+//
+//======
+
+const (
+	metricsPrefix      = "dfc_"
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+type metricswriter struct {
+	buf    bytes.Buffer
+	labels string // common to all samples: daemon_id and role
+}
+
+func newmetricswriter(daemonID, role string) *metricswriter {
+	m := &metricswriter{}
+	m.labels = "daemon_id=" + metricslabel(daemonID) + ",role=" + metricslabel(role)
+	return m
+}
+
+func metricslabel(v string) string {
+	v = strings.Replace(v, `\`, `\\`, -1)
+	v = strings.Replace(v, `"`, `\"`, -1)
+	v = strings.Replace(v, "\n", `\n`, -1)
+	return `"` + v + `"`
+}
+
+// family starts a metric; its samples must follow
+func (m *metricswriter) family(name, typ, help string) {
+	fmt.Fprintf(&m.buf, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, typ)
+}
+
+// sample adds a sample with the common labels followed by the given label name/value pairs
+func (m *metricswriter) sample(name string, value int64, labels ...string) {
+	assert(len(labels)%2 == 0)
+	fmt.Fprintf(&m.buf, "%s%s{%s", metricsPrefix, name, m.labels)
+	for i := 0; i < len(labels); i += 2 {
+		fmt.Fprintf(&m.buf, ",%s=%s", labels[i], metricslabel(labels[i+1]))
+	}
+	fmt.Fprintf(&m.buf, "} %d\n", value)
+}
+
+func (m *metricswriter) gauge(name, help string, value int64) {
+	m.family(name, "gauge", help)
+	m.sample(name, value)
+}
+
+// counters exports the core stats, one counter per JSON field (e.g. numget => dfc_numget_total)
+func (m *metricswriter) counters(core interface{}) {
+	jsbytes, err := json.Marshal(core)
+	assert(err == nil, err)
+	stats := make(map[string]int64, 32)
+	err = json.Unmarshal(jsbytes, &stats)
+	assert(err == nil, err)
+	names := make([]string, 0, len(stats))
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		m.family(name+"_total", "counter", "Core stats: "+name)
+		m.sample(name+"_total", stats[name])
+	}
+}
+
+func (m *metricswriter) capacity(capacity map[string]*fscapacity) {
+	mpaths := make([]string, 0, len(capacity))
+	for mpath := range capacity {
+		mpaths = append(mpaths, mpath)
+	}
+	sort.Strings(mpaths)
+	m.family("fs_used_bytes", "gauge", "Used capacity of the mountpath's filesystem")
+	for _, mpath := range mpaths {
+		m.sample("fs_used_bytes", int64(capacity[mpath].Used), "mountpath", mpath)
+	}
+	m.family("fs_avail_bytes", "gauge", "Available capacity of the mountpath's filesystem")
+	for _, mpath := range mpaths {
+		m.sample("fs_avail_bytes", int64(capacity[mpath].Avail), "mountpath", mpath)
+	}
+	m.family("fs_used_percent", "gauge", "Used capacity of the mountpath's filesystem, percent")
+	for _, mpath := range mpaths {
+		m.sample("fs_used_percent", int64(capacity[mpath].Usedpct), "mountpath", mpath)
+	}
+}
+
+// buckets exports the per-bucket usage (with bucket quotas only)
+func (m *metricswriter) buckets(buckets map[string]*bucketcapacity) {
+	if len(buckets) == 0 {
+		return
+	}
+	names := make([]string, 0, len(buckets))
+	for bucket := range buckets {
+		names = append(names, bucket)
+	}
+	sort.Strings(names)
+	m.family("bucket_used_bytes", "gauge", "Bucket usage as of the last LRU run")
+	for _, bucket := range names {
+		m.sample("bucket_used_bytes", buckets[bucket].Used, "bucket", bucket)
+	}
+	m.family("bucket_quota_bytes", "gauge", "Bucket quota, 0 - unlimited")
+	for _, bucket := range names {
+		m.sample("bucket_quota_bytes", buckets[bucket].Quota, "bucket", bucket)
+	}
+	m.family("bucket_evicted_bytes_total", "counter", "Bytes evicted from the bucket by the LRU")
+	for _, bucket := range names {
+		m.sample("bucket_evicted_bytes_total", buckets[bucket].Evicted, "bucket", bucket)
+	}
+}
+
+func (m *metricswriter) xactions(q *xactInProgress) {
+	running, done := q.counts()
+	kinds := make([]string, 0, len(running))
+	for kind := range running {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	m.family("xactions_running", "gauge", "Running xactions")
+	for _, kind := range kinds {
+		m.sample("xactions_running", running[kind], "kind", kind)
+	}
+	keys := make([]xactkey, 0, len(done))
+	for key := range done {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].status < keys[j].status
+	})
+	m.family("xactions_done_total", "counter", "Finished and aborted xactions")
+	for _, key := range keys {
+		m.sample("xactions_done_total", done[key], "kind", key.kind, "status", key.status)
+	}
+}
+
+func (m *metricswriter) write(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", metricsContentType)
+	w.Write(m.buf.Bytes())
+}
+
+func (p *proxyrunner) metricshdlr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		invalhdlr(w, r)
+		return
+	}
+	m := newmetricswriter(p.si.DaemonID, "proxy")
+	rr := getproxystatsrunner()
+	rr.Lock()
+	m.counters(&rr.Core)
+	rr.Unlock()
+	m.xactions(p.xactinp)
+
+	ctx.smap.lock()
+	smapversion, ntargets, primary := ctx.smap.version(), ctx.smap.count(), p.isprimary()
+	ctx.smap.unlock()
+	m.gauge("smap_version", "Cluster map version", smapversion)
+	m.gauge("lbmap_version", "Local buckets metadata version", p.lbmap.versionLocked())
+	m.gauge("targets", "Number of targets in the cluster map", int64(ntargets))
+	var isprimary int64
+	if primary {
+		isprimary = 1
+	}
+	m.gauge("proxy_primary", "1 if the proxy is the primary", isprimary)
+	m.write(w)
+}
+
+func (t *targetrunner) metricshdlr(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		invalhdlr(w, r)
+		return
+	}
+	m := newmetricswriter(t.si.DaemonID, "target")
+	rr := getstorstatsrunner()
+	rr.Lock()
+	m.counters(&rr.Core)
+	m.capacity(rr.Capacity)
+	m.buckets(rr.Buckets)
+	rr.Unlock()
+	m.xactions(t.xactinp)
+
+	smap, lbmap := t.smap, t.lbmap
+	m.gauge("smap_version", "Cluster map version", smap.Version)
+	m.gauge("lbmap_version", "Local buckets metadata version", lbmap.Version)
+	m.gauge("targets", "Number of targets in the cluster map", int64(smap.count()))
+	m.write(w)
+}
@@ -0,0 +1,361 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OneOfOne/xxhash"
+	"github.com/golang/glog"
+)
+
+// Multipart upload:
+// - mpinit generates upload ID and stores the upload's manifest under hrwMpath(uploadID);
+// - each part is received into mpath/multipart/uploadID/part.NNNNN where the mpath is
+//   selected by hrwMpath(uploadID/partnum) - hence, parts are spread across local filesystems;
+// - mpcomplete concatenates the parts in the order of their numbers, computes the checksum
+//   of the resulting object and commits it the same way as a regular PUT;
+// - uploads that show no activity during the configured abandon time are removed by mpcleanup.
+const (
+	mpDir             = "multipart"
+	mpManifest        = "manifest"
+	mpPartPrefix      = "part."
+	mpCompleting      = ".completing"
+	mpAbandonTime     = 24 * time.Hour // default
+	mpCleanupInterval = 10 * time.Minute
+)
+
+type mpmanifest struct {
+	Bucket  string    `json:"bucket"`
+	Objname string    `json:"objname"`
+	Started time.Time `json:"started"`
+}
+
+type mppart struct {
+	num int
+	fqn string
+}
+
+type mppartlist []mppart
+
+func (l mppartlist) Len() int           { return len(l) }
+func (l mppartlist) Less(i, j int) bool { return l[i].num < l[j].num }
+func (l mppartlist) Swap(i, j int)      { l[i], l[j] = l[j], l[i] }
+
+func mpuploaddir(mpath, uploadid string) string {
+	return filepath.Join(mpath, mpDir, uploadid)
+}
+
+func mpmanifestfqn(uploadid string) string {
+	return filepath.Join(mpuploaddir(hrwMpath(uploadid), uploadid), mpManifest)
+}
+
+func mppartfqn(uploadid string, partnum int) string {
+	name := fmt.Sprintf("%s%05d", mpPartPrefix, partnum)
+	return filepath.Join(mpuploaddir(hrwMpath(uploadid+"/"+strconv.Itoa(partnum)), uploadid), name)
+}
+
+// upload IDs are used as directory names and must not contain path separators
+func mpvalidid(uploadid string) bool {
+	return uploadid != "" && !strings.ContainsAny(uploadid, "/.")
+}
+
+//==============================================================
+//
+// multipart upload: initiate, put part, complete, abort
+//
+//==============================================================
+func (t *targetrunner) mpinit(w http.ResponseWriter, r *http.Request) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Rfiles); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	uploadid := strconv.FormatInt(time.Now().UnixNano(), 36) + "-" + strconv.FormatInt(rand.Int63(), 36)
+	manifest := &mpmanifest{Bucket: bucket, Objname: objname, Started: time.Now()}
+	fqn := mpmanifestfqn(uploadid)
+	if err := CreateDir(filepath.Dir(fqn)); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to create multipart upload dir, err: %v", err))
+		return
+	}
+	if err := localSave(fqn, manifest); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to store multipart upload manifest %s, err: %v", fqn, err))
+		return
+	}
+	glog.Infof("Multipart upload %s/%s: initiated, upload ID %s", bucket, objname, uploadid)
+	jsbytes, err := json.Marshal(&MPUploadMsg{UploadID: uploadid})
+	assert(err == nil, err)
+	t.writeJSON(w, r, jsbytes, "mpinit")
+}
+
+// mpgetmanifest loads and validates the upload's manifest
+func (t *targetrunner) mpgetmanifest(bucket, objname, uploadid string) (manifest *mpmanifest, errstr string, errcode int) {
+	if !mpvalidid(uploadid) {
+		return nil, fmt.Sprintf("Invalid multipart upload ID %q", uploadid), http.StatusBadRequest
+	}
+	manifest = &mpmanifest{}
+	if err := localLoad(mpmanifestfqn(uploadid), manifest); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Sprintf("Multipart upload %s does not exist", uploadid), http.StatusNotFound
+		}
+		return nil, fmt.Sprintf("Failed to load multipart upload %s manifest, err: %v", uploadid, err), 0
+	}
+	if manifest.Bucket != bucket || manifest.Objname != objname {
+		return nil, fmt.Sprintf("Multipart upload %s: %s/%s does not match %s/%s",
+			uploadid, bucket, objname, manifest.Bucket, manifest.Objname), http.StatusBadRequest
+	}
+	return
+}
+
+func (t *targetrunner) mpputpart(w http.ResponseWriter, r *http.Request, bucket, objname, uploadid, partnumstr string) {
+	partnum, err := strconv.Atoi(partnumstr)
+	if err != nil || partnum < 1 {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Invalid multipart upload part number %q", partnumstr))
+		return
+	}
+	if _, errstr, errcode := t.mpgetmanifest(bucket, objname, uploadid); errstr != "" {
+		t.mpinvalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	fqn := mppartfqn(uploadid, partnum)
+	putfqn := fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
+	hdhobj := newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
+	_, nhobj, written, errstr := t.receive(putfqn, false, bucket, objname, "", hdhobj, r.Body)
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	// re-uploaded part replaces the previous one
+	if err = os.Rename(putfqn, fqn); err != nil {
+		os.Remove(putfqn)
+		t.invalmsghdlr(w, r, fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", putfqn, fqn, err))
+		return
+	}
+	if errstr = finalizeobj(fqn, nhobj); errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+		return
+	}
+	// activity timestamp (see mpcleanup)
+	now := time.Now()
+	if err = os.Chtimes(mpmanifestfqn(uploadid), now, now); err != nil {
+		glog.Warningf("Multipart upload %s: failed to touch the manifest, err: %v", uploadid, err)
+	}
+	if nhobj != nil {
+		htype, hval := nhobj.get()
+		w.Header().Add(HeaderDfcChecksumType, htype)
+		w.Header().Add(HeaderDfcChecksumVal, hval)
+	}
+	if glog.V(3) {
+		glog.Infof("Multipart upload %s/%s (%s): part %d, size %d", bucket, objname, uploadid, partnum, written)
+	}
+	t.statsif.add("numrecvbytes", written)
+}
+
+func (t *targetrunner) mpcomplete(w http.ResponseWriter, r *http.Request, uploadid string) {
+	apitems := t.restAPIItems(r.URL.Path, 5)
+	if apitems = t.checkRestAPI(w, r, apitems, 2, Rversion, Rfiles); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	if _, errstr, errcode := t.mpgetmanifest(bucket, objname, uploadid); errstr != "" {
+		t.mpinvalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	// only one completion at a time
+	manifestfqn := mpmanifestfqn(uploadid)
+	if err := os.Rename(manifestfqn, manifestfqn+mpCompleting); err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Multipart upload %s is being completed or aborted", uploadid), http.StatusConflict)
+		return
+	}
+	errstr, errcode := t.mpconcat(r, bucket, objname, uploadid)
+	if errstr != "" {
+		// keep the parts to allow for a retry
+		if err := os.Rename(manifestfqn+mpCompleting, manifestfqn); err != nil {
+			glog.Errorf("Nested error %s => (rename %s => err: %v)", errstr, manifestfqn, err)
+		}
+		t.mpinvalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	t.mpremove(uploadid)
+	glog.Infof("Multipart upload %s/%s (%s): completed", bucket, objname, uploadid)
+}
+
+// mpconcat concatenates the parts into a new object while computing its checksum, and commits
+func (t *targetrunner) mpconcat(r *http.Request, bucket, objname, uploadid string) (errstr string, errcode int) {
+	var (
+		nhobj   cksumvalue
+		written int64
+	)
+	parts, errstr := t.mpparts(uploadid)
+	if errstr != "" {
+		return
+	}
+	if len(parts) == 0 {
+		return fmt.Sprintf("Multipart upload %s has no parts", uploadid), http.StatusBadRequest
+	}
+	for i, part := range parts {
+		if part.num != i+1 {
+			return fmt.Sprintf("Multipart upload %s: missing part %d", uploadid, i+1), http.StatusBadRequest
+		}
+	}
+	cksumcfg := t.lbmap.cksumconf(bucket)
+	fqn := t.fqn(bucket, objname)
+	putfqn := fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
+	file, err := CreateFile(putfqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to create %s, err: %v", putfqn, err), 0
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	xx := xxhash.New64()
+	for _, part := range parts {
+		var (
+			partfile *os.File
+			n        int64
+		)
+		if partfile, err = os.Open(part.fqn); err != nil {
+			errstr = fmt.Sprintf("Failed to open %s, err: %v", part.fqn, err)
+			break
+		}
+		if cksumcfg.Checksum != ChecksumNone {
+			n, errstr = ReceiveAndChecksum(file, partfile, buf, xx)
+		} else {
+			n, errstr = ReceiveAndChecksum(file, partfile, buf)
+		}
+		partfile.Close()
+		if errstr != "" {
+			break
+		}
+		written += n
+	}
+	if err = file.Close(); err != nil && errstr == "" {
+		errstr = fmt.Sprintf("Failed to close %s, err: %v", putfqn, err)
+	}
+	if errstr != "" {
+		os.Remove(putfqn)
+		return
+	}
+	// combined checksum
+	if cksumcfg.Checksum != ChecksumNone {
+		hashInBytes := make([]byte, 8)
+		binary.BigEndian.PutUint64(hashInBytes, xx.Sum64())
+		nhobj = newcksumvalue(ChecksumXXHash, hex.EncodeToString(hashInBytes))
+		// validate when and if provided
+		if hdhobj := newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal)); hdhobj != nil {
+			htype, hval := hdhobj.get()
+			_, nhval := nhobj.get()
+			if htype != ChecksumXXHash || hval != nhval {
+				os.Remove(putfqn)
+				t.statsif.add("numbadchecksum", 1)
+				t.statsif.add("bytesbadchecksum", written)
+				return fmt.Sprintf("Bad checksum: %s/%s %s %s != %s", bucket, objname, htype, hval, nhval), 0
+			}
+		}
+	}
+	if errstr, errcode = t.putCommit(spanof(r), bucket, objname, putfqn, fqn, nhobj, false); errstr != "" {
+		return
+	}
+	t.statsif.add("numrecvfiles", 1)
+	return
+}
+
+func (t *targetrunner) mpabort(w http.ResponseWriter, r *http.Request, bucket, objname, uploadid string) {
+	if _, errstr, errcode := t.mpgetmanifest(bucket, objname, uploadid); errstr != "" {
+		t.mpinvalmsghdlr(w, r, errstr, errcode)
+		return
+	}
+	t.mpremove(uploadid)
+	glog.Infof("Multipart upload %s/%s (%s): aborted", bucket, objname, uploadid)
+}
+
+func (t *targetrunner) mpinvalmsghdlr(w http.ResponseWriter, r *http.Request, errstr string, errcode int) {
+	if errcode == 0 {
+		t.invalmsghdlr(w, r, errstr)
+	} else {
+		t.invalmsghdlr(w, r, errstr, errcode)
+	}
+}
+
+// mpparts returns the upload's parts sorted by part numbers
+func (t *targetrunner) mpparts(uploadid string) (parts mppartlist, errstr string) {
+	for mpath := range ctx.mountpaths {
+		dir := mpuploaddir(mpath, uploadid)
+		finfos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Sprintf("Failed to read %s, err: %v", dir, err)
+		}
+		for _, finfo := range finfos {
+			name := finfo.Name()
+			if !strings.HasPrefix(name, mpPartPrefix) {
+				continue
+			}
+			num, err := strconv.Atoi(name[len(mpPartPrefix):])
+			if err != nil {
+				continue // part being received
+			}
+			parts = append(parts, mppart{num: num, fqn: filepath.Join(dir, name)})
+		}
+	}
+	sort.Sort(parts)
+	return
+}
+
+func (t *targetrunner) mpremove(uploadid string) {
+	for mpath := range ctx.mountpaths {
+		dir := mpuploaddir(mpath, uploadid)
+		if err := os.RemoveAll(dir); err != nil {
+			glog.Errorf("Failed to remove multipart upload dir %s, err: %v", dir, err)
+		}
+	}
+}
+
+// mpcleanup removes abandoned uploads: the activity time is the manifest's mtime
+// if the manifest exists, and the directory's own mtime otherwise
+func (t *targetrunner) mpcleanup() {
+	abandon := ctx.config.Multipart.AbandonTime
+	for mpath := range ctx.mountpaths {
+		finfos, err := ioutil.ReadDir(filepath.Join(mpath, mpDir))
+		if err != nil {
+			continue
+		}
+		for _, finfo := range finfos {
+			if !finfo.IsDir() {
+				continue
+			}
+			uploadid, mtime := finfo.Name(), finfo.ModTime()
+			if minfo, err := os.Stat(mpmanifestfqn(uploadid)); err == nil {
+				mtime = minfo.ModTime()
+			} else if _, err = os.Stat(mpmanifestfqn(uploadid) + mpCompleting); err == nil {
+				continue
+			}
+			if time.Since(mtime) < abandon {
+				continue
+			}
+			dir := mpuploaddir(mpath, uploadid)
+			if err = os.RemoveAll(dir); err != nil {
+				glog.Errorf("Failed to remove abandoned multipart upload dir %s, err: %v", dir, err)
+			} else {
+				glog.Infof("Removed abandoned multipart upload %s (%s)", uploadid, dir)
+			}
+		}
+	}
+}
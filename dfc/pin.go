@@ -0,0 +1,290 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// pinning: {"action": "pin"} on a list or range of objects makes the objects non-evictable
+// by the LRU xaction, either indefinitely or until the expiration (ActionMsg.Name,
+// e.g. "24h"); {"action": "unpin"} removes the pins. The pin is stored in the object's
+// xattrs (xattrPinned) as the expiration time in Unix nanoseconds, 0 - never expires.
+// Only the objects that are stored on their HRW targets are pinned
+//
+//======
+
+const xattrPinned = "user.obj.dfcpin"
+
+type xactPin struct {
+	xactBase
+	targetrunner *targetrunner
+}
+
+// getpin returns the pin's expiration (zero time - never expires)
+func getpin(fqn string) (expiry time.Time, pinned bool) {
+	b, errstr := Getxattr(fqn, xattrPinned)
+	if errstr != "" || len(b) == 0 {
+		return
+	}
+	nanos, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		glog.Errorf("Invalid pin %q of %s, err: %v", string(b), fqn, err)
+		return
+	}
+	if nanos == 0 {
+		return expiry, true
+	}
+	expiry = time.Unix(0, nanos)
+	return expiry, time.Now().Before(expiry)
+}
+
+func ispinned(fqn string) bool {
+	_, pinned := getpin(fqn)
+	return pinned
+}
+
+func setpin(fqn string, expiry time.Time) string {
+	var nanos int64
+	if !expiry.IsZero() {
+		nanos = expiry.UnixNano()
+	}
+	return Setxattr(fqn, xattrPinned, []byte(strconv.FormatInt(nanos, 10)))
+}
+
+func unpin(fqn string) string {
+	if b, errstr := Getxattr(fqn, xattrPinned); errstr != "" || len(b) == 0 {
+		return ""
+	}
+	return Deletexattr(fqn, xattrPinned)
+}
+
+// pinheader is sent along with the pinned object by sendfile
+func pinheader(fqn string) string {
+	expiry, pinned := getpin(fqn)
+	if !pinned {
+		return ""
+	}
+	if expiry.IsZero() {
+		return "0"
+	}
+	return strconv.FormatInt(expiry.UnixNano(), 10)
+}
+
+// the receiving side of the above
+func pinfromheader(fqn, hdr string) {
+	if hdr == "" {
+		return
+	}
+	nanos, err := strconv.ParseInt(hdr, 10, 64)
+	if err != nil {
+		glog.Errorf("Invalid %s %q, err: %v", HeaderDfcPinned, hdr, err)
+		return
+	}
+	var expiry time.Time
+	if nanos != 0 {
+		expiry = time.Unix(0, nanos)
+	}
+	if errstr := setpin(fqn, expiry); errstr != "" {
+		glog.Errorln(errstr)
+	}
+}
+
+//=================
+//
+// pin/unpin list and range
+//
+//=================
+
+func (t *targetrunner) pinfiles(w http.ResponseWriter, r *http.Request, msg ActionMsg) {
+	var (
+		pin    = msg.Action == ActPin
+		expiry time.Time
+	)
+	if pin && msg.Name != "" {
+		d, err := time.ParseDuration(msg.Name)
+		if err != nil || d <= 0 {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Invalid pin expiration %q (expecting positive duration, e.g. 24h)", msg.Name))
+			return
+		}
+		expiry = time.Now().Add(d)
+	}
+	jsmap, ok := msg.Value.(map[string]interface{})
+	if !ok {
+		t.invalmsghdlr(w, r, "Could not parse List/Range Message: ActionMsg.Value was not map[string]interface{}")
+		return
+	}
+	if _, ok := jsmap["objnames"]; ok {
+		// Pin with List
+		if pinMsg, err := parseListMsg(jsmap); err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse PinMsg: %v", err))
+		} else {
+			t.listOperation(w, r, msg.Action, pinMsg,
+				func(objs []string, bucket string, deadline time.Duration, done chan struct{}, job *lrjob) error {
+					return t.doListPin(pin, expiry, objs, bucket, deadline, done, job)
+				})
+		}
+	} else {
+		// Pin with Range
+		if pinMsg, err := parseRangeMsg(jsmap); err != nil {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Could not parse PinMsg: %v", err))
+		} else {
+			t.rangeOperation(w, r, msg.Action, pinMsg,
+				func(bucket, prefix, regex string, min, max int64, deadline time.Duration, done chan struct{}, job *lrjob) error {
+					objs, err := t.getLocalListFromRange(bucket, prefix, regex, min, max)
+					if err != nil {
+						job.finish(err)
+						if done != nil {
+							done <- struct{}{}
+						}
+						return err
+					}
+					return t.doListPin(pin, expiry, objs, bucket, deadline, done, job)
+				})
+		}
+	}
+}
+
+func (t *targetrunner) doListPin(pin bool, expiry time.Time, objs []string, bucket string, deadline time.Duration,
+	done chan struct{}, job *lrjob) (err error) {
+	xpin := t.xactinp.newPin(t, pin)
+	job.settotal(len(objs))
+	defer func() {
+		job.finish(err)
+		if done != nil {
+			var v struct{}
+			done <- v
+		}
+		xpin.etime = time.Now()
+		t.xactinp.del(xpin.id)
+	}()
+
+	var absdeadline time.Time
+	if deadline != 0 {
+		absdeadline = time.Now().Add(deadline)
+	}
+	var npinned, nfailed int
+	for _, objname := range objs {
+		select {
+		case <-xpin.abrt:
+			return nil
+		default:
+		}
+		if job.iscancelled() {
+			return nil
+		}
+		if !absdeadline.IsZero() && time.Now().After(absdeadline) {
+			break
+		}
+		fqn := t.fqn(bucket, objname)
+		if _, errstat := os.Stat(fqn); errstat != nil {
+			if os.IsNotExist(errstat) {
+				glog.Warningf("%s: %s/%s is not cached (not pinning)", xpin.tostring(), bucket, objname)
+			} else {
+				glog.Errorf("%s: failed to stat %s, err: %v", xpin.tostring(), fqn, errstat)
+			}
+			job.done(objname, errstat)
+			nfailed++
+			continue
+		}
+		var errstr string
+		if pin {
+			errstr = setpin(fqn, expiry)
+		} else {
+			errstr = unpin(fqn)
+		}
+		if errstr != "" {
+			glog.Errorln(errstr)
+			job.done(objname, errors.New(errstr))
+			nfailed++
+			continue
+		}
+		job.done(objname, nil)
+		npinned++
+		xpin.progress(1, 0)
+	}
+	glog.Infof("%s: bucket %s, %d objects done, %d failed", xpin.tostring(), bucket, npinned, nfailed)
+	if nfailed > 0 {
+		return fmt.Errorf("%s: failed to process %d out of %d objects", xpin.tostring(), nfailed, len(objs))
+	}
+	return nil
+}
+
+// getLocalListFromRange returns the names of the objects stored on this (HRW) target
+// that match the range; unlike getListFromRange, does not list the Cloud
+func (t *targetrunner) getLocalListFromRange(bucket, prefix, regex string, min, max int64) ([]string, error) {
+	re, err := regexp.Compile(regex)
+	if err != nil {
+		return nil, fmt.Errorf("Could not compile regex: %v", err)
+	}
+	dir := ctx.config.CloudBuckets
+	if t.islocalBucket(bucket) {
+		dir = ctx.config.LocalBuckets
+	}
+	objs := make([]string, 0, 64)
+	for mpath := range ctx.mountpaths {
+		bucketfqn := mpath + "/" + dir + "/" + bucket
+		if _, err := os.Stat(bucketfqn); err != nil {
+			continue
+		}
+		rootLength := len(bucketfqn) + 1
+		err := filepath.Walk(bucketfqn, func(fqn string, osfi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if osfi.IsDir() || strings.HasPrefix(osfi.Name(), ".") {
+				return nil
+			}
+			objname := fqn[rootLength:]
+			if !strings.HasPrefix(objname, prefix) || !acceptRegexRange(objname, prefix, re, min, max) {
+				return nil
+			}
+			if si, errstr := hrwTarget(bucket+"/"+objname, t.smap); errstr != "" || si.DaemonID != t.si.DaemonID {
+				return nil
+			}
+			objs = append(objs, objname)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to traverse %q, err: %v", bucketfqn, err)
+		}
+	}
+	return objs, nil
+}
+
+func (q *xactInProgress) newPin(t *targetrunner, pin bool) *xactPin {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	kind := ActUnpin
+	if pin {
+		kind = ActPin
+	}
+	id := q.uniqueid()
+	xpin := &xactPin{xactBase: *newxactBase(id, kind), targetrunner: t}
+	q.add(xpin)
+	return xpin
+}
+
+func (xact *xactPin) tostring() string {
+	start := xact.stime.Sub(xact.targetrunner.starttime)
+	if !xact.finished() {
+		return fmt.Sprintf("xaction %s:%d started %v", xact.kind, xact.id, start)
+	}
+	fin := time.Since(xact.targetrunner.starttime)
+	return fmt.Sprintf("xaction %s:%d started %v finished %v", xact.kind, xact.id, start, fin)
+}
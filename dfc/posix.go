@@ -0,0 +1,360 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+const posixPageSize = 1000
+
+//======
+//
+// implements cloudif: the "cloud" is a local or mounted (e.g., NFS) directory
+// tree with one subdirectory per bucket; object versions are mtimes in nanoseconds
+//
+//======
+type posiximpl struct {
+	t    *targetrunner
+	root string
+}
+
+func posixErrorToHTTP(err error) int {
+	if os.IsNotExist(err) {
+		return http.StatusNotFound
+	}
+	if os.IsPermission(err) {
+		return http.StatusForbidden
+	}
+	return http.StatusInternalServerError
+}
+
+// bucketpath returns the bucket's directory - a subdirectory of the root
+// (names that come with list/range messages are not cleaned by the http mux)
+func (posiximpl *posiximpl) bucketpath(bucket string) (bckpath, errstr string) {
+	root := filepath.Clean(posiximpl.root)
+	bckpath = filepath.Join(root, bucket)
+	if bucket == "" || filepath.Dir(bckpath) != root {
+		return "", fmt.Sprintf("posix: Invalid bucket name %q", bucket)
+	}
+	return
+}
+
+// objpath returns the object's path - under the bucket's directory
+func (posiximpl *posiximpl) objpath(bucket, objname string) (fqn, errstr string) {
+	bckpath, errstr := posiximpl.bucketpath(bucket)
+	if errstr != "" {
+		return
+	}
+	fqn = filepath.Join(bckpath, objname)
+	if !strings.HasPrefix(fqn, bckpath+string(filepath.Separator)) {
+		return "", fmt.Sprintf("posix: Invalid object name %q (bucket %s)", objname, bucket)
+	}
+	return
+}
+
+func posixversion(finfo os.FileInfo) string {
+	return strconv.FormatInt(finfo.ModTime().UnixNano(), 10)
+}
+
+//======
+//
+// methods
+//
+//======
+func (posiximpl *posiximpl) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	glog.Infof("posix: listbucket %s", bucket)
+	bckpath, errstr := posiximpl.bucketpath(bucket)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	if _, err := os.Stat(bckpath); err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to list objects of bucket %s, err: %v", bucket, err)
+		return
+	}
+	var reslist = BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
+	pagesize := posixPageSize
+	if msg.GetPageSize > 0 {
+		pagesize = msg.GetPageSize
+	}
+	// filepath.Walk visits the entries of each directory in lexical order, which is not
+	// the order of the full names ("a/b" is visited before "a.txt") - hence, the names
+	// are collected and sorted, and only then paged
+	type posixobj struct {
+		name, fqn string
+		osfi      os.FileInfo
+	}
+	var objs []posixobj
+	walkfn := func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil // removed while listing
+			}
+			return err
+		}
+		if osfi.IsDir() || strings.HasSuffix(fqn, ".tmp") {
+			return nil
+		}
+		objname, err := filepath.Rel(bckpath, fqn)
+		if err != nil {
+			return err
+		}
+		objname = filepath.ToSlash(objname)
+		if msg.GetPrefix != "" && !strings.HasPrefix(objname, msg.GetPrefix) {
+			return nil
+		}
+		if msg.GetPageMarker != "" && objname <= msg.GetPageMarker {
+			return nil
+		}
+		objs = append(objs, posixobj{name: objname, fqn: fqn, osfi: osfi})
+		return nil
+	}
+	if err := filepath.Walk(bckpath, walkfn); err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to list objects of bucket %s, err: %v", bucket, err)
+		return
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].name < objs[j].name })
+	if len(objs) > pagesize {
+		objs = objs[:pagesize]
+		reslist.PageMarker = objs[pagesize-1].name
+	}
+	for _, obj := range objs {
+		fqn, osfi := obj.fqn, obj.osfi
+		entry := &BucketEntry{Name: obj.name}
+		if strings.Contains(msg.GetProps, GetPropsSize) {
+			entry.Size = osfi.Size()
+		}
+		if strings.Contains(msg.GetProps, GetPropsBucket) {
+			entry.Bucket = bucket
+		}
+		if strings.Contains(msg.GetProps, GetPropsCtime) {
+			t := osfi.ModTime()
+			switch msg.GetTimeFormat {
+			case "":
+				fallthrough
+			case RFC822:
+				entry.Ctime = t.Format(time.RFC822)
+			default:
+				entry.Ctime = t.Format(msg.GetTimeFormat)
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsChecksum) {
+			if xxhash, errstr := Getxattr(fqn, xattrXXHashVal); errstr == "" {
+				entry.Checksum = string(xxhash)
+			}
+		}
+		if strings.Contains(msg.GetProps, GetPropsVersion) {
+			entry.Version = posixversion(osfi)
+		}
+		reslist.Entries = append(reslist.Entries, entry)
+	}
+	if glog.V(3) {
+		glog.Infof("listbucket count %d", len(reslist.Entries))
+	}
+	jsbytes, err := json.Marshal(reslist)
+	assert(err == nil, err)
+	return
+}
+
+func (posiximpl *posiximpl) headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int) {
+	glog.Infof("posix: headbucket %s", bucket)
+	bucketprops = make(map[string]string)
+	bckpath, errstr := posiximpl.bucketpath(bucket)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	finfo, err := os.Stat(bckpath)
+	if err == nil && !finfo.IsDir() {
+		err = fmt.Errorf("%s is not a directory", bckpath)
+	}
+	if err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: The bucket %s either does not exist or is not accessible, err: %v", bucket, err)
+		return
+	}
+	bucketprops[HeaderServer] = posixcloud
+	return
+}
+
+func (posiximpl *posiximpl) headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int) {
+	glog.Infof("posix: headobject %s/%s", bucket, objname)
+	objmeta = make(map[string]string)
+	fqn, errstr := posiximpl.objpath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to retrieve %s/%s metadata, err: %v", bucket, objname, err)
+		return
+	}
+	objmeta[HeaderServer] = posixcloud
+	objmeta["version"] = posixversion(finfo)
+	for k, v := range getusermeta(fqn) {
+		objmeta[HeaderDfcMetaPrefix+k] = v
+	}
+	return
+}
+
+func (posiximpl *posiximpl) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
+	var v cksumvalue
+	srcpath, errstr := posiximpl.objpath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	file, err := os.Open(srcpath)
+	if err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		errstr = fmt.Sprintf("posix: Failed to stat %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	// may not have dfc metadata
+	if xxhash, errstr := Getxattr(srcpath, xattrXXHashVal); errstr == "" && xxhash != nil {
+		v = newcksumvalue(ChecksumXXHash, string(xxhash))
+	}
+	props = &objectProps{usermeta: getusermeta(srcpath)}
+	if _, props.nhobj, props.size, errstr = posiximpl.t.receive(fqn, false, bucket, objname, "", v, file); errstr != "" {
+		return
+	}
+	props.version = posixversion(finfo)
+	if glog.V(3) {
+		glog.Infof("posix: GET %s/%s", bucket, objname)
+	}
+	return
+}
+
+func (posiximpl *posiximpl) getobjrange(bucket, objname string, offset, length int64) (rc io.ReadCloser, objsize int64, errstr string, errcode int) {
+	srcpath, errstr := posiximpl.objpath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	file, err := os.Open(srcpath)
+	if err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to GET %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	finfo, err := file.Stat()
+	if err == nil {
+		_, err = file.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		errstr = fmt.Sprintf("posix: Failed to GET %s/%s (offset %d), err: %v", bucket, objname, offset, err)
+		return
+	}
+	objsize = finfo.Size()
+	if offset >= objsize {
+		file.Close()
+		errcode = http.StatusRequestedRangeNotSatisfiable
+		errstr = fmt.Sprintf("posix: Invalid offset %d (%s/%s size %d)", offset, bucket, objname, objsize)
+		return
+	}
+	if length < 0 || offset+length > objsize {
+		length = objsize - offset
+	}
+	rc = &posixrangereader{Reader: io.LimitReader(file, length), file: file}
+	return
+}
+
+type posixrangereader struct {
+	io.Reader
+	file *os.File
+}
+
+func (r *posixrangereader) Close() error { return r.file.Close() }
+
+func (posiximpl *posiximpl) putobj(file *os.File, bucket, objname string, ohash cksumvalue, usermeta map[string]string) (errstr string, errcode int) {
+	dstpath, errstr := posiximpl.objpath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	tmppath := dstpath + ".tmp"
+	dst, err := CreateFile(tmppath)
+	if err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to create %s, err: %v", tmppath, err)
+		return
+	}
+	slab := selectslab(0)
+	buf := slab.alloc()
+	written, err := io.CopyBuffer(dst, file, buf)
+	slab.free(buf)
+	if err == nil {
+		err = dst.Close()
+	} else {
+		dst.Close()
+	}
+	if err != nil {
+		os.Remove(tmppath)
+		errstr = fmt.Sprintf("posix: PUT %s/%s: failed to copy, err: %v", bucket, objname, err)
+		return
+	}
+	if ohash != nil {
+		if htype, hval := ohash.get(); htype == ChecksumXXHash {
+			if errstr = Setxattr(tmppath, xattrXXHashVal, []byte(hval)); errstr != "" {
+				os.Remove(tmppath)
+				return
+			}
+		}
+	}
+	if errstr = setusermeta(tmppath, usermeta); errstr != "" {
+		os.Remove(tmppath)
+		return
+	}
+	if err = os.Rename(tmppath, dstpath); err != nil {
+		os.Remove(tmppath)
+		errstr = fmt.Sprintf("posix: PUT %s/%s: failed to rename, err: %v", bucket, objname, err)
+		return
+	}
+	if glog.V(3) {
+		glog.Infof("posix: PUT %s/%s, size %d", bucket, objname, written)
+	}
+	return
+}
+
+func (posiximpl *posiximpl) deleteobj(bucket, objname string) (errstr string, errcode int) {
+	fqn, errstr := posiximpl.objpath(bucket, objname)
+	if errstr != "" {
+		errcode = http.StatusBadRequest
+		return
+	}
+	if err := os.Remove(fqn); err != nil {
+		errcode = posixErrorToHTTP(err)
+		errstr = fmt.Sprintf("posix: Failed to DELETE %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	if glog.V(3) {
+		glog.Infof("posix: DELETE %s/%s", bucket, objname)
+	}
+	return
+}
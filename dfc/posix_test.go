@@ -0,0 +1,167 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func newposixtest(t *testing.T, objnames ...string) (*posiximpl, func()) {
+	root, err := ioutil.TempDir("", "posix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, objname := range objnames {
+		fqn := filepath.Join(root, "bucket", objname)
+		if err := os.MkdirAll(filepath.Dir(fqn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fqn, []byte(objname), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &posiximpl{root: root}, func() { os.RemoveAll(root) }
+}
+
+func TestPosixListPages(t *testing.T) {
+	// the walk visits a/b/c before a/b.txt, and the whole of a/ before a-b and a.txt
+	expected := []string{"a-b", "a.txt", "a/b.txt", "a/b/c", "a/c", "b", "c/d/e"}
+	posiximpl, cleanup := newposixtest(t, expected...)
+	defer cleanup()
+
+	for pagesize := 1; pagesize <= len(expected)+1; pagesize++ {
+		var (
+			names []string
+			msg   = &GetMsg{GetPageSize: pagesize}
+		)
+		for pages := 0; ; pages++ {
+			if pages > len(expected) {
+				t.Fatalf("page size %d: too many pages", pagesize)
+			}
+			jsbytes, errstr, _ := posiximpl.listbucket("bucket", msg)
+			if errstr != "" {
+				t.Fatalf("page size %d: %s", pagesize, errstr)
+			}
+			var page BucketList
+			if err := json.Unmarshal(jsbytes, &page); err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Entries) > pagesize {
+				t.Fatalf("page size %d: got %d entries", pagesize, len(page.Entries))
+			}
+			for _, entry := range page.Entries {
+				names = append(names, entry.Name)
+			}
+			if page.PageMarker == "" {
+				break
+			}
+			msg.GetPageMarker = page.PageMarker
+		}
+		if !reflect.DeepEqual(names, expected) {
+			t.Errorf("page size %d: listed %v, expecting %v", pagesize, names, expected)
+		}
+	}
+
+	jsbytes, errstr, _ := posiximpl.listbucket("bucket", &GetMsg{GetPrefix: "a/b"})
+	if errstr != "" {
+		t.Fatal(errstr)
+	}
+	var page BucketList
+	if err := json.Unmarshal(jsbytes, &page); err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Entries) != 2 || page.Entries[0].Name != "a/b.txt" || page.PageMarker != "" {
+		t.Errorf("prefix a/b: listed %+v", page)
+	}
+}
+
+func TestPosixContainment(t *testing.T) {
+	posiximpl, cleanup := newposixtest(t, "obj")
+	defer cleanup()
+	outside := filepath.Join(posiximpl.root, "outside")
+	if err := ioutil.WriteFile(outside, []byte("outside"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, objname := range []string{"../outside", "../../etc/passwd", "a/../../outside", "..", ""} {
+		if _, errstr := posiximpl.objpath("bucket", objname); errstr == "" {
+			t.Errorf("object %q: expecting invalid name", objname)
+		}
+		if _, errcode := posiximpl.deleteobj("bucket", objname); errcode != http.StatusBadRequest {
+			t.Errorf("delete %q: status %d, expecting %d", objname, errcode, http.StatusBadRequest)
+		}
+		if _, errstr, errcode := posiximpl.headobject("bucket", objname); errstr == "" || errcode != http.StatusBadRequest {
+			t.Errorf("head %q: status %d, expecting %d", objname, errcode, http.StatusBadRequest)
+		}
+	}
+	for _, bucket := range []string{"..", "../bucket", "bucket/..", "", "."} {
+		if _, errstr := posiximpl.bucketpath(bucket); errstr == "" {
+			t.Errorf("bucket %q: expecting invalid name", bucket)
+		}
+		if _, errstr, _ := posiximpl.listbucket(bucket, &GetMsg{}); errstr == "" {
+			t.Errorf("list %q: expecting invalid name", bucket)
+		}
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Errorf("%s: %v", outside, err)
+	}
+	if fqn, errstr := posiximpl.objpath("bucket", "a/../obj"); errstr != "" || fqn != filepath.Join(posiximpl.root, "bucket", "obj") {
+		t.Errorf("object a/../obj: %q, %s", fqn, errstr)
+	}
+}
+
+func TestPosixObjects(t *testing.T) {
+	posiximpl, cleanup := newposixtest(t)
+	defer cleanup()
+	if err := os.MkdirAll(filepath.Join(posiximpl.root, "bucket"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	src, err := ioutil.TempFile("", "posixsrc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(src.Name())
+	if _, err = src.WriteString("0123456789"); err == nil {
+		_, err = src.Seek(0, 0)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if errstr, _ := posiximpl.putobj(src, "bucket", "dir/obj", nil, nil); errstr != "" {
+		t.Fatal(errstr)
+	}
+	objmeta, errstr, _ := posiximpl.headobject("bucket", "dir/obj")
+	if errstr != "" || objmeta[HeaderServer] != posixcloud || objmeta["version"] == "" {
+		t.Errorf("head: %v, %s", objmeta, errstr)
+	}
+	rc, objsize, errstr, _ := posiximpl.getobjrange("bucket", "dir/obj", 2, 3)
+	if errstr != "" {
+		t.Fatal(errstr)
+	}
+	b, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(b) != "234" || objsize != 10 {
+		t.Errorf("range read: %q, size %d, err %v", string(b), objsize, err)
+	}
+	if _, _, _, errcode := posiximpl.getobjrange("bucket", "dir/obj", 10, -1); errcode != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("range read past the end: status %d", errcode)
+	}
+	if errstr, _ := posiximpl.deleteobj("bucket", "dir/obj"); errstr != "" {
+		t.Fatal(errstr)
+	}
+	if _, _, errcode := posiximpl.headobject("bucket", "dir/obj"); errcode != http.StatusNotFound {
+		t.Errorf("head deleted: status %d, expecting %d", errcode, http.StatusNotFound)
+	}
+	if _, _, errcode := posiximpl.headbucket("nobucket"); errcode != http.StatusNotFound {
+		t.Errorf("head missing bucket: status %d, expecting %d", errcode, http.StatusNotFound)
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 	"sync/atomic"
@@ -24,6 +25,7 @@ import (
 
 const (
 	syncmapsdelay = time.Second * 3
+	smapconfname  = "smap" // default Smap config, see dfconfig.SmapConf
 )
 
 // Keeps a target response when doing parallel requests to all targets
@@ -55,40 +57,66 @@ type proxyrunner struct {
 	xactinp     *xactInProgress
 	lbmap       *lbmap
 	syncmapinp  int64
+	metasyncer  *metasyncer
 }
 
 // start proxy runner
 func (p *proxyrunner) run() error {
 	p.httprunner.init(getproxystats())
-	ctx.smap.ProxySI = p.si
 	p.httprunner.kalive = getproxykalive()
+	p.httprunner.latency = getproxystatsrunner().Latency
+	p.metasyncer = getmetasyncer()
 
-	p.xactinp = newxactinp()
+	p.xactinp = newxactinp(p.si.DaemonID)
 	// local (aka cache-only) buckets
-	p.lbmap = &lbmap{LBmap: make(map[string]string)}
+	p.lbmap = newlbmap()
 	lbpathname := p.confdir + "/" + ctx.config.LBConf
-	p.lbmap.lock()
-	if localLoad(lbpathname, p.lbmap) != nil {
+	if err := localLoad(lbpathname, p.lbmap); err != nil {
+		if !os.IsNotExist(err) {
+			// NOTE: a newer version, if any, is then restored from the cluster (see reconcile)
+			glog.Errorf("Failed to load localbucket config %s, err: %v", lbpathname, err)
+			p.lbmap = newlbmap()
+		}
 		// create empty
 		p.lbmap.Version = 1
 		if err := localSave(lbpathname, p.lbmap); err != nil {
 			glog.Fatalf("FATAL: cannot store localbucket config, err: %v", err)
 		}
 	}
-	p.lbmap.unlock()
 
-	// startup: sync local buckets and cluster map when the latter stabilizes
-	go p.synchronizeMaps(clivars.ntargets, "")
+	if ctx.config.Proxy.Standby {
+		// the primary will then sync local buckets and cluster map
+		if err := p.join(); err != nil {
+			return err
+		}
+	} else {
+		ctx.smap.lock()
+		p.loadsmapconf()
+		ctx.smap.unlock()
+		// startup: reconcile with the cluster, and then sync local buckets and
+		// cluster map when the latter stabilizes
+		go func() {
+			p.reconcile()
+			p.synchronizeMaps(clivars.ntargets, "")
+		}()
+	}
 
 	//
 	// REST API: register proxy handlers and start listening
 	//
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rfiles+"/", p.filehdlr)
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon, p.daemonhdlr)
+	p.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon+"/", p.daemonhdlr) // FIXME
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rcluster, p.clusterhdlr)
 	p.httprunner.registerhdlr("/"+Rversion+"/"+Rcluster+"/", p.clusterhdlr) // FIXME
+	p.httprunner.registerhdlr("/"+Rversion+"/"+Rjobs+"/", p.jobhdlr)
+	p.httprunner.registerhdlr("/"+Rmetrics, p.metricshdlr)
+	p.httprunner.authorize = p.authorize
+	if p.httprunner.auth != nil {
+		p.httprunner.registerhdlr("/"+Rversion+"/"+Rtokens, p.tokenhdlr)
+	}
 	p.httprunner.registerhdlr("/", invalhdlr)
-	glog.Infof("Proxy %s is ready", p.si.DaemonID)
+	glog.Infof("Proxy %s is ready (primary: %t)", p.si.DaemonID, !ctx.config.Proxy.Standby)
 	glog.Flush()
 	p.starttime = time.Now()
 
@@ -99,6 +127,13 @@ func (p *proxyrunner) run() error {
 func (p *proxyrunner) stop(err error) {
 	glog.Infof("Stopping %s, err: %v", p.name, err)
 	p.xactinp.abortAll()
+	if !p.isprimary() {
+		if p.httprunner.h != nil {
+			p.unregister() // ignore errors
+		}
+		p.httprunner.stop(err)
+		return
+	}
 	//
 	// give targets a limited time to unregister
 	//
@@ -124,6 +159,9 @@ func (p *proxyrunner) stop(err error) {
 
 // handler for: "/"+Rversion+"/"+Rfiles+"/"
 func (p *proxyrunner) filehdlr(w http.ResponseWriter, r *http.Request) {
+	if p.redirectprimary(w, r) {
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		p.httpfilget(w, r)
@@ -169,12 +207,16 @@ func (p *proxyrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 
 	// GET
 	p.statsif.add("numget", 1)
+	spanof(r).object(bucket, objname)
 	si, errstr := hrwTarget(bucket+"/"+objname, ctx.smap)
 	if errstr != "" {
 		p.invalmsghdlr(w, r, errstr)
 		return
 	}
 	redirecturl := fmt.Sprintf("%s%s?%s=false", si.DirectURL, r.URL.Path, ParamLocal)
+	if r.URL.RawQuery != "" {
+		redirecturl += "&" + r.URL.RawQuery
+	}
 	if glog.V(3) {
 		glog.Infof("Redirecting %q to %s (%s)", r.URL.Path, si.DirectURL, r.Method)
 	}
@@ -182,15 +224,15 @@ func (p *proxyrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 		glog.Infof("passthru=false: proxy initiates the GET %s/%s", bucket, objname)
 		p.receiveDrop(w, r, redirecturl) // ignore error, proceed to http redirect
 	}
-	http.Redirect(w, r, redirecturl, http.StatusMovedPermanently)
+	p.redirect(w, r, redirecturl, http.StatusMovedPermanently)
 }
 
 // For cached = false goes to the Cloud, otherwise returns locally cached files
-func (p *proxyrunner) targetListBucket(bucket string, dinfo *daemonInfo,
+func (p *proxyrunner) targetListBucket(sp *span, bucket string, dinfo *daemonInfo,
 	reqBody []byte, islocal bool, cached bool) (response *bucketResp, err error) {
 	url := fmt.Sprintf("%s/%s/%s/%s?%s=%v&%s=%v", dinfo.DirectURL, Rversion,
 		Rfiles, bucket, ParamLocal, islocal, ParamCached, cached)
-	outjson, err, _, status := p.call(dinfo, url, http.MethodGet, reqBody, ctx.config.HTTP.Timeout)
+	outjson, err, _, status := p.calltraced(sp, dinfo, url, http.MethodGet, reqBody, ctx.config.HTTP.Timeout)
 	if err != nil {
 		p.kalive.onerr(err, status)
 	}
@@ -227,6 +269,7 @@ func (p *proxyrunner) consumeCachedList(bmap map[string]*BucketEntry,
 			if entry, ok := bmap[nm]; ok {
 				entry.IsCached = true
 				entry.Atime = newEntry.Atime
+				entry.Pinned = newEntry.Pinned
 			}
 		}
 	}
@@ -234,7 +277,7 @@ func (p *proxyrunner) consumeCachedList(bmap map[string]*BucketEntry,
 
 // Request list of all cached files from a target.
 // The target returns its list in batches `cachedPageSize` length
-func (p *proxyrunner) generateCachedList(bucket string, daemon *daemonInfo,
+func (p *proxyrunner) generateCachedList(sp *span, bucket string, daemon *daemonInfo,
 	dataCh chan *cachedFileBatch, wg *sync.WaitGroup, msg GetMsg) {
 	const (
 		cachedObjects = true
@@ -249,7 +292,7 @@ func (p *proxyrunner) generateCachedList(bucket string, daemon *daemonInfo,
 		// changes every loop run
 		listmsgjson, err := json.Marshal(&msg)
 		assert(err == nil, err)
-		resp, err := p.targetListBucket(bucket, daemon, listmsgjson, islocal, cachedObjects)
+		resp, err := p.targetListBucket(sp, bucket, daemon, listmsgjson, islocal, cachedObjects)
 		if err != nil {
 			if dataCh != nil {
 				dataCh <- &cachedFileBatch{
@@ -297,7 +340,7 @@ func (p *proxyrunner) generateCachedList(bucket string, daemon *daemonInfo,
 
 // Get list of cached files from all targets and update the list
 // of files from cloud with local metadata (iscached, atime etc)
-func (p *proxyrunner) collectCachedFileList(bucket string, fileList *BucketList, getmsgjson []byte) (err error) {
+func (p *proxyrunner) collectCachedFileList(sp *span, bucket string, fileList *BucketList, getmsgjson []byte) (err error) {
 	reqParams := GetMsg{}
 	err = json.Unmarshal(getmsgjson, &reqParams)
 	if err != nil {
@@ -318,11 +361,12 @@ func (p *proxyrunner) collectCachedFileList(bucket string, fileList *BucketList,
 	// since cached file page marker is not compatible with any cloud
 	// marker, it should be empty for the first call
 	reqParams.GetPageMarker = ""
+	reqParams.GetPageSize = 0
 
 	wg := &sync.WaitGroup{}
 	for _, daemon := range ctx.smap.Smap {
 		wg.Add(1)
-		go p.generateCachedList(bucket, daemon, dataCh, wg, reqParams)
+		go p.generateCachedList(sp, bucket, daemon, dataCh, wg, reqParams)
 	}
 	wg.Wait()
 	close(dataCh)
@@ -341,37 +385,84 @@ func (p *proxyrunner) collectCachedFileList(bucket string, fileList *BucketList,
 	return
 }
 
-func (p *proxyrunner) getLocalBucketObjects(bucket string, listmsgjson []byte) (allentries *BucketList, err error) {
+// listpage requests the same page of the local bucket, or of the bucket's cached objects,
+// from all targets in parallel and merges the target pages into one
+func (p *proxyrunner) listpage(sp *span, bucket string, msg *GetMsg, islocal, cached bool) (page *BucketList, err error) {
+	listmsgjson, err := json.Marshal(msg)
+	assert(err == nil, err)
+	var (
+		smap  = ctx.smap.Smap
+		pages = make([]*BucketList, 0, len(smap))
+		errch = make(chan error, len(smap))
+		mu    = &sync.Mutex{}
+		wg    = &sync.WaitGroup{}
+	)
+	for _, si := range smap {
+		wg.Add(1)
+		go func(si *daemonInfo) {
+			defer wg.Done()
+			resp, err := p.targetListBucket(sp, bucket, si, listmsgjson, islocal, cached)
+			if err != nil {
+				errch <- err
+				return
+			}
+			if len(resp.outjson) == 0 {
+				return
+			}
+			tpage := &BucketList{}
+			if err = json.Unmarshal(resp.outjson, tpage); err != nil {
+				errch <- err
+				return
+			}
+			mu.Lock()
+			pages = append(pages, tpage)
+			mu.Unlock()
+		}(si)
+	}
+	wg.Wait()
+	close(errch)
+	if err = <-errch; err != nil {
+		return
+	}
+	page = mergepages(pages, msg.GetPageSize)
+	return
+}
+
+func (p *proxyrunner) getLocalBucketObjects(sp *span, bucket string, msg *GetMsg) (allentries *BucketList, err error) {
 	const (
 		islocal    = true
 		cachedObjs = false
 	)
-	var resp *bucketResp
+	// with no page size, targets return all their objects that follow the marker
+	return p.listpage(sp, bucket, msg, islocal, cachedObjs)
+}
+
+// getCachedBucketObjects lists the Cloud bucket's objects that are cached in the cluster
+func (p *proxyrunner) getCachedBucketObjects(sp *span, bucket string, msg *GetMsg) (allentries *BucketList, err error) {
+	const (
+		islocal    = false
+		cachedObjs = true
+	)
+	if msg.GetPageSize > 0 {
+		return p.listpage(sp, bucket, msg, islocal, cachedObjs)
+	}
+	// targets return cached objects in pages of up to cachedPageSize - collect them all
+	pagemsg := *msg
 	allentries = &BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
-	for _, si := range ctx.smap.Smap {
-		resp, err = p.targetListBucket(bucket, si, listmsgjson, islocal, cachedObjs)
-		if err != nil {
+	for {
+		var page *BucketList
+		if page, err = p.listpage(sp, bucket, &pagemsg, islocal, cachedObjs); err != nil {
 			return
 		}
-
-		if resp.outjson == nil || len(resp.outjson) == 0 {
-			continue
-		}
-
-		entries := &BucketList{Entries: make([]*BucketEntry, 0, initialBucketListSize)}
-		if err = json.Unmarshal(resp.outjson, &entries); err != nil {
+		allentries.Entries = append(allentries.Entries, page.Entries...)
+		if page.PageMarker == "" {
 			return
 		}
-		if len(entries.Entries) == 0 {
-			continue
-		}
-
-		allentries.Entries = append(allentries.Entries, entries.Entries...)
+		pagemsg.GetPageMarker = page.PageMarker
 	}
-	return
 }
 
-func (p *proxyrunner) getCloudBucketObjects(bucket string, listmsgjson []byte) (allentries *BucketList, err error) {
+func (p *proxyrunner) getCloudBucketObjects(sp *span, bucket string, listmsgjson []byte) (allentries *BucketList, err error) {
 	const (
 		islocal       = false
 		cachedObjects = false
@@ -386,7 +477,7 @@ func (p *proxyrunner) getCloudBucketObjects(bucket string, listmsgjson []byte) (
 
 	// first, get the cloud object list from a random target
 	for _, si := range ctx.smap.Smap {
-		resp, err = p.targetListBucket(bucket, si, listmsgjson, islocal, cachedObjects)
+		resp, err = p.targetListBucket(sp, bucket, si, listmsgjson, islocal, cachedObjects)
 		if err != nil {
 			return
 		}
@@ -404,16 +495,20 @@ func (p *proxyrunner) getCloudBucketObjects(bucket string, listmsgjson []byte) (
 	}
 
 	if strings.Contains(msg.GetProps, GetPropsAtime) ||
-		strings.Contains(msg.GetProps, GetPropsIsCached) {
+		strings.Contains(msg.GetProps, GetPropsIsCached) ||
+		strings.Contains(msg.GetProps, GetPropsPinned) {
 		// Now add local properties to the cloud objects
 		// The call replaces allentries.Entries with new values
-		err = p.collectCachedFileList(bucket, allentries, listmsgjson)
+		err = p.collectCachedFileList(sp, bucket, allentries, listmsgjson)
 	}
 	return
 }
 
 func (p *proxyrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket string) {
-	var allentries *BucketList
+	var (
+		allentries *BucketList
+		started    = time.Now()
+	)
 	listmsgjson, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		s := fmt.Sprintf("listbucket: Failed to read %s request, err: %v", r.Method, err)
@@ -426,18 +521,54 @@ func (p *proxyrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 		p.invalmsghdlr(w, r, s)
 		return
 	}
-	if p.islocalBucket(bucket) {
-		allentries, err = p.getLocalBucketObjects(bucket, listmsgjson)
-	} else {
-		allentries, err = p.getCloudBucketObjects(bucket, listmsgjson)
+	msg := &GetMsg{}
+	if len(listmsgjson) > 0 {
+		if err = json.Unmarshal(listmsgjson, msg); err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("listbucket: Failed to unmarshal %s request, err: %v", r.Method, err))
+			return
+		}
+	}
+	spec, errstr := parsesort(msg.GetSort)
+	if errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	if msg.GetPageSize < 0 {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid page size %d", msg.GetPageSize))
+		return
+	}
+	// sorting by size or atime implies the respective property
+	if spec.by != GetSortName && !strings.Contains(msg.GetProps, spec.by) {
+		if msg.GetProps == "" {
+			msg.GetProps = spec.by
+		} else {
+			msg.GetProps += "," + spec.by
+		}
+	}
+	if listmsgjson, err = json.Marshal(msg); err != nil {
+		p.invalmsghdlr(w, r, err.Error())
+		return
+	}
+	cachedonly := r.URL.Query().Get(ParamCached) == "true"
+	sp := spanof(r)
+	sp.object(bucket, "")
+	switch {
+	case p.islocalBucket(bucket):
+		allentries, err = p.getLocalBucketObjects(sp, bucket, msg)
+	case cachedonly:
+		allentries, err = p.getCachedBucketObjects(sp, bucket, msg)
+	default:
+		allentries, err = p.getCloudBucketObjects(sp, bucket, listmsgjson)
 	}
 	if err != nil {
 		p.invalmsghdlr(w, r, err.Error())
 		return
 	}
+	sortentries(allentries.Entries, spec, msg.GetTimeFormat)
 	jsbytes, err := json.Marshal(allentries)
 	assert(err == nil, err)
 	p.writeJSON(w, r, jsbytes, "listbucket")
+	p.latency.add(latList, time.Since(started))
 }
 
 // receiveDrop reads until EOF and uses dummy writer (ReadToNull)
@@ -445,7 +576,13 @@ func (p *proxyrunner) receiveDrop(w http.ResponseWriter, r *http.Request, redire
 	if glog.V(3) {
 		glog.Infof("GET redirect URL %q", redirecturl)
 	}
-	newr, err := http.Get(redirecturl)
+	request, err := http.NewRequest(http.MethodGet, redirecturl, nil)
+	if err != nil {
+		glog.Errorf("Failed to create GET request %q, err: %v", redirecturl, err)
+		return
+	}
+	spanof(r).inject(request.Header)
+	newr, err := p.httpclientLongTimeout.Do(request)
 	if err != nil {
 		glog.Errorf("Failed to GET redirect URL %q, err: %v", redirecturl, err)
 		return
@@ -483,11 +620,15 @@ func (p *proxyrunner) httpfilput(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	redirecturl := si.DirectURL + r.URL.Path
+	if r.URL.RawQuery != "" {
+		redirecturl += "?" + r.URL.RawQuery // e.g., multipart upload ID and part number
+	}
 	if glog.V(3) {
 		glog.Infof("Redirecting %q to %s (%s)", r.URL.Path, si.DirectURL, r.Method)
 	}
 	p.statsif.add("numput", 1)
-	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+	spanof(r).object(bucket, objname)
+	p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 }
 
 // { action } "/"+Rversion+"/"+Rfiles
@@ -510,11 +651,15 @@ func (p *proxyrunner) httpfildelete(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		redirecturl := si.DirectURL + r.URL.Path
+		if r.URL.RawQuery != "" {
+			redirecturl += "?" + r.URL.RawQuery // e.g., version ID
+		}
 		if glog.V(3) {
 			glog.Infof("Redirecting %q to %s (%s)", r.URL.Path, si.DirectURL, r.Method)
 		}
 		p.statsif.add("numdelete", 1)
-		http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+		spanof(r).object(bucket, objname)
+		p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 		return
 	}
 
@@ -578,12 +723,26 @@ func (p *proxyrunner) httpfilpost(w http.ResponseWriter, r *http.Request) {
 		p.lbmap.lock()
 		defer p.lbmap.unlock()
 		p.synclbmap(w, r)
+	case ActSetCloud:
+		p.setcloud(w, r, lbucket, &msg)
+	case ActSetCopies:
+		p.setcopies(w, r, lbucket, &msg)
+	case ActSetEC:
+		p.setec(w, r, lbucket, &msg)
+	case ActSetProps:
+		p.setprops(w, r, lbucket, &msg)
 	case ActRename:
 		p.filrename(w, r, &msg)
 		return
-	case ActPrefetch:
+	case ActPrefetch, ActPin, ActUnpin:
 		p.actionlistrange(w, r, &msg)
 		return
+	case ActCopy, ActMove:
+		p.copybucket(w, r, lbucket, &msg)
+		return
+	case ActMPInit, ActMPComplete:
+		p.filmultipart(w, r, &msg)
+		return
 	default:
 		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
 		p.invalmsghdlr(w, r, s)
@@ -591,6 +750,149 @@ func (p *proxyrunner) httpfilpost(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// setcloud assigns cloud provider (ActionMsg.Value) to a given Cloud bucket;
+// the mapping is versioned and synchronized together with the lbmap
+func (p *proxyrunner) setcloud(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	provider, ok := msg.Value.(string)
+	if !ok {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid cloud provider %v: expecting string", msg.Value))
+		return
+	}
+	if provider != "" {
+		if err := validateprovider(provider, nil); err != nil {
+			p.invalmsghdlr(w, r, err.Error())
+			return
+		}
+		if errstr := p.checkprovider(provider); errstr != "" {
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	if p.islocalBucket(bucket) {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Cannot assign cloud provider to local bucket %s", bucket))
+		return
+	}
+	if !p.lbmap.setprovider(bucket, provider) {
+		return
+	}
+	p.synclbmap(w, r)
+}
+
+// checkprovider validates the cloud provider against the configs of the targets -
+// the ones that access the Cloud
+func (p *proxyrunner) checkprovider(provider string) (errstr string) {
+	if provider != posixcloud && provider != s3compatcloud {
+		return // nothing to configure
+	}
+	msgbytes, err := json.Marshal(GetMsg{GetWhat: GetWhatConfig})
+	assert(err == nil, err)
+	for sid, si := range p.targets() {
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		outjson, err, errs, status := p.call(si, url, http.MethodGet, msgbytes)
+		if err != nil {
+			p.kalive.onerr(err, status)
+			return fmt.Sprintf("Failed to get config from %s, err: %s", sid, errs)
+		}
+		config := &dfconfig{}
+		if err = json.Unmarshal(outjson, config); err != nil {
+			return fmt.Sprintf("Unexpected config from %s, err: %v", sid, err)
+		}
+		if err = validateprovider(provider, config); err != nil {
+			return fmt.Sprintf("Target %s: %v", sid, err)
+		}
+	}
+	return
+}
+
+// setcopies sets the number of object replicas (ActionMsg.Value) for a given bucket;
+// the objects are then stored on that many top HRW-ranked targets (or all of them, if fewer)
+func (p *proxyrunner) setcopies(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	v, ok := msg.Value.(float64)
+	if !ok || v < 1 || v != float64(int(v)) {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid number of copies %v: expecting positive integer", msg.Value))
+		return
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	if !p.lbmap.setcopies(bucket, int(v)) {
+		return
+	}
+	p.synclbmap(w, r)
+}
+
+// setprops sets the bucket's checksum, versioning, LRU, and ack policies (ActionMsg.Value = BucketProps);
+// the properties are versioned and synchronized together with the lbmap
+func (p *proxyrunner) setprops(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	jsbytes, err := json.Marshal(msg.Value)
+	assert(err == nil, err)
+	bprops := BucketProps{}
+	if err = json.Unmarshal(jsbytes, &bprops); err != nil {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Could not parse BucketProps %v, err: %v", msg.Value, err))
+		return
+	}
+	if err = validatebprops(&bprops); err != nil {
+		p.invalmsghdlr(w, r, err.Error())
+		return
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	if bprops.Versioning == "true" && !p.islocalBucket(bucket) {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Versioning is supported for local buckets only (%s is not local)", bucket))
+		return
+	}
+	if !p.lbmap.setprops(bucket, bprops) {
+		return
+	}
+	p.synclbmap(w, r)
+}
+
+// setec sets the number of erasure coding data and parity slices (ActionMsg.Value = ECMsg)
+// for a given local bucket; each slice is stored on a separate target
+func (p *proxyrunner) setec(w http.ResponseWriter, r *http.Request, bucket string, msg *ActionMsg) {
+	jsmap, ok := msg.Value.(map[string]interface{})
+	if !ok {
+		p.invalmsghdlr(w, r, "Could not parse ECMsg: ActionMsg.Value was not map[string]interface{}")
+		return
+	}
+	ecmsg := ECMsg{}
+	for name, v := range map[string]*int{"data": &ecmsg.Data, "parity": &ecmsg.Parity} {
+		f, ok := jsmap[name].(float64)
+		if !ok || f < 0 || f != float64(int(f)) {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Invalid ECMsg %s slices %v: expecting non-negative integer", name, jsmap[name]))
+			return
+		}
+		*v = int(f)
+	}
+	if (ecmsg.Data == 0) != (ecmsg.Parity == 0) {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid ECMsg %+v: both data and parity must be either zero or positive", ecmsg))
+		return
+	}
+	if ecmsg.Data+ecmsg.Parity > ctx.smap.count() {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid ECMsg %+v: not enough targets (%d)", ecmsg, ctx.smap.count()))
+		return
+	}
+	p.lbmap.lock()
+	defer p.lbmap.unlock()
+	if !p.islocalBucket(bucket) {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Cannot erasure code Cloud bucket %s", bucket))
+		return
+	}
+	if !p.lbmap.setec(bucket, ecmsg.Data, ecmsg.Parity) {
+		return
+	}
+	p.synclbmap(w, r)
+}
+
+// savesmapconf persists the cluster map and requires the caller to lock ctx.smap
+func (p *proxyrunner) savesmapconf() {
+	smappathname := p.confdir + "/" + ctx.config.SmapConf
+	if err := localSave(smappathname, ctx.smap); err != nil {
+		glog.Errorf("Failed to store Smap config %s, err: %v", smappathname, err)
+	}
+}
+
 // synclbmap requires the caller to lock p.lbmap
 func (p *proxyrunner) synclbmap(w http.ResponseWriter, r *http.Request) {
 	lbpathname := p.confdir + "/" + ctx.config.LBConf
@@ -631,7 +933,27 @@ func (p *proxyrunner) filrename(w http.ResponseWriter, r *http.Request, msg *Act
 	// NOTE:
 	//       code 307 is the only way to http-redirect with the
 	//       original JSON payload (GetMsg - see REST.go)
-	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+	p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+}
+
+// multipart upload: initiate and complete are redirected to the object's target
+func (p *proxyrunner) filmultipart(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	apitems := p.restAPIItems(r.URL.Path, 5)
+	if apitems = p.checkRestAPI(w, r, apitems, 2, Rversion, Rfiles); apitems == nil {
+		return
+	}
+	bucket, objname := apitems[0], strings.Join(apitems[1:], "/")
+	si, errstr := hrwTarget(bucket+"/"+objname, ctx.smap)
+	if errstr != "" {
+		p.invalmsghdlr(w, r, errstr)
+		return
+	}
+	redirecturl := si.DirectURL + r.URL.Path
+	if glog.V(3) {
+		glog.Infof("Redirecting %q to %s (%s)", r.URL.Path, si.DirectURL, msg.Action)
+	}
+	p.statsif.add("numpost", 1)
+	p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 }
 
 func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, actionMsg *ActionMsg) {
@@ -664,7 +986,7 @@ func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, ac
 	switch actionMsg.Action {
 	case ActEvict, ActDelete:
 		method = http.MethodDelete
-	case ActPrefetch:
+	case ActPrefetch, ActPin, ActUnpin, ActCopy, ActMove:
 		method = http.MethodPost
 	default:
 		s := fmt.Sprintf("Action unavailable for List/Range Operations: %s", actionMsg.Action)
@@ -672,8 +994,12 @@ func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, ac
 		return
 	}
 
-	wg := &sync.WaitGroup{}
-	for _, si := range ctx.smap.Smap {
+	var (
+		wg     = &sync.WaitGroup{}
+		jobid  = p.newjobid()
+		failed int32
+	)
+	for _, si := range p.targets() {
 		wg.Add(1)
 		go func(si *daemonInfo) {
 			defer wg.Done()
@@ -681,22 +1007,27 @@ func (p *proxyrunner) actionlistrange(w http.ResponseWriter, r *http.Request, ac
 				err     error
 				errstr  string
 				errcode int
-				url     = si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket
+				url     = si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "?" + ParamJobID + "=" + jobid
 			)
 			if wait {
 				_, err, errstr, errcode = p.call(si, url, method, jsonbytes, 0)
 			} else {
 				_, err, errstr, errcode = p.call(si, url, method, jsonbytes)
 			}
-			if err != nil {
+			if err != nil && atomic.CompareAndSwapInt32(&failed, 0, 1) {
 				s := fmt.Sprintf("Failed to execute List/Range request: %v (%d: %s)", err, errcode, errstr)
 				p.invalmsghdlr(w, r, s)
-				return
 			}
 		}(si)
 	}
 	wg.Wait()
-	glog.Infoln("Completed sending List/Range ActionMsg to all targets")
+	if failed != 0 {
+		return
+	}
+	glog.Infof("Completed sending List/Range ActionMsg to all targets, job %s", jobid)
+	jsbytes, err := json.Marshal(&JobMsg{JobID: jobid})
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "actionlistrange")
 }
 
 func (p *proxyrunner) httpfilhead(w http.ResponseWriter, r *http.Request) {
@@ -711,15 +1042,24 @@ func (p *proxyrunner) httpfilhead(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var si *daemonInfo
-	// Use random map iteration order to choose a random target to redirect to
-	for _, si = range ctx.smap.Smap {
-		break
+	if len(apitems) > 1 {
+		// HEAD object: the object's HRW target
+		var errstr string
+		if si, errstr = hrwTarget(bucket+"/"+strings.Join(apitems[1:], "/"), ctx.smap); errstr != "" {
+			p.invalmsghdlr(w, r, errstr)
+			return
+		}
+	} else {
+		// Use random map iteration order to choose a random target to redirect to
+		for _, si = range ctx.smap.Smap {
+			break
+		}
 	}
 	redirecturl := fmt.Sprintf("%s%s?%s=%t", si.DirectURL, r.URL.Path, ParamLocal, p.islocalBucket(bucket))
 	if glog.V(3) {
 		glog.Infof("Redirecting %q to %s (%s)", r.URL.Path, si.DirectURL, r.Method)
 	}
-	http.Redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
+	p.redirect(w, r, redirecturl, http.StatusTemporaryRedirect)
 }
 
 //===========================
@@ -746,14 +1086,26 @@ func (p *proxyrunner) httpdaeget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var msg GetMsg
-	if p.readJSON(w, r, &msg) != nil {
+	if p.readGetMsg(w, r, &msg) != nil {
 		return
 	}
 	switch msg.GetWhat {
 	case GetWhatConfig:
-		jsbytes, err := json.Marshal(ctx.config)
+		jsbytes, err := json.Marshal(redactedconfig())
 		assert(err == nil)
 		p.writeJSON(w, r, jsbytes, "httpdaeget")
+	case GetWhatSmap:
+		jsbytes, err := json.Marshal(ctx.smap)
+		assert(err == nil, err)
+		p.writeJSON(w, r, jsbytes, "httpdaeget")
+	case GetWhatMeta:
+		ctx.smap.lock()
+		p.lbmap.lock()
+		jsbytes, err := json.Marshal(&clustermeta{Smap: ctx.smap, LBmap: p.lbmap})
+		p.lbmap.unlock()
+		ctx.smap.unlock()
+		assert(err == nil, err)
+		p.writeJSON(w, r, jsbytes, "httpdaeget")
 	default:
 		s := fmt.Sprintf("Unexpected GetMsg <- JSON [%v]", msg)
 		p.invalmsghdlr(w, r, s)
@@ -765,6 +1117,16 @@ func (p *proxyrunner) httpdaeput(w http.ResponseWriter, r *http.Request) {
 	if apitems = p.checkRestAPI(w, r, apitems, 0, Rversion, Rdaemon); apitems == nil {
 		return
 	}
+	// standby: PUT '{Smap}' /v1/daemon/(syncsmap|rebalance)
+	if len(apitems) > 0 && (apitems[0] == Rsyncsmap || apitems[0] == Rebalance) {
+		p.httpdaeputSmap(w, r)
+		return
+	}
+	// standby: PUT '{lbmap}' /v1/daemon/localbuckets
+	if len(apitems) > 0 && apitems[0] == Rsynclb {
+		p.httpdaeputLBMap(w, r)
+		return
+	}
 	//
 	// other PUT /daemon actions
 	//
@@ -781,6 +1143,8 @@ func (p *proxyrunner) httpdaeput(w http.ResponseWriter, r *http.Request) {
 		} else if errstr := p.setconfig(msg.Name, value); errstr != "" {
 			p.invalmsghdlr(w, r, errstr)
 		}
+	case ActShutdown:
+		_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
 	default:
 		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
 		p.invalmsghdlr(w, r, s)
@@ -789,6 +1153,10 @@ func (p *proxyrunner) httpdaeput(w http.ResponseWriter, r *http.Request) {
 
 // handler for: "/"+Rversion+"/"+Rcluster
 func (p *proxyrunner) clusterhdlr(w http.ResponseWriter, r *http.Request) {
+	// standby proxies serve GET (Smap, stats) and redirect the rest to the primary
+	if r.Method != http.MethodGet && p.redirectprimary(w, r) {
+		return
+	}
 	switch r.Method {
 	case http.MethodGet:
 		p.httpcluget(w, r)
@@ -811,7 +1179,7 @@ func (p *proxyrunner) httpcluget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var msg GetMsg
-	if p.readJSON(w, r, &msg) != nil {
+	if p.readGetMsg(w, r, &msg) != nil {
 		return
 	}
 	switch msg.GetWhat {
@@ -823,6 +1191,14 @@ func (p *proxyrunner) httpcluget(w http.ResponseWriter, r *http.Request) {
 		getstatsmsg, err := json.Marshal(msg) // same message to all targets
 		assert(err == nil, err)
 		p.httpclugetstats(w, r, getstatsmsg)
+	case GetWhatMetasync:
+		jsbytes, err := json.Marshal(p.metasyncer.info())
+		assert(err == nil, err)
+		p.writeJSON(w, r, jsbytes, "httpcluget")
+	case GetWhatRebalance:
+		p.httpclugetrebalance(w, r)
+	case GetWhatXactions:
+		p.httpclugetxactions(w, r)
 	default:
 		s := fmt.Sprintf("Unexpected GetMsg <- JSON [%v]", msg)
 		p.invalmsghdlr(w, r, s)
@@ -846,10 +1222,26 @@ func (p *proxyrunner) httpclugetstats(w http.ResponseWriter, r *http.Request, ge
 			p.invalmsghdlr(w, r, string(outjson))
 			return
 		}
+		for bucket, bc := range stats.Buckets {
+			if out.Buckets == nil {
+				out.Buckets = make(map[string]*bucketcapacity, len(stats.Buckets))
+			}
+			total, ok := out.Buckets[bucket]
+			if !ok {
+				total = &bucketcapacity{Priority: bc.Priority}
+				out.Buckets[bucket] = total
+			}
+			total.Used += bc.Used
+			total.Quota += bc.Quota
+			total.Reserved += bc.Reserved
+			total.Evicted += bc.Evicted
+		}
+		out.Latency.merge(stats.Latency)
 	}
 	rr := getproxystatsrunner()
 	rr.Lock()
 	out.Proxy = &rr.Core
+	out.ProxyLatency = rr.Latency
 	jsbytes, err := json.Marshal(out)
 	rr.Unlock()
 	assert(err == nil, err)
@@ -867,6 +1259,10 @@ func (p *proxyrunner) httpclupost(w http.ResponseWriter, r *http.Request) {
 	if apitems = p.checkRestAPI(w, r, apitems, 0, Rversion, Rcluster); apitems == nil {
 		return
 	}
+	if len(apitems) > 0 && apitems[0] == Rproxy {
+		p.registerproxy(w, r, len(apitems) > 1 && apitems[1] == Rkeepalive)
+		return
+	}
 	if len(apitems) > 0 {
 		keepalive = (apitems[0] == Rkeepalive)
 	}
@@ -904,6 +1300,7 @@ func (p *proxyrunner) httpclupost(w http.ResponseWriter, r *http.Request) {
 	}
 add:
 	ctx.smap.add(&nsi)
+	p.savesmapconf()
 	ctx.smap.unlock()
 	if glog.V(3) {
 		glog.Infof("register target %s (count %d)", nsi.DaemonID, ctx.smap.count())
@@ -924,12 +1321,21 @@ func (p *proxyrunner) httpcludel(w http.ResponseWriter, r *http.Request) {
 	}
 	sid := apitems[1]
 	ctx.smap.lock()
+	if _, ok := ctx.smap.Pmap[sid]; ok && sid != p.si.DaemonID {
+		ctx.smap.delproxy(sid)
+		p.savesmapconf()
+		ctx.smap.unlock()
+		glog.Infof("Unregistered standby proxy {%s}", sid)
+		go p.synchronizeMaps(0, "")
+		return
+	}
 	if ctx.smap.get(sid) == nil {
 		glog.Errorf("Unknown target %s", sid)
 		ctx.smap.unlock()
 		return
 	}
 	ctx.smap.del(sid)
+	p.savesmapconf()
 	ctx.smap.unlock()
 	//
 	// TODO: startup -- leave --
@@ -973,20 +1379,31 @@ func (p *proxyrunner) httpcluput(w http.ResponseWriter, r *http.Request) {
 		}
 	case ActShutdown:
 		glog.Infoln("Proxy-controlled cluster shutdown...")
-		msgbytes, err := json.Marshal(msg) // same message -> all targets
+		msgbytes, err := json.Marshal(msg) // same message -> all targets and standby proxies
 		assert(err == nil, err)
 		for _, si := range ctx.smap.Smap {
 			url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
 			glog.Infof("%s: %s", msg.Action, url)
 			p.call(si, url, http.MethodPut, msgbytes) // ignore errors
 		}
+		for sid, si := range ctx.smap.Pmap {
+			if sid != p.si.DaemonID {
+				url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+				glog.Infof("%s: %s", msg.Action, url)
+				p.call(si, url, http.MethodPut, msgbytes) // ignore errors
+			}
+		}
 		time.Sleep(time.Second)
 		_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
 
 	case ActSyncSmap:
-		fallthrough
-	case ActRebalance:
 		go p.synchronizeMaps(0, msg.Action)
+	case ActRebalance, ActRebAbort, ActRebThrottle:
+		p.rebalancectl(w, r, &msg)
+	case ActLRU:
+		p.bcastaction(w, r, &msg)
+	case ActXactAbort:
+		p.abortxaction(w, r, &msg)
 
 	default:
 		s := fmt.Sprintf("Unexpected ActionMsg <- JSON [%v]", msg)
@@ -994,6 +1411,147 @@ func (p *proxyrunner) httpcluput(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GET /v1/cluster?what=rebalance: per-target rebalance progress, and the totals
+func (p *proxyrunner) httpclugetrebalance(w http.ResponseWriter, r *http.Request) {
+	msgbytes, err := json.Marshal(GetMsg{GetWhat: GetWhatRebalance})
+	assert(err == nil, err)
+	out := &RebalanceInfo{Target: make(map[string]*RebalanceStats, len(ctx.smap.Smap))}
+	for sid, si := range p.targets() {
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		outjson, err, errstr, status := p.call(si, url, http.MethodGet, msgbytes)
+		if err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Failed to get rebalance progress from %s, err: %s", sid, errstr))
+			p.kalive.onerr(err, status)
+			return
+		}
+		stats := &RebalanceStats{}
+		if err = json.Unmarshal(outjson, stats); err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Unexpected rebalance progress from %s, err: %v", sid, err))
+			return
+		}
+		out.Target[sid] = stats
+		out.Running = out.Running || stats.Running
+		out.ObjsMoved += stats.ObjsMoved
+		out.BytesMoved += stats.BytesMoved
+		out.Errors += stats.Errors
+	}
+	jsbytes, err := json.Marshal(out)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "httpclugetrebalance")
+}
+
+// GET /v1/cluster?what=xactions: per-target running and recently finished xactions
+func (p *proxyrunner) httpclugetxactions(w http.ResponseWriter, r *http.Request) {
+	msgbytes, err := json.Marshal(GetMsg{GetWhat: GetWhatXactions})
+	assert(err == nil, err)
+	out := &XactionsInfo{Target: make(map[string][]*XactionInfo, len(ctx.smap.Smap))}
+	for sid, si := range p.targets() {
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		outjson, err, errstr, status := p.call(si, url, http.MethodGet, msgbytes)
+		if err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Failed to get xactions from %s, err: %s", sid, errstr))
+			p.kalive.onerr(err, status)
+			return
+		}
+		infos := make([]*XactionInfo, 0, 8)
+		if err = json.Unmarshal(outjson, &infos); err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Unexpected xactions from %s, err: %v", sid, err))
+			return
+		}
+		out.Target[sid] = infos
+	}
+	jsbytes, err := json.Marshal(out)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "httpclugetxactions")
+}
+
+// abortxaction aborts the xaction with a given (cluster-unique) ID on all targets (or the one
+// named by msg.Name) and returns the aborted xaction; not found is 404
+func (p *proxyrunner) abortxaction(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	if id, ok := msg.Value.(string); !ok || id == "" {
+		p.invalmsghdlr(w, r, fmt.Sprintf("Invalid %s value %v (expecting xaction ID)", msg.Action, msg.Value))
+		return
+	}
+	msgbytes, err := json.Marshal(msg)
+	assert(err == nil, err)
+	out := &XactionsInfo{Target: make(map[string][]*XactionInfo, 1)}
+	for sid, si := range p.targets() {
+		if msg.Name != "" && msg.Name != sid {
+			continue
+		}
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		outjson, err, errstr, status := p.call(si, url, http.MethodPut, msgbytes)
+		if err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("%s failed on %s, err: %s", msg.Action, sid, errstr))
+			p.kalive.onerr(err, status)
+			return
+		}
+		infos := make([]*XactionInfo, 0, 1)
+		if err = json.Unmarshal(outjson, &infos); err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Unexpected %s response from %s, err: %v", msg.Action, sid, err))
+			return
+		}
+		if len(infos) > 0 {
+			out.Target[sid] = infos
+		}
+	}
+	if len(out.Target) == 0 {
+		p.invalmsghdlr(w, r, fmt.Sprintf("xaction %v is not running", msg.Value), http.StatusNotFound)
+		return
+	}
+	jsbytes, err := json.Marshal(out)
+	assert(err == nil, err)
+	p.writeJSON(w, r, jsbytes, "abortxaction")
+}
+
+// start, abort, or throttle the rebalance on all targets
+func (p *proxyrunner) rebalancectl(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	if msg.Action == ActRebThrottle {
+		if mbps, ok := msg.Value.(float64); !ok || mbps < 0 {
+			p.invalmsghdlr(w, r, fmt.Sprintf("Invalid %s value %v (expecting MB/s)", msg.Action, msg.Value))
+			return
+		}
+	}
+	// NOTE: the Smap-driven rebalance (see synchronizeMaps) remains the default
+	if msg.Action == ActRebalance && p.smapsyncpending() {
+		go p.synchronizeMaps(0, msg.Action)
+		return
+	}
+	p.bcastaction(w, r, msg)
+}
+
+// bcastaction sends the same ActionMsg to all targets: PUT /v1/daemon
+func (p *proxyrunner) bcastaction(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	msgbytes, err := json.Marshal(msg) // same message -> all targets
+	assert(err == nil, err)
+	for sid, si := range p.targets() {
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		if _, err, errstr, status := p.call(si, url, http.MethodPut, msgbytes); err != nil {
+			p.invalmsghdlr(w, r, fmt.Sprintf("%s failed on %s, err: %s", msg.Action, sid, errstr))
+			p.kalive.onerr(err, status)
+			return
+		}
+	}
+}
+
+// targets as per the current cluster map
+func (p *proxyrunner) targets() map[string]*daemonInfo {
+	ctx.smap.lock()
+	defer ctx.smap.unlock()
+	targets := make(map[string]*daemonInfo, len(ctx.smap.Smap))
+	for sid, si := range ctx.smap.Smap {
+		targets[sid] = si
+	}
+	return targets
+}
+
+// the cluster map has changed since the last broadcast
+func (p *proxyrunner) smapsyncpending() bool {
+	ctx.smap.lock()
+	defer ctx.smap.unlock()
+	return ctx.smap.version() != ctx.smap.syncversion
+}
+
 //========================
 //
 // delayed broadcasts
@@ -1075,37 +1633,132 @@ func (p *proxyrunner) synchronizeMaps(ntargets int, action string) {
 	glog.Infof("Smap (v%d) and lbmap (v%d) are now in sync with the targets", smapversion, lbversion)
 }
 
+// PUT '{Smap}' /v1/daemon/(syncsmap|rebalance) => targets and standby proxies (see metasync)
 func (p *proxyrunner) httpcluputSmap(action string) {
-	method := http.MethodPut
 	assert(action == Rebalance || action == Rsyncsmap)
-	ctx.smap.lock()
-	jsbytes, err := json.Marshal(ctx.smap)
-	ctx.smap.unlock()
-	assert(err == nil, err)
-	for _, si := range ctx.smap.Smap {
-		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon + "/" + action
-		glog.Infof("%s: %s", action, url)
-		if _, err, errstr, status := p.call(si, url, method, jsbytes); errstr != "" {
-			p.kalive.onerr(err, status)
-			return
+	glog.Infof("%s: Smap v%d", action, ctx.smap.versionLocked())
+	p.metasyncer.syncSmap(action)
+}
+
+// PUT '{lbmap}' /v1/daemon/localbuckets => ditto
+func (p *proxyrunner) httpfilputLB() {
+	glog.Infof("%s: lbmap v%d", Rsynclb, p.lbmap.versionLocked())
+	p.metasyncer.syncLBmap()
+}
+
+// loadsmapconf restores the persisted cluster map at (primary) startup
+// and requires the caller to lock ctx.smap
+func (p *proxyrunner) loadsmapconf() {
+	smappathname := p.confdir + "/" + ctx.config.SmapConf
+	smap := &Smap{}
+	if err := localLoad(smappathname, smap); err != nil {
+		if !os.IsNotExist(err) {
+			glog.Errorf("Failed to load Smap config %s, err: %v", smappathname, err)
+		}
+	} else {
+		if smap.Smap != nil {
+			ctx.smap.Smap = smap.Smap
+		}
+		if smap.Pmap != nil {
+			ctx.smap.Pmap = smap.Pmap
 		}
+		ctx.smap.ProxySI, ctx.smap.Version = smap.ProxySI, smap.Version
+		glog.Infof("Loaded Smap v%d: %d targets, %d proxies", smap.Version, len(ctx.smap.Smap), len(ctx.smap.Pmap))
 	}
+	p.takeover()
+	p.savesmapconf()
 }
 
-func (p *proxyrunner) httpfilputLB() {
-	p.lbmap.lock()
-	jsbytes, err := json.Marshal(p.lbmap)
-	assert(err == nil, err)
-	p.lbmap.unlock()
+// takeover makes this proxy the primary in the cluster map (and bumps the map's version),
+// unless it already is; requires the caller to lock ctx.smap
+func (p *proxyrunner) takeover() {
+	primary := ctx.smap.ProxySI
+	if primary != nil && primary.DaemonID == p.si.DaemonID && primary.DirectURL == p.si.DirectURL {
+		return
+	}
+	if primary != nil && primary.DaemonID != p.si.DaemonID {
+		glog.Warningf("Proxy %s: taking over as the primary from %s (Smap v%d)", p.si.DaemonID, primary.DaemonID, ctx.smap.Version)
+		delete(ctx.smap.Pmap, primary.DaemonID)
+	}
+	ctx.smap.ProxySI = p.si
+	ctx.smap.addproxy(p.si)
+}
 
+// reconcile (primary startup) queries the targets and standbys of the persisted cluster map
+// for their versions of Smap and lbmap, and adopts those that are newer than the local ones;
+// the resulting Smap version supersedes all the versions found in the cluster
+func (p *proxyrunner) reconcile() {
+	jsbytes, err := json.Marshal(&GetMsg{GetWhat: GetWhatMeta})
+	assert(err == nil, err)
+	ctx.smap.lock()
+	daemons := make([]*daemonInfo, 0, len(ctx.smap.Smap)+len(ctx.smap.Pmap))
 	for _, si := range ctx.smap.Smap {
-		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon + "/" + Rsynclb
-		glog.Infof("%s: %+v", url, p.lbmap)
-		if _, err, _, status := p.call(si, url, http.MethodPut, jsbytes); err != nil {
-			p.kalive.onerr(err, status)
-			return
+		daemons = append(daemons, si)
+	}
+	for sid, si := range ctx.smap.Pmap {
+		if sid != p.si.DaemonID {
+			daemons = append(daemons, si)
 		}
 	}
+	ctx.smap.unlock()
+
+	var (
+		newsmap  *Smap
+		newlbmap *lbmap
+	)
+	for _, si := range daemons {
+		url := si.DirectURL + "/" + Rversion + "/" + Rdaemon
+		outjson, err, errstr, _ := p.call(si, url, http.MethodGet, jsbytes, kalivetimeout)
+		if err != nil {
+			glog.Warningf("reconcile: %s is not responding: %s", si.DaemonID, errstr)
+			continue
+		}
+		meta := &clustermeta{}
+		if err = json.Unmarshal(outjson, meta); err != nil {
+			glog.Errorf("reconcile: unexpected response from %s, err: %v", si.DaemonID, err)
+			continue
+		}
+		if meta.Smap != nil && (newsmap == nil || meta.Smap.Version > newsmap.Version) {
+			newsmap = meta.Smap
+		}
+		if meta.LBmap != nil && (newlbmap == nil || meta.LBmap.Version > newlbmap.Version) {
+			newlbmap = meta.LBmap
+		}
+	}
+
+	ctx.smap.lock()
+	if newsmap != nil && newsmap.Version > ctx.smap.Version {
+		glog.Infof("reconcile: Smap v%d (primary %v) supersedes the local v%d", newsmap.Version, newsmap.ProxySI,
+			ctx.smap.Version)
+		if newsmap.Smap != nil {
+			ctx.smap.Smap = newsmap.Smap
+		}
+		if newsmap.Pmap != nil {
+			ctx.smap.Pmap = newsmap.Pmap
+		}
+		ctx.smap.ProxySI, ctx.smap.Version = newsmap.ProxySI, newsmap.Version
+		p.takeover()
+		p.savesmapconf()
+	}
+	ctx.smap.unlock()
+
+	p.lbmap.lock()
+	if newlbmap != nil && newlbmap.Version > p.lbmap.Version {
+		glog.Infof("reconcile: lbmap v%d supersedes the local v%d", newlbmap.Version, p.lbmap.Version)
+		p.lbmap.LBmap, p.lbmap.CBmap, p.lbmap.Props = newlbmap.LBmap, newlbmap.CBmap, newlbmap.Props
+		p.lbmap.Version = newlbmap.Version
+		if p.lbmap.CBmap == nil {
+			p.lbmap.CBmap = make(map[string]string)
+		}
+		if p.lbmap.Props == nil {
+			p.lbmap.Props = make(map[string]*bucketProps)
+		}
+		lbpathname := p.confdir + "/" + ctx.config.LBConf
+		if err := localSave(lbpathname, p.lbmap); err != nil {
+			glog.Errorf("Failed to store localbucket config %s, err: %v", lbpathname, err)
+		}
+	}
+	p.lbmap.unlock()
 }
 
 //===================
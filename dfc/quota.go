@@ -0,0 +1,179 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//======
+//
+// per-bucket capacity constraints (lru_config.bucket_quota), enforced by the LRU xaction:
+// - quota: buckets that store more than their quota get evicted down to the quota
+//   regardless of the watermarks;
+// - reserved: watermark-driven eviction never takes a bucket below its reserved size;
+// - priority: watermark-driven eviction goes from the lowest priority class up.
+// The sizes are cluster-wide; each target enforces its 1/N share
+//
+//======
+
+const quotacheckivl = time.Minute * 5
+
+// bucketcapacity is the per-bucket usage reported via GET {"what": "stats"}
+type bucketcapacity struct {
+	Used     int64 `json:"used"`     // bytes, as of the last LRU run
+	Quota    int64 `json:"quota"`    // ditto, 0 - unlimited
+	Reserved int64 `json:"reserved"` // ditto
+	Priority int   `json:"priority"`
+	Evicted  int64 `json:"evicted"` // bytes evicted by the LRU, total
+}
+
+func validatequotas(quotas map[string]*bucketquota) error {
+	for bucket, q := range quotas {
+		if q.QuotaMB < 0 || q.ReservedMB < 0 {
+			return fmt.Errorf("Bucket %s: negative quota %+v", bucket, *q)
+		}
+		if q.QuotaMB > 0 && q.ReservedMB > q.QuotaMB {
+			return fmt.Errorf("Bucket %s: reserved size exceeds the quota %+v", bucket, *q)
+		}
+	}
+	return nil
+}
+
+// setquota parses "bucket:quota_mb:reserved_mb:priority" (setconfig "bucket_quota");
+// zero quota, reserved size, and priority remove the bucket's constraints
+func setquota(value string) error {
+	items := strings.Split(value, ":")
+	if len(items) != 4 || items[0] == "" {
+		return fmt.Errorf("Invalid bucket quota %q - expecting bucket:quota_mb:reserved_mb:priority", value)
+	}
+	var (
+		q   = &bucketquota{}
+		err error
+	)
+	if q.QuotaMB, err = strconv.ParseInt(items[1], 10, 64); err != nil {
+		return fmt.Errorf("Failed to parse quota_mb %q, err: %v", items[1], err)
+	}
+	if q.ReservedMB, err = strconv.ParseInt(items[2], 10, 64); err != nil {
+		return fmt.Errorf("Failed to parse reserved_mb %q, err: %v", items[2], err)
+	}
+	if q.Priority, err = strconv.Atoi(items[3]); err != nil {
+		return fmt.Errorf("Failed to parse priority %q, err: %v", items[3], err)
+	}
+	if err = validatequotas(map[string]*bucketquota{items[0]: q}); err != nil {
+		return err
+	}
+	// copy-on-write: the LRU may be running
+	quotas := make(map[string]*bucketquota, len(ctx.config.LRUConfig.BucketQuota)+1)
+	for bucket, bq := range ctx.config.LRUConfig.BucketQuota {
+		quotas[bucket] = bq
+	}
+	if q.QuotaMB == 0 && q.ReservedMB == 0 && q.Priority == 0 {
+		delete(quotas, items[0])
+	} else {
+		quotas[items[0]] = q
+	}
+	ctx.config.LRUConfig.BucketQuota = quotas
+	return nil
+}
+
+// bucketlimits returns this target's share of the bucket's quota and reserved size, in bytes
+func (t *targetrunner) bucketlimits(bucket string) (quota, reserved int64, priority int) {
+	q, ok := ctx.config.LRUConfig.BucketQuota[bucket]
+	if !ok {
+		return
+	}
+	ntargets := int64(t.smap.count())
+	if ntargets == 0 {
+		ntargets = 1
+	}
+	quota = q.QuotaMB * 1024 * 1024 / ntargets
+	reserved = q.ReservedMB * 1024 * 1024 / ntargets
+	return quota, reserved, q.Priority
+}
+
+// bucketsizes walks all mountpaths and returns the bucket sizes per bucket directory (e.g. mpath/cloud)
+func (t *targetrunner) bucketsizes() map[string]map[string]int64 {
+	sizes := make(map[string]map[string]int64, len(ctx.mountpaths)*2)
+	for mpath := range ctx.mountpaths {
+		for _, dir := range []string{ctx.config.LocalBuckets, ctx.config.CloudBuckets} {
+			bucketdir := mpath + "/" + dir
+			bsizes := make(map[string]int64, 16)
+			sizes[bucketdir] = bsizes
+			_ = filepath.Walk(bucketdir, func(fqn string, osfi os.FileInfo, err error) error {
+				if err != nil || osfi.Mode().IsDir() || strings.HasPrefix(osfi.Name(), ".") {
+					return nil
+				}
+				if bucket, _, ok := t.fqn2bckobj(fqn); ok {
+					bsizes[bucket] += osfi.Size()
+				}
+				return nil
+			})
+		}
+	}
+	return sizes
+}
+
+// lruquotas computes, for each bucket directory, the bytes to evict from the buckets
+// that are over quota and the bytes that can be evicted before the buckets reach their reserves
+func (t *targetrunner) lruquotas(sizes map[string]map[string]int64) (excess, evictable map[string]map[string]int64) {
+	totals := make(map[string]int64, 16)
+	for _, bsizes := range sizes {
+		for bucket, size := range bsizes {
+			totals[bucket] += size
+		}
+	}
+	excess = make(map[string]map[string]int64, len(sizes))
+	evictable = make(map[string]map[string]int64, len(sizes))
+	for bucketdir, bsizes := range sizes {
+		excess[bucketdir] = make(map[string]int64, 4)
+		evictable[bucketdir] = make(map[string]int64, 4)
+		for bucket, size := range bsizes {
+			quota, reserved, _ := t.bucketlimits(bucket)
+			total := totals[bucket]
+			if total == 0 {
+				continue
+			}
+			// prorated by the bucket's size in this directory
+			if quota > 0 && total > quota {
+				excess[bucketdir][bucket] = int64(float64(total-quota) * float64(size) / float64(total))
+			}
+			if reserved > 0 {
+				evictable[bucketdir][bucket] = size - int64(float64(reserved)*float64(size)/float64(total))
+			}
+		}
+	}
+	// report
+	rr := getstorstatsrunner()
+	rr.Lock()
+	buckets := make(map[string]*bucketcapacity, len(totals))
+	for bucket, total := range totals {
+		quota, reserved, priority := t.bucketlimits(bucket)
+		buckets[bucket] = &bucketcapacity{Used: total, Quota: quota, Reserved: reserved, Priority: priority}
+		if bc, ok := rr.Buckets[bucket]; ok {
+			buckets[bucket].Evicted = bc.Evicted
+		}
+	}
+	rr.Buckets = buckets
+	rr.Unlock()
+	return
+}
+
+// evictedbucket updates the per-bucket usage upon eviction
+func (t *targetrunner) evictedbucket(bucket string, size int64) {
+	rr := getstorstatsrunner()
+	rr.Lock()
+	if bc, ok := rr.Buckets[bucket]; ok {
+		bc.Used -= size
+		bc.Evicted += size
+	}
+	rr.Unlock()
+}
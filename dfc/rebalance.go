@@ -12,34 +12,165 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 )
 
+// RebalanceStats is the rebalance progress of a given target:
+// GET {"what": "rebalance"} /v1/daemon, and (aggregated) /v1/cluster
+type RebalanceStats struct {
+	ID             string    `json:"id"`   // xaction ID (see XactionInfo), empty if none has run yet
+	Smap           int64     `json:"smap"` // Smap version
+	Running        bool      `json:"running"`
+	Aborted        bool      `json:"aborted"`
+	ObjsMoved      int64     `json:"objs_moved"`
+	BytesMoved     int64     `json:"bytes_moved"`
+	ObjsRemaining  int64     `json:"objs_remaining"`  // yet to be checked, as per the initial estimate
+	BytesRemaining int64     `json:"bytes_remaining"` // ditto
+	Errors         int64     `json:"errors"`
+	Throttle       int64     `json:"throttle"` // bytes per second, 0 - unlimited
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+}
+
+// RebalanceInfo is returned by GET {"what": "rebalance"} /v1/cluster
+type RebalanceInfo struct {
+	Running    bool                       `json:"running"` // in progress on at least one target
+	ObjsMoved  int64                      `json:"objs_moved"`
+	BytesMoved int64                      `json:"bytes_moved"`
+	Errors     int64                      `json:"errors"`
+	Target     map[string]*RebalanceStats `json:"target"`
+}
+
 func (t *targetrunner) runRebalance() {
 	xreb := t.xactinp.renewRebalance(t.smap.Version, t)
 	if xreb == nil {
 		return
 	}
 	glog.Infoln(xreb.tostring())
+	xreb.span = t.tracer.newspan(nil, "rebalance", spanInternal)
+	xreb.span.setint("dfc.smap_version", xreb.curversion)
+	for mpath := range ctx.mountpaths {
+		xreb.estimate(mpath + "/" + ctx.config.CloudBuckets)
+		xreb.estimate(mpath + "/" + ctx.config.LocalBuckets)
+		xreb.estimate(mpath + "/" + vsDir)
+	}
 	for mpath := range ctx.mountpaths {
-		aborted := t.oneRebalance(mpath+"/"+ctx.config.CloudBuckets, xreb)
+		aborted := t.oneRebalance(mpath+"/"+ctx.config.CloudBuckets, xreb.rewalkf)
 		if aborted {
 			break
 		}
-		aborted = t.oneRebalance(mpath+"/"+ctx.config.LocalBuckets, xreb)
+		aborted = t.oneRebalance(mpath+"/"+ctx.config.LocalBuckets, xreb.rewalkf)
+		if aborted {
+			break
+		}
+		// older versions follow the objects (see versioning.go)
+		aborted = t.oneRebalance(mpath+"/"+vsDir, xreb.vsrewalkf)
 		if aborted {
 			break
 		}
 	}
-	xreb.etime = time.Now()
-	glog.Infoln(xreb.tostring())
+	aborted := atomic.LoadInt64(&xreb.aborted) != 0
+	if !aborted {
+		xreb.etime = time.Now()
+	}
+	glog.Infof("%s: moved %d objects (%d bytes), errors %d", xreb.tostring(),
+		atomic.LoadInt64(&xreb.objsmoved), atomic.LoadInt64(&xreb.bytesmoved), atomic.LoadInt64(&xreb.errors))
+	xreb.span.setint("dfc.objs_moved", atomic.LoadInt64(&xreb.objsmoved))
+	xreb.span.setint("dfc.bytes_moved", atomic.LoadInt64(&xreb.bytesmoved))
+	if aborted {
+		xreb.span.end("aborted")
+	} else {
+		xreb.span.end("")
+	}
 	t.xactinp.del(xreb.id)
+	// erasure coded slices must follow the objects
+	if !aborted && t.lbmap.erasurecoded() {
+		t.runECRepair()
+	}
+}
+
+// estimate counts the objects (and bytes) to check
+func (xreb *xactRebalance) estimate(dir string) {
+	_ = filepath.Walk(dir, func(fqn string, osfi os.FileInfo, err error) error {
+		if err != nil || osfi.Mode().IsDir() {
+			return nil
+		}
+		atomic.AddInt64(&xreb.objstotal, 1)
+		atomic.AddInt64(&xreb.bytestotal, osfi.Size())
+		return nil
+	})
 }
 
-func (t *targetrunner) oneRebalance(mpath string, xreb *xactRebalance) bool {
-	if err := filepath.Walk(mpath, xreb.rewalkf); err != nil {
+// throttle paces the rebalance so that it does not exceed the configured bandwidth
+func (xreb *xactRebalance) throttle(size int64) {
+	rate := atomic.LoadInt64(&xreb.targetrunner.rebthrottle)
+	if rate != xreb.pacerate {
+		xreb.pacerate, xreb.pacestart, xreb.pacebytes = rate, time.Now(), 0
+	}
+	if rate <= 0 {
+		return
+	}
+	xreb.pacebytes += size
+	expected := time.Duration(float64(xreb.pacebytes) / float64(rate) * float64(time.Second))
+	if elapsed := time.Since(xreb.pacestart); elapsed < expected {
+		select {
+		case <-xreb.abrt:
+		case <-time.After(expected - elapsed):
+		}
+	}
+}
+
+func (xreb *xactRebalance) stats() *RebalanceStats {
+	objs := atomic.LoadInt64(&xreb.objstotal) - atomic.LoadInt64(&xreb.objsvisited)
+	bytes := atomic.LoadInt64(&xreb.bytestotal) - atomic.LoadInt64(&xreb.bytesvisited)
+	if objs < 0 || bytes < 0 {
+		objs, bytes = 0, 0 // new objects arrived in the meantime
+	}
+	return &RebalanceStats{
+		ID:             xreb.targetrunner.xactinp.xactid(xreb.id),
+		Smap:           xreb.curversion,
+		Running:        !xreb.finished(),
+		Aborted:        atomic.LoadInt64(&xreb.aborted) != 0,
+		ObjsMoved:      atomic.LoadInt64(&xreb.objsmoved),
+		BytesMoved:     atomic.LoadInt64(&xreb.bytesmoved),
+		ObjsRemaining:  objs,
+		BytesRemaining: bytes,
+		Errors:         atomic.LoadInt64(&xreb.errors),
+		StartTime:      xreb.stime,
+		EndTime:        xreb.etime,
+	}
+}
+
+// rebalancestats returns the progress of the current, or the last, rebalance
+func (t *targetrunner) rebalancestats() *RebalanceStats {
+	t.xactinp.lock.Lock()
+	xreb := t.lastreb
+	t.xactinp.lock.Unlock()
+	stats := &RebalanceStats{}
+	if xreb != nil {
+		stats = xreb.stats()
+	}
+	stats.Throttle = atomic.LoadInt64(&t.rebthrottle)
+	return stats
+}
+
+// abortRebalance aborts the rebalance in progress, if any
+func (t *targetrunner) abortRebalance() {
+	t.xactinp.lock.Lock()
+	defer t.xactinp.lock.Unlock()
+	if _, xx := t.xactinp.find(ActRebalance); xx != nil && !xx.finished() {
+		xx.abort()
+	}
+}
+
+func (t *targetrunner) oneRebalance(mpath string, walkf filepath.WalkFunc) bool {
+	if _, err := os.Stat(mpath); os.IsNotExist(err) {
+		return false
+	}
+	if err := filepath.Walk(mpath, walkf); err != nil {
 		s := err.Error()
 		if strings.Contains(s, "xaction") {
 			glog.Infof("Stopping mpath %q traversal: %s", mpath, s)
@@ -62,18 +193,8 @@ func (xreb *xactRebalance) rewalkf(fqn string, osfi os.FileInfo, err error) erro
 	if osfi.Mode().IsDir() {
 		return nil
 	}
-	// abort?
-	select {
-	case <-xreb.abrt:
-		s := fmt.Sprintf("%s aborted, exiting rewalkf", xreb.tostring())
-		glog.Infoln(s)
-		glog.Flush()
-		return errors.New(s)
-	case <-time.After(time.Millisecond):
-		break
-	}
-	if xreb.finished() {
-		return fmt.Errorf("%s aborted - exiting rewalkf", xreb.tostring())
+	if err = xreb.checkabort(); err != nil {
+		return err
 	}
 	// rebalance this fobject maybe
 	t := xreb.targetrunner
@@ -83,15 +204,37 @@ func (xreb *xactRebalance) rewalkf(fqn string, osfi os.FileInfo, err error) erro
 			fqn, bucket, objname)
 
 	}
+	size := osfi.Size()
+	atomic.AddInt64(&xreb.objsvisited, 1)
+	atomic.AddInt64(&xreb.bytesvisited, size)
+	if t.lbmap.copies(bucket) > 1 {
+		moved, failed, errstr := t.rebalancereplicas(xreb.span, fqn, bucket, objname, size)
+		if errstr != "" {
+			atomic.AddInt64(&xreb.errors, 1)
+			return fmt.Errorf(errstr)
+		}
+		if moved > 0 {
+			atomic.AddInt64(&xreb.objsmoved, 1)
+			atomic.AddInt64(&xreb.bytesmoved, size*int64(moved))
+			xreb.throttle(size * int64(moved))
+		}
+		atomic.AddInt64(&xreb.errors, int64(failed))
+		return nil
+	}
 	si, errstr := hrwTarget(bucket+"/"+objname, t.smap)
 	if errstr != "" {
+		atomic.AddInt64(&xreb.errors, 1)
 		return fmt.Errorf(errstr)
 	}
 	if si.DaemonID != t.si.DaemonID {
 		glog.Infof("rebalancing [%s %s] %s => %s", bucket, objname, t.si.DaemonID, si.DaemonID)
-		if s := xreb.targetrunner.sendfile(http.MethodPut, bucket, objname, si, osfi.Size(), ""); s != "" {
+		if s := xreb.targetrunner.sendfile(xreb.span, http.MethodPut, bucket, objname, si, size, "", ""); s != "" {
 			glog.Infof("Failed to rebalance [%s %s]: %s", bucket, objname, s)
+			atomic.AddInt64(&xreb.errors, 1)
 		} else {
+			atomic.AddInt64(&xreb.objsmoved, 1)
+			atomic.AddInt64(&xreb.bytesmoved, size)
+			xreb.throttle(size)
 			// FIXME: TODO: delay the removal or (even) rely on the LRU
 			if err := os.Remove(fqn); err != nil {
 				glog.Errorf("Failed to delete the file %s that has moved, err: %v", fqn, err)
@@ -100,3 +243,77 @@ func (xreb *xactRebalance) rewalkf(fqn string, osfi os.FileInfo, err error) erro
 	}
 	return nil
 }
+
+// vsrewalkf sends the older versions and the delete markers to the targets that store
+// the object - the object's replica holders, or its HRW target - see rewalkf
+func (xreb *xactRebalance) vsrewalkf(fqn string, osfi os.FileInfo, err error) error {
+	if err != nil {
+		glog.Errorf("vsrewalkf callback invoked with err: %v", err)
+		return err
+	}
+	if osfi.Mode().IsDir() {
+		return nil
+	}
+	if err = xreb.checkabort(); err != nil {
+		return err
+	}
+	t := xreb.targetrunner
+	bucket, objname, version, ok := vsfqn2obj(fqn)
+	if !ok {
+		return nil
+	}
+	size := osfi.Size()
+	atomic.AddInt64(&xreb.objsvisited, 1)
+	atomic.AddInt64(&xreb.bytesvisited, size)
+	sis, errstr := t.replicas(bucket, objname)
+	if errstr != "" {
+		atomic.AddInt64(&xreb.errors, 1)
+		return fmt.Errorf(errstr)
+	}
+	var (
+		ismember bool
+		failed   int
+	)
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			ismember = true
+			continue
+		}
+		if t.hasversion(si, bucket, objname, version, size) {
+			continue
+		}
+		glog.Infof("rebalancing version [%s %s %s] %s => %s", bucket, objname, version, t.si.DaemonID, si.DaemonID)
+		if s := t.sendversion(xreb.span, bucket, objname, version, si); s != "" {
+			glog.Infof("Failed to rebalance version [%s %s %s]: %s", bucket, objname, version, s)
+			failed++
+			continue
+		}
+		atomic.AddInt64(&xreb.objsmoved, 1)
+		atomic.AddInt64(&xreb.bytesmoved, size)
+		xreb.throttle(size)
+	}
+	atomic.AddInt64(&xreb.errors, int64(failed))
+	if !ismember && failed == 0 {
+		if err := os.Remove(fqn); err != nil {
+			glog.Errorf("Failed to delete the version %s that has moved, err: %v", fqn, err)
+		}
+	}
+	return nil
+}
+
+// checkabort returns an error if the rebalance is aborted or finished
+func (xreb *xactRebalance) checkabort() error {
+	select {
+	case <-xreb.abrt:
+		s := fmt.Sprintf("%s aborted, exiting rewalkf", xreb.tostring())
+		glog.Infoln(s)
+		glog.Flush()
+		return errors.New(s)
+	case <-time.After(time.Millisecond):
+		break
+	}
+	if xreb.finished() {
+		return fmt.Errorf("%s aborted - exiting rewalkf", xreb.tostring())
+	}
+	return nil
+}
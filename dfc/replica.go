@@ -0,0 +1,248 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// object replication: a bucket with N copies (lbmap.copies) has its objects
+// stored on the top-N HRW targets - see hrwTargets; the first target (the "primary")
+// is the one that receives PUTs and GETs via the proxy, the primary then sends
+// the object to the rest of them; the replicas are accessed target-to-target
+// with ParamReplica=true; the replica holders of a versioned bucket keep
+// the same older versions (see versioning.go)
+//
+//======
+
+// replicas returns the targets that must store a given object (the primary first)
+func (t *targetrunner) replicas(bucket, objname string) ([]*daemonInfo, string) {
+	return hrwTargets(bucket+"/"+objname, t.smap, t.lbmap.copies(bucket))
+}
+
+// replicate sends the (locally stored) object to the rest of its replica holders;
+// failures are not fatal - rebalance will restore the replica count
+func (t *targetrunner) replicate(bucket, objname string) {
+	if t.lbmap.copies(bucket) <= 1 {
+		return
+	}
+	sis, errstr := t.replicas(bucket, objname)
+	if errstr != "" {
+		glog.Errorf("Failed to replicate %s/%s: %s", bucket, objname, errstr)
+		return
+	}
+	// runs in the background: keep the object from being overwritten or removed while sending
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, false)
+
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		glog.Errorf("Failed to replicate %s/%s, err: %v", bucket, objname, err)
+		return
+	}
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		if errstr = t.sendfile(nil, http.MethodPut, bucket, objname, si, finfo.Size(), "", ""); errstr != "" {
+			glog.Errorf("Failed to replicate %s/%s => %s: %s", bucket, objname, si.DaemonID, errstr)
+			continue
+		}
+		if glog.V(3) {
+			glog.Infof("Replicated %s/%s => %s", bucket, objname, si.DaemonID)
+		}
+	}
+}
+
+// getreplica gets the object from one of its replica holders;
+// the caller must hold the exclusive lock on the object's name
+func (t *targetrunner) getreplica(bucket, objname, fqn string) (ok bool) {
+	sis, errstr := t.replicas(bucket, objname)
+	if errstr != "" {
+		return
+	}
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		if ok = t.getreplicafrom(si, bucket, objname, fqn); ok {
+			glog.Infof("GET %s/%s: restored from the replica at %s", bucket, objname, si.DaemonID)
+			return
+		}
+	}
+	return
+}
+
+func (t *targetrunner) getreplicafrom(si *daemonInfo, bucket, objname, fqn string) bool {
+	started := time.Now()
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	url += fmt.Sprintf("?%s=true", ParamReplica)
+	response, err := t.httpclient.Get(url)
+	if err != nil {
+		glog.Errorf("Failed to GET %s/%s from %s, err: %v", bucket, objname, si.DaemonID, err)
+		return false
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return false
+	}
+	var (
+		hdhobj = newcksumvalue(response.Header.Get(HeaderDfcChecksumType), response.Header.Get(HeaderDfcChecksumVal))
+		getfqn = fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
+	)
+	_, nhobj, _, errstr := t.receive(getfqn, false, bucket, objname, "", hdhobj, response.Body)
+	if errstr == "" {
+		errstr = finalizeobj(getfqn, nhobj)
+	}
+	if errstr == "" {
+		if version := response.Header.Get(HeaderDfcObjVersion); version != "" {
+			errstr = Setxattr(getfqn, xattrObjVersion, []byte(version))
+		}
+	}
+	if errstr == "" {
+		usermeta, _ := usermetafromheader(response.Header)
+		errstr = setusermeta(getfqn, usermeta)
+	}
+	if errstr == "" {
+		if err = os.Rename(getfqn, fqn); err != nil {
+			errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", getfqn, fqn, err)
+		}
+	}
+	if errstr != "" {
+		glog.Errorf("Failed to GET %s/%s from %s: %s", bucket, objname, si.DaemonID, errstr)
+		os.Remove(getfqn)
+		return false
+	}
+	t.statsif.add("numrecvfiles", 1)
+	t.statsif.add("numrecvbytes", response.ContentLength)
+	t.latency.add(latRecv, time.Since(started))
+	return true
+}
+
+// hasreplica checks (via HEAD) whether a given target stores the object of a given size
+func (t *targetrunner) hasreplica(si *daemonInfo, bucket, objname string, size int64) bool {
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	url += fmt.Sprintf("?%s=true", ParamReplica)
+	response, err := t.httpclient.Head(url)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == http.StatusOK && response.ContentLength == size
+}
+
+// delreplicas removes the object's replicas from all targets but self; given a version, removes
+// the version only (see vsremove) or, given a delete marker, keeps the object as an older version
+// and adds the marker (see vsdelete)
+func (t *targetrunner) delreplicas(bucket, objname, version string) {
+	if t.lbmap.copies(bucket) <= 1 {
+		return
+	}
+	sis, errstr := t.replicas(bucket, objname)
+	if errstr != "" {
+		glog.Errorf("Failed to delete replicas of %s/%s: %s", bucket, objname, errstr)
+		return
+	}
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			continue
+		}
+		url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+		url += fmt.Sprintf("?%s=true", ParamReplica)
+		if version != "" {
+			url += fmt.Sprintf("&%s=%s", ParamVersion, version)
+		}
+		if _, err, errstr, _ := t.call(si, url, http.MethodDelete, nil); err != nil {
+			glog.Errorf("Failed to delete replica %s/%s at %s: %s", bucket, objname, si.DaemonID, errstr)
+		}
+	}
+}
+
+//
+// target-to-target handlers (ParamReplica=true)
+//
+func (t *targetrunner) headreplica(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn := t.fqn(bucket, objname)
+	if version := r.URL.Query().Get(ParamVersion); version != "" {
+		if !vsvalid(version) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		fqn = filepath.Join(vsdirfqn(bucket, objname), version)
+	}
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(finfo.Size(), 10))
+}
+
+func (t *targetrunner) delreplica(w http.ResponseWriter, r *http.Request, bucket, objname string) {
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, true)
+
+	var errstr string
+	switch version := r.URL.Query().Get(ParamVersion); {
+	case version != "" && !vsvalid(version):
+		errstr = fmt.Sprintf("Invalid version %q of %s/%s", version, bucket, objname)
+	case version == "":
+		if err := os.Remove(fqn); err != nil && !os.IsNotExist(err) {
+			errstr = fmt.Sprintf("Failed to delete replica %s, err: %v", fqn, err)
+		}
+	case strings.HasSuffix(version, vsMarkerSuffix):
+		errstr = vsdelete(bucket, objname, fqn, strings.TrimSuffix(version, vsMarkerSuffix))
+	default:
+		_, _, errstr, _ = vsremove(bucket, objname, fqn, version)
+	}
+	if errstr != "" {
+		t.invalmsghdlr(w, r, errstr)
+	}
+}
+
+// rebalancereplicas makes sure that all replica holders of a given (locally stored) object
+// do have it, and removes the local copy if this target is not one of them
+func (t *targetrunner) rebalancereplicas(sp *span, fqn, bucket, objname string, size int64) (moved, failed int, errstr string) {
+	sis, errstr := t.replicas(bucket, objname)
+	if errstr != "" {
+		return
+	}
+	var ismember bool
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			ismember = true
+			continue
+		}
+		if t.hasreplica(si, bucket, objname, size) {
+			continue
+		}
+		glog.Infof("rebalancing replica [%s %s] %s => %s", bucket, objname, t.si.DaemonID, si.DaemonID)
+		if s := t.sendfile(sp, http.MethodPut, bucket, objname, si, size, "", ""); s != "" {
+			glog.Infof("Failed to rebalance replica [%s %s]: %s", bucket, objname, s)
+			failed++
+		} else {
+			moved++
+		}
+	}
+	if !ismember && failed == 0 {
+		if err := os.Remove(fqn); err != nil {
+			glog.Errorf("Failed to delete the file %s that has moved, err: %v", fqn, err)
+		}
+	}
+	return
+}
@@ -6,6 +6,7 @@
 	"cloud_buckets":		"cloud",
 	"local_buckets":		"local",
 	"lb_conf":                	"localbuckets",
+	"smap_conf":              	"smap",
 	"stats_time":			"10s",
 	"http": {
 		"timeout":		"30s",
@@ -19,12 +20,19 @@
 	},
 	"proxy": {
 		"url": 			"${PROXYURL}",
-		"passthru": 		true
+		"passthru": 		true,
+		"standby": 		${STANDBY}
 	},
 	"s3": {
 		"maxconcurrdownld":	64,
 		"maxconcurrupld":	64,
-		"maxpartsize":		4294967296
+		"maxpartsize":		4294967296,
+		"endpoint":		"${S3ENDPOINT}",
+		"region":		"",
+		"path_style":		true
+	},
+	"posix": {
+		"root":			"${POSIXROOT}"
 	},
 	"cksum_config": {
                  "validate_cold_get":	true,
@@ -37,7 +45,10 @@
 		"lowwm":		75,
 		"highwm":		90,
 		"dont_evict_time":	"120m",
-		"lru_enabled":  	true
+		"lru_enabled":  	true,
+		"policy":		"lru",
+		"bucket_policy":	{},
+		"bucket_quota":	{}
 	},
 	"test_fspaths": {
 		"root":			"/tmp/dfc/",
@@ -51,6 +62,28 @@
 	"ack_policy": {
 		"put":			"disk",
 		"max_mem_mb":		16
+	},
+	"multipart": {
+		"abandon_time":		"24h"
+	},
+	"trace": {
+		"enabled":		${TRACE:-false},
+		"collector":		"",
+		"file":			"$LOGDIR/spans.json",
+		"sample":		1.0
+	},
+	"auth": {
+		"enabled":		${AUTH:-false},
+		"secret":		"${AUTHSECRET}",
+		"token_ttl":		"24h",
+		"users":		{}
+	},
+	"tls": {
+		"enabled":		${TLS:-false},
+		"certificate":		"${TLSCERT}",
+		"key":			"${TLSKEY}",
+		"ca":			"${TLSCA}",
+		"mutual":		${MTLS:-false}
 	}
 }
 EOL
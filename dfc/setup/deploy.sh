@@ -8,6 +8,9 @@
 
 export GOOGLE_CLOUD_PROJECT="involuted-forge-189016"
 PROXYURL="http://localhost:8080"
+if [ "${TLS:-false}" = true ]; then
+	PROXYURL="https://localhost:8080"
+fi
 PORT=8079
 LOGLEVEL="3" # Verbosity: 0 (minimal) to 4 (max)
 LOGROOT="/tmp/dfc"
@@ -55,6 +58,12 @@ fi
 START=0
 END=$servcount
 
+echo "Number of standby proxies (enter 0 for a single proxy):"
+read standbycnt
+if ! [[ "$standbycnt" =~ ^[0-9]+$ ]] ; then
+	echo "Error: '$standbycnt' is not a number"; exit 1
+fi
+
 echo "Number of local cache directories (enter 0 to use preconfigured filesystems):"
 read testfspathcnt
 if ! [[ "$testfspathcnt" =~ ^[0-9]+$ ]] ; then
@@ -65,6 +74,8 @@ TESTFSPATHCOUNT=$testfspathcnt
 echo Select Cloud Provider:
 echo  1: Amazon Cloud
 echo  2: Google Cloud
+echo  3: Local directory \(posix\)
+echo  4: S3-compatible endpoint
 echo Enter your choice:
 read cldprovider
 if [ $cldprovider -eq 1 ]
@@ -73,8 +84,24 @@ then
 elif [ $cldprovider -eq 2 ]
 then
 	CLDPROVIDER="gcp"
+elif [ $cldprovider -eq 3 ]
+then
+	CLDPROVIDER="posix"
+	POSIXROOT=${POSIXROOT:-/tmp/dfc-posix}
+	echo "Posix root directory (one subdirectory per bucket) [$POSIXROOT]:"
+	read posixroot
+	POSIXROOT=${posixroot:-$POSIXROOT}
+	mkdir -p $POSIXROOT
+elif [ $cldprovider -eq 4 ]
+then
+	CLDPROVIDER="s3compat"
+	echo "S3-compatible endpoint URL (e.g. http://localhost:9000):"
+	read S3ENDPOINT
+	if [ -z "$S3ENDPOINT" ]; then
+		echo "Error: endpoint is required"; exit 1
+	fi
 else
-	echo "Error: '$cldprovider' is not a valid input, can be either 1 or 2"; exit 1
+	echo "Error: '$cldprovider' is not a valid input, can be 1, 2, 3 or 4"; exit 1
 fi
 
 mkdir -p $CONFPATH
@@ -82,11 +109,16 @@ mkdir -p $CONFPATH
 # generate conf file(s) based on the settings/selections above
 #
 DIR="$( cd "$( dirname "${BASH_SOURCE[0]}" )" && pwd )"
-for (( c=$START; c<=$END; c++ ))
+# (the primary proxy, targets, and then standby proxies, if any)
+for (( c=$START; c<=$END+$standbycnt; c++ ))
 do
 	PORT=$(expr $PORT + 1)
 	CONFFILE="$CONFPATH/dfc$c.json"
 	LOGDIR="$LOGROOT/$c/log"
+	STANDBY=false
+	if [ $c -gt $END ]; then
+		STANDBY=true
+	fi
 	source $DIR/config.sh
 done
 
@@ -116,5 +148,13 @@ do
 			{ set +x; } 2>/dev/null
 	fi
 done
+# standby proxies join the primary once the latter is up and running
+for (( c=$END+1; c<=$END+$standbycnt; c++ ))
+do
+	CONFFILE="$CONFPATH/dfc$c.json"
+	set -x
+	$GOPATH/bin/dfc -config=$CONFFILE -role=proxy $1 $2 &
+	{ set +x; } 2>/dev/null
+done
 sleep 2
 echo done
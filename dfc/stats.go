@@ -59,6 +59,8 @@ type targetCoreStats struct {
 	Bytesvchanged    int64 `json:"bytesvchanged"`
 	Numbadchecksum   int64 `json:"numbadchecksum"`
 	Bytesbadchecksum int64 `json:"bytesbadchecksum"`
+	Numrangeget      int64 `json:"numrangeget"`
+	Numecrestored    int64 `json:"numecrestored"`
 }
 
 type statsrunner struct {
@@ -71,20 +73,28 @@ type statsrunner struct {
 type proxystatsrunner struct {
 	statsrunner `json:"-"`
 	Core        proxyCoreStats `json:"core"`
+	Latency     latencies      `json:"latency"`
 	ccopy       proxyCoreStats `json:"-"`
 }
 
 type storstatsrunner struct {
 	statsrunner `json:"-"`
-	Core        targetCoreStats         `json:"core"`
-	Capacity    map[string]*fscapacity  `json:"capacity"`
-	ccopy       targetCoreStats         `json:"-"`
-	fsmap       map[syscall.Fsid]string `json:"-"`
+	Core        targetCoreStats            `json:"core"`
+	Capacity    map[string]*fscapacity     `json:"capacity"`
+	Buckets     map[string]*bucketcapacity `json:"buckets,omitempty"` // with bucket quotas only
+	Latency     latencies                  `json:"latency"`
+	ccopy       targetCoreStats            `json:"-"`
+	fsmap       map[syscall.Fsid]string    `json:"-"`
+	mpcleaned   time.Time                  `json:"-"`
+	quotachkd   time.Time                  `json:"-"`
 }
 
 type ClusterStats struct {
-	Proxy  *proxyCoreStats             `json:"proxy"`
-	Target map[string]*storstatsrunner `json:"target"`
+	Proxy        *proxyCoreStats             `json:"proxy"`
+	ProxyLatency latencies                   `json:"proxy_latency"`
+	Target       map[string]*storstatsrunner `json:"target"`
+	Buckets      map[string]*bucketcapacity  `json:"buckets,omitempty"` // totals across targets
+	Latency      latencies                   `json:"latency"`           // merged across targets
 }
 
 //
@@ -95,7 +105,7 @@ func newClusterStats() *ClusterStats {
 	for _, si := range ctx.smap.Smap {
 		targets[si.DaemonID] = &storstatsrunner{Capacity: make(map[string]*fscapacity)}
 	}
-	return &ClusterStats{Target: targets}
+	return &ClusterStats{Target: targets, Latency: newlatencies(targetLatencyOps)}
 }
 
 func (s *proxyCoreStats) add(name string, val int64) {
@@ -165,6 +175,10 @@ func (s *targetCoreStats) add(name string, val int64) {
 		v = &s.Numbadchecksum
 	case "bytesbadchecksum":
 		v = &s.Bytesbadchecksum
+	case "numrangeget":
+		v = &s.Numrangeget
+	case "numecrestored":
+		v = &s.Numecrestored
 	default:
 		assert(false, "Invalid stats name "+name)
 	}
@@ -273,7 +287,12 @@ func (r *storstatsrunner) log() (runlru bool) {
 func (r *storstatsrunner) housekeep(runlru bool) {
 	t := gettarget()
 
-	if runlru && ctx.config.LRUConfig.LRUEnabled {
+	// bucket quotas are enforced periodically, regardless of the watermarks
+	if !runlru && len(ctx.config.LRUConfig.BucketQuota) > 0 && time.Since(r.quotachkd) >= quotacheckivl {
+		runlru = true
+	}
+	if runlru && t.lbmap.lruenabled() {
+		r.quotachkd = time.Now()
 		go t.runLRU()
 	}
 
@@ -281,6 +300,12 @@ func (r *storstatsrunner) housekeep(runlru bool) {
 	if len(t.prefetchQueue) > 0 {
 		go t.doPrefetch()
 	}
+
+	// remove abandoned multipart uploads
+	if time.Since(r.mpcleaned) >= mpCleanupInterval {
+		r.mpcleaned = time.Now()
+		go t.mpcleanup()
+	}
 }
 
 func (r *storstatsrunner) updateCapacity() (runlru bool) {
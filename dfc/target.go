@@ -16,9 +16,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -45,17 +46,16 @@ type allfinfos struct {
 }
 
 type cachedInfos struct {
-	files        []*BucketEntry
-	fileCount    int
-	rootLength   int
-	prefix       string
-	marker       string
-	markerDirs   []string
-	needAtime    bool
-	msg          *GetMsg
-	lastFilePath string
-	t            *targetrunner
-	bucket       string
+	files      []*BucketEntry
+	fileCount  int // per mountpath
+	limit      int
+	rootLength int
+	prefix     string
+	marker     string
+	needAtime  bool
+	msg        *GetMsg
+	t          *targetrunner
+	bucket     string
 }
 
 //===========================================================================
@@ -65,7 +65,8 @@ type cachedInfos struct {
 //===========================================================================
 type targetrunner struct {
 	httprunner
-	cloudif       cloudif // multi-cloud vendor support
+	cloudifs      map[string]cloudif // multi-cloud vendor support: cloud provider => cloudif
+	cloudmtx      sync.Mutex
 	smap          *Smap
 	proxysi       *daemonInfo
 	xactinp       *xactInProgress
@@ -73,16 +74,20 @@ type targetrunner struct {
 	lbmap         *lbmap
 	rtnamemap     *rtnamemap
 	prefetchQueue chan filesWithDeadline
+	lrjobs        *lrjobs        // list/range jobs
+	lastreb       *xactRebalance // the last (or current) rebalance, protected by xactinp.lock
+	rebthrottle   int64          // rebalance bandwidth limit, bytes per second (0 - unlimited)
 }
 
 // start target runner
 func (t *targetrunner) run() error {
 	t.httprunner.init(getstorstats())
 	t.httprunner.kalive = gettargetkalive()
-	t.smap = &Smap{}                                 // cluster map
-	t.xactinp = newxactinp()                         // extended actions
-	t.lbmap = &lbmap{LBmap: make(map[string]string)} // local (cache-only) buckets
-	t.rtnamemap = newrtnamemap(128)                  // lock/unlock name
+	t.httprunner.latency = getstorstatsrunner().Latency
+	t.smap = &Smap{}                      // cluster map
+	t.xactinp = newxactinp(t.si.DaemonID) // extended actions
+	t.lbmap = newlbmap()                  // local (cache-only) buckets
+	t.rtnamemap = newrtnamemap(128)       // lock/unlock name
 
 	if status, err := t.register(0); err != nil {
 		glog.Errorf("Target %s failed to register with proxy, err: %v", t.si.DaemonID, err)
@@ -119,20 +124,15 @@ func (t *targetrunner) run() error {
 		}
 	}
 
-	// cloud provider
-	if ctx.config.CloudProvider == amazoncloud {
-		// TODO: sessions
-		t.cloudif = &awsimpl{t}
-
-	} else {
-		assert(ctx.config.CloudProvider == googlecloud)
-		t.cloudif = &gcpimpl{t}
-	}
+	// cloud provider(s)
+	t.cloudifs = make(map[string]cloudif, 2)
+	t.cloudifs[ctx.config.CloudProvider] = t.newcloudif(ctx.config.CloudProvider)
 	// init capacity
 	rr := getstorstatsrunner()
 	rr.initCapacity()
 	// prefetch
 	t.prefetchQueue = make(chan filesWithDeadline, prefetchChanSize)
+	t.lrjobs = newlrjobs()
 
 	//
 	// REST API: register storage target's handler(s) and start listening
@@ -141,6 +141,9 @@ func (t *targetrunner) run() error {
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon, t.daemonhdlr)
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rdaemon+"/", t.daemonhdlr) // FIXME
 	t.httprunner.registerhdlr("/"+Rversion+"/"+Rpush+"/", t.pushhdlr)
+	t.httprunner.registerhdlr("/"+Rversion+"/"+Rjobs+"/", t.jobhdlr)
+	t.httprunner.registerhdlr("/"+Rmetrics, t.metricshdlr)
+	t.httprunner.authorize = t.authorize
 	t.httprunner.registerhdlr("/", invalhdlr)
 	glog.Infof("Target %s is ready", t.si.DaemonID)
 	glog.Flush()
@@ -148,6 +151,33 @@ func (t *targetrunner) run() error {
 	return t.httprunner.run()
 }
 
+// newcloudif returns cloudif implementation for the given (and already validated) cloud provider
+func (t *targetrunner) newcloudif(provider string) cloudif {
+	switch provider {
+	case amazoncloud, s3compatcloud:
+		// TODO: sessions
+		return &awsimpl{t: t, provider: provider}
+	case posixcloud:
+		return &posiximpl{t: t, root: ctx.config.Posix.Root}
+	default:
+		assert(provider == googlecloud, provider)
+		return &gcpimpl{t}
+	}
+}
+
+// getcloudif returns cloudif of the cloud provider that is assigned to the bucket
+func (t *targetrunner) getcloudif(bucket string) cloudif {
+	provider := t.lbmap.provider(bucket)
+	t.cloudmtx.Lock()
+	defer t.cloudmtx.Unlock()
+	cloudif, ok := t.cloudifs[provider]
+	if !ok {
+		cloudif = t.newcloudif(provider)
+		t.cloudifs[provider] = cloudif
+	}
+	return cloudif
+}
+
 // stop gracefully
 func (t *targetrunner) stop(err error) {
 	glog.Infof("Stopping %s, err: %v", t.name, err)
@@ -162,13 +192,21 @@ func (t *targetrunner) stop(err error) {
 	}
 }
 
+// proxyurl returns the primary proxy's URL as per the current Smap or, if not known yet, the configured one
+func (t *targetrunner) proxyurl() string {
+	if t.proxysi != nil {
+		return t.proxysi.DirectURL
+	}
+	return ctx.config.Proxy.URL
+}
+
 // target registration with proxy
 func (t *targetrunner) register(timeout time.Duration) (status int, err error) {
 	jsbytes, err := json.Marshal(t.si)
 	if err != nil {
 		return 0, fmt.Errorf("Unexpected failure to json-marshal %+v, err: %v", t.si, err)
 	}
-	url := ctx.config.Proxy.URL + "/" + Rversion + "/" + Rcluster
+	url := t.proxyurl() + "/" + Rversion + "/" + Rcluster
 	if timeout > 0 { // keepalive
 		url += "/" + Rkeepalive
 		_, err, _, status = t.call(t.proxysi, url, http.MethodPost, jsbytes, timeout)
@@ -179,7 +217,7 @@ func (t *targetrunner) register(timeout time.Duration) (status int, err error) {
 }
 
 func (t *targetrunner) unregister() (status int, err error) {
-	url := ctx.config.Proxy.URL + "/" + Rversion + "/" + Rcluster
+	url := t.proxyurl() + "/" + Rversion + "/" + Rcluster
 	url += "/" + Rdaemon + "/" + t.si.DaemonID
 	_, err, _, status = t.call(t.proxysi, url, http.MethodDelete, nil)
 	return
@@ -212,9 +250,9 @@ func (t *targetrunner) filehdlr(w http.ResponseWriter, r *http.Request) {
 // checkCloudVersion returns if versions of an object differ in Cloud and DFC cache
 // and the object should be refreshed from Cloud storage
 // It should be called only in case of the object is present in DFC cache
-func (t *targetrunner) checkCloudVersion(bucket, objname, version string) (vchanged bool, errstr string, errcode int) {
+func (t *targetrunner) checkCloudVersion(sp *span, bucket, objname, version string) (vchanged bool, errstr string, errcode int) {
 	var objmeta map[string]string
-	if objmeta, errstr, errcode = t.cloudif.headobject(bucket, objname); errstr != "" {
+	if objmeta, errstr, errcode = t.tracecloud(sp, bucket).headobject(bucket, objname); errstr != "" {
 		return
 	}
 	if cloudVersion, ok := objmeta["version"]; ok {
@@ -235,14 +273,13 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 	var (
 		nhobj                        cksumvalue
 		coldget, exclusive, vchanged bool
+		isreplica                    bool
 		bucket, objname, fqn         string
 		uname, errstr, version       string
 		size                         int64
 		errcode                      int
 		props                        *objectProps
 	)
-	cksumcfg := &ctx.config.CksumConfig
-	versioncfg := &ctx.config.VersionConfig
 	apitems := t.restAPIItems(r.URL.Path, 5)
 	if apitems = t.checkRestAPI(w, r, apitems, 1, Rversion, Rfiles); apitems == nil {
 		return
@@ -251,6 +288,8 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 	if len(apitems) > 1 {
 		objname = apitems[1]
 	}
+	cksumcfg := t.lbmap.cksumconf(bucket)
+	versioncfg := t.lbmap.versionconf(bucket)
 	if strings.Contains(bucket, "/") {
 		errstr = fmt.Sprintf("Invalid bucket name %s (contains '/')", bucket)
 		t.invalmsghdlr(w, r, errstr)
@@ -263,6 +302,7 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 		t.listbucket(w, r, bucket)
 		return
 	}
+	started, latop := time.Now(), latGet
 	//
 	// serialize on the name
 	//
@@ -270,6 +310,37 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 	t.rtnamemap.lockname(uname, exclusive, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
 	defer func(locktype *bool) { t.rtnamemap.unlockname(uname, *locktype) }(&exclusive)
 
+	//
+	// erasure coded slice?
+	//
+	if r.URL.Query().Get(ParamECSlice) == "true" {
+		t.ecgetslice(w, r, bucket, objname)
+		return
+	}
+	//
+	// older version?
+	//
+	if v := r.URL.Query().Get(ParamVersion); v != "" && t.islocalBucket(bucket) {
+		if finfo, err := os.Stat(fqn); err != nil || objversion(fqn, finfo) != v {
+			t.vsget(w, r, bucket, objname, v)
+			return
+		}
+	}
+	//
+	// not present locally? get it from one of the replicas (if any) rather than from the Cloud,
+	// or restore it from its erasure coded slices
+	//
+	isreplica = r.URL.Query().Get(ParamReplica) == "true"
+	if !isreplica && (t.lbmap.copies(bucket) > 1 || t.ecenabled(bucket)) {
+		if _, err := os.Stat(fqn); err != nil && os.IsNotExist(err) {
+			restored := t.lbmap.copies(bucket) > 1 && t.getreplica(bucket, objname, fqn)
+			if !restored && t.ecenabled(bucket) {
+				if s := t.ecrestore(bucket, objname, fqn); s != "" {
+					glog.Errorln(s)
+				}
+			}
+		}
+	}
 	//
 	// get the object from the bucket
 	//
@@ -277,19 +348,32 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 		t.invalmsghdlr(w, r, errstr, http.StatusInternalServerError)
 		return
 	}
+	if coldget && isreplica {
+		errstr = fmt.Sprintf("Replica %s/%s does not exist at %s", bucket, objname, t.si.DaemonID)
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
 	// FIXME - TODO: split ValidateWarmGet into a) validate and b) get new if invalid
 	// the second flag controls whether the original request blocks on version update
-	if !coldget && versioncfg.ValidateWarmGet && version != "" {
-		if vchanged, errstr, errcode = t.checkCloudVersion(bucket, objname, version); errstr != "" {
+	if !coldget && !isreplica && versioncfg.ValidateWarmGet && version != "" && !t.islocalBucket(bucket) {
+		if vchanged, errstr, errcode = t.checkCloudVersion(spanof(r), bucket, objname, version); errstr != "" {
 			t.invalmsghdlr(w, r, errstr, errcode)
 			return
 		}
 		coldget = vchanged
 	}
+	// cold GET of a single range: optionally, read only the range
+	if coldget && !vchanged && r.URL.Query().Get(ParamRangeOnly) == "true" && r.Header.Get(HeaderIfRange) == "" {
+		if offset, length, ok := parseSingleRange(r.Header.Get(HeaderRange)); ok {
+			t.getcoldrange(w, r, bucket, objname, offset, length)
+			return
+		}
+	}
 	if coldget {
 		// FIXME - TODO: with rename similar to PUT
 		// getfqn := fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
-		if props, errstr, errcode = getcloudif().getobj(fqn, bucket, objname); errstr != "" {
+		cloudstarted := time.Now()
+		if props, errstr, errcode = t.tracecloud(spanof(r), bucket).getobj(fqn, bucket, objname); errstr != "" {
 			if errcode == 0 {
 				t.invalmsghdlr(w, r, errstr)
 			} else {
@@ -297,7 +381,12 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
-		size, nhobj = props.size, props.nhobj
+		t.latency.add(latCloudGet, time.Since(cloudstarted))
+		latop = latColdGet
+		size, nhobj, version = props.size, props.nhobj, props.version
+		if errs := setusermeta(fqn, props.usermeta); errs != "" {
+			glog.Errorln(errs)
+		}
 		t.statsif.add("numcoldget", 1)
 		t.statsif.add("bytesloaded", size)
 		if vchanged {
@@ -305,6 +394,9 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 			t.statsif.add("numvchanged", 1)
 		}
 	}
+	if !isreplica {
+		t.accessed(bucket, fqn, coldget)
+	}
 	//
 	// downgrade lock(name)
 	//
@@ -332,6 +424,36 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 			nhobj = newcksumvalue(cksumcfg.Checksum, string(hashbinary))
 		}
 	}
+	if nhobj != nil {
+		_, hval := nhobj.get()
+		w.Header().Set(HeaderETag, strconv.Quote(hval))
+	}
+	if version != "" {
+		w.Header().Set(HeaderDfcObjVersion, version)
+	}
+	setusermetaheader(w.Header(), getusermeta(fqn))
+	//
+	// range read(s): single and multi-range, 206 and If-Range - all via http.ServeContent
+	//
+	if r.Header.Get(HeaderRange) != "" {
+		finfo, err := file.Stat()
+		if err != nil {
+			errstr = fmt.Sprintf("Failed to fstat %s, err: %v", fqn, err)
+			t.invalmsghdlr(w, r, errstr, http.StatusInternalServerError)
+			return
+		}
+		http.ServeContent(w, r, objname, finfo.ModTime(), file)
+		if coldget && props.version != "" {
+			Setxattr(fqn, xattrObjVersion, []byte(props.version))
+		}
+		if coldget {
+			go t.replicate(bucket, objname)
+		}
+		t.statsif.add("numget", 1)
+		t.statsif.add("numrangeget", 1)
+		t.latency.add(latop, time.Since(started))
+		return
+	}
 	if nhobj != nil {
 		htype, hval := nhobj.get()
 		w.Header().Add(HeaderDfcChecksumType, htype)
@@ -358,7 +480,77 @@ func (t *targetrunner) httpfilget(w http.ResponseWriter, r *http.Request) {
 	if coldget && props.version != "" {
 		Setxattr(fqn, xattrObjVersion, []byte(props.version))
 	}
+	if coldget {
+		go t.replicate(bucket, objname)
+	}
 	t.statsif.add("numget", 1)
+	t.latency.add(latop, time.Since(started))
+}
+
+// getcoldrange reads the requested range directly from the Cloud - the object is not cached
+func (t *targetrunner) getcoldrange(w http.ResponseWriter, r *http.Request, bucket, objname string, offset, length int64) {
+	rc, objsize, errstr, errcode := t.tracecloud(spanof(r), bucket).getobjrange(bucket, objname, offset, length)
+	if errstr != "" {
+		if errcode == 0 {
+			t.invalmsghdlr(w, r, errstr)
+		} else {
+			t.invalmsghdlr(w, r, errstr, errcode)
+		}
+		return
+	}
+	defer rc.Close()
+	last := offset + length - 1
+	if length < 0 || last >= objsize {
+		last = objsize - 1
+	}
+	w.Header().Set("Accept-Ranges", "bytes")
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, last, objsize))
+	w.Header().Set("Content-Length", strconv.FormatInt(last-offset+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+
+	slab := selectslab(last - offset + 1)
+	buf := slab.alloc()
+	defer slab.free(buf)
+	written, err := io.CopyBuffer(w, rc, buf)
+	if err != nil {
+		// the status has been already sent
+		glog.Errorf("Failed to send %s/%s range [%d, %d], err: %v", bucket, objname, offset, last, err)
+		t.statsif.add("numerr", 1)
+		return
+	}
+	if glog.V(3) {
+		glog.Infof("GET: sent %s/%s range [%d, %d] (%.2f MB)", bucket, objname, offset, last, float64(written)/1000/1000)
+	}
+	t.statsif.add("numget", 1)
+	t.statsif.add("numrangeget", 1)
+	t.statsif.add("bytesloaded", written)
+}
+
+// parseSingleRange parses "bytes=<first>-[<last>]" and returns the offset and length (-1: till the end);
+// multiple ranges and suffix ranges ("bytes=-<n>") are not supported
+func parseSingleRange(hdr string) (offset, length int64, ok bool) {
+	const prefix = "bytes="
+	if !strings.HasPrefix(hdr, prefix) || strings.Contains(hdr, ",") {
+		return
+	}
+	rng := strings.Split(strings.TrimSpace(hdr[len(prefix):]), "-")
+	if len(rng) != 2 || rng[0] == "" {
+		return
+	}
+	var err error
+	if offset, err = strconv.ParseInt(rng[0], 10, 64); err != nil || offset < 0 {
+		return
+	}
+	length = -1
+	if rng[1] != "" {
+		last, err := strconv.ParseInt(rng[1], 10, 64)
+		if err != nil || last < offset {
+			return
+		}
+		length = last - offset + 1
+	}
+	ok = true
+	return
 }
 
 func (t *targetrunner) getchecklocal(bucket, objname, fqn string) (coldget bool, size int64, version string, errstr string) {
@@ -446,27 +638,23 @@ func (t *targetrunner) listCachedObjects(bucket string, msg *GetMsg) (outbytes [
 		return nil, fmt.Sprintf("Cache is unavailable for local bucket %s", bucket), 0
 	}
 
-	needAtime := strings.Contains(msg.GetProps, GetPropsAtime)
-	// Split a marker into separate directory list to make pagination
-	// more effective. All directories in the list are skipped by
-	// filepath.Walk. The last directory of the marker must be excluded
-	// from the list because the marker can point to the middle of it
-	markerDirs := make([]string, 0)
-	if msg.GetPageMarker != "" && strings.Contains(msg.GetPageMarker, "/") {
-		idx := strings.LastIndex(msg.GetPageMarker, "/")
-		markerDirs = strings.Split(msg.GetPageMarker[:idx], "/")
-		markerDirs = markerDirs[:len(markerDirs)-1]
+	allfinfos := cachedInfos{
+		files:     make([]*BucketEntry, 0, initialBucketListSize),
+		prefix:    msg.GetPrefix,
+		marker:    msg.GetPageMarker,
+		needAtime: strings.Contains(msg.GetProps, GetPropsAtime),
+		msg:       msg,
+		t:         t,
+		bucket:    bucket,
 	}
-	allfinfos := cachedInfos{make([]*BucketEntry, 0, cachedPageSize), 0, 0, msg.GetPrefix, msg.GetPageMarker, markerDirs, needAtime, msg, "", t, bucket}
-
-	// We need stable order of mountpaths
-	mpathList := make([]string, 0, len(ctx.mountpaths))
-	for mpath := range ctx.mountpaths {
-		mpathList = append(mpathList, mpath)
+	pagesize := cachedPageSize
+	if msg.GetPageSize > 0 {
+		pagesize = msg.GetPageSize
 	}
-	sort.Strings(mpathList)
-
-	for _, mpath := range mpathList {
+	// each mountpath is walked in name order up to one name past the page, which is
+	// enough to tell whether the merged page is truncated
+	allfinfos.limit = pagesize + 1
+	for mpath := range ctx.mountpaths {
 		localbucketfqn := mpath + "/" + ctx.config.CloudBuckets + "/" + bucket
 		_, err = os.Stat(localbucketfqn)
 		if err != nil {
@@ -474,18 +662,15 @@ func (t *targetrunner) listCachedObjects(bucket string, msg *GetMsg) (outbytes [
 		}
 
 		allfinfos.rootLength = len(localbucketfqn) + 1 // +1 for separator between bucket and filename
-		if err = filepath.Walk(localbucketfqn, allfinfos.listwalkf); err != nil {
+		allfinfos.fileCount = 0
+		if err = walkbyname(localbucketfqn, allfinfos.listwalkf); err != nil {
 			errstr = fmt.Sprintf("Failed to traverse mpath %q, err: %v", mpath, err)
 			glog.Errorf(errstr)
 		}
 	}
 
 	if err == nil {
-		var reslist = BucketList{Entries: allfinfos.files}
-		// Mark the batch as truncated if it is full
-		if len(allfinfos.files) >= cachedPageSize {
-			reslist.PageMarker = allfinfos.lastFilePath
-		}
+		reslist := pagebymarker(allfinfos.files, msg.GetPageMarker, pagesize)
 		outbytes, err = json.Marshal(reslist)
 	}
 	if err != nil {
@@ -507,55 +692,91 @@ func (t *targetrunner) doLocalBucketList(w http.ResponseWriter, r *http.Request,
 	}
 
 	t.statsif.add("numlist", 1)
-	var reslist = BucketList{Entries: make([]*BucketEntry, 0, len(finfos.finfos))}
+	allversions := strings.Contains(msg.GetProps, GetPropsAllVersions)
+	// page by names first, to read the properties of the page's objects only
+	entries := make([]*BucketEntry, 0, len(finfos.finfos))
+	stubs := make(map[*BucketEntry]fipair, len(finfos.finfos))
 	for _, fi := range finfos.finfos {
 		if msg.GetPrefix != "" && !strings.HasPrefix(fi.relname, msg.GetPrefix) {
 			continue
 		}
-
-		entry := &BucketEntry{}
-		entry.Name = fi.relname
-
-		if strings.Contains(msg.GetProps, GetPropsSize) {
-			entry.Size = fi.Size()
-		}
-		if strings.Contains(msg.GetProps, GetPropsCtime) {
-			t := fi.ModTime()
-			switch msg.GetTimeFormat {
-			case "":
-				fallthrough
-			case RFC822:
-				entry.Ctime = t.Format(time.RFC822)
-			default:
-				entry.Ctime = t.Format(msg.GetTimeFormat)
-			}
+		// the replicas are listed by their primary only
+		if si, errstr := hrwTarget(bucket+"/"+fi.relname, t.smap); errstr != "" || si.DaemonID != t.si.DaemonID {
+			continue
 		}
-		if strings.Contains(msg.GetProps, GetPropsChecksum) {
-			fqn := t.fqn(bucket, fi.relname)
-			xxhex, errstr := Getxattr(fqn, xattrXXHashVal)
-			if errstr == "" {
-				entry.Checksum = hex.EncodeToString(xxhex)
-			}
+		stub := &BucketEntry{Name: fi.relname}
+		stubs[stub] = fi
+		entries = append(entries, stub)
+	}
+	if allversions {
+		entries = append(entries, t.vslistbucket(bucket, msg)...)
+	}
+	reslist := pagebymarker(entries, msg.GetPageMarker, msg.GetPageSize)
+	for i, stub := range reslist.Entries {
+		fi, ok := stubs[stub]
+		if !ok {
+			continue // older version or delete marker
 		}
-		if strings.Contains(msg.GetProps, GetPropsAtime) {
-			if msg.GetTimeFormat == "" {
-				entry.Atime = fi.atime.Format(RFC822)
-			} else {
-				entry.Atime = fi.atime.Format(msg.GetTimeFormat)
+		fqn := t.fqn(bucket, fi.relname)
+		entry := t.newLocalEntry(msg, fi.relname, fqn, fi, fi.atime)
+		if strings.Contains(msg.GetProps, GetPropsVersion) {
+			if b, errstr := Getxattr(fqn, xattrObjVersion); errstr == "" {
+				entry.Version = string(b)
 			}
 		}
-		reslist.Entries = append(reslist.Entries, entry)
+		entry.IsLatest = allversions
+		reslist.Entries[i] = entry
 	}
 	jsbytes, err := json.Marshal(reslist)
 	assert(err == nil, err)
 	t.writeJSON(w, r, jsbytes, "listbucket")
 }
 
+// newLocalEntry fills in the local bucket listing entry as per GetMsg.GetProps
+func (t *targetrunner) newLocalEntry(msg *GetMsg, objname, fqn string, fi os.FileInfo, atime time.Time) *BucketEntry {
+	entry := &BucketEntry{Name: objname}
+	if strings.Contains(msg.GetProps, GetPropsSize) {
+		entry.Size = fi.Size()
+	}
+	if strings.Contains(msg.GetProps, GetPropsCtime) {
+		t := fi.ModTime()
+		switch msg.GetTimeFormat {
+		case "":
+			fallthrough
+		case RFC822:
+			entry.Ctime = t.Format(time.RFC822)
+		default:
+			entry.Ctime = t.Format(msg.GetTimeFormat)
+		}
+	}
+	if strings.Contains(msg.GetProps, GetPropsChecksum) {
+		xxhex, errstr := Getxattr(fqn, xattrXXHashVal)
+		if errstr == "" {
+			entry.Checksum = hex.EncodeToString(xxhex)
+		}
+	}
+	if strings.Contains(msg.GetProps, GetPropsAtime) {
+		if msg.GetTimeFormat == "" {
+			entry.Atime = atime.Format(RFC822)
+		} else {
+			entry.Atime = atime.Format(msg.GetTimeFormat)
+		}
+	}
+	if strings.Contains(msg.GetProps, GetPropsPinned) {
+		entry.Pinned = ispinned(fqn)
+	}
+	if strings.Contains(msg.GetProps, GetPropsUserMeta) {
+		entry.UserMeta = getusermeta(fqn)
+	}
+	return entry
+}
+
 func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket string) {
 	var (
 		jsbytes []byte
 		errstr  string
 		errcode int
+		started = time.Now()
 	)
 	islocal, errstr, errcode := t.checkLocalQueryParameter(bucket, r)
 	if errstr != "" {
@@ -572,6 +793,7 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 	}
 	if islocal {
 		t.doLocalBucketList(w, r, bucket, msg)
+		t.latency.add(latList, time.Since(started))
 		return
 	}
 	if useCache {
@@ -579,7 +801,7 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 		jsbytes, errstr, errcode = t.listCachedObjects(bucket, msg)
 	} else {
 		// do cloud request
-		if jsbytes, errstr, errcode = getcloudif().listbucket(bucket, msg); errstr == "" {
+		if jsbytes, errstr, errcode = t.tracecloud(spanof(r), bucket).listbucket(bucket, msg); errstr == "" {
 			t.statsif.add("numlist", 1)
 		}
 	}
@@ -592,6 +814,7 @@ func (t *targetrunner) listbucket(w http.ResponseWriter, r *http.Request, bucket
 		return
 	}
 	t.writeJSON(w, r, jsbytes, "listbucket")
+	t.latency.add(latList, time.Since(started))
 }
 
 func (all *allfinfos) listwalkf(fqn string, osfi os.FileInfo, err error) error {
@@ -615,21 +838,14 @@ func (ci *cachedInfos) processDir(fqn string) error {
 	}
 
 	relname := fqn[ci.rootLength:]
-	if ci.prefix != "" && !strings.HasPrefix(ci.prefix, relname) {
+	if ci.prefix != "" && !strings.HasPrefix(ci.prefix, relname) && !strings.HasPrefix(relname, ci.prefix) {
 		return filepath.SkipDir
 	}
 
-	if len(ci.markerDirs) != 0 {
-		dirs := strings.Split(fqn, "/")
-		maxIdx := len(dirs)
-		if len(ci.markerDirs) < maxIdx {
-			maxIdx = len(ci.markerDirs)
-		}
-		for idx := 0; idx < maxIdx; idx++ {
-			if dirs[idx] < ci.markerDirs[idx] {
-				return filepath.SkipDir
-			}
-		}
+	// all names in the directory precede the marker unless the marker is in the directory
+	dir := relname + "/"
+	if ci.marker != "" && dir < ci.marker && !strings.HasPrefix(ci.marker, dir) {
+		return filepath.SkipDir
 	}
 
 	return nil
@@ -641,7 +857,7 @@ func (ci *cachedInfos) processRegularFile(fqn string, osfi os.FileInfo) error {
 		return nil
 	}
 
-	if ci.marker != "" && fqn <= ci.marker {
+	if ci.marker != "" && relname <= ci.marker {
 		return nil
 	}
 
@@ -666,8 +882,13 @@ func (ci *cachedInfos) processRegularFile(fqn string, osfi os.FileInfo) error {
 			fileInfo.Atime = atime.Format(ci.msg.GetTimeFormat)
 		}
 	}
+	if strings.Contains(ci.msg.GetProps, GetPropsPinned) {
+		fileInfo.Pinned = ispinned(fqn)
+	}
+	if strings.Contains(ci.msg.GetProps, GetPropsUserMeta) {
+		fileInfo.UserMeta = getusermeta(fqn)
+	}
 	ci.files = append(ci.files, fileInfo)
-	ci.lastFilePath = fqn
 	return nil
 }
 
@@ -677,7 +898,7 @@ func (ci *cachedInfos) listwalkf(fqn string, osfi os.FileInfo, err error) error
 		return err
 	}
 
-	if ci.fileCount >= cachedPageSize {
+	if ci.fileCount >= ci.limit {
 		return filepath.SkipDir
 	}
 
@@ -694,7 +915,7 @@ func (t *targetrunner) httpfilput(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("Problem in put with URL " + r.URL.Path)
 		return
 	}
-	query := r.URL.Query()
+	query, started := r.URL.Query(), time.Now()
 
 	from, to, bucket, objname := query.Get(ParamFromID), query.Get(ParamToID), apitems[0], ""
 	if len(apitems) > 1 {
@@ -714,6 +935,13 @@ func (t *targetrunner) httpfilput(w http.ResponseWriter, r *http.Request) {
 		}
 		t.statsif.add("numrecvfiles", 1)
 		t.statsif.add("numrecvbytes", size)
+		t.latency.add(latRecv, time.Since(started))
+	} else if query.Get(ParamECSlice) == "true" {
+		// erasure coded slice: "/"+Rversion+"/"+Rfiles+"/"+bucket+"/"+objname+"?ecslice=true"
+		t.ecputslice(w, r, bucket, objname)
+	} else if uploadid := query.Get(ParamUploadID); uploadid != "" {
+		// multipart upload: "/"+Rversion+"/"+Rfiles+"/"+bucket+"/"+objname+"?uploadid="+uploadid+"&partnum="+partnum
+		t.mpputpart(w, r, bucket, objname, uploadid, query.Get(ParamPartNum))
 	} else {
 		// PUT: "/"+Rversion+"/"+Rfiles+"/"+bucket+"/"+objname
 		errstr, errcode := t.doput(w, r, bucket, objname)
@@ -725,6 +953,7 @@ func (t *targetrunner) httpfilput(w http.ResponseWriter, r *http.Request) {
 			}
 			return
 		}
+		t.latency.add(latPut, time.Since(started))
 	}
 }
 
@@ -737,18 +966,22 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 		htype, hval, nhtype, nhval string
 		sgl                        *SGLIO
 	)
-	cksumcfg := &ctx.config.CksumConfig
+	cksumcfg := t.lbmap.cksumconf(bucket)
 	fqn := t.fqn(bucket, objname)
 	putfqn := fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
 	if glog.V(3) {
 		glog.Infof("PUT: %s => %s", fqn, putfqn)
 	}
+	usermeta, errstr := usermetafromheader(r.Header)
+	if errstr != "" {
+		return
+	}
 	hdhobj = newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
 	if hdhobj != nil {
 		htype, hval = hdhobj.get()
 	}
-	// optimize out if the checksums do match
-	if hdhobj != nil && cksumcfg.Checksum != ChecksumNone {
+	// optimize out if the checksums do match (versioning: each PUT is a new version)
+	if hdhobj != nil && cksumcfg.Checksum != ChecksumNone && !t.versioning(bucket) && usermeta == nil {
 		file, err = os.Open(fqn)
 		// exists - compute checksum and compare with the caller's
 		if err == nil {
@@ -775,8 +1008,8 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 			}
 		}
 	}
-	inmem := (ctx.config.AckPolicy.Put == AckWhenInMem)
-	if sgl, nhobj, _, errstr = t.receive(putfqn, inmem, objname, "", hdhobj, r.Body); errstr != "" {
+	inmem := (t.lbmap.ackput(bucket) == AckWhenInMem)
+	if sgl, nhobj, _, errstr = t.receive(putfqn, inmem, bucket, objname, "", hdhobj, r.Body); errstr != "" {
 		return
 	}
 	if nhobj != nil {
@@ -790,14 +1023,23 @@ func (t *targetrunner) doput(w http.ResponseWriter, r *http.Request, bucket, obj
 	}
 	// commit
 	if sgl == nil {
-		return t.putCommit(bucket, objname, putfqn, fqn, nhobj, false)
+		if errstr = setusermeta(putfqn, usermeta); errstr != "" {
+			os.Remove(putfqn)
+			return
+		}
+		if errstr, errcode = t.putCommit(spanof(r), bucket, objname, putfqn, fqn, nhobj, false); errstr == "" && t.versioning(bucket) {
+			if version, errs := Getxattr(fqn, xattrObjVersion); errs == "" {
+				w.Header().Set(HeaderDfcObjVersion, string(version))
+			}
+		}
+		return
 	}
 	// FIXME: AA: use xaction
-	go t.sglToCloudAsync(sgl, bucket, objname, putfqn, fqn, nhobj)
+	go t.sglToCloudAsync(sgl, bucket, objname, putfqn, fqn, nhobj, usermeta)
 	return
 }
 
-func (t *targetrunner) sglToCloudAsync(sgl *SGLIO, bucket, objname, putfqn, fqn string, nhobj cksumvalue) {
+func (t *targetrunner) sglToCloudAsync(sgl *SGLIO, bucket, objname, putfqn, fqn string, nhobj cksumvalue, usermeta map[string]string) {
 	slab := selectslab(sgl.Size())
 	buf := slab.alloc()
 	defer func() {
@@ -831,7 +1073,12 @@ func (t *targetrunner) sglToCloudAsync(sgl *SGLIO, bucket, objname, putfqn, fqn
 		}
 		return
 	}
-	errstr, _ := t.putCommit(bucket, objname, putfqn, fqn, nhobj, false)
+	if errstr := setusermeta(putfqn, usermeta); errstr != "" {
+		glog.Errorln("sglToCloudAsync:", errstr)
+		os.Remove(putfqn)
+		return
+	}
+	errstr, _ := t.putCommit(nil, bucket, objname, putfqn, fqn, nhobj, false)
 	if errstr != "" {
 		glog.Errorln("sglToCloudAsync: commit", errstr)
 		return
@@ -839,7 +1086,7 @@ func (t *targetrunner) sglToCloudAsync(sgl *SGLIO, bucket, objname, putfqn, fqn
 	glog.Infof("sglToCloudAsync: %s/%s done", bucket, objname)
 }
 
-func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string, nhobj cksumvalue, rebalance bool) (errstr string, errcode int) {
+func (t *targetrunner) putCommit(sp *span, bucket, objname, putfqn, fqn string, nhobj cksumvalue, rebalance bool) (errstr string, errcode int) {
 	var (
 		file *os.File
 		err  error
@@ -857,10 +1104,12 @@ func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string, nhobj cksu
 			errstr = fmt.Sprintf("Failed to reopen %s err: %v", putfqn, err)
 			return
 		}
-		if errstr, errcode = getcloudif().putobj(file, bucket, objname, nhobj); errstr != "" {
+		cloudstarted := time.Now()
+		if errstr, errcode = t.tracecloud(sp, bucket).putobj(file, bucket, objname, nhobj, getusermeta(putfqn)); errstr != "" {
 			_ = file.Close()
 			return
 		}
+		t.latency.add(latCloudPut, time.Since(cloudstarted))
 		if err = file.Close(); err != nil {
 			glog.Errorf("Unexpected failure to close an already PUT file %s, err: %v", putfqn, err)
 			_ = os.Remove(putfqn)
@@ -868,7 +1117,12 @@ func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string, nhobj cksu
 		}
 	}
 	// when all set and done:
-	if errstr = t.putSafeRename(bucket, objname, putfqn, fqn); errstr != "" {
+	if t.versioning(bucket) {
+		errstr = t.vsputcommit(bucket, objname, putfqn, fqn, rebalance)
+	} else {
+		errstr = t.putSafeRename(bucket, objname, putfqn, fqn)
+	}
+	if errstr != "" {
 		return
 	}
 	// FIXME: PUT must be returning the version - use it here to "finalize"
@@ -876,6 +1130,12 @@ func (t *targetrunner) putCommit(bucket, objname, putfqn, fqn string, nhobj cksu
 		return
 	}
 	t.statsif.add("numput", 1)
+	if !rebalance {
+		go t.replicate(bucket, objname)
+		if t.ecenabled(bucket) {
+			go t.ecupdate(bucket, objname)
+		}
+	}
 	return
 }
 
@@ -921,7 +1181,7 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 			return
 		}
 		size = finfo.Size()
-		if errstr = t.sendfile(r.Method, bucket, objname, si, size, ""); errstr != "" {
+		if errstr = t.sendfile(spanof(r), r.Method, bucket, objname, si, size, "", ""); errstr != "" {
 			return
 		}
 		if glog.V(3) {
@@ -934,6 +1194,9 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 		if glog.V(3) {
 			glog.Infof("Rebalance to %q: bucket %q objname %q <= from %q", to, bucket, objname, from)
 		}
+		if version := r.URL.Query().Get(ParamVersion); version != "" {
+			return t.vsreceive(r, bucket, objname, version)
+		}
 		putfqn := fmt.Sprintf("%s.%d", fqn, time.Now().UnixNano())
 		_, err := os.Stat(fqn)
 		if err != nil && os.IsExist(err) {
@@ -945,7 +1208,7 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 			nhobj  cksumvalue
 			inmem  = false // TODO
 		)
-		if _, nhobj, size, errstr = t.receive(putfqn, inmem, objname, "", hdhobj, r.Body); errstr != "" {
+		if _, nhobj, size, errstr = t.receive(putfqn, inmem, bucket, objname, "", hdhobj, r.Body); errstr != "" {
 			return
 		}
 		if nhobj != nil {
@@ -958,7 +1221,22 @@ func (t *targetrunner) dorebalance(r *http.Request, from, to, bucket, objname st
 				return
 			}
 		}
-		errstr, _ = t.putCommit(bucket, objname, putfqn, fqn, nhobj, true)
+		usermeta, _ := usermetafromheader(r.Header)
+		if errstr = setusermeta(putfqn, usermeta); errstr != "" {
+			os.Remove(putfqn)
+			return
+		}
+		// a copy from another bucket is committed as a regular PUT (Cloud upload included), and not pinned
+		iscopy := r.URL.Query().Get(ParamCopy) == "true"
+		if version := r.Header.Get(HeaderDfcObjVersion); version != "" && !iscopy {
+			if errstr = Setxattr(putfqn, xattrObjVersion, []byte(version)); errstr != "" {
+				glog.Errorln(errstr)
+				errstr = ""
+			}
+		}
+		if errstr, _ = t.putCommit(spanof(r), bucket, objname, putfqn, fqn, nhobj, !iscopy); errstr == "" && !iscopy {
+			pinfromheader(fqn, r.Header.Get(HeaderDfcPinned))
+		}
 	}
 	return
 }
@@ -968,6 +1246,7 @@ func (t *targetrunner) httpfildelete(w http.ResponseWriter, r *http.Request) {
 		bucket, objname string
 		msg             ActionMsg
 		evict           bool
+		started         = time.Now()
 	)
 	apitems := t.restAPIItems(r.URL.Path, 5)
 	if apitems = t.checkRestAPI(w, r, apitems, 1, Rversion, Rfiles); apitems == nil {
@@ -995,23 +1274,45 @@ func (t *targetrunner) httpfildelete(w http.ResponseWriter, r *http.Request) {
 		t.invalmsghdlr(w, r, s)
 		return
 	}
+	if objname != "" && r.URL.Query().Get(ParamReplica) == "true" {
+		t.delreplica(w, r, bucket, objname)
+		return
+	}
+	if objname != "" && r.URL.Query().Get(ParamECSlice) == "true" {
+		t.ecdelslice(w, r, bucket, objname)
+		return
+	}
 	if objname == "" && len(b) > 0 {
 		// It must be a List/Range request, since there is no object name
 		t.deletefiles(w, r, msg)
 		return
+	} else if objname != "" && msg.Action == ActMPAbort {
+		t.mpabort(w, r, bucket, objname, msg.Name)
+		return
+	} else if version := r.URL.Query().Get(ParamVersion); objname != "" && version != "" && t.islocalBucket(bucket) {
+		if errstr, errcode := t.vsdelversion(bucket, objname, version); errstr != "" {
+			if errcode == 0 {
+				t.invalmsghdlr(w, r, errstr)
+			} else {
+				t.invalmsghdlr(w, r, errstr, errcode)
+			}
+		}
+		return
 	} else if objname != "" {
-		err := t.fildelete(bucket, objname, evict)
+		err := t.fildelete(spanof(r), bucket, objname, evict)
 		if err != nil {
 			s := fmt.Sprintf("Error deleting %s/%s: %v", bucket, objname, err)
 			t.invalmsghdlr(w, r, s)
+			return
 		}
+		t.latency.add(latDelete, time.Since(started))
 		return
 	}
 	s := fmt.Sprintf("Invalid API request: No object name or message body.")
 	t.invalmsghdlr(w, r, s)
 }
 
-func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
+func (t *targetrunner) fildelete(sp *span, bucket, objname string, evict bool) error {
 	var (
 		errstr  string
 		errcode int
@@ -1019,12 +1320,14 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 	fqn := t.fqn(bucket, objname)
 	uname := bucket + objname
 	localbucket := t.islocalBucket(bucket)
+	versioning := localbucket && !evict && t.versioning(bucket)
+	marker := newvsid(time.Now())
 
 	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
 	defer t.rtnamemap.unlockname(uname, true)
 
 	if !localbucket && !evict {
-		errstr, errcode = getcloudif().deleteobj(bucket, objname)
+		errstr, errcode = t.tracecloud(sp, bucket).deleteobj(bucket, objname)
 		t.statsif.add("numdelete", 1)
 		if errstr != "" {
 			if errcode == 0 {
@@ -1033,6 +1336,15 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 			return fmt.Errorf("%d: %s", errcode, errstr)
 		}
 	}
+	if versioning {
+		// the replica holders keep the object as an older version, too
+		t.delreplicas(bucket, objname, marker+vsMarkerSuffix)
+	} else if !(evict && localbucket) {
+		t.delreplicas(bucket, objname, "")
+	}
+	if t.ecenabled(bucket) && !evict {
+		t.ecdelslices(bucket, objname)
+	}
 
 	finfo, err := os.Stat(fqn)
 	if err != nil {
@@ -1047,7 +1359,12 @@ func (t *targetrunner) fildelete(bucket, objname string, evict bool) error {
 
 		}
 	}
-	if !(evict && localbucket) {
+	if versioning {
+		// keep the object as an older version and add a delete marker
+		if errstr = vsdelete(bucket, objname, fqn, marker); errstr != "" {
+			return fmt.Errorf("%s", errstr)
+		}
+	} else if !(evict && localbucket) {
 		// Don't evict from a local bucket (this would be deletion)
 		if err := os.Remove(fqn); err != nil {
 			return err
@@ -1068,8 +1385,18 @@ func (t *targetrunner) httpfilpost(w http.ResponseWriter, r *http.Request) {
 	switch msg.Action {
 	case ActPrefetch:
 		t.prefetchfiles(w, r, msg)
+	case ActPin, ActUnpin:
+		t.pinfiles(w, r, msg)
+	case ActCopy, ActMove:
+		t.copyfiles(w, r, msg)
 	case ActRename:
 		t.renamefile(w, r, msg)
+	case ActMPInit:
+		t.mpinit(w, r)
+	case ActMPComplete:
+		t.mpcomplete(w, r, msg.Name)
+	case ActECRepair:
+		t.ecrepair(w, r)
 	default:
 		t.invalmsghdlr(w, r, "Unexpected action "+msg.Action)
 	}
@@ -1097,13 +1424,28 @@ func (t *targetrunner) renamefile(w http.ResponseWriter, r *http.Request, msg Ac
 		t.invalmsghdlr(w, r, errstr)
 		return
 	}
-	si, errstr := hrwTarget(bucket+"/"+newobjname, t.smap)
+	// the new name's HRW target (and replica holders, if any)
+	sis, errstr := t.replicas(bucket, newobjname)
 	if errstr != "" {
 		t.invalmsghdlr(w, r, errstr)
 		return
 	}
+	var islocal bool
+	for _, si := range sis {
+		if si.DaemonID == t.si.DaemonID {
+			islocal = true
+			continue
+		}
+		// move/migrate
+		glog.Infof("Migrating [%s %s => %s] %s => %s", bucket, objname, newobjname, t.si.DaemonID, si.DaemonID)
+
+		if errstr = t.sendfile(spanof(r), http.MethodPut, bucket, objname, si, finfo.Size(), "", newobjname); errstr != "" {
+			t.invalmsghdlr(w, r, errstr)
+			return
+		}
+	}
 	// local rename
-	if si.DaemonID == t.si.DaemonID {
+	if islocal {
 		newfqn := t.fqn(bucket, newobjname)
 		dirname := filepath.Dir(newfqn)
 		if err := CreateDir(dirname); err != nil {
@@ -1118,13 +1460,12 @@ func (t *targetrunner) renamefile(w http.ResponseWriter, r *http.Request, msg Ac
 				glog.Infof("Renamed %s => %s", fqn, newfqn)
 			}
 		}
-		return
 	}
-	// move/migrate
-	glog.Infof("Migrating [%s %s => %s] %s => %s", bucket, objname, newobjname, t.si.DaemonID, si.DaemonID)
-
-	if errstr = t.sendfile(http.MethodPut, bucket, objname, si, finfo.Size(), newobjname); errstr != "" {
-		t.invalmsghdlr(w, r, errstr)
+	if errstr == "" {
+		t.delreplicas(bucket, objname, "")
+		if t.ecenabled(bucket) {
+			t.ecrename(bucket, objname, newobjname, sis[0])
+		}
 	}
 }
 
@@ -1179,22 +1520,36 @@ func (t *targetrunner) deletefiles(w http.ResponseWriter, r *http.Request, msg A
 	}
 }
 
-func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonInfo, size int64, newobjname string) string {
+// sendfile sends the object to another target; the object can be sent under a new name
+// and/or to another bucket - the latter is a copy that the destination commits as a regular PUT
+func (t *targetrunner) sendfile(parent *span, method, bucket, objname string, destsi *daemonInfo, size int64,
+	newbucket, newobjname string) (errstr string) {
 	var (
 		xxhashval string
-		errstr    string
+		started   = time.Now()
 	)
 	if size == 0 {
 		return fmt.Sprintf("Unexpected: %s/%s size is zero", bucket, objname)
 	}
-	cksumcfg := &ctx.config.CksumConfig
+	cksumcfg := t.lbmap.cksumconf(bucket)
 	if newobjname == "" {
 		newobjname = objname
 	}
+	if newbucket == "" {
+		newbucket = bucket
+	}
 	fromid, toid := t.si.DaemonID, destsi.DaemonID // source=self and destination
+	sp := parent.child("sendfile", spanClient)
+	sp.object(bucket, objname)
+	sp.setstr("dfc.to_id", toid)
+	sp.setint("dfc.size", size)
+	defer func() { sp.end(errstr) }()
 	url := destsi.DirectURL + "/" + Rversion + "/" + Rfiles + "/"
-	url += bucket + "/" + newobjname
+	url += newbucket + "/" + newobjname
 	url += fmt.Sprintf("?%s=%s&%s=%s", ParamFromID, fromid, ParamToID, toid)
+	if newbucket != bucket {
+		url += fmt.Sprintf("&%s=true", ParamCopy)
+	}
 
 	fqn := t.fqn(bucket, objname)
 	file, err := os.Open(fqn)
@@ -1225,10 +1580,18 @@ func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonIn
 	if err != nil {
 		return fmt.Sprintf("Unexpected failure to create %s request %s, err: %v", method, url, err)
 	}
+	sp.inject(request.Header)
 	if xxhashval != "" {
 		request.Header.Set(HeaderDfcChecksumType, ChecksumXXHash)
 		request.Header.Set(HeaderDfcChecksumVal, xxhashval)
 	}
+	if version, errstr := Getxattr(fqn, xattrObjVersion); errstr == "" && len(version) > 0 && newbucket == bucket {
+		request.Header.Set(HeaderDfcObjVersion, string(version))
+	}
+	setusermetaheader(request.Header, getusermeta(fqn))
+	if pin := pinheader(fqn); pin != "" {
+		request.Header.Set(HeaderDfcPinned, pin)
+	}
 	response, err := t.httpclient.Do(request)
 	if err != nil {
 		return fmt.Sprintf("Failed to send %q from %s, err: %v", fqn, t.si.DaemonID, err)
@@ -1247,9 +1610,13 @@ func (t *targetrunner) sendfile(method, bucket, objname string, destsi *daemonIn
 			}
 			return s
 		}
+		if response.StatusCode >= http.StatusBadRequest {
+			return fmt.Sprintf("Failed to send %q from %s to %s: %s", fqn, t.si.DaemonID, toid, response.Status)
+		}
 	}
 	t.statsif.add("numsentfiles", 1)
 	t.statsif.add("numsentbytes", size)
+	t.latency.add(latSend, time.Since(started))
 	return ""
 }
 
@@ -1272,15 +1639,27 @@ func (t *targetrunner) httpfilhead(w http.ResponseWriter, r *http.Request) {
 		t.invalmsghdlr(w, r, errstr)
 		return
 	}
+	if len(apitems) > 1 && r.URL.Query().Get(ParamReplica) == "true" {
+		t.headreplica(w, r, bucket, strings.Join(apitems[1:], "/"))
+		return
+	}
+	if len(apitems) > 1 && r.URL.Query().Get(ParamECSlice) == "true" {
+		t.ecgetslice(w, r, bucket, strings.Join(apitems[1:], "/"))
+		return
+	}
 
 	islocal, errstr, errcode = t.checkLocalQueryParameter(bucket, r)
 	if errstr != "" {
 		t.invalmsghdlr(w, r, errstr, errcode)
 		return
 	}
+	if len(apitems) > 1 {
+		t.headobject(w, r, bucket, strings.Join(apitems[1:], "/"), islocal)
+		return
+	}
 
 	if !islocal {
-		bucketprops, errstr, errcode = getcloudif().headbucket(bucket)
+		bucketprops, errstr, errcode = t.tracecloud(spanof(r), bucket).headbucket(bucket)
 		if errstr != "" {
 			t.invalmsghdlr(w, r, errstr, http.StatusInternalServerError)
 			if errcode == 0 {
@@ -1294,12 +1673,61 @@ func (t *targetrunner) httpfilhead(w http.ResponseWriter, r *http.Request) {
 		bucketprops = make(map[string]string)
 		bucketprops[HeaderServer] = dfclocal
 	}
+	jsbytes, err := json.Marshal(t.lbmap.effprops(bucket))
+	assert(err == nil, err)
+	bucketprops[HeaderDfcBucketProps] = string(jsbytes)
 
 	for k, v := range bucketprops {
 		w.Header().Add(k, v)
 	}
 }
 
+// headobject returns the object's size, checksum, version, and user metadata; Cloud objects
+// that are not cached are HEAD-ed in the Cloud
+func (t *targetrunner) headobject(w http.ResponseWriter, r *http.Request, bucket, objname string, islocal bool) {
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, false, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, false)
+
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Failed to fstat %s, err: %v", fqn, err), http.StatusInternalServerError)
+			return
+		}
+		if islocal {
+			t.invalmsghdlr(w, r, fmt.Sprintf("HEAD local: object %s/%s does not exist", bucket, objname), http.StatusNotFound)
+			return
+		}
+		objmeta, errstr, errcode := t.tracecloud(spanof(r), bucket).headobject(bucket, objname)
+		if errstr != "" {
+			if errcode == 0 {
+				t.invalmsghdlr(w, r, errstr)
+			} else {
+				t.invalmsghdlr(w, r, errstr, errcode)
+			}
+			return
+		}
+		for k, v := range objmeta {
+			if k == "version" {
+				w.Header().Set(HeaderDfcObjVersion, v)
+			} else {
+				w.Header().Set(k, v)
+			}
+		}
+		return
+	}
+	w.Header().Set("Content-Length", strconv.FormatInt(finfo.Size(), 10))
+	if hval, errstr := Getxattr(fqn, xattrXXHashVal); errstr == "" && len(hval) > 0 {
+		w.Header().Set(HeaderDfcChecksumType, ChecksumXXHash)
+		w.Header().Set(HeaderDfcChecksumVal, string(hval))
+	}
+	if version, errstr := Getxattr(fqn, xattrObjVersion); errstr == "" && len(version) > 0 {
+		w.Header().Set(HeaderDfcObjVersion, string(version))
+	}
+	setusermetaheader(w.Header(), getusermeta(fqn))
+}
+
 func (t *targetrunner) checkCacheQueryParameter(r *http.Request) (useCache bool, errstr string, errcode int) {
 	useCacheStr := r.URL.Query().Get(ParamCached)
 	if useCacheStr != "" && useCacheStr != "true" && useCacheStr != "false" {
@@ -1398,6 +1826,21 @@ func (t *targetrunner) httpdaeput(w http.ResponseWriter, r *http.Request) {
 				lruxact.abort()
 			}
 		}
+	case ActLRU:
+		go t.runLRU()
+	case ActRebalance:
+		go t.runRebalance()
+	case ActRebAbort:
+		t.abortRebalance()
+	case ActXactAbort:
+		t.abortxaction(w, r, &msg)
+	case ActRebThrottle:
+		if mbps, ok := msg.Value.(float64); !ok || mbps < 0 {
+			t.invalmsghdlr(w, r, fmt.Sprintf("Invalid %s value %v (expecting MB/s)", msg.Action, msg.Value))
+		} else {
+			atomic.StoreInt64(&t.rebthrottle, int64(mbps*1024*1024))
+			glog.Infof("Rebalance throttle: %v MB/s", mbps)
+		}
 	case ActShutdown:
 		_ = syscall.Kill(syscall.Getpid(), syscall.SIGINT)
 	default:
@@ -1412,6 +1855,7 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 	if t.readJSON(w, r, &newsmap) != nil {
 		return
 	}
+	defer t.metaack(w, r)
 	if curversion == newsmap.Version {
 		return
 	}
@@ -1424,7 +1868,8 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 	glog.Infof("%s: new Smap version %d (old %d)", apitems[0], newsmap.Version, curversion)
 
 	// check whether this target is present in the new Smap
-	// rebalance? (nothing to rebalance if the new map is a strict subset of the old)
+	// rebalance? (nothing to rebalance if the new map is a strict subset of the old,
+	// unless some of the buckets are replicated)
 	// assign proxysi
 	// log
 	existentialQ, isSubset := false, true
@@ -1440,13 +1885,21 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 		}
 	}
 	assert(existentialQ)
+	sameTargets := isSubset && len(newsmap.Smap) == len(t.smap.Smap)
 
 	t.smap, t.proxysi = newsmap, newsmap.ProxySI
 	if apitems[0] == Rsyncsmap {
 		return
 	}
-	if isSubset {
+	if sameTargets {
+		glog.Infoln("nothing to rebalance: same targets (proxy change)")
+		return
+	}
+	if isSubset && !t.lbmap.replicated() {
 		glog.Infoln("nothing to rebalance: new Smap is a strict subset of the old")
+		if t.lbmap.erasurecoded() {
+			go t.runECRepair()
+		}
 		return
 	}
 	// xaction
@@ -1455,10 +1908,11 @@ func (t *targetrunner) httpdaeputSmap(w http.ResponseWriter, r *http.Request, ap
 
 func (t *targetrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, apitems []string) {
 	curversion := t.lbmap.Version
-	newlbmap := &lbmap{LBmap: make(map[string]string)}
+	newlbmap := newlbmap()
 	if t.readJSON(w, r, newlbmap) != nil {
 		return
 	}
+	defer t.metaack(w, r)
 	if curversion == newlbmap.Version {
 		return
 	}
@@ -1477,10 +1931,39 @@ func (t *targetrunner) httpdaeputLBMap(w http.ResponseWriter, r *http.Request, a
 				if err := os.RemoveAll(localbucketfqn); err != nil {
 					glog.Errorf("Failed to destroy local bucket dir %q, err: %v", localbucketfqn, err)
 				}
+				slicesfqn := filepath.Join(mpath, ecDir, bucket)
+				if err := os.RemoveAll(slicesfqn); err != nil {
+					glog.Errorf("Failed to destroy erasure coded slices dir %q, err: %v", slicesfqn, err)
+				}
+				versionsfqn := filepath.Join(mpath, vsDir, bucket)
+				if err := os.RemoveAll(versionsfqn); err != nil {
+					glog.Errorf("Failed to destroy versions dir %q, err: %v", versionsfqn, err)
+				}
 			}
 		}
 	}
+	// more replicas than before? restore the replica count via rebalance
+	// erasure coding enabled or changed? (re)encode via ecrepair
+	var rebalance, ecrepair bool
+	for bucket := range newlbmap.Props {
+		if newlbmap.copies(bucket) > t.lbmap.copies(bucket) {
+			rebalance = true
+		}
+	}
+	for bucket := range newlbmap.LBmap {
+		newdata, newparity := newlbmap.ecslices(bucket)
+		data, parity := t.lbmap.ecslices(bucket)
+		if newdata != data || newparity != parity {
+			ecrepair = true
+		}
+	}
 	t.lbmap = newlbmap
+	if rebalance {
+		go t.runRebalance()
+	}
+	if ecrepair {
+		go t.runECRepair()
+	}
 	for mpath := range ctx.mountpaths {
 		for bucket := range t.lbmap.LBmap {
 			localbucketfqn := mpath + "/" + ctx.config.LocalBuckets + "/" + bucket
@@ -1497,7 +1980,7 @@ func (t *targetrunner) httpdaeget(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	var msg GetMsg
-	if t.readJSON(w, r, &msg) != nil {
+	if t.readGetMsg(w, r, &msg) != nil {
 		return
 	}
 	var (
@@ -1506,17 +1989,26 @@ func (t *targetrunner) httpdaeget(w http.ResponseWriter, r *http.Request) {
 	)
 	switch msg.GetWhat {
 	case GetWhatConfig:
-		jsbytes, err = json.Marshal(ctx.config)
+		jsbytes, err = json.Marshal(redactedconfig())
 		assert(err == nil, err)
 	case GetWhatSmap:
 		jsbytes, err = json.Marshal(t.si)
 		assert(err == nil, err)
+	case GetWhatMeta:
+		jsbytes, err = json.Marshal(&clustermeta{Smap: t.smap, LBmap: t.lbmap})
+		assert(err == nil, err)
 	case GetWhatStats:
 		rr := getstorstatsrunner()
 		rr.Lock()
 		jsbytes, err = json.Marshal(rr)
 		rr.Unlock()
 		assert(err == nil, err)
+	case GetWhatRebalance:
+		jsbytes, err = json.Marshal(t.rebalancestats())
+		assert(err == nil, err)
+	case GetWhatXactions:
+		jsbytes, err = json.Marshal(t.xactinp.infos())
+		assert(err == nil, err)
 	default:
 		s := fmt.Sprintf("Unexpected GetMsg <- JSON [%v]", msg)
 		t.invalmsghdlr(w, r, s)
@@ -1648,14 +2140,14 @@ func (t *targetrunner) mpath2Fsid() (fsmap map[syscall.Fsid]string) {
 // empty omd5 or oxxhash: not considered an exception even when the configuration says otherwise;
 // xxhash is always preferred over md5
 //=====
-func (t *targetrunner) receive(fqn string, inmem bool, objname, omd5 string, ohobj cksumvalue,
+func (t *targetrunner) receive(fqn string, inmem bool, bucket, objname, omd5 string, ohobj cksumvalue,
 	reader io.Reader) (sgl *SGLIO, nhobj cksumvalue, written int64, errstr string) {
 	var (
 		err                  error
 		file                 *os.File
 		filewriter           io.Writer
 		ohtype, ohval, nhval string
-		cksumcfg             = &ctx.config.CksumConfig
+		cksumcfg             = t.lbmap.cksumconf(bucket)
 	)
 	// ack policy = memory
 	if inmem {
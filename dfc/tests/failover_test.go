@@ -0,0 +1,125 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+//
+// Example run (requires at least one standby proxy; NOTE: shuts down the primary proxy):
+// 	go test -v -run=failover -args -failover
+//
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"math/rand"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/NVIDIA/dfcpub/dfc"
+	"github.com/NVIDIA/dfcpub/pkg/client"
+	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+)
+
+const (
+	FailoverBucketName = "failoverbucket"
+	failoverTimeout    = time.Minute * 3
+)
+
+var failover bool
+
+func init() {
+	flag.BoolVar(&failover, "failover", false, "shut down the primary proxy and wait for the standbys to elect the new one")
+}
+
+func Test_failover(t *testing.T) {
+	const (
+		numPuts = 10
+		size    = int64(1024 * 16)
+	)
+	parse()
+	if !failover {
+		t.Skip("Shuts down the primary proxy - run with -failover")
+	}
+	smap, err := client.GetClusterMap(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get the cluster map: %v", err)
+	}
+	if len(smap.Pmap) < 2 {
+		t.Skipf("Failover requires at least one standby proxy, have %d proxies", len(smap.Pmap))
+	}
+	var standbyurl string
+	for sid, si := range smap.Pmap {
+		if sid != smap.ProxySI.DaemonID {
+			standbyurl = si.DirectURL
+			break
+		}
+	}
+	createLocalBucket(httpclient, t, FailoverBucketName)
+	waitMetasync(t)
+	random := rand.New(rand.NewSource(time.Now().UnixNano()))
+	objnames := make([]string, numPuts)
+	for i := range objnames {
+		objnames[i] = client.FastRandomFilename(random, 20)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, FailoverBucketName, objnames[i], true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", FailoverBucketName, objnames[i], err)
+		}
+	}
+
+	// shut down the primary
+	injson, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActShutdown})
+	if err != nil {
+		t.Fatalf("Failed to marshal ActionMsg: %v", err)
+	}
+	req, err := http.NewRequest("PUT", smap.ProxySI.DirectURL+"/v1/daemon", bytes.NewBuffer(injson))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	if r, err := httpclient.Do(req); err == nil {
+		r.Body.Close()
+	}
+	tlogf("Shut down the primary proxy %s, waiting for the new one...\n", smap.ProxySI.DaemonID)
+
+	var newurl string
+	for deadline := time.Now().Add(failoverTimeout); time.Now().Before(deadline) && newurl == ""; {
+		time.Sleep(time.Second * 5)
+		if url, err := client.GetPrimaryProxy(standbyurl); err == nil && url != smap.ProxySI.DirectURL {
+			newurl = url
+		}
+	}
+	if newurl == "" {
+		t.Fatalf("Standby proxies failed to elect the new primary in %v", failoverTimeout)
+	}
+	tlogf("New primary proxy: %s\n", newurl)
+	proxyurl = newurl
+
+	// the new primary must have all the targets, and the data must remain accessible
+	newsmap, err := client.GetClusterMap(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get the cluster map from the new primary: %v", err)
+	}
+	if len(newsmap.Smap) != len(smap.Smap) {
+		t.Errorf("Expected %d targets, the new primary has %d", len(smap.Smap), len(newsmap.Smap))
+	}
+	if _, ok := newsmap.Pmap[smap.ProxySI.DaemonID]; ok {
+		t.Errorf("The failed primary %s is still in the cluster map", smap.ProxySI.DaemonID)
+	}
+	for _, objname := range objnames {
+		// via the standby, if any (redirects to the primary)
+		if _, err := client.Get(standbyurl, FailoverBucketName, objname, nil, nil, true, false); err != nil {
+			t.Errorf("Failed to get %s/%s via %s: %v", FailoverBucketName, objname, standbyurl, err)
+		}
+	}
+	for _, objname := range objnames {
+		if err := client.Del(proxyurl, FailoverBucketName, objname, nil, nil, true); err != nil {
+			t.Errorf("Failed to delete %s/%s: %v", FailoverBucketName, objname, err)
+		}
+	}
+	destroyLocalBucket(httpclient, t, FailoverBucketName)
+}
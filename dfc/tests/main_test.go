@@ -58,6 +58,10 @@ var (
 	totalio     int64
 	proxyurl    string
 	props       string
+	authsecret  string
+	cacert      string
+
+	basetransport http.RoundTripper = http.DefaultTransport // verifies the cluster CA, if given; set by parse()
 )
 
 // worker's result
@@ -91,6 +95,8 @@ func init() {
 	flag.StringVar(&clichecksum, "checksum", "all", "all | xxhash | coldmd5")
 	flag.Int64Var(&totalio, "totalio", 80, "Total IO Size in MB")
 	flag.StringVar(&props, "props", "", "List of object properties to return. Empty value means default set of properties")
+	flag.StringVar(&authsecret, "authsecret", os.Getenv("DFCAUTHSECRET"), "Cluster's auth secret: the tests then use admin tokens")
+	flag.StringVar(&cacert, "cacert", os.Getenv("DFCCACERT"), "Cluster CA (PEM) to verify the HTTPS cluster, e.g. with -proxyurl=https://localhost:8080")
 }
 
 func checkMemory() {
@@ -107,6 +113,25 @@ func parse() {
 	usingSG = readerType == readers.ReaderTypeSG
 	usingFile = readerType == readers.ReaderTypeFile
 	checkMemory()
+	if cacert != "" {
+		tlsconf, err := client.TLSConfig(cacert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to load cluster CA %s: %v\n", cacert, err)
+			os.Exit(1)
+		}
+		client.SetTLSConfig(tlsconf)
+		basetransport = &http.Transport{TLSClientConfig: tlsconf}
+	}
+	if authsecret != "" {
+		token, err := dfc.SignToken(authsecret, &dfc.AuthToken{User: "test", Expires: time.Now().Add(24 * time.Hour).Unix(), Admin: true})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to sign auth token: %v\n", err)
+			os.Exit(1)
+		}
+		client.SetAuthToken(token)
+	}
+	http.DefaultClient.Transport = client.AuthTransport(basetransport)
+	httpclient.Transport = client.AuthTransport(basetransport)
 }
 
 func Test_download(t *testing.T) {
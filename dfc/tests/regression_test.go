@@ -10,11 +10,14 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"net/http"
 	_ "net/http/pprof" // profile
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -24,6 +27,7 @@ import (
 	"github.com/NVIDIA/dfcpub/dfc"
 	"github.com/NVIDIA/dfcpub/pkg/client"
 	"github.com/NVIDIA/dfcpub/pkg/client/readers"
+	"github.com/OneOfOne/xxhash"
 )
 
 type Test struct {
@@ -39,6 +43,39 @@ const (
 	RenameStr             = "rename"
 	ListRangeDir          = "/tmp/dfc/listrange"
 	ListRangeStr          = "__listrange"
+	RangeReadBucketName   = "rangereadbucket"
+	RangeReadStr          = "__rangeread"
+	MultipartBucketName   = "multipartbucket"
+	MultipartStr          = "__multipart"
+	ReplicaBucketName     = "replicabucket"
+	ReplicaStr            = "__replica"
+	ECBucketName          = "ecbucket"
+	ECStr                 = "__ec"
+	MetasyncBucketName    = "metasyncbucket"
+	RebalanceBucketName   = "rebalancebucket"
+	RebalanceStr          = "__rebalance"
+	QuotaBucketName       = "quotabucket"
+	QuotaStr              = "__quota"
+	PinBucketName         = "pinbucket"
+	PinStr                = "__pin"
+	XactBucketName        = "xactbucket"
+	XactStr               = "__xact"
+	JobBucketName         = "jobbucket"
+	JobStr                = "__job"
+	CopyBucketName        = "copybucket"
+	CopyStr               = "__copy"
+	PropsBucketName       = "propsbucket"
+	PropsStr              = "__props"
+	VersionBucketName     = "versionbucket"
+	VersionStr            = "__version"
+	UserMetaBucketName    = "usermetabucket"
+	UserMetaStr           = "__usermeta"
+	ListPagesBucketName   = "listpagesbucket"
+	ListPagesStr          = "__listpages"
+	LatencyBucketName     = "latencybucket"
+	TraceStr              = "__trace"
+	AuthBucketName        = "authbucket"
+	AuthStr               = "__auth"
 )
 
 var (
@@ -67,12 +104,36 @@ var (
 		Test{"Config", regressionConfig},
 		Test{"Rebalance", regressionRebalance},
 		Test{"LRU", regressionLRU},
+		Test{"LRUPolicy", regressionLRUPolicy},
+		Test{"BucketQuota", regressionBucketQuota},
+		Test{"Pin", regressionPin},
 		Test{"Rename", regressionRename},
 		Test{"RW stress", regressionRWStress},
 		Test{"PrefetchList", regressionPrefetchList},
 		Test{"PrefetchRange", regressionPrefetchRange},
 		Test{"DeleteList", regressionDeleteList},
 		Test{"DeleteRange", regressionDeleteRange},
+		Test{"RangeRead", regressionRangeRead},
+		Test{"ColdRangeRead", regressionColdRangeRead},
+		Test{"Multipart", regressionMultipart},
+		Test{"Replication", regressionReplication},
+		Test{"ErasureCoding", regressionErasureCoding},
+		Test{"Metasync", regressionMetasync},
+		Test{"RebalanceControl", regressionRebalanceControl},
+		Test{"Xactions", regressionXactions},
+		Test{"ListRangeJob", regressionListRangeJob},
+		Test{"BucketCopy", regressionBucketCopy},
+		Test{"BucketProps", regressionBucketProps},
+		Test{"CloudProvider", regressionCloudProvider},
+		Test{"Versioning", regressionVersioning},
+		Test{"VersionRebalance", regressionVersionRebalance},
+		Test{"UserMeta", regressionUserMeta},
+		Test{"ListPages", regressionListPages},
+		Test{"Metrics", regressionMetrics},
+		Test{"Latency", regressionLatency},
+		Test{"Trace", regressionTrace},
+		Test{"Auth", regressionAuth},
+		Test{"TLS", regressionTLS},
 	}
 	abortonerr      = false
 	readerType      = readers.ReaderTypeSG
@@ -385,6 +446,190 @@ func regressionLRU(t *testing.T) {
 	}
 }
 
+// eviction policies can be changed cluster-wide at runtime, invalid ones are rejected
+func regressionLRUPolicy(t *testing.T) {
+	smap := getClusterMap(httpclient, t)
+	oconfig := getConfig(proxyurl+"/v1/daemon", httpclient, t)
+	if t.Failed() {
+		return
+	}
+	opolicy := oconfig["lru_config"].(map[string]interface{})["policy"].(string)
+	defer setConfig("lru_policy", opolicy, proxyurl+"/v1/cluster", httpclient, t)
+
+	check := func(expected string) {
+		for sid, si := range smap.Smap {
+			cfg := getConfig(si.DirectURL+RestAPIDaemonSuffix, httpclient, t)
+			if t.Failed() {
+				return
+			}
+			if policy := cfg["lru_config"].(map[string]interface{})["policy"]; policy != expected {
+				t.Errorf("Target %s: eviction policy %v, expecting %s", sid, policy, expected)
+			}
+		}
+	}
+	for _, policy := range []string{dfc.EvictLFU, dfc.EvictGDS, dfc.EvictARC, dfc.EvictLRU} {
+		setConfig("lru_policy", policy, proxyurl+"/v1/cluster", httpclient, t)
+		check(policy)
+	}
+	setConfig("lru_policy", "mru", proxyurl+"/v1/cluster", httpclient, t)
+	check(dfc.EvictLRU)
+}
+
+// a bucket over its quota gets evicted down to the quota, the usage is reported via stats
+func regressionBucketQuota(t *testing.T) {
+	const (
+		numPuts = 30
+		size    = int64(256 * 1024)
+		quotaMB = 3
+	)
+	createLocalBucket(httpclient, t, QuotaBucketName)
+	defer destroyLocalBucket(httpclient, t, QuotaBucketName)
+	waitMetasync(t)
+
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", QuotaStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, QuotaBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", QuotaBucketName, objname, err)
+		}
+	}
+	oconfig := getConfig(proxyurl+"/v1/daemon", httpclient, t)
+	if t.Failed() {
+		return
+	}
+	olruconfig := oconfig["lru_config"].(map[string]interface{})
+	defer func() {
+		setConfig("bucket_quota", QuotaBucketName+":0:0:0", proxyurl+"/v1/cluster", httpclient, t)
+		setConfig("dont_evict_time", olruconfig["dont_evict_time"].(string), proxyurl+"/v1/cluster", httpclient, t)
+	}()
+	setConfig("dont_evict_time", "1s", proxyurl+"/v1/cluster", httpclient, t)
+	setConfig("bucket_quota", fmt.Sprintf("%s:%d:1:5", QuotaBucketName, quotaMB), proxyurl+"/v1/cluster", httpclient, t)
+	time.Sleep(time.Second * 2)
+	if err := client.RunLRU(proxyurl); err != nil {
+		t.Fatalf("Failed to run LRU: %v", err)
+	}
+	time.Sleep(time.Second * 3)
+
+	stats := getClusterStats(httpclient, t)
+	bc, ok := stats.Buckets[QuotaBucketName]
+	if !ok {
+		t.Fatalf("Bucket %s is missing in the cluster stats", QuotaBucketName)
+	}
+	tlogf("Bucket %s: used %d, quota %d, reserved %d, evicted %d\n", QuotaBucketName, bc.Used, bc.Quota, bc.Reserved, bc.Evicted)
+	if bc.Priority != 5 || bc.Reserved == 0 {
+		t.Errorf("Unexpected bucket stats %+v", *bc)
+	}
+	// each target may keep up to one object over its share of the quota
+	if bc.Evicted == 0 || bc.Used > quotaMB*1024*1024+int64(len(stats.Target))*size {
+		t.Errorf("Bucket %s: used %d, evicted %d - expecting at most %d", QuotaBucketName, bc.Used, bc.Evicted, quotaMB*1024*1024)
+	}
+	objs, err := client.ListObjects(proxyurl, QuotaBucketName, QuotaStr)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", QuotaBucketName, err)
+	}
+	if int64(len(objs))*size != bc.Used {
+		t.Errorf("Bucket %s: listed %d objects, expecting %d bytes", QuotaBucketName, len(objs), bc.Used)
+	}
+}
+
+func regressionPin(t *testing.T) {
+	const (
+		numPuts = 20
+		size    = int64(256 * 1024)
+	)
+	createLocalBucket(httpclient, t, PinBucketName)
+	defer destroyLocalBucket(httpclient, t, PinBucketName)
+	waitMetasync(t)
+
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", PinStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, PinBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", PinBucketName, objname, err)
+		}
+	}
+	// pin obj0..obj2 by list and obj10..obj12 by range
+	pinned := map[string]bool{}
+	objlist := make([]string, 0, 3)
+	for i := 0; i < 3; i++ {
+		objlist = append(objlist, fmt.Sprintf("%s/obj%d", PinStr, i))
+		pinned[fmt.Sprintf("%s/obj%d", PinStr, i)] = true
+		pinned[fmt.Sprintf("%s/obj%d", PinStr, 10+i)] = true
+	}
+	if err := client.PinList(proxyurl, PinBucketName, objlist, true, 0, 0); err != nil {
+		t.Fatalf("Failed to pin %v: %v", objlist, err)
+	}
+	if err := client.PinRange(proxyurl, PinBucketName, PinStr+"/", "\\d+$", "10:12", true, 0, time.Hour); err != nil {
+		t.Fatalf("Failed to pin range: %v", err)
+	}
+	checkpinned := func(expected map[string]bool) {
+		msg, err := json.Marshal(&dfc.GetMsg{GetPrefix: PinStr, GetProps: dfc.GetPropsPinned})
+		if err != nil {
+			t.Fatalf("Failed to marshal GetMsg: %v", err)
+		}
+		bucketList, err := client.ListBucket(proxyurl, PinBucketName, msg)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", PinBucketName, err)
+		}
+		for _, entry := range bucketList.Entries {
+			if entry.Pinned != expected[entry.Name] {
+				t.Errorf("%s/%s: pinned %v, expecting %v", PinBucketName, entry.Name, entry.Pinned, expected[entry.Name])
+			}
+		}
+	}
+	checkpinned(pinned)
+
+	// evict the bucket down to zero: only the pinned objects must remain
+	oconfig := getConfig(proxyurl+"/v1/daemon", httpclient, t)
+	if t.Failed() {
+		return
+	}
+	olruconfig := oconfig["lru_config"].(map[string]interface{})
+	defer func() {
+		setConfig("bucket_quota", PinBucketName+":0:0:0", proxyurl+"/v1/cluster", httpclient, t)
+		setConfig("dont_evict_time", olruconfig["dont_evict_time"].(string), proxyurl+"/v1/cluster", httpclient, t)
+	}()
+	setConfig("dont_evict_time", "1s", proxyurl+"/v1/cluster", httpclient, t)
+	setConfig("bucket_quota", PinBucketName+":1:0:0", proxyurl+"/v1/cluster", httpclient, t)
+	time.Sleep(time.Second * 2)
+	if err := client.RunLRU(proxyurl); err != nil {
+		t.Fatalf("Failed to run LRU: %v", err)
+	}
+	time.Sleep(time.Second * 3)
+
+	objs, err := client.ListObjects(proxyurl, PinBucketName, PinStr)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", PinBucketName, err)
+	}
+	remaining := make(map[string]bool, len(objs))
+	for _, objname := range objs {
+		remaining[objname] = true
+	}
+	for objname := range pinned {
+		if !remaining[objname] {
+			t.Errorf("Pinned %s/%s was evicted", PinBucketName, objname)
+		}
+	}
+	if len(objs) == numPuts {
+		t.Errorf("Bucket %s: nothing was evicted", PinBucketName)
+	}
+	tlogf("Bucket %s: %d objects remaining, %d pinned\n", PinBucketName, len(objs), len(pinned))
+
+	if err := client.UnpinList(proxyurl, PinBucketName, objlist, true, 0); err != nil {
+		t.Fatalf("Failed to unpin %v: %v", objlist, err)
+	}
+	for _, objname := range objlist {
+		delete(pinned, objname)
+	}
+	checkpinned(pinned)
+}
+
 func regressionRebalance(t *testing.T) {
 	var (
 		sid      string
@@ -846,6 +1091,385 @@ func regressionDeleteList(t *testing.T) {
 //
 //========
 
+func regressionRangeRead(t *testing.T) {
+	const size = int64(1024 * 1024)
+	objname := RangeReadStr + "/obj"
+	createLocalBucket(httpclient, t, RangeReadBucketName)
+	defer destroyLocalBucket(httpclient, t, RangeReadBucketName)
+	waitMetasync(t)
+
+	reader, err := readers.NewInMemReader(size, false)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	rc, err := reader.Open()
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	expected, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("Failed to read reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, RangeReadBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to put %s/%s: %v", RangeReadBucketName, objname, err)
+	}
+
+	// single ranges
+	for _, rng := range [][2]int64{{0, 100}, {4096, 65536}, {size - 100, 0}, {size - 1, 1}} {
+		buf := &bytes.Buffer{}
+		offset, length := rng[0], rng[1]
+		if _, err = client.GetRange(proxyurl, RangeReadBucketName, objname, offset, length, false, buf); err != nil {
+			t.Errorf("GetRange(%d, %d) failed: %v", offset, length, err)
+			continue
+		}
+		end := size
+		if length > 0 {
+			end = offset + length
+		}
+		if !bytes.Equal(buf.Bytes(), expected[offset:end]) {
+			t.Errorf("GetRange(%d, %d): data mismatch (got %d bytes)", offset, length, buf.Len())
+		}
+	}
+
+	// multi-range
+	req, err := http.NewRequest(http.MethodGet, proxyurl+"/v1/files/"+RangeReadBucketName+"/"+objname, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set(dfc.HeaderRange, "bytes=0-9,100-199")
+	r, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("Multi-range GET failed: %v", err)
+	}
+	ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusPartialContent || !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/byteranges") {
+		t.Errorf("Multi-range GET: unexpected status %d, content type %q", r.StatusCode, r.Header.Get("Content-Type"))
+	}
+
+	// If-Range with a stale ETag: must return the entire object
+	req.Header.Set(dfc.HeaderRange, "bytes=0-9")
+	req.Header.Set(dfc.HeaderIfRange, "\"stale-etag\"")
+	r, err = httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("If-Range GET failed: %v", err)
+	}
+	data, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK || int64(len(data)) != size {
+		t.Errorf("If-Range GET: expected entire object, got status %d, %d bytes", r.StatusCode, len(data))
+	}
+
+	if err = client.Del(proxyurl, RangeReadBucketName, objname, nil, nil, true); err != nil {
+		t.Errorf("Failed to delete %s/%s: %v", RangeReadBucketName, objname, err)
+	}
+}
+
+// regressionColdRangeRead reads ranges of an evicted Cloud object with and without ?rangeonly=true:
+// the range-only reads must not bring the object into the cache
+func regressionColdRangeRead(t *testing.T) {
+	const size = int64(256 * 1024)
+	objname := RangeReadStr + "/cold"
+	server, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Could not execute HeadBucket Request: %v", err)
+	}
+	if server == "dfc" {
+		t.Skipf("Cannot cold-read from local bucket %s", clibucket)
+	}
+
+	reader, err := readers.NewInMemReader(size, false)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	rc, err := reader.Open()
+	if err != nil {
+		t.Fatalf("Failed to open reader: %v", err)
+	}
+	expected, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatalf("Failed to read reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to put %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+
+	iscached := func() bool {
+		msg := dfc.GetMsg{GetPrefix: objname, GetProps: dfc.GetPropsIsCached}
+		bucketlist := testListBucketAll(t, clibucket, msg)
+		if bucketlist == nil {
+			t.Fatalf("Failed to list %s/%s", clibucket, objname)
+		}
+		for _, entry := range bucketlist.Entries {
+			if entry.Name == objname {
+				return entry.IsCached
+			}
+		}
+		t.Fatalf("%s/%s is not listed", clibucket, objname)
+		return false
+	}
+	getrange := func(offset, length int64, rangeonly bool) {
+		buf := &bytes.Buffer{}
+		if _, err := client.GetRange(proxyurl, clibucket, objname, offset, length, rangeonly, buf); err != nil {
+			t.Errorf("GetRange(%d, %d, rangeonly=%v) failed: %v", offset, length, rangeonly, err)
+			return
+		}
+		end := size
+		if length > 0 {
+			end = offset + length
+		}
+		if !bytes.Equal(buf.Bytes(), expected[offset:end]) {
+			t.Errorf("GetRange(%d, %d, rangeonly=%v): data mismatch (got %d bytes)", offset, length, rangeonly, buf.Len())
+		}
+	}
+
+	if err = client.EvictList(proxyurl, clibucket, []string{objname}, true, 0); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+	}
+	for _, rng := range [][2]int64{{0, 100}, {4096, 65536}, {size - 100, 0}, {size - 1, 1}} {
+		getrange(rng[0], rng[1], true)
+	}
+	if iscached() {
+		t.Errorf("%s/%s is cached after the range-only reads", clibucket, objname)
+	}
+
+	// without rangeonly, the cold GET caches the entire object and returns the range
+	getrange(4096, 100, false)
+	if !iscached() {
+		t.Errorf("%s/%s is not cached after the cold range read", clibucket, objname)
+	}
+	// and once cached, rangeonly is a no-op
+	getrange(100, 4096, true)
+}
+
+func regressionMultipart(t *testing.T) {
+	const (
+		numParts = 3
+		partSize = int64(1024 * 1024)
+	)
+	objname := MultipartStr + "/obj"
+	createLocalBucket(httpclient, t, MultipartBucketName)
+	defer destroyLocalBucket(httpclient, t, MultipartBucketName)
+	waitMetasync(t)
+
+	uploadid, err := client.MPInit(proxyurl, MultipartBucketName, objname)
+	if err != nil {
+		t.Fatalf("Failed to initiate multipart upload: %v", err)
+	}
+	// upload parts in reverse order, while computing the checksum of the entire object
+	parts := make([][]byte, numParts)
+	for partnum := numParts; partnum >= 1; partnum-- {
+		reader, err := readers.NewInMemReader(partSize, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		rc, _ := reader.Open()
+		parts[partnum-1], _ = ioutil.ReadAll(rc)
+		rc.Close()
+		if err = client.MPPutPart(proxyurl, reader, MultipartBucketName, objname, uploadid, partnum); err != nil {
+			t.Fatalf("Failed to upload part %d: %v", partnum, err)
+		}
+	}
+	hash, errstr := dfc.ComputeXXHash(bytes.NewReader(bytes.Join(parts, nil)), nil, xxhash.New64())
+	if errstr != "" {
+		t.Fatalf("Failed to compute xxhash: %s", errstr)
+	}
+	if err = client.MPComplete(proxyurl, MultipartBucketName, objname, uploadid, hash); err != nil {
+		t.Fatalf("Failed to complete multipart upload: %v", err)
+	}
+	// the upload is gone
+	if err = client.MPComplete(proxyurl, MultipartBucketName, objname, uploadid, ""); err == nil {
+		t.Errorf("Completing multipart upload %s twice was expected to fail", uploadid)
+	}
+	buf := &bytes.Buffer{}
+	if _, err = client.GetRange(proxyurl, MultipartBucketName, objname, partSize-10, 20, false, buf); err != nil {
+		t.Errorf("Failed to read %s/%s: %v", MultipartBucketName, objname, err)
+	} else if !bytes.Equal(buf.Bytes(), append(parts[0][partSize-10:], parts[1][:10]...)) {
+		t.Errorf("Multipart upload %s/%s: data mismatch at the parts boundary", MultipartBucketName, objname)
+	}
+
+	// abort
+	if uploadid, err = client.MPInit(proxyurl, MultipartBucketName, objname); err != nil {
+		t.Fatalf("Failed to initiate multipart upload: %v", err)
+	}
+	reader, err := readers.NewInMemReader(partSize, true)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if err = client.MPPutPart(proxyurl, reader, MultipartBucketName, objname, uploadid, 1); err != nil {
+		t.Fatalf("Failed to upload part: %v", err)
+	}
+	if err = client.MPAbort(proxyurl, MultipartBucketName, objname, uploadid); err != nil {
+		t.Errorf("Failed to abort multipart upload: %v", err)
+	}
+	if err = client.MPComplete(proxyurl, MultipartBucketName, objname, uploadid, ""); err == nil {
+		t.Errorf("Completing aborted multipart upload %s was expected to fail", uploadid)
+	}
+
+	if err = client.Del(proxyurl, MultipartBucketName, objname, nil, nil, true); err != nil {
+		t.Errorf("Failed to delete %s/%s: %v", MultipartBucketName, objname, err)
+	}
+}
+
+func regressionReplication(t *testing.T) {
+	const (
+		numPuts = 10
+		copies  = 2
+		size    = int64(1024 * 64)
+	)
+	smap := getClusterMap(httpclient, t)
+	l := len(smap.Smap)
+	if l < copies {
+		t.Skipf("Replication requires at least %d targets, have %d", copies, l)
+	}
+	createLocalBucket(httpclient, t, ReplicaBucketName)
+	defer destroyLocalBucket(httpclient, t, ReplicaBucketName)
+	if err := client.SetCopies(proxyurl, ReplicaBucketName, copies); err != nil {
+		t.Fatalf("Failed to set the number of copies: %v", err)
+	}
+	waitMetasync(t)
+	// the transfers of the rebalance still running (if any) must not be counted as replicas
+	waitRebalance(t)
+
+	frecvOrig := int64(0)
+	stats := getClusterStats(httpclient, t)
+	for _, v := range stats.Target {
+		frecvOrig += v.Core.Numrecvfiles
+	}
+	objnames := make([]string, numPuts)
+	for i := range objnames {
+		objnames[i] = fmt.Sprintf("%s/obj%d", ReplicaStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, ReplicaBucketName, objnames[i], true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", ReplicaBucketName, objnames[i], err)
+		}
+	}
+	// each PUT results in (copies - 1) target-to-target transfers - in the background
+	for deadline := time.Now().Add(time.Second * 30); ; time.Sleep(time.Second) {
+		var frecv int64
+		stats = getClusterStats(httpclient, t)
+		for _, v := range stats.Target {
+			frecv += v.Core.Numrecvfiles
+		}
+		if frecv-frecvOrig == numPuts*(copies-1) {
+			break
+		}
+		if frecv-frecvOrig > numPuts*(copies-1) || time.Now().After(deadline) {
+			t.Fatalf("Expected %d replicas, received %d", numPuts*(copies-1), frecv-frecvOrig)
+		}
+	}
+	// the replicas are not listed
+	checkReplicaList := func() {
+		listed, err := client.ListObjects(proxyurl, ReplicaBucketName, ReplicaStr)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", ReplicaBucketName, err)
+		}
+		sort.Strings(listed)
+		if strings.Join(listed, ",") != strings.Join(objnames, ",") {
+			t.Errorf("Listed %v, expecting %v", listed, objnames)
+		}
+	}
+	checkReplicaList()
+
+	// local bucket objects must survive the loss of a target
+	var sid string
+	for sid = range smap.Smap {
+		break
+	}
+	unregisterTarget(sid, t)
+	tlogf("Unregistered %s: cluster size = %d (targets)\n", sid, l-1)
+	for _, objname := range objnames {
+		if _, err := client.Get(proxyurl, ReplicaBucketName, objname, nil, nil, true, false); err != nil {
+			t.Errorf("Failed to get %s/%s with target %s gone: %v", ReplicaBucketName, objname, sid, err)
+		}
+	}
+	registerTarget(sid, &smap, t)
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Second)
+		if len(getClusterMap(httpclient, t).Smap) == l {
+			break
+		}
+	}
+	waitProgressBar("Rebalance: ", time.Second*10)
+	checkReplicaList()
+
+	for _, objname := range objnames {
+		if err := client.Del(proxyurl, ReplicaBucketName, objname, nil, nil, true); err != nil {
+			t.Errorf("Failed to delete %s/%s: %v", ReplicaBucketName, objname, err)
+		}
+	}
+}
+
+func regressionErasureCoding(t *testing.T) {
+	const (
+		numPuts = 10
+		data    = 2
+		parity  = 1
+		size    = int64(1024*64 + 13) // not a multiple of the number of slices
+	)
+	smap := getClusterMap(httpclient, t)
+	l := len(smap.Smap)
+	if l < data+parity {
+		t.Skipf("Erasure coding requires at least %d targets, have %d", data+parity, l)
+	}
+	createLocalBucket(httpclient, t, ECBucketName)
+	defer destroyLocalBucket(httpclient, t, ECBucketName)
+	if err := client.SetEC(proxyurl, ECBucketName, data, parity); err != nil {
+		t.Fatalf("Failed to enable erasure coding: %v", err)
+	}
+	waitMetasync(t)
+
+	objnames := make([]string, numPuts)
+	for i := range objnames {
+		objnames[i] = fmt.Sprintf("%s/obj%d", ECStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, ECBucketName, objnames[i], true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", ECBucketName, objnames[i], err)
+		}
+	}
+
+	// objects must be restored from the remaining slices when a target is gone
+	var sid string
+	for sid = range smap.Smap {
+		break
+	}
+	unregisterTarget(sid, t)
+	tlogf("Unregistered %s: cluster size = %d (targets)\n", sid, l-1)
+	errch := make(chan error, numPuts*2)
+	for _, objname := range objnames {
+		if _, err := client.Get(proxyurl, ECBucketName, objname, nil, errch, true, true); err != nil {
+			t.Errorf("Failed to get %s/%s with target %s gone: %v", ECBucketName, objname, sid, err)
+		}
+	}
+	close(errch)
+	for err := range errch {
+		t.Error(err)
+	}
+	registerTarget(sid, &smap, t)
+	for i := 0; i < 10; i++ {
+		time.Sleep(time.Second)
+		if len(getClusterMap(httpclient, t).Smap) == l {
+			break
+		}
+	}
+	waitProgressBar("Rebalance: ", time.Second*10)
+
+	for _, objname := range objnames {
+		if err := client.Del(proxyurl, ECBucketName, objname, nil, nil, true); err != nil {
+			t.Errorf("Failed to delete %s/%s: %v", ECBucketName, objname, err)
+		}
+	}
+}
+
 func waitProgressBar(prefix string, wait time.Duration) {
 	ticker := time.NewTicker(time.Second * 5)
 	tlogf(prefix)
@@ -1029,25 +1653,333 @@ func getDaemonStats(httpclient *http.Client, t *testing.T, URL string) (stats ma
 	return
 }
 
-func getClusterMap(httpclient *http.Client, t *testing.T) (smap dfc.Smap) {
-	var (
-		req    *http.Request
-		r      *http.Response
-		injson []byte
-		err    error
-	)
-	injson, err = json.Marshal(GetSmapMsg)
-	if err != nil {
-		t.Fatalf("Failed to marshal GetStatsMsg: %v", err)
-	}
-	req, err = http.NewRequest("GET", proxyurl+"/v1/cluster", bytes.NewBuffer(injson))
+func regressionMetasync(t *testing.T) {
+	info, err := client.GetMetasyncInfo(proxyurl)
 	if err != nil {
-		t.Fatalf("Failed to create request: %v", err)
+		t.Fatalf("Failed to get metasync info: %v", err)
 	}
-	r, err = httpclient.Do(req)
-	defer func() {
-		if r != nil {
-			r.Body.Close()
+	lbversion := info.LBmap
+	createLocalBucket(httpclient, t, MetasyncBucketName)
+	defer destroyLocalBucket(httpclient, t, MetasyncBucketName)
+
+	// the new lbmap version must be broadcast and acknowledged by all targets
+	smap := getClusterMap(httpclient, t)
+	for deadline := time.Now().Add(time.Second * 30); ; {
+		time.Sleep(time.Second * 2)
+		if info, err = client.GetMetasyncInfo(proxyurl); err != nil {
+			t.Fatalf("Failed to get metasync info: %v", err)
+		}
+		if info.SyncedLBmap > lbversion && len(info.Lagging) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lbmap v%d (synced v%d) failed to reach all daemons, lagging: %v",
+				info.LBmap, info.SyncedLBmap, info.Lagging)
+		}
+	}
+	for sid := range smap.Smap {
+		st, ok := info.Daemons[sid]
+		if !ok {
+			t.Errorf("Target %s is missing in the metasync info", sid)
+			continue
+		}
+		if st.LBmap < info.SyncedLBmap || st.Smap < info.SyncedSmap {
+			t.Errorf("Target %s acknowledged Smap v%d, lbmap v%d - expecting v%d, v%d",
+				sid, st.Smap, st.LBmap, info.SyncedSmap, info.SyncedLBmap)
+		}
+	}
+}
+
+// throttle the rebalance, abort it midway, and then restart it explicitly
+func regressionRebalanceControl(t *testing.T) {
+	const (
+		numPuts = 40
+		size    = int64(1024 * 128)
+	)
+	smap := getClusterMap(httpclient, t)
+	l := len(smap.Smap)
+	if l < 2 {
+		t.Skipf("Rebalance requires at least 2 targets, have %d", l)
+	}
+	createLocalBucket(httpclient, t, RebalanceBucketName)
+	defer destroyLocalBucket(httpclient, t, RebalanceBucketName)
+	waitMetasync(t)
+
+	if err := client.ThrottleRebalance(proxyurl, 0.1); err != nil {
+		t.Fatalf("Failed to throttle rebalance: %v", err)
+	}
+	defer client.ThrottleRebalance(proxyurl, 0)
+
+	var sid string
+	for sid = range smap.Smap {
+		break
+	}
+	unregisterTarget(sid, t)
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", RebalanceStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, RebalanceBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", RebalanceBucketName, objname, err)
+		}
+	}
+	registerTarget(sid, &smap, t)
+
+	// wait for the (throttled) rebalance to start, and abort it
+	var (
+		info *dfc.RebalanceInfo
+		err  error
+	)
+	for deadline := time.Now().Add(time.Second * 30); ; {
+		time.Sleep(time.Millisecond * 500)
+		if info, err = client.GetRebalanceInfo(proxyurl); err != nil {
+			t.Fatalf("Failed to get rebalance info: %v", err)
+		}
+		if info.Running && len(info.Target) == l {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Rebalance did not start")
+		}
+	}
+	tlogf("Rebalance in progress: moved %d objects (%d bytes)\n", info.ObjsMoved, info.BytesMoved)
+	if err = client.AbortRebalance(proxyurl); err != nil {
+		t.Fatalf("Failed to abort rebalance: %v", err)
+	}
+	time.Sleep(time.Second)
+	if info, err = client.GetRebalanceInfo(proxyurl); err != nil {
+		t.Fatalf("Failed to get rebalance info: %v", err)
+	}
+	if info.Running {
+		t.Fatalf("Rebalance is still running after abort")
+	}
+	aborted := make(map[string]string, l)
+	moved := info.ObjsMoved
+	for tid, stats := range info.Target {
+		aborted[tid] = stats.ID
+		if stats.Throttle == 0 {
+			t.Errorf("Target %s: rebalance is not throttled", tid)
+		}
+	}
+
+	// unthrottle, restart, and wait for the rebalance to complete
+	if err = client.ThrottleRebalance(proxyurl, 0); err != nil {
+		t.Fatalf("Failed to unthrottle rebalance: %v", err)
+	}
+	if err = client.StartRebalance(proxyurl); err != nil {
+		t.Fatalf("Failed to start rebalance: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second * 30); ; {
+		time.Sleep(time.Second)
+		if info, err = client.GetRebalanceInfo(proxyurl); err != nil {
+			t.Fatalf("Failed to get rebalance info: %v", err)
+		}
+		if !info.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Rebalance did not complete")
+		}
+	}
+	for tid, stats := range info.Target {
+		if stats.ID == aborted[tid] || stats.Aborted || stats.EndTime.IsZero() {
+			t.Errorf("Target %s: expecting completed rebalance, got %+v", tid, stats)
+		}
+		if stats.Errors != 0 {
+			t.Errorf("Target %s: rebalance errors %d", tid, stats.Errors)
+		}
+	}
+	if moved+info.ObjsMoved == 0 {
+		t.Errorf("Rebalance moved no objects")
+	}
+	tlogf("Rebalance done: moved %d + %d objects\n", moved, info.ObjsMoved)
+}
+
+func regressionXactions(t *testing.T) {
+	const (
+		numPuts = 20
+		size    = int64(1024 * 128)
+	)
+	smap := getClusterMap(httpclient, t)
+	l := len(smap.Smap)
+	if l < 2 {
+		t.Skipf("Rebalance requires at least 2 targets, have %d", l)
+	}
+	// 1. finished xactions are listed
+	if err := client.RunLRU(proxyurl); err != nil {
+		t.Fatalf("Failed to run LRU: %v", err)
+	}
+	time.Sleep(time.Second * 2)
+	xinfo, err := client.GetXactions(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get xactions: %v", err)
+	}
+	if len(xinfo.Target) != l {
+		t.Fatalf("Expecting xactions of %d targets, got %d", l, len(xinfo.Target))
+	}
+	for sid, infos := range xinfo.Target {
+		var found bool
+		for _, info := range infos {
+			if info.Kind == dfc.ActLRU && info.Status == dfc.XactFinished && !info.EndTime.Before(info.StartTime) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Errorf("Target %s: finished %s xaction is missing in %d xactions", sid, dfc.ActLRU, len(infos))
+		}
+	}
+	// 2. unknown ID; IDs are cluster-unique
+	if err = client.AbortXaction(proxyurl, "nosuchtarget/1", ""); err == nil {
+		t.Errorf("Aborting nonexistent xaction succeeded")
+	}
+	ids := make(map[string]string, 64)
+	for sid, infos := range xinfo.Target {
+		for _, info := range infos {
+			if other, ok := ids[info.ID]; ok {
+				t.Errorf("Xaction ID %s is not unique: targets %s and %s", info.ID, other, sid)
+			}
+			ids[info.ID] = sid
+		}
+	}
+
+	// 3. abort (throttled) rebalance on a single target by its cluster-unique ID
+	createLocalBucket(httpclient, t, XactBucketName)
+	defer destroyLocalBucket(httpclient, t, XactBucketName)
+	waitMetasync(t)
+	if err = client.ThrottleRebalance(proxyurl, 0.1); err != nil {
+		t.Fatalf("Failed to throttle rebalance: %v", err)
+	}
+	defer client.ThrottleRebalance(proxyurl, 0)
+
+	var sid string
+	for sid = range smap.Smap {
+		break
+	}
+	unregisterTarget(sid, t)
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", XactStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, XactBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", XactBucketName, objname, err)
+		}
+	}
+	registerTarget(sid, &smap, t)
+
+	var (
+		running *dfc.XactionInfo
+		tid     string
+		others  map[string]bool // the other targets' running rebalance
+	)
+	for deadline := time.Now().Add(time.Second * 30); running == nil; {
+		time.Sleep(time.Millisecond * 500)
+		if xinfo, err = client.GetXactions(proxyurl); err != nil {
+			t.Fatalf("Failed to get xactions: %v", err)
+		}
+		others = make(map[string]bool, l)
+		for id, infos := range xinfo.Target {
+			for _, info := range infos {
+				if info.Kind != dfc.ActRebalance || info.Status != dfc.XactRunning {
+					continue
+				}
+				if running == nil && id != sid {
+					running, tid = info, id
+				} else {
+					others[info.ID] = true
+				}
+			}
+		}
+		if running == nil && time.Now().After(deadline) {
+			t.Fatalf("Rebalance did not start")
+		}
+	}
+	tlogf("Aborting %s xaction %s on %s\n", running.Kind, running.ID, tid)
+	if err = client.AbortXaction(proxyurl, running.ID, ""); err != nil {
+		t.Fatalf("Failed to abort xaction %s: %v", running.ID, err)
+	}
+	time.Sleep(time.Second)
+	if xinfo, err = client.GetXactions(proxyurl); err != nil {
+		t.Fatalf("Failed to get xactions: %v", err)
+	}
+	for id, infos := range xinfo.Target {
+		for _, info := range infos {
+			if info.ID == running.ID && info.Status != dfc.XactAborted {
+				t.Errorf("Target %s: xaction %s status %s, expecting %s", id, info.ID, info.Status, dfc.XactAborted)
+			}
+			if others[info.ID] && info.Status == dfc.XactAborted {
+				t.Errorf("Target %s: xaction %s aborted, expecting only %s aborted", id, info.ID, running.ID)
+			}
+		}
+	}
+
+	// let the rebalance complete
+	if err = client.ThrottleRebalance(proxyurl, 0); err != nil {
+		t.Fatalf("Failed to unthrottle rebalance: %v", err)
+	}
+	if err = client.StartRebalance(proxyurl); err != nil {
+		t.Fatalf("Failed to start rebalance: %v", err)
+	}
+	waitRebalance(t)
+}
+
+// waitRebalance waits for the rebalance, if any, to complete on all targets
+func waitRebalance(t *testing.T) {
+	for deadline := time.Now().Add(time.Second * 30); ; {
+		time.Sleep(time.Second)
+		info, err := client.GetRebalanceInfo(proxyurl)
+		if err != nil {
+			t.Fatalf("Failed to get rebalance info: %v", err)
+		}
+		if !info.Running {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Rebalance did not complete")
+		}
+	}
+}
+
+// waitMetasync waits for the current lbmap to reach all targets
+func waitMetasync(t *testing.T) {
+	for deadline := time.Now().Add(time.Second * 30); ; {
+		info, err := client.GetMetasyncInfo(proxyurl)
+		if err != nil {
+			t.Fatalf("Failed to get metasync info: %v", err)
+		}
+		if info.SyncedLBmap == info.LBmap && len(info.Lagging) == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("lbmap v%d (synced v%d) failed to reach all targets, lagging: %v", info.LBmap, info.SyncedLBmap, info.Lagging)
+		}
+		time.Sleep(time.Millisecond * 500)
+	}
+}
+
+func getClusterMap(httpclient *http.Client, t *testing.T) (smap dfc.Smap) {
+	var (
+		req    *http.Request
+		r      *http.Response
+		injson []byte
+		err    error
+	)
+	injson, err = json.Marshal(GetSmapMsg)
+	if err != nil {
+		t.Fatalf("Failed to marshal GetStatsMsg: %v", err)
+	}
+	req, err = http.NewRequest("GET", proxyurl+"/v1/cluster", bytes.NewBuffer(injson))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	r, err = httpclient.Do(req)
+	defer func() {
+		if r != nil {
+			r.Body.Close()
 		}
 	}()
 	if testfail(err, "Get configuration", r, nil, t) {
@@ -1159,3 +2091,1139 @@ func tlogf(msg string, args ...interface{}) {
 func tlogln(msg string) {
 	tlogf(msg + "\n")
 }
+
+func regressionListRangeJob(t *testing.T) {
+	const (
+		numPuts    = 20
+		numMissing = 5
+		size       = int64(64 * 1024)
+	)
+	createLocalBucket(httpclient, t, JobBucketName)
+	defer destroyLocalBucket(httpclient, t, JobBucketName)
+	waitMetasync(t)
+
+	objlist := make([]string, 0, numPuts+numMissing)
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", JobStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, JobBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", JobBucketName, objname, err)
+		}
+		objlist = append(objlist, objname)
+	}
+	missing := make(map[string]bool, numMissing)
+	for i := 0; i < numMissing; i++ {
+		objname := fmt.Sprintf("%s/missing%d", JobStr, i)
+		objlist = append(objlist, objname)
+		missing[objname] = true
+	}
+
+	// delete the list that includes non-existing objects: the job must delete all the rest
+	// and report the failures
+	deleteMsg := dfc.ActionMsg{Action: dfc.ActDelete, Value: dfc.ListMsg{Objnames: objlist}}
+	jobID, err := client.StartListRangeJob(proxyurl, JobBucketName, deleteMsg)
+	if err != nil {
+		t.Fatalf("Failed to start the delete job: %v", err)
+	}
+	if jobID == "" {
+		t.Fatalf("Delete job: empty job ID")
+	}
+	info := waitJob(t, jobID)
+	if info.Status != dfc.JobFailed {
+		t.Errorf("Job %s: status %s, expecting %s", jobID, info.Status, dfc.JobFailed)
+	}
+	if info.Total != int64(len(objlist)) || info.Done != info.Total {
+		t.Errorf("Job %s: %d out of %d done, expecting %d", jobID, info.Done, info.Total, len(objlist))
+	}
+	if info.Failed != numMissing {
+		t.Errorf("Job %s: %d failed, expecting %d", jobID, info.Failed, numMissing)
+	}
+	for sid, stats := range info.Target {
+		for objname := range stats.Failures {
+			if !missing[objname] {
+				t.Errorf("Job %s: target %s reported unexpected failure %s: %s", jobID, sid, objname, stats.Failures[objname])
+			}
+		}
+	}
+	objs, err := client.ListObjects(proxyurl, JobBucketName, JobStr)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", JobBucketName, err)
+	}
+	if len(objs) != 0 {
+		t.Errorf("Bucket %s: %d objects remaining after delete", JobBucketName, len(objs))
+	}
+
+	// cancelling the finished job does not change its status
+	if info, err = client.CancelJob(proxyurl, jobID); err != nil {
+		t.Errorf("Failed to cancel job %s: %v", jobID, err)
+	} else if info.Status != dfc.JobFailed {
+		t.Errorf("Cancelled finished job %s: status %s, expecting %s", jobID, info.Status, dfc.JobFailed)
+	}
+	if _, err = client.GetJobStatus(proxyurl, "nosuchjob"); err == nil {
+		t.Errorf("Expecting an error getting the status of a non-existing job")
+	}
+	if _, err = client.CancelJob(proxyurl, "nosuchjob"); err == nil {
+		t.Errorf("Expecting an error cancelling a non-existing job")
+	}
+}
+
+func regressionBucketCopy(t *testing.T) {
+	const (
+		numPuts = 10
+		size    = int64(64 * 1024)
+	)
+	tobucket := CopyBucketName + "2"
+	createLocalBucket(httpclient, t, CopyBucketName)
+	defer destroyLocalBucket(httpclient, t, CopyBucketName)
+	createLocalBucket(httpclient, t, tobucket)
+	defer destroyLocalBucket(httpclient, t, tobucket)
+	waitMetasync(t)
+
+	objlist := make([]string, 0, numPuts)
+	for i := 0; i < numPuts; i++ {
+		objname := fmt.Sprintf("%s/obj%d", CopyStr, i)
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, CopyBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", CopyBucketName, objname, err)
+		}
+		objlist = append(objlist, objname)
+	}
+	checkobjs := func(bucket string, expected []string) {
+		objs, err := client.ListObjects(proxyurl, bucket, CopyStr)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", bucket, err)
+		}
+		if len(objs) != len(expected) {
+			t.Errorf("Bucket %s: %d objects, expecting %d", bucket, len(objs), len(expected))
+		}
+		for _, objname := range expected {
+			if _, err := client.Get(proxyurl, bucket, objname, nil, nil, true, true); err != nil {
+				t.Errorf("Failed to get %s/%s: %v", bucket, objname, err)
+			}
+		}
+	}
+	checkjob := func(jobID string, err error, expected int) {
+		if err != nil {
+			t.Fatalf("Failed to start the job: %v", err)
+		}
+		info := waitJob(t, jobID)
+		if info.Status != dfc.JobFinished || info.Done != int64(expected) || info.Failed != 0 {
+			t.Fatalf("Job %s: %s, %d done, %d failed, expecting %d done", jobID, info.Status, info.Done, info.Failed, expected)
+		}
+	}
+
+	// local => local: copy obj0..obj4 by range, and then move obj5..obj9 by list
+	jobID, err := client.CopyRange(proxyurl, CopyBucketName, tobucket, CopyStr+"/obj", "\\d+$", "0:4", 0)
+	checkjob(jobID, err, 5)
+	checkobjs(tobucket, objlist[:5])
+	checkobjs(CopyBucketName, objlist)
+
+	jobID, err = client.MoveList(proxyurl, CopyBucketName, tobucket, objlist[5:], 0)
+	checkjob(jobID, err, 5)
+	checkobjs(tobucket, objlist)
+	checkobjs(CopyBucketName, objlist[:5])
+	if t.Failed() {
+		return
+	}
+
+	// local => Cloud and back: upload obj0..obj2, and then copy them from the Cloud to the empty local bucket
+	cloudlist := objlist[:3]
+	defer func() {
+		if err := client.DeleteList(proxyurl, clibucket, cloudlist, true, 0); err != nil {
+			t.Errorf("Failed to delete %v from %s: %v", cloudlist, clibucket, err)
+		}
+	}()
+	jobID, err = client.CopyList(proxyurl, CopyBucketName, clibucket, cloudlist, 0)
+	checkjob(jobID, err, len(cloudlist))
+	if err = client.EvictList(proxyurl, clibucket, cloudlist, true, 0); err != nil {
+		t.Fatalf("Failed to evict %v from %s: %v", cloudlist, clibucket, err)
+	}
+	if err = client.DeleteList(proxyurl, tobucket, objlist, true, 0); err != nil {
+		t.Fatalf("Failed to delete %v from %s: %v", objlist, tobucket, err)
+	}
+	checkobjs(tobucket, nil)
+	jobID, err = client.CopyList(proxyurl, clibucket, tobucket, cloudlist, 0)
+	checkjob(jobID, err, len(cloudlist))
+	checkobjs(tobucket, cloudlist)
+
+	// Cloud to Cloud is not supported
+	if _, err = client.CopyList(proxyurl, clibucket, clibucket+"2", cloudlist, 0); err == nil {
+		t.Errorf("Expecting Cloud to Cloud copy to fail")
+	}
+}
+
+// waitJob waits for the list/range job to finish and returns its final status
+func waitJob(t *testing.T, jobID string) *dfc.JobInfo {
+	for deadline := time.Now().Add(time.Second * 30); ; time.Sleep(time.Second) {
+		info, err := client.GetJobStatus(proxyurl, jobID)
+		if err != nil {
+			t.Fatalf("Failed to get job %s status: %v", jobID, err)
+		}
+		if info.Status != dfc.JobRunning {
+			tlogf("Job %s: %s, %d out of %d done, %d failed\n", jobID, info.Status, info.Done, info.Total, info.Failed)
+			return info
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Job %s is still running: %d out of %d done", jobID, info.Done, info.Total)
+		}
+	}
+}
+
+// regressionCloudProvider checks that the provider is validated against the targets' configs:
+// the test cluster has the posix root configured, and no s3compat endpoint
+func regressionCloudProvider(t *testing.T) {
+	server, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Could not execute HeadBucket Request: %v", err)
+	}
+	if server == "dfc" {
+		t.Skipf("Cannot set cloud provider of local bucket %s", clibucket)
+	}
+	smap := getClusterMap(httpclient, t)
+	for sid, si := range smap.Smap {
+		config := getConfig(si.DirectURL+"/v1/daemon", httpclient, t)
+		if s3, ok := config["s3"].(map[string]interface{}); ok && s3["endpoint"] != "" && s3["endpoint"] != nil {
+			t.Skipf("s3compat is configured on %s (endpoint %v)", sid, s3["endpoint"])
+		}
+	}
+	if err = client.SetCloudProvider(proxyurl, clibucket, "nosuchcloud"); err == nil {
+		t.Errorf("Set cloud provider nosuchcloud: expecting error")
+	}
+	if err = client.SetCloudProvider(proxyurl, clibucket, "s3compat"); err == nil {
+		t.Errorf("Set cloud provider s3compat with no endpoint configured: expecting error")
+	}
+	if server == "posix" {
+		if err = client.SetCloudProvider(proxyurl, clibucket, "posix"); err != nil {
+			t.Errorf("Failed to set cloud provider posix: %v", err)
+		}
+	}
+	if err = client.SetCloudProvider(proxyurl, clibucket, ""); err != nil {
+		t.Errorf("Failed to reset cloud provider: %v", err)
+	}
+	waitMetasync(t)
+}
+
+func regressionBucketProps(t *testing.T) {
+	const size = int64(16 * 1024)
+	createLocalBucket(httpclient, t, PropsBucketName)
+	defer destroyLocalBucket(httpclient, t, PropsBucketName)
+	waitMetasync(t)
+
+	// defaults: inherited from the configuration
+	config := getConfig(proxyurl+"/v1/daemon", httpclient, t)
+	cksumconfig := config["cksum_config"].(map[string]interface{})
+	bprops, err := client.HeadBucketProps(proxyurl, PropsBucketName)
+	if err != nil {
+		t.Fatalf("Failed to head bucket %s: %v", PropsBucketName, err)
+	}
+	if bprops.Checksum != cksumconfig["checksum"].(string) {
+		t.Errorf("Bucket %s: checksum %q, expecting %q (config)", PropsBucketName, bprops.Checksum, cksumconfig["checksum"])
+	}
+
+	// invalid properties are rejected
+	if err = client.SetBucketProps(proxyurl, PropsBucketName, dfc.BucketProps{Checksum: "md5"}); err == nil {
+		t.Errorf("Setting checksum md5 on bucket %s must fail", PropsBucketName)
+	}
+	if err = client.SetBucketProps(proxyurl, PropsBucketName, dfc.BucketProps{DontEvictTime: "never"}); err == nil {
+		t.Errorf("Setting dont_evict_time \"never\" on bucket %s must fail", PropsBucketName)
+	}
+
+	newprops := dfc.BucketProps{Checksum: dfc.ChecksumNone, LRUEnabled: "false", DontEvictTime: "1h0m0s", AckPut: dfc.AckWhenOnDisk}
+	if err = client.SetBucketProps(proxyurl, PropsBucketName, newprops); err != nil {
+		t.Fatalf("Failed to set bucket %s props: %v", PropsBucketName, err)
+	}
+	waitMetasync(t)
+	if bprops, err = client.HeadBucketProps(proxyurl, PropsBucketName); err != nil {
+		t.Fatalf("Failed to head bucket %s: %v", PropsBucketName, err)
+	}
+	if bprops.Checksum != newprops.Checksum || bprops.LRUEnabled != newprops.LRUEnabled ||
+		bprops.DontEvictTime != newprops.DontEvictTime || bprops.AckPut != newprops.AckPut {
+		t.Errorf("Bucket %s: props %+v, expecting %+v", PropsBucketName, *bprops, newprops)
+	}
+
+	// no checksums
+	objname := PropsStr + "/obj"
+	reader, err := readers.NewInMemReader(size, false)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, PropsBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to put %s/%s: %v", PropsBucketName, objname, err)
+	}
+	r, err := http.Get(proxyurl + "/v1/files/" + PropsBucketName + "/" + objname)
+	if err != nil {
+		t.Fatalf("Failed to get %s/%s: %v", PropsBucketName, objname, err)
+	}
+	n, _ := io.Copy(ioutil.Discard, r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK || n != size {
+		t.Errorf("GET %s/%s: status %d, size %d (expecting %d)", PropsBucketName, objname, r.StatusCode, n, size)
+	}
+	if htype := r.Header.Get(dfc.HeaderDfcChecksumType); htype != "" {
+		t.Errorf("GET %s/%s: unexpected checksum %s (bucket checksum %s)", PropsBucketName, objname, htype, dfc.ChecksumNone)
+	}
+
+	// reset
+	if err = client.SetBucketProps(proxyurl, PropsBucketName, dfc.BucketProps{}); err != nil {
+		t.Fatalf("Failed to reset bucket %s props: %v", PropsBucketName, err)
+	}
+	waitMetasync(t)
+	if bprops, err = client.HeadBucketProps(proxyurl, PropsBucketName); err != nil {
+		t.Fatalf("Failed to head bucket %s: %v", PropsBucketName, err)
+	}
+	if bprops.Checksum != cksumconfig["checksum"].(string) {
+		t.Errorf("Bucket %s: checksum %q after reset, expecting %q", PropsBucketName, bprops.Checksum, cksumconfig["checksum"])
+	}
+}
+
+func regressionVersioning(t *testing.T) {
+	sizes := []int64{1000, 2000, 3000}
+	objname := VersionStr + "/obj"
+	createLocalBucket(httpclient, t, VersionBucketName)
+	defer destroyLocalBucket(httpclient, t, VersionBucketName)
+	if err := client.SetBucketProps(proxyurl, VersionBucketName, dfc.BucketProps{Versioning: "true"}); err != nil {
+		t.Fatalf("Failed to enable versioning: %v", err)
+	}
+	waitMetasync(t)
+	if err := client.SetBucketProps(proxyurl, clibucket, dfc.BucketProps{Versioning: "true"}); err == nil {
+		t.Errorf("Enabling versioning of Cloud bucket %s must fail", clibucket)
+	}
+
+	for _, size := range sizes {
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, VersionBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", VersionBucketName, objname, err)
+		}
+	}
+	// all versions, oldest first
+	listversions := func() (versions []*dfc.BucketEntry, latest *dfc.BucketEntry) {
+		injson, err := json.Marshal(dfc.GetMsg{GetProps: dfc.GetPropsAllVersions + ", " + dfc.GetPropsSize, GetPrefix: VersionStr})
+		if err != nil {
+			t.Fatalf("Failed to marshal GetMsg: %v", err)
+		}
+		list, err := client.ListBucket(proxyurl, VersionBucketName, injson)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", VersionBucketName, err)
+		}
+		for _, entry := range list.Entries {
+			if entry.Name != objname {
+				t.Errorf("Unexpected object %s in bucket %s", entry.Name, VersionBucketName)
+				continue
+			}
+			if entry.IsLatest {
+				if latest != nil {
+					t.Errorf("%s/%s: more than one latest version (%s, %s)", VersionBucketName, objname, latest.Version, entry.Version)
+				}
+				latest = entry
+			}
+			versions = append(versions, entry)
+		}
+		sort.Slice(versions, func(i, j int) bool {
+			vi, _ := strconv.ParseInt(versions[i].Version, 36, 64)
+			vj, _ := strconv.ParseInt(versions[j].Version, 36, 64)
+			return vi < vj
+		})
+		return
+	}
+	versions, latest := listversions()
+	if len(versions) != len(sizes) {
+		t.Fatalf("%s/%s: %d versions, expecting %d", VersionBucketName, objname, len(versions), len(sizes))
+	}
+	for i, entry := range versions {
+		if entry.Size != sizes[i] || entry.DeleteMarker {
+			t.Errorf("%s/%s version %s: size %d, delete marker %t (expecting size %d)",
+				VersionBucketName, objname, entry.Version, entry.Size, entry.DeleteMarker, sizes[i])
+		}
+	}
+	if latest == nil || latest.Version != versions[len(versions)-1].Version {
+		t.Errorf("%s/%s: the latest version must be the last PUT", VersionBucketName, objname)
+	}
+	oldest := versions[0].Version
+	if n, err := client.GetVersion(proxyurl, VersionBucketName, objname, oldest, ioutil.Discard); err != nil || n != sizes[0] {
+		t.Errorf("GET %s/%s version %s: size %d, err: %v (expecting %d)", VersionBucketName, objname, oldest, n, err, sizes[0])
+	}
+
+	// delete adds a delete marker
+	if err := client.Del(proxyurl, VersionBucketName, objname, nil, nil, true); err != nil {
+		t.Fatalf("Failed to delete %s/%s: %v", VersionBucketName, objname, err)
+	}
+	if _, err := client.Get(proxyurl, VersionBucketName, objname, nil, nil, true, false); err == nil {
+		t.Errorf("GET %s/%s must fail once deleted", VersionBucketName, objname)
+	}
+	versions, latest = listversions()
+	if len(versions) != len(sizes)+1 || latest == nil || !latest.DeleteMarker {
+		t.Fatalf("%s/%s: %d versions, expecting %d with the latest delete marker", VersionBucketName, objname, len(versions), len(sizes)+1)
+	}
+	last := versions[len(sizes)-1].Version
+	if n, err := client.GetVersion(proxyurl, VersionBucketName, objname, last, ioutil.Discard); err != nil || n != sizes[len(sizes)-1] {
+		t.Errorf("GET %s/%s version %s: size %d, err: %v", VersionBucketName, objname, last, n, err)
+	}
+
+	// removing the delete marker restores the object
+	if err := client.DelVersion(proxyurl, VersionBucketName, objname, latest.Version); err != nil {
+		t.Fatalf("Failed to remove delete marker %s of %s/%s: %v", latest.Version, VersionBucketName, objname, err)
+	}
+	if n, err := client.Get(proxyurl, VersionBucketName, objname, nil, nil, true, false); err != nil || n != sizes[len(sizes)-1] {
+		t.Errorf("GET %s/%s: size %d, err: %v (expecting %d)", VersionBucketName, objname, n, err, sizes[len(sizes)-1])
+	}
+	// removing an older version for good
+	if err := client.DelVersion(proxyurl, VersionBucketName, objname, oldest); err != nil {
+		t.Fatalf("Failed to remove version %s of %s/%s: %v", oldest, VersionBucketName, objname, err)
+	}
+	if _, err := client.GetVersion(proxyurl, VersionBucketName, objname, oldest, ioutil.Discard); err == nil {
+		t.Errorf("GET %s/%s version %s must fail once removed", VersionBucketName, objname, oldest)
+	}
+	if versions, _ = listversions(); len(versions) != len(sizes)-1 {
+		t.Errorf("%s/%s: %d versions, expecting %d", VersionBucketName, objname, len(versions), len(sizes)-1)
+	}
+}
+
+// older versions and delete markers follow the objects to their replica holders
+func regressionVersionRebalance(t *testing.T) {
+	const (
+		numobjs = 10
+		copies  = 2
+	)
+	sizes := []int64{1000, 2000}
+	smap := getClusterMap(httpclient, t)
+	l := len(smap.Smap)
+	if l < copies+1 {
+		t.Skipf("Version rebalance requires at least %d targets, have %d", copies+1, l)
+	}
+	createLocalBucket(httpclient, t, VersionBucketName)
+	defer destroyLocalBucket(httpclient, t, VersionBucketName)
+	if err := client.SetBucketProps(proxyurl, VersionBucketName, dfc.BucketProps{Versioning: "true"}); err != nil {
+		t.Fatalf("Failed to enable versioning: %v", err)
+	}
+	if err := client.SetCopies(proxyurl, VersionBucketName, copies); err != nil {
+		t.Fatalf("Failed to set the number of copies: %v", err)
+	}
+	waitMetasync(t)
+
+	sids := make([]string, 0, l)
+	for sid := range smap.Smap {
+		sids = append(sids, sid)
+	}
+	unregisterTarget(sids[0], t)
+	objnames := make([]string, numobjs)
+	for i := range objnames {
+		objnames[i] = fmt.Sprintf("%s/rebalance%d", VersionStr, i)
+		for _, size := range sizes {
+			reader, err := readers.NewInMemReader(size, true)
+			if err != nil {
+				t.Fatalf("Failed to create reader: %v", err)
+			}
+			if err = client.Put(proxyurl, reader, VersionBucketName, objnames[i], true); err != nil {
+				t.Fatalf("Failed to put %s/%s: %v", VersionBucketName, objnames[i], err)
+			}
+		}
+		// every other object is deleted
+		if i%2 == 0 {
+			if err := client.Del(proxyurl, VersionBucketName, objnames[i], nil, nil, true); err != nil {
+				t.Fatalf("Failed to delete %s/%s: %v", VersionBucketName, objnames[i], err)
+			}
+		}
+	}
+	// all versions of all objects are listed, and the older ones can be read
+	checkversions := func() {
+		injson, err := json.Marshal(dfc.GetMsg{GetProps: dfc.GetPropsAllVersions + ", " + dfc.GetPropsSize, GetPrefix: VersionStr})
+		if err != nil {
+			t.Fatalf("Failed to marshal GetMsg: %v", err)
+		}
+		list, err := client.ListBucket(proxyurl, VersionBucketName, injson)
+		if err != nil {
+			t.Fatalf("Failed to list %s: %v", VersionBucketName, err)
+		}
+		older := make(map[string]map[string]*dfc.BucketEntry, numobjs) // name => version => entry
+		markers := make(map[string]bool, numobjs)
+		for _, entry := range list.Entries {
+			if entry.DeleteMarker {
+				markers[entry.Name] = true
+			} else if !entry.IsLatest {
+				if older[entry.Name] == nil {
+					older[entry.Name] = make(map[string]*dfc.BucketEntry, len(sizes))
+				}
+				older[entry.Name][entry.Version] = entry
+			}
+		}
+		for i, objname := range objnames {
+			if markers[objname] != (i%2 == 0) {
+				t.Errorf("%s/%s: delete marker %t", VersionBucketName, objname, markers[objname])
+			}
+			expected := len(sizes) - 1
+			if i%2 == 0 {
+				expected = len(sizes)
+			}
+			if len(older[objname]) != expected {
+				t.Errorf("%s/%s: %d older versions, expecting %d", VersionBucketName, objname, len(older[objname]), expected)
+				continue
+			}
+			for _, entry := range older[objname] {
+				if n, err := client.GetVersion(proxyurl, VersionBucketName, objname, entry.Version, ioutil.Discard); err != nil || n != entry.Size {
+					t.Errorf("GET %s/%s version %s: size %d, err: %v (expecting %d)", VersionBucketName, objname, entry.Version, n, err, entry.Size)
+				}
+			}
+		}
+	}
+	checkversions()
+
+	// the returning target becomes the holder of some of the objects
+	registerTarget(sids[0], &smap, t)
+	waitProgressBar("Rebalance: ", time.Second*10)
+	checkversions()
+
+	// the replica holders take over
+	unregisterTarget(sids[1], t)
+	waitProgressBar("Rebalance: ", time.Second*10)
+	checkversions()
+	registerTarget(sids[1], &smap, t)
+	waitProgressBar("Rebalance: ", time.Second*10)
+}
+
+func regressionUserMeta(t *testing.T) {
+	const size = int64(4 * 1024)
+	var (
+		usermeta = map[string]string{"color": "blue", "Owner": "qa"}
+		expected = map[string]string{"color": "blue", "owner": "qa"}
+	)
+	createLocalBucket(httpclient, t, UserMetaBucketName)
+	defer destroyLocalBucket(httpclient, t, UserMetaBucketName)
+	waitMetasync(t)
+
+	checkmeta := func(bucket, objname string, expected map[string]string) {
+		meta, err := client.HeadObject(proxyurl, bucket, objname)
+		if err != nil {
+			t.Fatalf("Failed to head %s/%s: %v", bucket, objname, err)
+		}
+		if len(meta.UserMeta) != len(expected) {
+			t.Errorf("HEAD %s/%s: user metadata %v, expecting %v", bucket, objname, meta.UserMeta, expected)
+		}
+		for k, v := range expected {
+			if meta.UserMeta[k] != v {
+				t.Errorf("HEAD %s/%s: %s=%q, expecting %q", bucket, objname, k, meta.UserMeta[k], v)
+			}
+		}
+		r, err := http.Get(proxyurl + "/v1/files/" + bucket + "/" + objname)
+		if err != nil {
+			t.Fatalf("Failed to get %s/%s: %v", bucket, objname, err)
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+		for k, v := range expected {
+			if hv := r.Header.Get(dfc.HeaderDfcMetaPrefix + k); hv != v {
+				t.Errorf("GET %s/%s: %s=%q, expecting %q", bucket, objname, k, hv, v)
+			}
+		}
+	}
+	putmeta := func(bucket, objname string, usermeta map[string]string) {
+		reader, err := readers.NewInMemReader(size, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.PutWithMeta(proxyurl, reader, bucket, objname, usermeta, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", bucket, objname, err)
+		}
+	}
+
+	// local bucket
+	objname := UserMetaStr + "/obj"
+	putmeta(UserMetaBucketName, objname, usermeta)
+	checkmeta(UserMetaBucketName, objname, expected)
+	injson, err := json.Marshal(dfc.GetMsg{GetProps: dfc.GetPropsUserMeta, GetPrefix: UserMetaStr})
+	if err != nil {
+		t.Fatalf("Failed to marshal GetMsg: %v", err)
+	}
+	list, err := client.ListBucket(proxyurl, UserMetaBucketName, injson)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", UserMetaBucketName, err)
+	}
+	if len(list.Entries) != 1 || list.Entries[0].UserMeta["color"] != "blue" {
+		t.Errorf("List %s: unexpected entries %+v", UserMetaBucketName, list.Entries)
+	}
+	// PUT without metadata replaces it
+	putmeta(UserMetaBucketName, objname, nil)
+	checkmeta(UserMetaBucketName, objname, nil)
+
+	// Cloud bucket: written through, and returned once evicted
+	cloudobj := UserMetaStr + "/cloudobj"
+	putmeta(clibucket, cloudobj, usermeta)
+	defer client.Del(proxyurl, clibucket, cloudobj, nil, nil, true)
+	if err = client.Evict(proxyurl, clibucket, cloudobj); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, cloudobj, err)
+	}
+	checkmeta(clibucket, cloudobj, expected) // HEAD in the Cloud, then cold GET
+	checkmeta(clibucket, cloudobj, expected) // cached
+}
+
+func regressionListPages(t *testing.T) {
+	const (
+		numobjs  = 23
+		pagesize = 5
+	)
+	createLocalBucket(httpclient, t, ListPagesBucketName)
+	defer destroyLocalBucket(httpclient, t, ListPagesBucketName)
+	waitMetasync(t)
+
+	objnames := make([]string, 0, numobjs)
+	for i := 0; i < numobjs; i++ {
+		objname := fmt.Sprintf("%s/%02d/obj", ListPagesStr, numobjs-i)
+		reader, err := readers.NewInMemReader(int64(i+1)*128, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, ListPagesBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", ListPagesBucketName, objname, err)
+		}
+		objnames = append(objnames, objname)
+	}
+	sort.Strings(objnames)
+
+	// all pages, in name order
+	listpages := func(list func(injson []byte) (*dfc.BucketList, error), msg *dfc.GetMsg) []string {
+		names := make([]string, 0, numobjs)
+		for pages := 0; ; pages++ {
+			if pages > numobjs {
+				t.Fatalf("Too many pages")
+			}
+			injson, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatalf("Failed to marshal GetMsg: %v", err)
+			}
+			page, err := list(injson)
+			if err != nil {
+				t.Fatalf("Failed to list page %d: %v", pages, err)
+			}
+			if len(page.Entries) > msg.GetPageSize {
+				t.Errorf("Page %d: %d entries, page size %d", pages, len(page.Entries), msg.GetPageSize)
+			}
+			for _, entry := range page.Entries {
+				names = append(names, entry.Name)
+			}
+			if page.PageMarker == "" {
+				return names
+			}
+			msg.GetPageMarker = page.PageMarker
+		}
+	}
+	checknames := func(names, expected []string) {
+		if len(names) != len(expected) {
+			t.Fatalf("Listed %d objects, expecting %d: %v", len(names), len(expected), names)
+		}
+		for i := range names {
+			if names[i] != expected[i] {
+				t.Errorf("Listed %q at %d, expecting %q", names[i], i, expected[i])
+			}
+		}
+	}
+	listlocal := func(injson []byte) (*dfc.BucketList, error) {
+		return client.ListBucket(proxyurl, ListPagesBucketName, injson)
+	}
+	checknames(listpages(listlocal, &dfc.GetMsg{GetPrefix: ListPagesStr, GetPageSize: pagesize}), objnames)
+
+	// sorting implies the property
+	injson, _ := json.Marshal(dfc.GetMsg{GetPrefix: ListPagesStr, GetSort: dfc.GetSortDes + ", " + dfc.GetSortSize})
+	list, err := client.ListBucket(proxyurl, ListPagesBucketName, injson)
+	if err != nil {
+		t.Fatalf("Failed to list %s: %v", ListPagesBucketName, err)
+	}
+	if len(list.Entries) != numobjs {
+		t.Fatalf("Listed %d objects, expecting %d", len(list.Entries), numobjs)
+	}
+	for i := 1; i < len(list.Entries); i++ {
+		if list.Entries[i-1].Size <= list.Entries[i].Size {
+			t.Errorf("Not sorted by size, descending: %s (%d) before %s (%d)", list.Entries[i-1].Name,
+				list.Entries[i-1].Size, list.Entries[i].Name, list.Entries[i].Size)
+		}
+	}
+	injson, _ = json.Marshal(dfc.GetMsg{GetSort: "sideways"})
+	if _, err = client.ListBucket(proxyurl, ListPagesBucketName, injson); err == nil {
+		t.Errorf("Listing with invalid sort succeeded")
+	}
+
+	// cached objects of a Cloud bucket
+	cloudnames := objnames[:7]
+	for _, objname := range cloudnames {
+		reader, err := readers.NewInMemReader(1024, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", clibucket, objname, err)
+		}
+		defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	}
+	listcached := func(injson []byte) (*dfc.BucketList, error) {
+		return client.ListCachedObjects(proxyurl, clibucket, injson)
+	}
+	checknames(listpages(listcached, &dfc.GetMsg{GetPrefix: ListPagesStr, GetPageSize: 3}), cloudnames)
+	if err = client.Evict(proxyurl, clibucket, cloudnames[0]); err != nil {
+		t.Fatalf("Failed to evict %s/%s: %v", clibucket, cloudnames[0], err)
+	}
+	checknames(listpages(listcached, &dfc.GetMsg{GetPrefix: ListPagesStr, GetPageSize: 3}), cloudnames[1:])
+}
+
+func regressionMetrics(t *testing.T) {
+	var (
+		sample  = regexp.MustCompile(`^dfc_[a-z_]+\{daemon_id="[^"]+",role="(proxy|target)"(,[a-z_]+="[^"]*")*\} -?[0-9]+$`)
+		scrape  func(url string) string
+		smap, _ = client.GetClusterMap(proxyurl)
+	)
+	if smap == nil {
+		t.Fatalf("Failed to get cluster map")
+	}
+	scrape = func(url string) string {
+		r, err := http.Get(url + "/metrics")
+		if err != nil {
+			t.Fatalf("Failed to scrape %s: %v", url, err)
+		}
+		defer r.Body.Close()
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil || r.StatusCode != http.StatusOK {
+			t.Fatalf("Failed to scrape %s: status %d, err: %v", url, r.StatusCode, err)
+		}
+		if ct := r.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("%s/metrics: unexpected Content-Type %q", url, ct)
+		}
+		text := string(b)
+		for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+			if !strings.HasPrefix(line, "#") && !sample.MatchString(line) {
+				t.Errorf("%s/metrics: invalid sample %q", url, line)
+			}
+		}
+		return text
+	}
+
+	text := scrape(proxyurl)
+	for _, name := range []string{"dfc_numget_total", "dfc_smap_version", "dfc_lbmap_version", "dfc_proxy_primary"} {
+		if !strings.Contains(text, "\n"+name+"{") {
+			t.Errorf("Proxy metrics: missing %s", name)
+		}
+	}
+	if !strings.Contains(text, fmt.Sprintf("dfc_smap_version{daemon_id=%q,role=\"proxy\"} %d", smap.ProxySI.DaemonID, smap.Version)) {
+		t.Errorf("Proxy metrics: unexpected Smap version (expecting %d)", smap.Version)
+	}
+	for _, si := range smap.Smap {
+		text = scrape(si.DirectURL)
+		for _, name := range []string{"dfc_numcoldget_total", "dfc_fs_used_bytes", "dfc_fs_avail_bytes", "dfc_smap_version"} {
+			if !strings.Contains(text, "\n"+name+"{daemon_id=\""+si.DaemonID+"\",role=\"target\"") {
+				t.Errorf("Target %s metrics: missing %s", si.DaemonID, name)
+			}
+		}
+		if !strings.Contains(text, ",mountpath=") {
+			t.Errorf("Target %s metrics: missing mountpath labels", si.DaemonID)
+		}
+	}
+}
+
+func regressionLatency(t *testing.T) {
+	const numobjs = 10
+	latencies := func(v interface{}) map[string]dfc.LatencyStats {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("Failed to marshal latencies: %v", err)
+		}
+		l := make(map[string]dfc.LatencyStats)
+		if err = json.Unmarshal(b, &l); err != nil {
+			t.Fatalf("Failed to unmarshal latencies %s: %v", string(b), err)
+		}
+		return l
+	}
+	before := latencies(getClusterStats(httpclient, t).Latency)
+
+	createLocalBucket(httpclient, t, LatencyBucketName)
+	defer destroyLocalBucket(httpclient, t, LatencyBucketName)
+	waitMetasync(t)
+	for i := 0; i < numobjs; i++ {
+		objname := fmt.Sprintf("obj%d", i)
+		reader, err := readers.NewInMemReader(16*1024, true)
+		if err != nil {
+			t.Fatalf("Failed to create reader: %v", err)
+		}
+		if err = client.Put(proxyurl, reader, LatencyBucketName, objname, true); err != nil {
+			t.Fatalf("Failed to put %s/%s: %v", LatencyBucketName, objname, err)
+		}
+		if _, err = client.Get(proxyurl, LatencyBucketName, objname, nil, nil, true, false); err != nil {
+			t.Fatalf("Failed to get %s/%s: %v", LatencyBucketName, objname, err)
+		}
+	}
+
+	stats := getClusterStats(httpclient, t)
+	after := latencies(stats.Latency)
+	for _, op := range []string{"put", "get"} {
+		l := after[op]
+		if l.Count-before[op].Count < numobjs {
+			t.Errorf("%s latency: count %d, expecting at least %d more than %d", op, l.Count, numobjs, before[op].Count)
+		}
+		if l.P50 <= 0 || l.P50 > l.P90 || l.P90 > l.P99 || l.P99 > l.Max {
+			t.Errorf("%s latency: invalid percentiles %+v", op, l)
+		}
+		var total int64
+		for _, n := range l.Buckets {
+			total += n
+		}
+		if total != l.Count {
+			t.Errorf("%s latency: buckets add up to %d, count %d", op, total, l.Count)
+		}
+		// cluster-wide = sum over targets
+		total = 0
+		for id, tstats := range stats.Target {
+			tl := latencies(tstats.Latency)[op]
+			if tl.Max > l.Max {
+				t.Errorf("%s latency: target %s max %v exceeds the cluster max %v", op, id, tl.Max, l.Max)
+			}
+			total += tl.Count
+		}
+		if total != l.Count {
+			t.Errorf("%s latency: targets count %d, cluster count %d", op, total, l.Count)
+		}
+	}
+}
+
+func regressionTrace(t *testing.T) {
+	type otlpspan struct {
+		TraceID      string `json:"traceId"`
+		SpanID       string `json:"spanId"`
+		ParentSpanID string `json:"parentSpanId"`
+		Name         string `json:"name"`
+		Kind         int    `json:"kind"`
+	}
+	type otlpexport struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []otlpspan `json:"spans"`
+			} `json:"scopeSpans"`
+		} `json:"resourceSpans"`
+	}
+	var (
+		objname  = TraceStr + "/obj"
+		traceid  = fmt.Sprintf("%016x%016x", rand.Uint64(), rand.Uint64())
+		parentid = fmt.Sprintf("%016x", rand.Uint64())
+		files    = make(map[string]bool)
+	)
+	tracefile := func(url string) string {
+		trace, _ := getConfig(url+RestAPIDaemonSuffix, httpclient, t)["trace"].(map[string]interface{})
+		if enabled, _ := trace["enabled"].(bool); !enabled {
+			return ""
+		}
+		file, _ := trace["file"].(string)
+		return file
+	}
+	if file := tracefile(proxyurl); file == "" {
+		t.Skip("Tracing to a file is not enabled")
+	} else {
+		files[file] = true
+	}
+	smap, err := client.GetClusterMap(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get cluster map: %v", err)
+	}
+	for _, si := range smap.Smap {
+		if file := tracefile(si.DirectURL); file != "" {
+			files[file] = true
+		}
+	}
+
+	// cold GET: client => proxy => (redirect) => target => Cloud
+	reader, err := readers.NewInMemReader(16*1024, true)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, clibucket, objname, true); err != nil {
+		t.Fatalf("Failed to put %s/%s: %v", clibucket, objname, err)
+	}
+	defer client.Del(proxyurl, clibucket, objname, nil, nil, true)
+	server, err := client.HeadBucket(proxyurl, clibucket)
+	if err != nil {
+		t.Fatalf("Failed to head bucket %s: %v", clibucket, err)
+	}
+	islocal := server == "dfc"
+	if !islocal {
+		if err = client.Evict(proxyurl, clibucket, objname); err != nil {
+			t.Fatalf("Failed to evict %s/%s: %v", clibucket, objname, err)
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, proxyurl+"/v1/files/"+clibucket+"/"+objname, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set(dfc.HeaderTraceParent, "00-"+traceid+"-"+parentid+"-01")
+	r, err := httpclient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to get %s/%s: %v", clibucket, objname, err)
+	}
+	ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		t.Fatalf("Failed to get %s/%s: %s", clibucket, objname, r.Status)
+	}
+	if reqid := r.Header.Get(dfc.HeaderDfcRequestID); reqid != traceid {
+		t.Errorf("Request ID %q, expecting the trace ID %q", reqid, traceid)
+	}
+	// an invalid trace context starts a new trace
+	req.Header.Set(dfc.HeaderTraceParent, "00-"+traceid+"-0000000000000000-01")
+	if r, err = httpclient.Do(req); err != nil {
+		t.Fatalf("Failed to get %s/%s: %v", clibucket, objname, err)
+	}
+	ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if reqid := r.Header.Get(dfc.HeaderDfcRequestID); len(reqid) != 32 || reqid == traceid {
+		t.Errorf("Request ID %q, expecting a new trace", reqid)
+	}
+
+	// the spans get exported within 5s
+	time.Sleep(7 * time.Second)
+	spans := make(map[string]otlpspan)
+	for file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatalf("Failed to read spans from %s: %v", file, err)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+			export := otlpexport{}
+			if err = json.Unmarshal([]byte(line), &export); err != nil {
+				t.Fatalf("Failed to unmarshal spans from %s: %v", file, err)
+			}
+			for _, rs := range export.ResourceSpans {
+				for _, ss := range rs.ScopeSpans {
+					for _, span := range ss.Spans {
+						if span.TraceID == traceid {
+							spans[span.SpanID] = span
+						}
+					}
+				}
+			}
+		}
+	}
+	child := func(parentid, name string) otlpspan {
+		for _, span := range spans {
+			if span.ParentSpanID == parentid && span.Name == name {
+				return span
+			}
+		}
+		t.Fatalf("Trace %s: no span %q with parent %s among %+v", traceid, name, parentid, spans)
+		return otlpspan{}
+	}
+	proxyspan := child(parentid, "GET /v1/files")
+	targetspan := child(proxyspan.SpanID, "GET /v1/files")
+	if proxyspan.Kind != 2 || targetspan.Kind != 2 {
+		t.Errorf("Expecting server spans, got %+v and %+v", proxyspan, targetspan)
+	}
+	if !islocal {
+		if cloudspan := child(targetspan.SpanID, "cloud.getobj"); cloudspan.Kind != 3 {
+			t.Errorf("Expecting a client span, got %+v", cloudspan)
+		}
+	}
+}
+
+func regressionAuth(t *testing.T) {
+	var (
+		objname = AuthStr + "/obj"
+		objurl  = proxyurl + "/v1/files/" + AuthBucketName + "/" + objname
+		plain   = &http.Client{Transport: basetransport} // carries explicit tokens only
+		norelo  = &http.Client{Transport: basetransport, CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}}
+		expires = time.Now().Add(time.Hour).Unix()
+	)
+	if authsecret == "" {
+		t.Skip("Auth secret is not known (see -authsecret)")
+	}
+	auth, _ := getConfig(proxyurl+RestAPIDaemonSuffix, httpclient, t)["auth"].(map[string]interface{})
+	if enabled, _ := auth["enabled"].(bool); !enabled {
+		t.Skip("Authentication is not enabled")
+	}
+	if secret, _ := auth["secret"].(string); secret != "" {
+		t.Errorf("GET config: auth secret is not redacted")
+	}
+	sign := func(secret string, token *dfc.AuthToken) string {
+		signed, err := dfc.SignToken(secret, token)
+		if err != nil {
+			t.Fatalf("Failed to sign token: %v", err)
+		}
+		return signed
+	}
+	do := func(c *http.Client, method, url, token string, body []byte) *http.Response {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		if token != "" {
+			req.Header.Set(dfc.HeaderAuthorization, "Bearer "+token)
+		}
+		r, err := c.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, url, err)
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+		return r
+	}
+	expect := func(status int, c *http.Client, method, url, token string, body []byte) {
+		if r := do(c, method, url, token, body); r.StatusCode != status {
+			t.Errorf("%s %s: status %d, expecting %d", method, url, r.StatusCode, status)
+		}
+	}
+	actmsg := func(msg dfc.ActionMsg) []byte {
+		b, err := json.Marshal(msg)
+		if err != nil {
+			t.Fatalf("Failed to marshal %v: %v", msg, err)
+		}
+		return b
+	}
+
+	createLocalBucket(httpclient, t, AuthBucketName)
+	defer destroyLocalBucket(httpclient, t, AuthBucketName)
+	waitMetasync(t)
+	reader, err := readers.NewInMemReader(1024, true)
+	if err != nil {
+		t.Fatalf("Failed to create reader: %v", err)
+	}
+	if err = client.Put(proxyurl, reader, AuthBucketName, objname, true); err != nil {
+		t.Fatalf("Failed to put %s/%s: %v", AuthBucketName, objname, err)
+	}
+
+	// authentication
+	rtoken := sign(authsecret, &dfc.AuthToken{User: "reader", Expires: expires, Buckets: map[string]string{AuthBucketName: dfc.AuthRead}})
+	expect(http.StatusOK, plain, http.MethodGet, objurl, rtoken, nil)
+	expect(http.StatusUnauthorized, plain, http.MethodGet, objurl, "", nil)
+	expect(http.StatusUnauthorized, plain, http.MethodGet, objurl, rtoken[:len(rtoken)-2]+"xx", nil)
+	expect(http.StatusUnauthorized, plain, http.MethodGet, objurl,
+		sign(authsecret+"x", &dfc.AuthToken{User: "forged", Expires: expires, Admin: true}), nil)
+	expect(http.StatusUnauthorized, plain, http.MethodGet, objurl,
+		sign(authsecret, &dfc.AuthToken{User: "expired", Expires: time.Now().Add(-time.Minute).Unix(), Admin: true}), nil)
+	creds, err := json.Marshal(dfc.TokenMsg{User: "nobody", Password: "invalid"})
+	if err != nil {
+		t.Fatalf("Failed to marshal credentials: %v", err)
+	}
+	expect(http.StatusUnauthorized, plain, http.MethodPost, proxyurl+"/v1/"+dfc.Rtokens, "", creds)
+
+	// per-bucket permissions
+	wtoken := sign(authsecret, &dfc.AuthToken{User: "writer", Expires: expires, Buckets: map[string]string{"*": dfc.AuthWrite}})
+	expect(http.StatusForbidden, plain, http.MethodPut, objurl, rtoken, []byte("data"))
+	expect(http.StatusOK, plain, http.MethodPut, objurl, wtoken, []byte("data"))
+	expect(http.StatusOK, plain, http.MethodGet, objurl, wtoken, nil)
+	expect(http.StatusForbidden, plain, http.MethodDelete, proxyurl+"/v1/files/"+AuthBucketName, wtoken,
+		actmsg(dfc.ActionMsg{Action: dfc.ActDestroyLB}))
+	expect(http.StatusForbidden, plain, http.MethodPost, proxyurl+"/v1/files/"+AuthBucketName, wtoken,
+		actmsg(dfc.ActionMsg{Action: dfc.ActSetCopies, Value: float64(2)}))
+	expect(http.StatusForbidden, plain, http.MethodPost, proxyurl+"/v1/files/"+AuthBucketName, rtoken,
+		actmsg(dfc.ActionMsg{Action: dfc.ActCopy, Name: AuthBucketName + "copy", Value: map[string]interface{}{"objnames": []string{objname}}}))
+
+	// cluster actions: admin only
+	expect(http.StatusForbidden, plain, http.MethodPut, proxyurl+"/v1/cluster", wtoken,
+		actmsg(dfc.ActionMsg{Action: dfc.ActSetConfig, Name: "stats_time", Value: "1s"}))
+	expect(http.StatusForbidden, plain, http.MethodPut, proxyurl+"/v1/daemon", wtoken,
+		actmsg(dfc.ActionMsg{Action: dfc.ActShutdown}))
+
+	// targets: redirected requests only
+	smap, err := client.GetClusterMap(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get cluster map: %v", err)
+	}
+	atoken := sign(authsecret, &dfc.AuthToken{User: "admin", Expires: expires, Admin: true})
+	for _, si := range smap.Smap {
+		expect(http.StatusForbidden, plain, http.MethodGet, si.DirectURL+"/v1/files/"+AuthBucketName+"/"+objname, atoken, nil)
+		expect(http.StatusForbidden, plain, http.MethodPut, si.DirectURL+"/v1/daemon", wtoken,
+			actmsg(dfc.ActionMsg{Action: dfc.ActSetConfig, Name: "stats_time", Value: "1s"}))
+	}
+	r := do(norelo, http.MethodGet, objurl, rtoken, nil)
+	location := r.Header.Get("Location")
+	if location == "" || r.StatusCode >= http.StatusBadRequest {
+		t.Fatalf("GET %s: status %d, expecting redirect", objurl, r.StatusCode)
+	}
+	expect(http.StatusOK, plain, http.MethodGet, location, "", nil)
+	expect(http.StatusForbidden, plain, http.MethodHead, location, "", nil)
+	expect(http.StatusForbidden, plain, http.MethodGet, strings.Replace(location, objname, objname+"x", 1), "", nil)
+	expect(http.StatusForbidden, plain, http.MethodGet, location+"&"+dfc.ParamReplica+"=true", "", nil)
+
+	// the proxy does not pass on (and sign) the intra-cluster parameters
+	var sid string
+	for sid = range smap.Smap {
+		break
+	}
+	peerurl := fmt.Sprintf("%s?%s=%s&%s=%s&%s=true&%s=true&%s=true", objurl, dfc.ParamFromID, sid,
+		dfc.ParamToID, sid, dfc.ParamReplica, dfc.ParamECSlice, dfc.ParamCopy)
+	r = do(norelo, http.MethodPut, peerurl, wtoken, nil)
+	location = r.Header.Get("Location")
+	if location == "" || r.StatusCode >= http.StatusBadRequest {
+		t.Fatalf("PUT %s: status %d, expecting redirect", peerurl, r.StatusCode)
+	}
+	for _, param := range []string{dfc.ParamFromID, dfc.ParamToID, dfc.ParamReplica, dfc.ParamECSlice, dfc.ParamCopy} {
+		if strings.Contains(location, param+"=") {
+			t.Errorf("PUT %s: redirected to %s", peerurl, location)
+		}
+	}
+	expect(http.StatusOK, plain, http.MethodPut, location, "", []byte("data"))
+}
+
+func regressionTLS(t *testing.T) {
+	if !strings.HasPrefix(proxyurl, "https://") {
+		t.Skip("The cluster is not HTTPS (see -proxyurl and -cacert)")
+	}
+	tlsconf, _ := getConfig(proxyurl+RestAPIDaemonSuffix, httpclient, t)["tls"].(map[string]interface{})
+	smap, err := client.GetClusterMap(proxyurl)
+	if err != nil {
+		t.Fatalf("Failed to get cluster map: %v", err)
+	}
+	for sid, si := range smap.Smap {
+		if !strings.HasPrefix(si.DirectURL, "https://") {
+			t.Errorf("Target %s: URL %s, expecting HTTPS", sid, si.DirectURL)
+		}
+	}
+	// the cluster's certificates do not verify without the cluster CA
+	if r, err := (&http.Client{}).Get(proxyurl + "/v1/cluster"); err == nil {
+		r.Body.Close()
+		t.Errorf("GET %s: expecting certificate verification error, got status %d", proxyurl, r.StatusCode)
+	}
+	// plain HTTP
+	if r, err := httpclient.Get("http://" + strings.TrimPrefix(proxyurl, "https://") + "/v1/cluster"); err == nil {
+		r.Body.Close()
+		if r.StatusCode != http.StatusBadRequest {
+			t.Errorf("HTTP request to HTTPS proxy: status %d, expecting %d", r.StatusCode, http.StatusBadRequest)
+		}
+	}
+	if mutual, _ := tlsconf["mutual"].(bool); !mutual {
+		return
+	}
+	// intra-cluster requests without the cluster's client certificate
+	expect := func(method, url string, body []byte) {
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		r, err := httpclient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, url, err)
+		}
+		io.Copy(ioutil.Discard, r.Body)
+		r.Body.Close()
+		if r.StatusCode != http.StatusForbidden {
+			t.Errorf("%s %s: status %d, expecting %d", method, url, r.StatusCode, http.StatusForbidden)
+		}
+	}
+	for sid, si := range smap.Smap {
+		jsbytes, err := json.Marshal(si)
+		if err != nil {
+			t.Fatalf("Failed to marshal %+v: %v", si, err)
+		}
+		expect(http.MethodPost, proxyurl+"/v1/cluster/keepalive", jsbytes)
+		expect(http.MethodPut, si.DirectURL+"/v1/daemon/"+dfc.Rsyncsmap, []byte("{}"))
+		expect(http.MethodPut, fmt.Sprintf("%s/v1/files/%s/%s?%s=%s&%s=%s", si.DirectURL, TestLocalBucketName, "obj",
+			dfc.ParamFromID, sid, dfc.ParamToID, sid), []byte("data"))
+		for _, param := range []string{dfc.ParamReplica, dfc.ParamECSlice} {
+			url := fmt.Sprintf("%s/v1/files/%s/%s?%s=true", si.DirectURL, TestLocalBucketName, "obj", param)
+			for _, method := range []string{http.MethodPut, http.MethodGet, http.MethodHead, http.MethodDelete} {
+				expect(method, url, []byte("data"))
+			}
+		}
+	}
+}
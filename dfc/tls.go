@@ -0,0 +1,100 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+//======
+//
+// TLS: proxies and targets serve HTTPS with the configured certificate, and verify
+// each other's certificates with the cluster CA. With mutual TLS the daemons also
+// present their certificates when calling each other - the certificates then must be
+// valid for both server and client authentication - and the intra-cluster only
+// requests (registration and keepalive, metadata sync, and object transfers
+// between targets) are rejected unless they come with a verified certificate
+//
+//======
+
+// newtlsconfig returns the server's and the intra-cluster client's TLS configurations
+func newtlsconfig(conf *tlsconfig) (server, client *tls.Config, err error) {
+	cert, err := tls.LoadX509KeyPair(conf.Certificate, conf.Key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to load certificate %s (key %s), err: %v", conf.Certificate, conf.Key, err)
+	}
+	server = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	client = &tls.Config{MinVersion: tls.VersionTLS12}
+	if conf.CA == "" {
+		return
+	}
+	pool, err := loadcerts(conf.CA)
+	if err != nil {
+		return nil, nil, err
+	}
+	client.RootCAs = pool
+	if conf.Mutual {
+		// clients outside the cluster do not have certificates - hence, "if given"
+		server.ClientAuth = tls.VerifyClientCertIfGiven
+		server.ClientCAs = pool
+		client.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+func loadcerts(pemfile string) (*x509.CertPool, error) {
+	b, err := ioutil.ReadFile(pemfile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CA %s, err: %v", pemfile, err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("Invalid CA %s: no PEM-encoded certificates", pemfile)
+	}
+	return pool, nil
+}
+
+// peeronly returns true for the requests that only the cluster's daemons make:
+//   POST /v1/cluster[/keepalive], POST /v1/cluster/proxy[/keepalive] - registration and keepalive
+//   PUT /v1/daemon/(syncsmap|rebalance|localbuckets) - metadata sync
+//   PUT /v1/files/bucket/object?from_id=... - object transfer between targets
+//   /v1/files/bucket/object?replica=true|ecslice=true - replicas and erasure coded slices (any method)
+func peeronly(r *http.Request) bool {
+	apitems := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(apitems) < 2 || apitems[0] != Rversion {
+		return false
+	}
+	switch apitems[1] {
+	case Rcluster:
+		return r.Method == http.MethodPost
+	case Rdaemon:
+		return r.Method == http.MethodPut && len(apitems) > 2 &&
+			(apitems[2] == Rsyncsmap || apitems[2] == Rebalance || apitems[2] == Rsynclb)
+	case Rfiles:
+		query := r.URL.Query()
+		if query.Get(ParamReplica) == "true" || query.Get(ParamECSlice) == "true" {
+			return true
+		}
+		return r.Method == http.MethodPut && query.Get(ParamFromID) != ""
+	}
+	return false
+}
+
+// peerhandler rejects the intra-cluster only requests that come without a verified certificate
+func (h *httprunner) peerhandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if peeronly(r) && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+			h.invalmsghdlr(w, r, "intra-cluster request without a verified client certificate", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
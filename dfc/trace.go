@@ -0,0 +1,542 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// distributed tracing: W3C trace context (the traceparent header) propagated across
+// client => proxy => (redirect) => target => target/Cloud, and spans exported in the
+// OpenTelemetry OTLP/JSON format to a collector (OTLP/HTTP) and/or a local file.
+// The trace ID doubles as the request ID: returned in the HeaderDfcRequestID response
+// header and logged with the request's errors.
+// Intra-cluster requests that are not part of a traced request (keepalive, metasync,
+// and such) carry a non-sampled context and are never exported
+//
+//======
+
+const (
+	traceBatchSize = 512             // spans per export
+	traceFlushTime = 5 * time.Second // max time a span waits to be exported
+	traceQueueSize = 4096            // spans beyond that get dropped
+	traceVersion   = "00"            // traceparent version
+	traceSampled   = 0x01            // traceparent flags
+)
+
+// OTLP span kinds
+const (
+	spanInternal = 1
+	spanServer   = 2
+	spanClient   = 3
+)
+
+const spanStatusError = 2 // OTLP status code
+
+type spankey struct{}
+
+type tracectx struct {
+	traceID [16]byte
+	spanID  [8]byte
+	sampled bool
+}
+
+type span struct {
+	tracectx
+	y        *tracerunner
+	parentID [8]byte
+	name     string
+	kind     int
+	start    time.Time
+	attrs    []otlpkv
+}
+
+type tracerunner struct {
+	namedrunner
+	spanch   chan *otlpspan
+	chstop   chan struct{}
+	sample   float64 // fraction of the new traces that get exported
+	resource otlpresource
+	client   *http.Client
+	dropped  int64
+}
+
+//
+// OTLP/JSON (see opentelemetry-proto: ExportTraceServiceRequest)
+//
+type otlpvalue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // int64 is a string in OTLP/JSON
+}
+
+type otlpkv struct {
+	Key   string    `json:"key"`
+	Value otlpvalue `json:"value"`
+}
+
+type otlpstatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpspan struct {
+	TraceID      string      `json:"traceId"`
+	SpanID       string      `json:"spanId"`
+	ParentSpanID string      `json:"parentSpanId,omitempty"`
+	Name         string      `json:"name"`
+	Kind         int         `json:"kind"`
+	Start        string      `json:"startTimeUnixNano"`
+	End          string      `json:"endTimeUnixNano"`
+	Attributes   []otlpkv    `json:"attributes,omitempty"`
+	Status       *otlpstatus `json:"status,omitempty"`
+}
+
+type otlpresource struct {
+	Attributes []otlpkv `json:"attributes"`
+}
+
+type otlpscope struct {
+	Name string `json:"name"`
+}
+
+type otlpscopespans struct {
+	Scope otlpscope   `json:"scope"`
+	Spans []*otlpspan `json:"spans"`
+}
+
+type otlpresourcespans struct {
+	Resource   otlpresource     `json:"resource"`
+	ScopeSpans []otlpscopespans `json:"scopeSpans"`
+}
+
+type otlpexport struct {
+	ResourceSpans []otlpresourcespans `json:"resourceSpans"`
+}
+
+func otlpstring(key, value string) otlpkv {
+	return otlpkv{Key: key, Value: otlpvalue{StringValue: &value}}
+}
+
+func otlpint(key string, value int64) otlpkv {
+	s := strconv.FormatInt(value, 10)
+	return otlpkv{Key: key, Value: otlpvalue{IntValue: &s}}
+}
+
+//
+// trace context
+//
+
+// newtracectx starts a new trace; the sampling ratio applies to the random trace ID
+func (y *tracerunner) newtracectx() (c tracectx) {
+	_, err := rand.Read(c.traceID[:])
+	assert(err == nil, err)
+	_, err = rand.Read(c.spanID[:])
+	assert(err == nil, err)
+	c.sampled = y.sample >= 1 || float64(binary.BigEndian.Uint64(c.traceID[8:]))/math.Pow(2, 64) < y.sample
+	return
+}
+
+// traceparent: 00-<32 hex trace ID>-<16 hex span ID>-<2 hex flags>
+func (c *tracectx) traceparent() string {
+	var flags byte
+	if c.sampled {
+		flags = traceSampled
+	}
+	return fmt.Sprintf("%s-%x-%x-%02x", traceVersion, c.traceID, c.spanID, flags)
+}
+
+func parsetraceparent(s string) (c tracectx, ok bool) {
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == traceVersion && len(parts) != 4) {
+		return
+	}
+	if len(parts[1]) != 2*len(c.traceID) || len(parts[2]) != 2*len(c.spanID) || len(parts[3]) != 2 {
+		return
+	}
+	if _, err := hex.Decode(c.traceID[:], []byte(parts[1])); err != nil {
+		return
+	}
+	if _, err := hex.Decode(c.spanID[:], []byte(parts[2])); err != nil {
+		return
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return
+	}
+	if c.traceID == [16]byte{} || c.spanID == [8]byte{} {
+		return
+	}
+	c.sampled = flags[0]&traceSampled != 0
+	ok = true
+	return
+}
+
+//
+// spans: all methods can be called on a nil span - tracing disabled or not traced
+//
+
+// newspan starts a span; the parent is either local, or remote (see tracehandler), or none
+func (y *tracerunner) newspan(parent *tracectx, name string, kind int) *span {
+	if y == nil {
+		return nil
+	}
+	sp := &span{y: y, name: name, kind: kind, start: time.Now()}
+	if parent == nil {
+		sp.tracectx = y.newtracectx()
+		return sp
+	}
+	sp.traceID, sp.sampled, sp.parentID = parent.traceID, parent.sampled, parent.spanID
+	_, err := rand.Read(sp.spanID[:])
+	assert(err == nil, err)
+	return sp
+}
+
+func (sp *span) child(name string, kind int) *span {
+	if sp == nil {
+		return nil
+	}
+	return sp.y.newspan(&sp.tracectx, name, kind)
+}
+
+func (sp *span) setstr(key, value string) {
+	if sp != nil && sp.sampled {
+		sp.attrs = append(sp.attrs, otlpstring(key, value))
+	}
+}
+
+func (sp *span) setint(key string, value int64) {
+	if sp != nil && sp.sampled {
+		sp.attrs = append(sp.attrs, otlpint(key, value))
+	}
+}
+
+// object sets the standard bucket and object attributes
+func (sp *span) object(bucket, objname string) {
+	sp.setstr("dfc.bucket", bucket)
+	if objname != "" {
+		sp.setstr("dfc.object", objname)
+	}
+}
+
+func (sp *span) reqid() string {
+	if sp == nil {
+		return ""
+	}
+	return hex.EncodeToString(sp.traceID[:])
+}
+
+// inject propagates the span's context to the request's destination
+func (sp *span) inject(header http.Header) {
+	if sp != nil {
+		header.Set(HeaderTraceParent, sp.traceparent())
+	}
+}
+
+// end finishes the span with an error, if any, and queues it for export (if sampled)
+func (sp *span) end(errstr string) {
+	if sp == nil || !sp.sampled {
+		return
+	}
+	ospan := &otlpspan{
+		TraceID:    hex.EncodeToString(sp.traceID[:]),
+		SpanID:     hex.EncodeToString(sp.spanID[:]),
+		Name:       sp.name,
+		Kind:       sp.kind,
+		Start:      strconv.FormatInt(sp.start.UnixNano(), 10),
+		End:        strconv.FormatInt(time.Now().UnixNano(), 10),
+		Attributes: sp.attrs,
+	}
+	if sp.parentID != [8]byte{} {
+		ospan.ParentSpanID = hex.EncodeToString(sp.parentID[:])
+	}
+	if errstr != "" {
+		ospan.Status = &otlpstatus{Code: spanStatusError, Message: errstr}
+	}
+	select {
+	case sp.y.spanch <- ospan:
+	default:
+		atomic.AddInt64(&sp.y.dropped, 1)
+	}
+}
+
+// spanof returns the span of the request that is being handled, if any
+func spanof(r *http.Request) *span {
+	sp, _ := r.Context().Value(spankey{}).(*span)
+	return sp
+}
+
+//
+// http
+//
+
+// tracewriter captures the response status
+type tracewriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *tracewriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// tracepusher preserves http.Pusher (HTTP/2), see pushhdlr
+type tracepusher struct {
+	*tracewriter
+	http.Pusher
+}
+
+// tracehandler starts the server span of each request and makes it available via spanof;
+// the parent is the redirecting proxy (ParamTraceParent) or the caller (HeaderTraceParent)
+func (h *httprunner) tracehandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var parent *tracectx
+		if c, ok := parsetraceparent(r.URL.Query().Get(ParamTraceParent)); ok {
+			parent = &c
+		} else if c, ok := parsetraceparent(r.Header.Get(HeaderTraceParent)); ok {
+			parent = &c
+		}
+		sp := h.tracer.newspan(parent, r.Method+" "+tracepath(r.URL.Path), spanServer)
+		sp.setstr("http.request.method", r.Method)
+		sp.setstr("url.path", r.URL.Path)
+		sp.setstr("client.address", r.RemoteAddr)
+		w.Header().Set(HeaderDfcRequestID, sp.reqid())
+
+		tw := &tracewriter{ResponseWriter: w, status: http.StatusOK}
+		r = r.WithContext(context.WithValue(r.Context(), spankey{}, sp))
+		if pusher, ok := w.(http.Pusher); ok {
+			next.ServeHTTP(&tracepusher{tw, pusher}, r)
+		} else {
+			next.ServeHTTP(tw, r)
+		}
+		sp.setint("http.response.status_code", int64(tw.status))
+		if tw.status >= http.StatusBadRequest {
+			sp.end(http.StatusText(tw.status))
+		} else {
+			sp.end("")
+		}
+	})
+}
+
+// tracepath names the server spans by the path's first two elements, e.g. /v1/files
+func tracepath(path string) string {
+	items := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 3)
+	if len(items) > 2 {
+		items = items[:2]
+	}
+	return "/" + strings.Join(items, "/")
+}
+
+//
+// Cloud
+//
+
+// tracedcloud wraps the bucket's cloudif with client spans - one per Cloud request
+type tracedcloud struct {
+	cloudif
+	sp       *span
+	provider string
+}
+
+// tracecloud returns the bucket's cloudif that is traced as part of the parent span, if any
+func (t *targetrunner) tracecloud(sp *span, bucket string) cloudif {
+	if sp == nil {
+		return t.getcloudif(bucket)
+	}
+	return &tracedcloud{cloudif: t.getcloudif(bucket), sp: sp, provider: t.lbmap.provider(bucket)}
+}
+
+func (c *tracedcloud) child(op, bucket, objname string) *span {
+	sp := c.sp.child("cloud."+op, spanClient)
+	sp.setstr("dfc.cloud", c.provider)
+	sp.object(bucket, objname)
+	return sp
+}
+
+func (c *tracedcloud) listbucket(bucket string, msg *GetMsg) (jsbytes []byte, errstr string, errcode int) {
+	sp := c.child("listbucket", bucket, "")
+	jsbytes, errstr, errcode = c.cloudif.listbucket(bucket, msg)
+	sp.end(errstr)
+	return
+}
+
+func (c *tracedcloud) headbucket(bucket string) (bucketprops map[string]string, errstr string, errcode int) {
+	sp := c.child("headbucket", bucket, "")
+	bucketprops, errstr, errcode = c.cloudif.headbucket(bucket)
+	sp.end(errstr)
+	return
+}
+
+func (c *tracedcloud) headobject(bucket string, objname string) (objmeta map[string]string, errstr string, errcode int) {
+	sp := c.child("headobject", bucket, objname)
+	objmeta, errstr, errcode = c.cloudif.headobject(bucket, objname)
+	sp.end(errstr)
+	return
+}
+
+func (c *tracedcloud) getobj(fqn, bucket, objname string) (props *objectProps, errstr string, errcode int) {
+	sp := c.child("getobj", bucket, objname)
+	props, errstr, errcode = c.cloudif.getobj(fqn, bucket, objname)
+	if props != nil {
+		sp.setint("dfc.size", props.size)
+	}
+	sp.end(errstr)
+	return
+}
+
+// the span ends when the range is opened
+func (c *tracedcloud) getobjrange(bucket, objname string, offset, length int64) (rc io.ReadCloser, objsize int64, errstr string, errcode int) {
+	sp := c.child("getobjrange", bucket, objname)
+	sp.setint("dfc.offset", offset)
+	sp.setint("dfc.length", length)
+	rc, objsize, errstr, errcode = c.cloudif.getobjrange(bucket, objname, offset, length)
+	sp.end(errstr)
+	return
+}
+
+func (c *tracedcloud) putobj(file *os.File, bucket, objname string, ohobj cksumvalue, usermeta map[string]string) (errstr string, errcode int) {
+	sp := c.child("putobj", bucket, objname)
+	errstr, errcode = c.cloudif.putobj(file, bucket, objname, ohobj, usermeta)
+	sp.end(errstr)
+	return
+}
+
+func (c *tracedcloud) deleteobj(bucket, objname string) (errstr string, errcode int) {
+	sp := c.child("deleteobj", bucket, objname)
+	errstr, errcode = c.cloudif.deleteobj(bucket, objname)
+	sp.end(errstr)
+	return
+}
+
+//
+// tracerunner: batches the finished spans and exports them
+//
+
+func newtracerunner() *tracerunner {
+	y := &tracerunner{
+		spanch: make(chan *otlpspan, traceQueueSize),
+		chstop: make(chan struct{}, 4),
+		sample: ctx.config.Trace.Sample,
+		client: &http.Client{Timeout: ctx.config.HTTP.Timeout},
+	}
+	return y
+}
+
+// setresource identifies the daemon that produces the spans; must be called before serving requests
+func (y *tracerunner) setresource(si *daemonInfo, role string) {
+	y.resource = otlpresource{Attributes: []otlpkv{
+		otlpstring("service.name", "dfc-"+role),
+		otlpstring("service.instance.id", si.DaemonID),
+		otlpstring("host.name", si.NodeIPAddr),
+	}}
+}
+
+func (y *tracerunner) run() error {
+	glog.Infof("Starting %s: collector %q, file %q, sample %v", y.name,
+		ctx.config.Trace.Collector, ctx.config.Trace.File, ctx.config.Trace.Sample)
+	var (
+		batch  = make([]*otlpspan, 0, traceBatchSize)
+		ticker = time.NewTicker(traceFlushTime)
+	)
+	for {
+		select {
+		case ospan := <-y.spanch:
+			if batch = append(batch, ospan); len(batch) >= traceBatchSize {
+				y.export(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				y.export(batch)
+				batch = batch[:0]
+			}
+		case <-y.chstop:
+			ticker.Stop()
+			for len(y.spanch) > 0 {
+				batch = append(batch, <-y.spanch)
+			}
+			if len(batch) > 0 {
+				y.export(batch)
+			}
+			return nil
+		}
+	}
+}
+
+func (y *tracerunner) stop(err error) {
+	glog.Infof("Stopping %s, err: %v", y.name, err)
+	var v struct{}
+	y.chstop <- v
+	close(y.chstop)
+}
+
+func (y *tracerunner) export(batch []*otlpspan) {
+	if dropped := atomic.SwapInt64(&y.dropped, 0); dropped > 0 {
+		glog.Warningf("%s: dropped %d spans (export queue full)", y.name, dropped)
+	}
+	req := otlpexport{ResourceSpans: []otlpresourcespans{{
+		Resource:   y.resource,
+		ScopeSpans: []otlpscopespans{{Scope: otlpscope{Name: "dfc"}, Spans: batch}},
+	}}}
+	jsbytes, err := json.Marshal(&req)
+	assert(err == nil, err)
+	if ctx.config.Trace.File != "" {
+		if err = y.tofile(jsbytes); err != nil {
+			glog.Errorf("%s: failed to write %d spans to %q, err: %v", y.name, len(batch), ctx.config.Trace.File, err)
+		}
+	}
+	if ctx.config.Trace.Collector != "" {
+		if err = y.tocollector(jsbytes); err != nil {
+			glog.Errorf("%s: failed to export %d spans to %q, err: %v", y.name, len(batch), ctx.config.Trace.Collector, err)
+		}
+	}
+}
+
+// tofile appends one export request per line, as per the OTLP JSON file format
+func (y *tracerunner) tofile(jsbytes []byte) error {
+	file, err := os.OpenFile(ctx.config.Trace.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = file.Write(append(jsbytes, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// tocollector POSTs the export request to the OTLP/HTTP endpoint, e.g. http://localhost:4318/v1/traces
+func (y *tracerunner) tocollector(jsbytes []byte) error {
+	response, err := y.client.Post(ctx.config.Trace.Collector, "application/json", bytes.NewReader(jsbytes))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	body, _ := ioutil.ReadAll(response.Body)
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("%s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
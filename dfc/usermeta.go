@@ -0,0 +1,81 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// user-defined object metadata: PUT with HeaderDfcMeta-<key>: <value> headers attaches
+// key/value pairs to the object (keys are case-insensitive and get lowercased). The pairs
+// are stored in the object's xattrs (xattrUserMeta), written through to the Cloud along
+// with the object, returned by GET and HEAD as the same headers, and listed with
+// GetPropsUserMeta. PUT without the headers removes the previous version's metadata
+//
+//======
+
+const (
+	xattrUserMeta   = "user.obj.dfcmeta"
+	maxUserMetaSize = 2048 // JSON-encoded, to fit xattrs
+)
+
+// usermetafromheader collects the user metadata from HTTP headers (nil if none)
+func usermetafromheader(hdr http.Header) (usermeta map[string]string, errstr string) {
+	prefix := strings.ToLower(HeaderDfcMetaPrefix)
+	for k, v := range hdr {
+		if !strings.HasPrefix(strings.ToLower(k), prefix) || len(v) == 0 {
+			continue
+		}
+		key := strings.ToLower(k[len(prefix):])
+		if key == "" {
+			return nil, fmt.Sprintf("Invalid user metadata header %q: empty key", k)
+		}
+		if usermeta == nil {
+			usermeta = make(map[string]string, 4)
+		}
+		usermeta[key] = v[0]
+	}
+	if usermeta == nil {
+		return
+	}
+	if b, _ := json.Marshal(usermeta); len(b) > maxUserMetaSize {
+		errstr = fmt.Sprintf("User metadata is too large: %d bytes (max %d)", len(b), maxUserMetaSize)
+	}
+	return
+}
+
+func setusermetaheader(hdr http.Header, usermeta map[string]string) {
+	for k, v := range usermeta {
+		hdr.Set(HeaderDfcMetaPrefix+k, v)
+	}
+}
+
+func getusermeta(fqn string) (usermeta map[string]string) {
+	b, errstr := Getxattr(fqn, xattrUserMeta)
+	if errstr != "" || len(b) == 0 {
+		return
+	}
+	if err := json.Unmarshal(b, &usermeta); err != nil {
+		glog.Errorf("Invalid user metadata %q of %s, err: %v", string(b), fqn, err)
+	}
+	return
+}
+
+func setusermeta(fqn string, usermeta map[string]string) string {
+	if len(usermeta) == 0 {
+		return ""
+	}
+	b, err := json.Marshal(usermeta)
+	assert(err == nil, err)
+	return Setxattr(fqn, xattrUserMeta, b)
+}
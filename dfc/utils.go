@@ -19,6 +19,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
 	"syscall"
 
 	"github.com/golang/glog"
@@ -239,6 +240,9 @@ func localSave(pathname string, v interface{}) error {
 	}
 	r := bytes.NewReader(b)
 	_, err = io.Copy(file, r)
+	if err == nil {
+		err = file.Sync() // the rename below must not precede the data
+	}
 	errclose := file.Close()
 	if err != nil {
 		_ = os.Remove(tmp)
@@ -246,7 +250,7 @@ func localSave(pathname string, v interface{}) error {
 	}
 	if errclose != nil {
 		_ = os.Remove(tmp)
-		return err
+		return errclose
 	}
 	err = os.Rename(tmp, pathname)
 	return err
@@ -270,6 +274,47 @@ func osRemove(prefix, fqn string) error {
 	return nil
 }
 
+// walkbyname is filepath.Walk that visits the tree in the order of full path names:
+// a/b.txt, a/b/c, and a/c rather than a/b/c, a/b.txt, and a/c
+func walkbyname(root string, walkf filepath.WalkFunc) error {
+	osfi, err := os.Lstat(root)
+	if err != nil {
+		err = walkf(root, nil, err)
+	} else {
+		err = walkdir(root, osfi, walkf)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walkdir(fqn string, osfi os.FileInfo, walkf filepath.WalkFunc) error {
+	if !osfi.IsDir() {
+		return walkf(fqn, osfi, nil)
+	}
+	osfis, err := ioutil.ReadDir(fqn)
+	if err1 := walkf(fqn, osfi, err); err != nil || err1 != nil {
+		return err1
+	}
+	// directory x sorts as x/
+	key := func(osfi os.FileInfo) string {
+		if osfi.IsDir() {
+			return osfi.Name() + "/"
+		}
+		return osfi.Name()
+	}
+	sort.Slice(osfis, func(i, j int) bool { return key(osfis[i]) < key(osfis[j]) })
+	for _, osfi := range osfis {
+		if err = walkdir(filepath.Join(fqn, osfi.Name()), osfi, walkf); err != nil {
+			if !osfi.IsDir() || err != filepath.SkipDir {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // as of 1.9 net/http does not appear to provide any better way..
 func IsErrConnectionRefused(err error) (yes bool) {
 	if uerr, ok := err.(*url.Error); ok {
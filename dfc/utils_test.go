@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWalkByName(t *testing.T) {
+	root, err := ioutil.TempDir("", "walk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	expected := []string{"a-b", "a.txt", "a/b.txt", "a/b/c", "a/c", "b", "c/d/e", "c/d0"}
+	for _, name := range expected {
+		fqn := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(fqn), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(fqn, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	walk := func(limit int, skipdir string) (names []string) {
+		walkf := func(fqn string, osfi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if len(names) >= limit {
+				return filepath.SkipDir
+			}
+			relname := fqn[len(root):]
+			if osfi.IsDir() {
+				if skipdir != "" && relname == skipdir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			names = append(names, relname[1:])
+			return nil
+		}
+		if err := walkbyname(root, walkf); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	if names := walk(len(expected), ""); !reflect.DeepEqual(names, expected) {
+		t.Errorf("walked %v, expecting %v", names, expected)
+	}
+	if names := walk(4, ""); !reflect.DeepEqual(names, expected[:4]) {
+		t.Errorf("walked %v, expecting %v", names, expected[:4])
+	}
+	if names := walk(len(expected), "/a/b"); !reflect.DeepEqual(names, []string{"a-b", "a.txt", "a/b.txt", "a/c", "b", "c/d/e", "c/d0"}) {
+		t.Errorf("walked %v, skipping a/b", names)
+	}
+}
@@ -0,0 +1,483 @@
+// Package dfc provides distributed file-based cache with Amazon and Google Cloud backends.
+/*
+ * Copyright (c) 2017, NVIDIA CORPORATION. All rights reserved.
+ *
+ */
+package dfc
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+//======
+//
+// local bucket versioning: with {"versioning": "true"} (see BucketProps), each PUT assigns
+// the object a new version ID (xattrObjVersion) and moves the previous version to
+// mpath/versions/bucket/objname/<version ID> on the same mountpath (hrwMpath) as the object;
+// DELETE does the same and adds a delete marker: mpath/versions/bucket/objname/<ID>.delmarker.
+// Older versions are read via GET ?version=<ID> and removed via DELETE ?version=<ID>;
+// listing with GetPropsAllVersions returns all versions and delete markers.
+// Older versions follow the object: the replica holders archive and remove them the same way
+// (see delreplica), and rebalance sends them to the object's new targets (see vsrewalkf)
+//
+//======
+
+const (
+	vsDir          = "versions"
+	vsMarkerSuffix = ".delmarker"
+)
+
+// an older version or a delete marker
+type vsentry struct {
+	version string
+	marker  bool
+	fqn     string
+	finfo   os.FileInfo
+}
+
+func (t *targetrunner) versioning(bucket string) bool {
+	return t.islocalBucket(bucket) && t.lbmap.versioning(bucket)
+}
+
+func vsdirfqn(bucket, objname string) string {
+	return filepath.Join(hrwMpath(bucket+"/"+objname), vsDir, bucket, objname)
+}
+
+// version IDs are base-36 Unix nanoseconds - compare with vsidless
+func newvsid(tm time.Time) string {
+	return strconv.FormatInt(tm.UnixNano(), 36)
+}
+
+func vsidless(a, b string) bool {
+	na, _ := strconv.ParseInt(a, 36, 64)
+	nb, _ := strconv.ParseInt(b, 36, 64)
+	return na < nb
+}
+
+// objversion returns the object's version ID; objects PUT before versioning was enabled
+// get their IDs from the modification time
+func objversion(fqn string, finfo os.FileInfo) string {
+	if b, errstr := Getxattr(fqn, xattrObjVersion); errstr == "" && len(b) > 0 {
+		return string(b)
+	}
+	return newvsid(finfo.ModTime())
+}
+
+// vslist returns the object's older versions and delete markers, oldest first
+func vslist(bucket, objname string) ([]*vsentry, error) {
+	dir := vsdirfqn(bucket, objname)
+	finfos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	entries := make([]*vsentry, 0, len(finfos))
+	for _, finfo := range finfos {
+		if finfo.IsDir() { // versions of objname/...
+			continue
+		}
+		name := finfo.Name()
+		entries = append(entries, &vsentry{version: strings.TrimSuffix(name, vsMarkerSuffix),
+			marker: strings.HasSuffix(name, vsMarkerSuffix), fqn: filepath.Join(dir, name), finfo: finfo})
+	}
+	sort.Slice(entries, func(i, j int) bool { return vsidless(entries[i].version, entries[j].version) })
+	return entries, nil
+}
+
+// vsarchive moves the current version (if exists) to the versions dir
+func vsarchive(bucket, objname, fqn string) (errstr string) {
+	finfo, err := os.Stat(fqn)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			errstr = fmt.Sprintf("Failed to fstat %s, err: %v", fqn, err)
+		}
+		return
+	}
+	dir := vsdirfqn(bucket, objname)
+	if err = CreateDir(dir); err != nil {
+		return fmt.Sprintf("Failed to create versions dir %s, err: %v", dir, err)
+	}
+	vfqn := filepath.Join(dir, objversion(fqn, finfo))
+	if err = os.Rename(fqn, vfqn); err != nil {
+		errstr = fmt.Sprintf("Failed to rename %s => %s, err: %v", fqn, vfqn, err)
+	}
+	return
+}
+
+// vsputcommit is putSafeRename for the versioned buckets; the objects sent by the other targets
+// (rebalance and replication) keep their version IDs, and replace the current version of the same ID
+func (t *targetrunner) vsputcommit(bucket, objname, putfqn, fqn string, rebalance bool) (errstr string) {
+	uname := bucket + objname
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, true)
+
+	var version string
+	if rebalance {
+		if b, s := Getxattr(putfqn, xattrObjVersion); s == "" {
+			version = string(b)
+		}
+	}
+	if version == "" {
+		version = newvsid(time.Now())
+		if errstr = Setxattr(putfqn, xattrObjVersion, []byte(version)); errstr != "" {
+			return
+		}
+	}
+	if finfo, err := os.Stat(fqn); err == nil && objversion(fqn, finfo) != version {
+		if errstr = vsarchive(bucket, objname, fqn); errstr != "" {
+			return
+		}
+	}
+	if err := os.Rename(putfqn, fqn); err != nil {
+		errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", putfqn, fqn, err)
+		return
+	}
+	glog.Infof("PUT done: %s <= %s (version %s)", fqn, putfqn, version)
+	return
+}
+
+// vsdelete archives the current version and adds a given delete marker (the caller takes the lock)
+func vsdelete(bucket, objname, fqn, marker string) (errstr string) {
+	if errstr = vsarchive(bucket, objname, fqn); errstr != "" {
+		return
+	}
+	markerfqn := filepath.Join(vsdirfqn(bucket, objname), marker+vsMarkerSuffix)
+	file, err := CreateFile(markerfqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to create delete marker %s, err: %v", markerfqn, err)
+	}
+	if err = file.Close(); err != nil {
+		errstr = fmt.Sprintf("Failed to close delete marker %s, err: %v", markerfqn, err)
+	}
+	return
+}
+
+// vspromote makes the newest older version current unless the object is deleted
+// (the newest is a delete marker) or the current version exists
+func vspromote(bucket, objname, fqn string) (promoted bool, errstr string) {
+	if _, err := os.Stat(fqn); err == nil {
+		return
+	}
+	entries, err := vslist(bucket, objname)
+	if err != nil {
+		return false, fmt.Sprintf("Failed to list versions of %s/%s, err: %v", bucket, objname, err)
+	}
+	if len(entries) == 0 || entries[len(entries)-1].marker {
+		return
+	}
+	newest := entries[len(entries)-1]
+	if err = os.Rename(newest.fqn, fqn); err != nil {
+		return false, fmt.Sprintf("Failed to rename %s => %s, err: %v", newest.fqn, fqn, err)
+	}
+	if errstr = Setxattr(fqn, xattrObjVersion, []byte(newest.version)); errstr == "" {
+		promoted = true
+	}
+	return
+}
+
+//===================
+//
+// GET and DELETE ?version=<ID>
+//
+//===================
+
+// vsget returns an older version; the caller takes the lock and handles the current version
+func (t *targetrunner) vsget(w http.ResponseWriter, r *http.Request, bucket, objname, version string) {
+	entries, err := vslist(bucket, objname)
+	if err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to list versions of %s/%s, err: %v", bucket, objname, err))
+		return
+	}
+	var entry *vsentry
+	for _, e := range entries {
+		if e.version == version {
+			entry = e
+			break
+		}
+	}
+	if entry == nil || entry.marker {
+		errstr := fmt.Sprintf("Version %s of %s/%s does not exist", version, bucket, objname)
+		if entry != nil {
+			errstr = fmt.Sprintf("Version %s of %s/%s is a delete marker", version, bucket, objname)
+		}
+		t.invalmsghdlr(w, r, errstr, http.StatusNotFound)
+		return
+	}
+	file, err := os.Open(entry.fqn)
+	if err != nil {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Failed to open %s, err: %v", entry.fqn, err), http.StatusInternalServerError)
+		return
+	}
+	defer file.Close()
+	if hashbinary, errstr := Getxattr(entry.fqn, xattrXXHashVal); errstr == "" && len(hashbinary) > 0 {
+		w.Header().Set(HeaderETag, strconv.Quote(string(hashbinary)))
+		if r.Header.Get(HeaderRange) == "" {
+			w.Header().Set(HeaderDfcChecksumType, ChecksumXXHash)
+			w.Header().Set(HeaderDfcChecksumVal, string(hashbinary))
+		}
+	}
+	w.Header().Set(HeaderDfcObjVersion, version)
+	setusermetaheader(w.Header(), getusermeta(entry.fqn))
+	http.ServeContent(w, r, objname, entry.finfo.ModTime(), file)
+	t.statsif.add("numget", 1)
+}
+
+// vsdelversion removes a given version, current or older, for good; removing the current version
+// (or the newest delete marker) makes the previous version current
+func (t *targetrunner) vsdelversion(bucket, objname, version string) (errstr string, errcode int) {
+	fqn, uname := t.fqn(bucket, objname), bucket+objname
+	t.rtnamemap.lockname(uname, true, &pendinginfo{Time: time.Now(), fqn: fqn}, time.Second)
+	defer t.rtnamemap.unlockname(uname, true)
+
+	current, promoted, errstr, errcode := vsremove(bucket, objname, fqn, version)
+	if current || errstr == "" {
+		// the replica holders remove (and promote) the same version
+		t.delreplicas(bucket, objname, version)
+	}
+	if current && t.ecenabled(bucket) {
+		t.ecdelslices(bucket, objname)
+	}
+	if errstr != "" {
+		return
+	}
+	t.statsif.add("numdelete", 1)
+	if promoted {
+		glog.Infof("%s/%s: version %s removed, %s is current", bucket, objname, version, fqn)
+		if t.ecenabled(bucket) {
+			go t.ecupdate(bucket, objname)
+		}
+	}
+	return
+}
+
+// vsremove removes a given version and promotes the previous one if need be (the caller takes the lock)
+func vsremove(bucket, objname, fqn, version string) (current, promoted bool, errstr string, errcode int) {
+	if finfo, err := os.Stat(fqn); err == nil && objversion(fqn, finfo) == version {
+		if err = os.Remove(fqn); err != nil {
+			errstr = fmt.Sprintf("Failed to remove %s, err: %v", fqn, err)
+			return
+		}
+		current = true
+	} else {
+		entries, err := vslist(bucket, objname)
+		if err != nil {
+			errstr = fmt.Sprintf("Failed to list versions of %s/%s, err: %v", bucket, objname, err)
+			return
+		}
+		var entry *vsentry
+		for _, e := range entries {
+			if e.version == version {
+				entry = e
+				break
+			}
+		}
+		if entry == nil {
+			errstr, errcode = fmt.Sprintf("Version %s of %s/%s does not exist", version, bucket, objname), http.StatusNotFound
+			return
+		}
+		if err = os.Remove(entry.fqn); err != nil {
+			errstr = fmt.Sprintf("Failed to remove %s, err: %v", entry.fqn, err)
+			return
+		}
+	}
+	promoted, errstr = vspromote(bucket, objname, fqn)
+	return
+}
+
+//===================
+//
+// list all versions
+//
+//===================
+
+// vslistbucket returns the bucket's older versions and delete markers; the newest delete marker
+// of a deleted object is the latest version
+func (t *targetrunner) vslistbucket(bucket string, msg *GetMsg) []*BucketEntry {
+	type vsobj struct {
+		name    string
+		entries []*vsentry
+	}
+	objs := make(map[string]*vsobj, 16)
+	for mpath := range ctx.mountpaths {
+		bucketfqn := filepath.Join(mpath, vsDir, bucket)
+		if _, err := os.Stat(bucketfqn); err != nil {
+			continue
+		}
+		rootLength := len(bucketfqn) + 1
+		err := filepath.Walk(bucketfqn, func(fqn string, osfi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if osfi.IsDir() {
+				return nil
+			}
+			idx := strings.LastIndex(fqn, "/")
+			if idx < rootLength {
+				return nil
+			}
+			objname, name := fqn[rootLength:idx], osfi.Name()
+			if msg.GetPrefix != "" && !strings.HasPrefix(objname, msg.GetPrefix) {
+				return nil
+			}
+			if si, errstr := hrwTarget(bucket+"/"+objname, t.smap); errstr != "" || si.DaemonID != t.si.DaemonID {
+				return nil
+			}
+			obj, ok := objs[objname]
+			if !ok {
+				obj = &vsobj{name: objname}
+				objs[objname] = obj
+			}
+			obj.entries = append(obj.entries, &vsentry{version: strings.TrimSuffix(name, vsMarkerSuffix),
+				marker: strings.HasSuffix(name, vsMarkerSuffix), fqn: fqn, finfo: osfi})
+			return nil
+		})
+		if err != nil {
+			glog.Errorf("Failed to traverse %q, err: %v", bucketfqn, err)
+		}
+	}
+	entries := make([]*BucketEntry, 0, len(objs))
+	for _, obj := range objs {
+		sort.Slice(obj.entries, func(i, j int) bool { return vsidless(obj.entries[i].version, obj.entries[j].version) })
+		_, err := os.Stat(t.fqn(bucket, obj.name))
+		deleted := err != nil && os.IsNotExist(err)
+		for i, e := range obj.entries {
+			atime, _, _ := getAmTimes(e.finfo)
+			entry := t.newLocalEntry(msg, obj.name, e.fqn, e.finfo, atime)
+			entry.Version = e.version
+			entry.DeleteMarker = e.marker
+			entry.IsLatest = deleted && e.marker && i == len(obj.entries)-1
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+//===================
+//
+// rebalance: target-to-target PUT ?from_id=<ID>&to_id=<ID>&version=<version ID>[.delmarker]
+//
+//===================
+
+// vsfqn2obj parses mpath/versions/bucket/objname/<version ID>[.delmarker]
+func vsfqn2obj(fqn string) (bucket, objname, version string, ok bool) {
+	for mpath := range ctx.mountpaths {
+		prefix := filepath.Join(mpath, vsDir) + "/"
+		if !strings.HasPrefix(fqn, prefix) {
+			continue
+		}
+		relname := fqn[len(prefix):]
+		i, j := strings.Index(relname, "/"), strings.LastIndex(relname, "/")
+		if i <= 0 || j <= i+1 || j == len(relname)-1 {
+			return
+		}
+		return relname[:i], relname[i+1 : j], relname[j+1:], true
+	}
+	return
+}
+
+// vsvalid validates the version (or the delete marker) received from another target
+func vsvalid(version string) bool {
+	_, err := strconv.ParseInt(strings.TrimSuffix(version, vsMarkerSuffix), 36, 64)
+	return err == nil
+}
+
+// hasversion checks (via HEAD) whether a given target stores the version of a given size
+func (t *targetrunner) hasversion(si *daemonInfo, bucket, objname, version string, size int64) bool {
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	url += fmt.Sprintf("?%s=true&%s=%s", ParamReplica, ParamVersion, version)
+	response, err := t.httpclient.Head(url)
+	if err != nil {
+		return false
+	}
+	response.Body.Close()
+	return response.StatusCode == http.StatusOK && response.ContentLength == size
+}
+
+// sendversion sends an older version (or a delete marker) to a given target - see vsreceive
+func (t *targetrunner) sendversion(parent *span, bucket, objname, version string, si *daemonInfo) (errstr string) {
+	started := time.Now()
+	sp := parent.child("sendversion", spanClient)
+	sp.object(bucket, objname)
+	sp.setstr("dfc.to_id", si.DaemonID)
+	defer func() { sp.end(errstr) }()
+
+	vfqn := filepath.Join(vsdirfqn(bucket, objname), version)
+	file, err := os.Open(vfqn)
+	if err != nil {
+		return fmt.Sprintf("Failed to open %q, err: %v", vfqn, err)
+	}
+	defer file.Close()
+	finfo, err := file.Stat()
+	if err != nil {
+		return fmt.Sprintf("Failed to fstat %q, err: %v", vfqn, err)
+	}
+	url := si.DirectURL + "/" + Rversion + "/" + Rfiles + "/" + bucket + "/" + objname
+	url += fmt.Sprintf("?%s=%s&%s=%s&%s=%s", ParamFromID, t.si.DaemonID, ParamToID, si.DaemonID, ParamVersion, version)
+	request, err := http.NewRequest(http.MethodPut, url, file)
+	if err != nil {
+		return fmt.Sprintf("Unexpected failure to create PUT request %s, err: %v", url, err)
+	}
+	request.ContentLength = finfo.Size()
+	sp.inject(request.Header)
+	if hval, errstr := Getxattr(vfqn, xattrXXHashVal); errstr == "" && len(hval) > 0 {
+		request.Header.Set(HeaderDfcChecksumType, ChecksumXXHash)
+		request.Header.Set(HeaderDfcChecksumVal, string(hval))
+	}
+	setusermetaheader(request.Header, getusermeta(vfqn))
+	response, err := t.httpclient.Do(request)
+	if err != nil {
+		return fmt.Sprintf("Failed to send %q from %s, err: %v", vfqn, t.si.DaemonID, err)
+	}
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return fmt.Sprintf("Failed to send %q from %s to %s: %s", vfqn, t.si.DaemonID, si.DaemonID, response.Status)
+	}
+	t.statsif.add("numsentfiles", 1)
+	t.statsif.add("numsentbytes", finfo.Size())
+	t.latency.add(latSend, time.Since(started))
+	return
+}
+
+// vsreceive stores an older version (or a delete marker) sent by another target
+func (t *targetrunner) vsreceive(r *http.Request, bucket, objname, version string) (size int64, errstr string) {
+	if !t.islocalBucket(bucket) || !vsvalid(version) {
+		return 0, fmt.Sprintf("Invalid version %q of %s/%s", version, bucket, objname)
+	}
+	var (
+		vfqn   = filepath.Join(vsdirfqn(bucket, objname), version)
+		putfqn = fmt.Sprintf("%s.%d", t.fqn(bucket, objname), time.Now().UnixNano())
+		hdhobj = newcksumvalue(r.Header.Get(HeaderDfcChecksumType), r.Header.Get(HeaderDfcChecksumVal))
+		nhobj  cksumvalue
+	)
+	if _, nhobj, size, errstr = t.receive(putfqn, false, bucket, objname, "", hdhobj, r.Body); errstr != "" {
+		return
+	}
+	if errstr = finalizeobj(putfqn, nhobj); errstr == "" {
+		usermeta, _ := usermetafromheader(r.Header)
+		errstr = setusermeta(putfqn, usermeta)
+	}
+	if errstr == "" {
+		if err := CreateDir(filepath.Dir(vfqn)); err != nil {
+			errstr = fmt.Sprintf("Failed to create versions dir %s, err: %v", filepath.Dir(vfqn), err)
+		} else if err = os.Rename(putfqn, vfqn); err != nil {
+			errstr = fmt.Sprintf("Unexpected failure to rename %s => %s, err: %v", putfqn, vfqn, err)
+		}
+	}
+	if errstr != "" {
+		os.Remove(putfqn)
+	}
+	return
+}
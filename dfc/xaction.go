@@ -9,38 +9,92 @@
 package dfc
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/golang/glog"
 )
 
+// max number of the finished xactions kept for GET {"what": "xactions"}
+const xactHistory = 32
+
+// XactionInfo describes a running or recently finished xaction
+type XactionInfo struct {
+	ID        string    `json:"id"` // cluster-unique: <daemon ID>/<number> - see xactid
+	Kind      string    `json:"kind"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Objects   int64     `json:"objects"` // processed: moved, evicted, prefetched, etc. - depending on the kind
+	Bytes     int64     `json:"bytes"`
+	Status    string    `json:"status"` // XactionInfo.Status enum
+}
+
+// XactionsInfo is the proxy-aggregated GET /v1/cluster?what=xactions
+type XactionsInfo struct {
+	Target map[string][]*XactionInfo `json:"target"`
+}
+
+// XactionInfo.Status enum
+const (
+	XactRunning  = "running"
+	XactFinished = "finished"
+	XactAborted  = "aborted"
+)
+
 type xactInterface interface {
 	getid() int64
 	getkind() string
 	tostring() string
 	abort()
 	finished() bool
+	info() *XactionInfo
 }
 
 type xactInProgress struct {
-	xactinp []xactInterface
-	lock    *sync.Mutex
+	xactinp  []xactInterface
+	done     []xactInterface // history, most recent last
+	ndone    map[xactkey]int64
+	lock     *sync.Mutex
+	daemonid string // qualifies the xaction IDs
+}
+
+// finished xactions are counted by kind and status (XactFinished | XactAborted)
+type xactkey struct {
+	kind   string
+	status string
 }
 
 type xactBase struct {
-	id    int64
-	stime time.Time
-	etime time.Time
-	kind  string
-	abrt  chan struct{}
+	id      int64
+	stime   time.Time
+	etime   time.Time
+	kind    string
+	abrt    chan struct{}
+	objs    int64 // progress, updated atomically
+	bytes   int64
+	aborted int64
 }
 
 type xactRebalance struct {
 	xactBase
 	curversion   int64
 	targetrunner *targetrunner
+	// progress (see RebalanceStats)
+	objstotal, bytestotal     int64
+	objsvisited, bytesvisited int64
+	objsmoved, bytesmoved     int64
+	errors                    int64
+	// throttling: bytes moved since the last rate change
+	pacerate  int64
+	pacestart time.Time
+	pacebytes int64
+	span      *span // tracing: the parent of the objects' sendfile spans
 }
 
 type xactLRU struct {
@@ -48,6 +102,12 @@ type xactLRU struct {
 	targetrunner *targetrunner
 }
 
+type xactECRepair struct {
+	xactBase
+	curversion   int64
+	targetrunner *targetrunner
+}
+
 //====================
 //
 // xactBase
@@ -68,6 +128,7 @@ func (xact *xactBase) getkind() string {
 func (xact *xactBase) tostring() string { assert(false, "must be implemented"); return "" }
 
 func (xact *xactBase) abort() {
+	atomic.StoreInt64(&xact.aborted, 1)
 	xact.etime = time.Now()
 	var e struct{}
 	xact.abrt <- e
@@ -78,19 +139,47 @@ func (xact *xactBase) finished() bool {
 	return !xact.etime.IsZero()
 }
 
+func (xact *xactBase) progress(objs, bytes int64) {
+	atomic.AddInt64(&xact.objs, objs)
+	atomic.AddInt64(&xact.bytes, bytes)
+}
+
+func (xact *xactBase) info() *XactionInfo {
+	info := &XactionInfo{Kind: xact.kind, StartTime: xact.stime, EndTime: xact.etime,
+		Objects: atomic.LoadInt64(&xact.objs), Bytes: atomic.LoadInt64(&xact.bytes), Status: XactRunning}
+	if atomic.LoadInt64(&xact.aborted) != 0 {
+		info.Status = XactAborted
+	} else if xact.finished() {
+		info.Status = XactFinished
+	}
+	return info
+}
+
 //===================
 //
 // xactInProgress
 //
 //===================
 
-func newxactinp() *xactInProgress {
+func newxactinp(daemonid string) *xactInProgress {
 	q := make([]xactInterface, 4)
-	qq := &xactInProgress{xactinp: q[0:0]}
+	qq := &xactInProgress{xactinp: q[0:0], ndone: make(map[xactkey]int64, 8), daemonid: daemonid}
 	qq.lock = &sync.Mutex{}
 	return qq
 }
 
+// xactid qualifies the xaction ID, which is unique per daemon, with the daemon ID
+func (q *xactInProgress) xactid(id int64) string {
+	return q.daemonid + "/" + strconv.FormatInt(id, 10)
+}
+
+// info returns the xaction's info with its cluster-unique ID
+func (q *xactInProgress) info(xact xactInterface) *XactionInfo {
+	info := xact.info()
+	info.ID = q.xactid(xact.getid())
+	return info
+}
+
 func (q *xactInProgress) uniqueid() int64 {
 	id := time.Now().UTC().UnixNano() & 0xffff
 	for i := 0; i < 10; i++ {
@@ -145,6 +234,65 @@ func (q *xactInProgress) del(by interface{}) {
 	}
 	q.xactinp[l-1] = nil
 	q.xactinp = q.xactinp[:l-1]
+	// keep it for the history (notice: xactions set their etime upon completion)
+	if len(q.done) >= xactHistory {
+		copy(q.done, q.done[1:])
+		q.done = q.done[:len(q.done)-1]
+	}
+	q.done = append(q.done, xact)
+	key := xactkey{kind: xact.getkind(), status: XactFinished}
+	if xact.info().Status == XactAborted {
+		key.status = XactAborted
+	}
+	q.ndone[key]++
+}
+
+// counts returns the numbers of running xactions by kind, and of finished ones by kind and status
+func (q *xactInProgress) counts() (running map[string]int64, done map[xactkey]int64) {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	running = make(map[string]int64, len(q.xactinp))
+	for _, xact := range q.xactinp {
+		running[xact.getkind()]++
+	}
+	done = make(map[xactkey]int64, len(q.ndone))
+	for key, n := range q.ndone {
+		done[key] = n
+	}
+	return
+}
+
+// infos returns the running xactions followed by the history
+func (q *xactInProgress) infos() []*XactionInfo {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	infos := make([]*XactionInfo, 0, len(q.xactinp)+len(q.done))
+	for _, xact := range q.xactinp {
+		infos = append(infos, q.info(xact))
+	}
+	for i := len(q.done) - 1; i >= 0; i-- {
+		infos = append(infos, q.info(q.done[i]))
+	}
+	return infos
+}
+
+// abortid aborts the xaction with a given (cluster-unique) ID, if running
+func (q *xactInProgress) abortid(id string) *XactionInfo {
+	if !strings.HasPrefix(id, q.daemonid+"/") {
+		return nil // not ours
+	}
+	n, err := strconv.ParseInt(id[len(q.daemonid)+1:], 10, 64)
+	if err != nil || n <= 0 {
+		return nil
+	}
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, xact := q.find(n)
+	if xact == nil || xact.finished() {
+		return nil
+	}
+	xact.abort()
+	return q.info(xact)
 }
 
 func (q *xactInProgress) renewRebalance(curversion int64, t *targetrunner) *xactRebalance {
@@ -166,6 +314,7 @@ func (q *xactInProgress) renewRebalance(curversion int64, t *targetrunner) *xact
 	xreb := &xactRebalance{xactBase: *newxactBase(id, ActRebalance), curversion: curversion}
 	xreb.targetrunner = t
 	q.add(xreb)
+	t.lastreb = xreb
 	return xreb
 }
 
@@ -185,6 +334,27 @@ func (q *xactInProgress) renewLRU(t *targetrunner) *xactLRU {
 	return xlru
 }
 
+func (q *xactInProgress) renewECRepair(curversion int64, t *targetrunner) *xactECRepair {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	_, xx := q.find(ActECRepair)
+	if xx != nil {
+		xrep := xx.(*xactECRepair)
+		if !xrep.finished() {
+			if xrep.curversion == curversion {
+				glog.Infof("%s already running, nothing to do", xrep.tostring())
+				return nil
+			}
+			xrep.abort()
+		}
+	}
+	id := q.uniqueid()
+	xrep := &xactECRepair{xactBase: *newxactBase(id, ActECRepair), curversion: curversion}
+	xrep.targetrunner = t
+	q.add(xrep)
+	return xrep
+}
+
 func (q *xactInProgress) abortAll() (sleep bool) {
 	q.lock.Lock()
 	defer q.lock.Unlock()
@@ -197,6 +367,25 @@ func (q *xactInProgress) abortAll() (sleep bool) {
 	return
 }
 
+// abortxaction handles {"action": "xactabort"} and responds with the aborted xaction, if any
+func (t *targetrunner) abortxaction(w http.ResponseWriter, r *http.Request, msg *ActionMsg) {
+	id, ok := msg.Value.(string)
+	if !ok {
+		t.invalmsghdlr(w, r, fmt.Sprintf("Invalid %s value %v (expecting xaction ID)", msg.Action, msg.Value))
+		return
+	}
+	infos := make([]*XactionInfo, 0, 1)
+	if msg.Name == "" || msg.Name == t.si.DaemonID {
+		if info := t.xactinp.abortid(id); info != nil {
+			glog.Infof("Aborted xaction %s:%s", info.Kind, info.ID)
+			infos = append(infos, info)
+		}
+	}
+	jsbytes, err := json.Marshal(infos)
+	assert(err == nil, err)
+	t.writeJSON(w, r, jsbytes, "abortxaction")
+}
+
 //===================
 //
 // xactLRU
@@ -229,3 +418,23 @@ func (xact *xactRebalance) abort() {
 	xact.xactBase.abort()
 	glog.Infof("ABORT: " + xact.tostring())
 }
+
+func (xact *xactRebalance) info() *XactionInfo {
+	info := xact.xactBase.info()
+	info.Objects, info.Bytes = atomic.LoadInt64(&xact.objsmoved), atomic.LoadInt64(&xact.bytesmoved)
+	return info
+}
+
+//===================
+//
+// xactECRepair
+//
+//===================
+func (xact *xactECRepair) tostring() string {
+	start := xact.stime.Sub(xact.targetrunner.starttime)
+	if !xact.finished() {
+		return fmt.Sprintf("xaction %s:%d v%d started %v", xact.kind, xact.id, xact.curversion, start)
+	}
+	fin := time.Since(xact.targetrunner.starttime)
+	return fmt.Sprintf("xaction %s:%d v%d started %v finished %v", xact.kind, xact.id, xact.curversion, start, fin)
+}
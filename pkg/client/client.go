@@ -8,6 +8,8 @@ package client
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -16,8 +18,10 @@ import (
 	"math/rand"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/NVIDIA/dfcpub/dfc"
@@ -34,9 +38,11 @@ var (
 
 	client = &http.Client{
 		Timeout:   600 * time.Second,
-		Transport: transport,
+		Transport: AuthTransport(transport),
 	}
 
+	authtoken atomic.Value // see SetAuthToken
+
 	ProxyProto      = "http"
 	ProxyIP         = "localhost"
 	ProxyPort       = 8080
@@ -129,7 +135,7 @@ func Get(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan
 		defer wg.Done()
 	}
 	url := proxyurl + "/v1/files/" + bucket + "/" + keyname
-	r, err := http.Get(url)
+	r, err := client.Get(url)
 	defer func() {
 		if r != nil {
 			r.Body.Close()
@@ -162,6 +168,70 @@ func Get(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan
 	return len, err
 }
 
+// GetRange reads length bytes (length <= 0: till the end) of the object starting at offset
+// and writes them to w; with rangeonly set, a cold GET fetches only the range from the Cloud
+func GetRange(proxyurl, bucket, keyname string, offset, length int64, rangeonly bool, w io.Writer) (int64, error) {
+	url := proxyurl + "/v1/files/" + bucket + "/" + keyname
+	if rangeonly {
+		url += "?" + dfc.ParamRangeOnly + "=true"
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	rng := fmt.Sprintf("bytes=%d-", offset)
+	if length > 0 {
+		rng += strconv.FormatInt(offset+length-1, 10)
+	}
+	req.Header.Set(dfc.HeaderRange, rng)
+
+	r, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetRange"); err != nil {
+		return 0, err
+	}
+	if r.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("GET %s/%s (%s): unexpected HTTP status %d", bucket, keyname, rng, r.StatusCode)
+	}
+	return io.Copy(w, r.Body)
+}
+
+// GetVersion reads the given version of the local bucket object (see versioning) and writes it to w
+func GetVersion(proxyurl, bucket, keyname, version string, w io.Writer) (int64, error) {
+	r, err := client.Get(proxyurl + "/v1/files/" + bucket + "/" + keyname + "?" + dfc.ParamVersion + "=" + version)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetVersion"); err != nil {
+		return 0, err
+	}
+	return io.Copy(w, r.Body)
+}
+
+// DelVersion removes the given version (or delete marker) of the local bucket object for good
+func DelVersion(proxyurl, bucket, keyname, version string) error {
+	req, err := http.NewRequest(http.MethodDelete, proxyurl+"/v1/files/"+bucket+"/"+keyname+"?"+dfc.ParamVersion+"="+version, nil)
+	if err != nil {
+		return err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	return checkHTTPStatus(r, "DelVersion")
+}
+
 func Del(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan error, silent bool) (err error) {
 	if wg != nil {
 		defer wg.Done()
@@ -195,8 +265,16 @@ func Del(proxyurl, bucket string, keyname string, wg *sync.WaitGroup, errch chan
 }
 
 func ListBucket(proxyurl, bucket string, injson []byte) (*dfc.BucketList, error) {
+	return listbucket(proxyurl+"/v1/files/"+bucket, bucket, injson)
+}
+
+// ListCachedObjects returns a page of the Cloud bucket's objects that are cached in DFC
+func ListCachedObjects(proxyurl, bucket string, injson []byte) (*dfc.BucketList, error) {
+	return listbucket(proxyurl+"/v1/files/"+bucket+"?"+dfc.ParamCached+"=true", bucket, injson)
+}
+
+func listbucket(url, bucket string, injson []byte) (*dfc.BucketList, error) {
 	var (
-		url     = proxyurl + "/v1/files/" + bucket
 		err     error
 		request *http.Request
 		r       *http.Response
@@ -269,13 +347,17 @@ func Evict(proxyurl, bucket string, fname string) error {
 }
 
 func doListRangeCall(proxyurl, bucket, action, method string, listrangemsg interface{}, wait bool) error {
+	actionMsg := dfc.ActionMsg{Action: action, Value: listrangemsg}
+	return doListRangeAction(proxyurl, bucket, method, actionMsg, wait)
+}
+
+func doListRangeAction(proxyurl, bucket, method string, actionMsg dfc.ActionMsg, wait bool) error {
 	var (
 		req    *http.Request
 		r      *http.Response
 		injson []byte
 		err    error
 	)
-	actionMsg := dfc.ActionMsg{Action: action, Value: listrangemsg}
 	injson, err = json.Marshal(actionMsg)
 	if err != nil {
 		return fmt.Errorf("Failed to marhsal ActionMsg: %v", err)
@@ -332,6 +414,135 @@ func EvictRange(proxyurl, bucket, prefix, regex, rng string, wait bool, deadline
 	return doListRangeCall(proxyurl, bucket, dfc.ActEvict, http.MethodDelete, evictMsg, wait)
 }
 
+// PinList makes the listed objects non-evictable; zero expiry - indefinitely
+func PinList(proxyurl, bucket string, fileslist []string, wait bool, deadline, expiry time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait}
+	pinMsg := dfc.ListMsg{Objnames: fileslist, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeAction(proxyurl, bucket, http.MethodPost, pinAction(dfc.ActPin, pinMsg, expiry), wait)
+}
+
+func PinRange(proxyurl, bucket, prefix, regex, rng string, wait bool, deadline, expiry time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait}
+	pinMsg := dfc.RangeMsg{Prefix: prefix, Regex: regex, Range: rng, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeAction(proxyurl, bucket, http.MethodPost, pinAction(dfc.ActPin, pinMsg, expiry), wait)
+}
+
+func UnpinList(proxyurl, bucket string, fileslist []string, wait bool, deadline time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait}
+	unpinMsg := dfc.ListMsg{Objnames: fileslist, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeCall(proxyurl, bucket, dfc.ActUnpin, http.MethodPost, unpinMsg, wait)
+}
+
+func UnpinRange(proxyurl, bucket, prefix, regex, rng string, wait bool, deadline time.Duration) error {
+	rangeListMsgBase := dfc.RangeListMsgBase{Deadline: deadline, Wait: wait}
+	unpinMsg := dfc.RangeMsg{Prefix: prefix, Regex: regex, Range: rng, RangeListMsgBase: rangeListMsgBase}
+	return doListRangeCall(proxyurl, bucket, dfc.ActUnpin, http.MethodPost, unpinMsg, wait)
+}
+
+func pinAction(action string, listrangemsg interface{}, expiry time.Duration) dfc.ActionMsg {
+	actionMsg := dfc.ActionMsg{Action: action, Value: listrangemsg}
+	if expiry != 0 {
+		actionMsg.Name = expiry.String()
+	}
+	return actionMsg
+}
+
+// CopyList copies the listed objects to another bucket (local to local, Cloud to local, or local to Cloud)
+// and returns the job ID to track the progress with GetJobStatus
+func CopyList(proxyurl, frombucket, tobucket string, fileslist []string, deadline time.Duration) (string, error) {
+	copyMsg := dfc.ListMsg{Objnames: fileslist, RangeListMsgBase: dfc.RangeListMsgBase{Deadline: deadline}}
+	return StartListRangeJob(proxyurl, frombucket, dfc.ActionMsg{Action: dfc.ActCopy, Name: tobucket, Value: copyMsg})
+}
+
+func CopyRange(proxyurl, frombucket, tobucket, prefix, regex, rng string, deadline time.Duration) (string, error) {
+	copyMsg := dfc.RangeMsg{Prefix: prefix, Regex: regex, Range: rng, RangeListMsgBase: dfc.RangeListMsgBase{Deadline: deadline}}
+	return StartListRangeJob(proxyurl, frombucket, dfc.ActionMsg{Action: dfc.ActCopy, Name: tobucket, Value: copyMsg})
+}
+
+// MoveList is CopyList that deletes the source objects once copied
+func MoveList(proxyurl, frombucket, tobucket string, fileslist []string, deadline time.Duration) (string, error) {
+	moveMsg := dfc.ListMsg{Objnames: fileslist, RangeListMsgBase: dfc.RangeListMsgBase{Deadline: deadline}}
+	return StartListRangeJob(proxyurl, frombucket, dfc.ActionMsg{Action: dfc.ActMove, Name: tobucket, Value: moveMsg})
+}
+
+func MoveRange(proxyurl, frombucket, tobucket, prefix, regex, rng string, deadline time.Duration) (string, error) {
+	moveMsg := dfc.RangeMsg{Prefix: prefix, Regex: regex, Range: rng, RangeListMsgBase: dfc.RangeListMsgBase{Deadline: deadline}}
+	return StartListRangeJob(proxyurl, frombucket, dfc.ActionMsg{Action: dfc.ActMove, Name: tobucket, Value: moveMsg})
+}
+
+// StartListRangeJob starts the list/range operation (e.g. {"action": "prefetch", "value": dfc.RangeMsg{...}})
+// and returns its job ID to be used with GetJobStatus and CancelJob
+func StartListRangeJob(proxyurl, bucket string, actionMsg dfc.ActionMsg) (string, error) {
+	method := http.MethodPost
+	if actionMsg.Action == dfc.ActDelete || actionMsg.Action == dfc.ActEvict {
+		method = http.MethodDelete
+	}
+	injson, err := json.Marshal(actionMsg)
+	if err != nil {
+		return "", fmt.Errorf("Failed to marhsal ActionMsg: %v", err)
+	}
+	req, err := http.NewRequest(method, proxyurl+"/v1/files/"+bucket+"/", bytes.NewBuffer(injson))
+	if err != nil {
+		return "", fmt.Errorf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	b, err := doJobReq(req, "StartListRangeJob")
+	if err != nil {
+		return "", err
+	}
+	jobmsg := &dfc.JobMsg{}
+	if err = json.Unmarshal(b, jobmsg); err != nil {
+		return "", fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return jobmsg.JobID, nil
+}
+
+// GetJobStatus returns the cluster-wide progress of the list/range job, including per-target failures
+func GetJobStatus(proxyurl, jobID string) (*dfc.JobInfo, error) {
+	req, err := http.NewRequest(http.MethodGet, proxyurl+"/v1/jobs/"+jobID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return jobInfo(req, "GetJobStatus")
+}
+
+// CancelJob cancels the list/range job on all targets and returns its status as of the cancellation
+func CancelJob(proxyurl, jobID string) (*dfc.JobInfo, error) {
+	req, err := http.NewRequest(http.MethodDelete, proxyurl+"/v1/jobs/"+jobID, nil)
+	if err != nil {
+		return nil, err
+	}
+	return jobInfo(req, "CancelJob")
+}
+
+func jobInfo(req *http.Request, op string) (*dfc.JobInfo, error) {
+	b, err := doJobReq(req, op)
+	if err != nil {
+		return nil, err
+	}
+	info := &dfc.JobInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return info, nil
+}
+
+func doJobReq(req *http.Request, op string) ([]byte, error) {
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response, err: %v", err)
+	}
+	if r.StatusCode >= http.StatusBadRequest {
+		return nil, reqError{code: r.StatusCode, message: fmt.Sprintf("%s: %s", op, string(b))}
+	}
+	return b, nil
+}
+
 // fastRandomFilename is taken from https://stackoverflow.com/questions/22892120/how-to-generate-a-random-string-of-a-fixed-length-in-golang
 const (
 	letterBytes   = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
@@ -377,6 +588,298 @@ func HeadBucket(proxyurl, bucket string) (server string, err error) {
 	return
 }
 
+// ObjectMeta is returned by HeadObject
+type ObjectMeta struct {
+	Size     int64
+	Checksum string // xxhash, if available
+	Version  string
+	UserMeta map[string]string // user-defined metadata, lowercase keys
+}
+
+// HeadObject returns the object's properties and user-defined metadata
+func HeadObject(proxyurl, bucket, key string) (*ObjectMeta, error) {
+	r, err := client.Head(proxyurl + "/v1/files/" + bucket + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "HeadObject"); err != nil {
+		return nil, err
+	}
+	meta := &ObjectMeta{Size: r.ContentLength, Checksum: r.Header.Get(dfc.HeaderDfcChecksumVal),
+		Version: r.Header.Get(dfc.HeaderDfcObjVersion)}
+	prefix := strings.ToLower(dfc.HeaderDfcMetaPrefix)
+	for k, v := range r.Header {
+		if strings.HasPrefix(strings.ToLower(k), prefix) && len(v) > 0 {
+			if meta.UserMeta == nil {
+				meta.UserMeta = make(map[string]string, 4)
+			}
+			meta.UserMeta[strings.ToLower(k[len(prefix):])] = v[0]
+		}
+	}
+	return meta, nil
+}
+
+// HeadBucketProps returns the bucket's effective checksum, versioning, LRU, and ack properties
+func HeadBucketProps(proxyurl, bucket string) (*dfc.BucketProps, error) {
+	r, err := client.Head(proxyurl + "/v1/files/" + bucket)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if r.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("Head bucket %s failed, HTTP status %d", bucket, r.StatusCode)
+	}
+	bprops := &dfc.BucketProps{}
+	if err = json.Unmarshal([]byte(r.Header.Get(dfc.HeaderDfcBucketProps)), bprops); err != nil {
+		return nil, fmt.Errorf("Failed to parse %s of bucket %s, err: %v", dfc.HeaderDfcBucketProps, bucket, err)
+	}
+	return bprops, nil
+}
+
+// GetClusterMap retrieves the cluster map from a given proxy (primary or standby)
+func GetClusterMap(proxyURL string) (*dfc.Smap, error) {
+	msg, err := json.Marshal(dfc.GetMsg{GetWhat: dfc.GetWhatSmap})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", proxyURL+"/v1/cluster", bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetClusterMap"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read json, err: %v", err)
+	}
+	smap := &dfc.Smap{}
+	if err = json.Unmarshal(b, smap); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return smap, nil
+}
+
+// GetMetasyncInfo returns the Smap and lbmap versions acknowledged by each target and standby proxy
+func GetMetasyncInfo(proxyURL string) (*dfc.MetasyncInfo, error) {
+	msg, err := json.Marshal(dfc.GetMsg{GetWhat: dfc.GetWhatMetasync})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("GET", proxyURL+"/v1/cluster", bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetMetasyncInfo"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read json, err: %v", err)
+	}
+	info := &dfc.MetasyncInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return info, nil
+}
+
+// GetRebalanceInfo returns the per-target and overall rebalance progress
+func GetRebalanceInfo(proxyURL string) (*dfc.RebalanceInfo, error) {
+	r, err := client.Get(proxyURL + "/v1/cluster?" + dfc.ParamWhat + "=" + dfc.GetWhatRebalance)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetRebalanceInfo"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read json, err: %v", err)
+	}
+	info := &dfc.RebalanceInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return info, nil
+}
+
+// StartRebalance starts the cluster-wide rebalance
+func StartRebalance(proxyURL string) error {
+	return doClusterAction(proxyURL, dfc.ActionMsg{Action: dfc.ActRebalance}, "StartRebalance")
+}
+
+// AbortRebalance aborts the rebalance in progress on all targets
+func AbortRebalance(proxyURL string) error {
+	return doClusterAction(proxyURL, dfc.ActionMsg{Action: dfc.ActRebAbort}, "AbortRebalance")
+}
+
+// ThrottleRebalance limits the rebalance bandwidth of each target, MB/s (0 - unlimited)
+func ThrottleRebalance(proxyURL string, mbps float64) error {
+	return doClusterAction(proxyURL, dfc.ActionMsg{Action: dfc.ActRebThrottle, Value: mbps}, "ThrottleRebalance")
+}
+
+// RunLRU starts the LRU (eviction) on all targets, regardless of the capacity watermarks
+func RunLRU(proxyURL string) error {
+	return doClusterAction(proxyURL, dfc.ActionMsg{Action: dfc.ActLRU}, "RunLRU")
+}
+
+// GetXactions returns the running and recently finished xactions of each target
+func GetXactions(proxyURL string) (*dfc.XactionsInfo, error) {
+	r, err := client.Get(proxyURL + "/v1/cluster?" + dfc.ParamWhat + "=" + dfc.GetWhatXactions)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	if err = checkHTTPStatus(r, "GetXactions"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read json, err: %v", err)
+	}
+	info := &dfc.XactionsInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return info, nil
+}
+
+// AbortXaction aborts the xaction with a given ID on all targets or, if daemonID is not empty, on that target only
+func AbortXaction(proxyURL, id, daemonID string) error {
+	return doClusterAction(proxyURL, dfc.ActionMsg{Action: dfc.ActXactAbort, Name: daemonID, Value: id}, "AbortXaction")
+}
+
+func doClusterAction(proxyURL string, actmsg dfc.ActionMsg, op string) error {
+	msg, err := json.Marshal(actmsg)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest("PUT", proxyURL+"/v1/cluster", bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+	return doActionReq(req, op)
+}
+
+// GetPrimaryProxy returns the URL of the current primary proxy as per the first given proxy that responds;
+// clients that know more than one proxy use it to re-point to the new primary after a failover
+func GetPrimaryProxy(proxyURLs ...string) (string, error) {
+	err := fmt.Errorf("No proxy URLs")
+	for _, url := range proxyURLs {
+		var smap *dfc.Smap
+		if smap, err = GetClusterMap(url); err != nil {
+			continue
+		}
+		if smap.ProxySI == nil {
+			err = fmt.Errorf("Proxy %s does not know the primary yet", url)
+			continue
+		}
+		return smap.ProxySI.DirectURL, nil
+	}
+	return "", err
+}
+
+// SetAuthToken sets the token that the requests carry: "Authorization: Bearer <token>";
+// empty token - unauthenticated requests
+func SetAuthToken(token string) {
+	authtoken.Store(token)
+}
+
+type authTransport struct {
+	base http.RoundTripper
+}
+
+// AuthTransport returns the RoundTripper that adds the token (see SetAuthToken) to the requests
+// that do not carry their own Authorization header
+func AuthTransport(base http.RoundTripper) http.RoundTripper {
+	return &authTransport{base: base}
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, _ := authtoken.Load().(string)
+	if token == "" || req.Header.Get(dfc.HeaderAuthorization) != "" {
+		return t.base.RoundTrip(req)
+	}
+	// must not modify the caller's request
+	req2 := new(http.Request)
+	*req2 = *req
+	req2.Header = make(http.Header, len(req.Header)+1)
+	for k, v := range req.Header {
+		req2.Header[k] = v
+	}
+	req2.Header.Set(dfc.HeaderAuthorization, "Bearer "+token)
+	return t.base.RoundTrip(req2)
+}
+
+// TLSConfig returns the TLS configuration that verifies the cluster's certificates
+// with the given CA (PEM-encoded certificates)
+func TLSConfig(cafile string) (*tls.Config, error) {
+	b, err := ioutil.ReadFile(cafile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, fmt.Errorf("No PEM-encoded certificates in %s", cafile)
+	}
+	return &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}, nil
+}
+
+// SetTLSConfig sets the TLS configuration of the requests to an HTTPS cluster (see TLSConfig)
+func SetTLSConfig(conf *tls.Config) {
+	transport.TLSClientConfig = conf
+}
+
+// GetAuthToken requests a token from the proxy's built-in auth service
+func GetAuthToken(proxyURL, user, password string) (*dfc.TokenInfo, error) {
+	msg, err := json.Marshal(dfc.TokenMsg{User: user, Password: password})
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Post(proxyURL+"/v1/"+dfc.Rtokens, "application/json", bytes.NewBuffer(msg))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	if err = checkHTTPStatus(r, "GetAuthToken"); err != nil {
+		return nil, err
+	}
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read json, err: %v", err)
+	}
+	info := &dfc.TokenInfo{}
+	if err = json.Unmarshal(b, info); err != nil {
+		return nil, fmt.Errorf("Failed to json-unmarshal, err: %v [%s]", err, string(b))
+	}
+	return info, nil
+}
+
 func checkHTTPStatus(resp *http.Response, op string) error {
 	if resp.StatusCode >= http.StatusBadRequest {
 		return reqError{
@@ -395,6 +898,11 @@ func discardHTTPResp(resp *http.Response) {
 
 // Put sends a PUT request to the given URL
 func Put(proxyURL string, reader Reader, bucket string, key string, silent bool) error {
+	return PutWithMeta(proxyURL, reader, bucket, key, nil, silent)
+}
+
+// PutWithMeta PUTs the object along with user-defined metadata (keys are case-insensitive)
+func PutWithMeta(proxyURL string, reader Reader, bucket, key string, usermeta map[string]string, silent bool) error {
 	url := proxyURL + "/v1/files/" + bucket + "/" + key
 
 	if !silent {
@@ -423,6 +931,10 @@ func Put(proxyURL string, reader Reader, bucket string, key string, silent bool)
 		req.Header.Set(dfc.HeaderDfcChecksumVal, reader.XXHash())
 	}
 
+	for k, v := range usermeta {
+		req.Header.Set(dfc.HeaderDfcMetaPrefix+k, v)
+	}
+
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
@@ -434,6 +946,103 @@ func Put(proxyURL string, reader Reader, bucket string, key string, silent bool)
 	return err
 }
 
+// MPInit initiates multipart upload of the object and returns the upload ID
+func MPInit(proxyURL, bucket, key string) (string, error) {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActMPInit})
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Post(proxyURL+"/v1/files/"+bucket+"/"+key, "application/json", bytes.NewBuffer(msg))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if err = checkHTTPStatus(resp, "MPInit"); err != nil {
+		return "", err
+	}
+	mpmsg := &dfc.MPUploadMsg{}
+	if err = json.NewDecoder(resp.Body).Decode(mpmsg); err != nil {
+		return "", fmt.Errorf("Failed to decode multipart upload response, err: %v", err)
+	}
+	return mpmsg.UploadID, nil
+}
+
+// MPPutPart uploads one part (partnum >= 1) of the multipart upload; re-uploading a part replaces it
+func MPPutPart(proxyURL string, reader Reader, bucket, key, uploadid string, partnum int) error {
+	url := fmt.Sprintf("%s/v1/files/%s/%s?%s=%s&%s=%d", proxyURL, bucket, key,
+		dfc.ParamUploadID, uploadid, dfc.ParamPartNum, partnum)
+	handle, err := reader.Open()
+	if err != nil {
+		return fmt.Errorf("Failed to open reader, err: %v", err)
+	}
+	defer handle.Close()
+
+	req, err := http.NewRequest(http.MethodPut, url, handle)
+	if err != nil {
+		return fmt.Errorf("Failed to create new http request, err: %v", err)
+	}
+	req.GetBody = func() (io.ReadCloser, error) {
+		return reader.Open()
+	}
+	if reader.XXHash() != "" {
+		req.Header.Set(dfc.HeaderDfcChecksumType, dfc.ChecksumXXHash)
+		req.Header.Set(dfc.HeaderDfcChecksumVal, reader.XXHash())
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = checkHTTPStatus(resp, "MPPutPart")
+	discardHTTPResp(resp)
+	return err
+}
+
+// MPComplete assembles the object out of the uploaded parts (in the order of part numbers);
+// xxhash, if not empty, is validated against the checksum of the resulting object
+func MPComplete(proxyURL, bucket, key, uploadid, xxhash string) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActMPComplete, Name: uploadid})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, proxyURL+"/v1/files/"+bucket+"/"+key, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+	if xxhash != "" {
+		req.Header.Set(dfc.HeaderDfcChecksumType, dfc.ChecksumXXHash)
+		req.Header.Set(dfc.HeaderDfcChecksumVal, xxhash)
+	}
+	return doActionReq(req, "MPComplete")
+}
+
+// MPAbort aborts the multipart upload and removes all its parts
+func MPAbort(proxyURL, bucket, key, uploadid string) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActMPAbort, Name: uploadid})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodDelete, proxyURL+"/v1/files/"+bucket+"/"+key, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+	return doActionReq(req, "MPAbort")
+}
+
+func doActionReq(req *http.Request, op string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	err = checkHTTPStatus(resp, op)
+	discardHTTPResp(resp)
+	return err
+}
+
 // PutAsync sends a PUT request to the given URL
 func PutAsync(wg *sync.WaitGroup, proxyURL string, reader Reader, bucket string, key string, errch chan error, silent bool) {
 	defer wg.Done()
@@ -469,6 +1078,95 @@ func CreateLocalBucket(proxyURL, bucket string) error {
 	return err
 }
 
+// SetCloudProvider assigns cloud provider (e.g. "aws", "gcp", "posix", "s3compat") to a Cloud bucket;
+// empty provider reverts the bucket to the cluster-wide default
+func SetCloudProvider(proxyURL, bucket, provider string) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActSetCloud, Value: provider})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", proxyURL+"/v1/files/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	return checkHTTPStatus(r, "SetCloudProvider")
+}
+
+// SetCopies sets the number of object replicas for a bucket (1 - no replication)
+func SetCopies(proxyURL, bucket string, copies int) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActSetCopies, Value: copies})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", proxyURL+"/v1/files/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	return checkHTTPStatus(r, "SetCopies")
+}
+
+// SetEC sets the number of erasure coding data and parity slices for a local bucket (zeros - no erasure coding)
+func SetEC(proxyURL, bucket string, data, parity int) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActSetEC, Value: dfc.ECMsg{Data: data, Parity: parity}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", proxyURL+"/v1/files/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	return checkHTTPStatus(r, "SetEC")
+}
+
+// SetBucketProps sets the bucket's properties; empty properties inherit the cluster configuration
+func SetBucketProps(proxyURL, bucket string, props dfc.BucketProps) error {
+	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActSetProps, Value: props})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", proxyURL+"/v1/files/"+bucket, bytes.NewBuffer(msg))
+	if err != nil {
+		return err
+	}
+
+	r, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		r.Body.Close()
+	}()
+	return checkHTTPStatus(r, "SetBucketProps")
+}
+
 // DestroyLocalBucket deletes a local bucket
 func DestroyLocalBucket(proxyURL, bucket string) error {
 	msg, err := json.Marshal(dfc.ActionMsg{Action: dfc.ActDestroyLB})
@@ -2,6 +2,7 @@ package client_test
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
 	"encoding/hex"
 	"flag"
@@ -40,6 +41,38 @@ func TestPutSG(t *testing.T) {
 	}
 }
 
+func TestGetRange(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	rangesrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(content))
+	}))
+	defer rangesrv.Close()
+
+	tcs := []struct {
+		offset, length int64
+		expected       string
+	}{
+		{0, 10, "0123456789"},
+		{10, 6, "abcdef"},
+		{30, 0, "uvwxyz"},
+		{30, 100, "uvwxyz"},
+	}
+	for _, tc := range tcs {
+		buf := &bytes.Buffer{}
+		n, err := client.GetRange(rangesrv.URL, "bucket", "key", tc.offset, tc.length, false, buf)
+		if err != nil {
+			t.Fatalf("GetRange(%d, %d) failed, err: %v", tc.offset, tc.length, err)
+		}
+		if n != int64(len(tc.expected)) || buf.String() != tc.expected {
+			t.Errorf("GetRange(%d, %d): expected %q, got %q (%d)", tc.offset, tc.length, tc.expected, buf.String(), n)
+		}
+	}
+
+	if _, err := client.GetRange(rangesrv.URL, "bucket", "key", 100, 1, false, ioutil.Discard); err == nil {
+		t.Errorf("GetRange beyond the end of the object was expected to fail")
+	}
+}
+
 func putFile(size int64, withHash bool) error {
 	fn := "dfc-client-test-" + client.FastRandomFilename(rand.New(rand.NewSource(time.Now().UnixNano())), 32)
 	dir := "/tmp"